@@ -15,9 +15,47 @@ func NewRouter() *mux.Router {
 
 	// Public auth route
 	router.HandleFunc("/auth/login", handlers.LoginHandler).Methods("POST")
+	router.HandleFunc("/auth/register-patient", handlers.RegisterPatientHandler).Methods("POST")
+
+	// Payment gateway webhooks, authenticated by their signature
+	router.HandleFunc("/webhooks/payments", handlers.PaymentWebhookHandler).Methods("POST")
+
+	// FHIR R4 read API for partner systems
+	router.HandleFunc("/fhir/R4/metadata", handlers.FHIRCapabilityHandler).Methods("GET")
+	fhirAPI := router.PathPrefix("/fhir/R4").Subrouter()
+	fhirAPI.Use(middleware.AuthMiddleware)
+	fhirAPI.Use(middleware.DenyRole("patient"))
+	fhirAPI.HandleFunc("/$export", handlers.FHIRExportHandler).Methods("GET")
+	fhirAPI.HandleFunc("/Patient/$export", handlers.FHIRPatientExportHandler).Methods("GET")
+	fhirAPI.HandleFunc("/$export-status/{id}", handlers.FHIRExportStatusHandler).Methods("GET")
+	fhirAPI.HandleFunc("/$export-status/{id}", handlers.FHIRExportCancelHandler).Methods("DELETE")
+	fhirAPI.HandleFunc("/$export-file/{id}/{file}", handlers.FHIRExportFileHandler).Methods("GET")
+	fhirAPI.HandleFunc("/{type}", handlers.FHIRSearchHandler).Methods("GET")
+	fhirAPI.HandleFunc("/{type}/{id}", handlers.FHIRReadHandler).Methods("GET")
+
+	// Patient portal, registered before /api so patients only ever reach their own data
+	me := router.PathPrefix("/api/me").Subrouter()
+	me.Use(middleware.AuthMiddleware)
+	me.Use(middleware.RequireRole("patient"))
+	me.HandleFunc("", handlers.GetMyProfileHandler).Methods("GET")
+	me.HandleFunc("/booking-policy", handlers.GetBookingPolicyHandler).Methods("GET")
+	me.HandleFunc("/doctors", handlers.GetPortalDoctorsHandler).Methods("GET")
+	me.HandleFunc("/appointments", handlers.GetMyAppointmentsHandler).Methods("GET")
+	me.HandleFunc("/appointments", handlers.BookMyAppointmentHandler).Methods("POST")
+	me.HandleFunc("/appointments/{id}/cancel", handlers.CancelMyAppointmentHandler).Methods("POST")
+	me.HandleFunc("/invoices", handlers.GetMyInvoicesHandler).Methods("GET")
+	me.HandleFunc("/invoices/{id}", handlers.GetMyInvoiceHandler).Methods("GET")
+	me.HandleFunc("/invoices/{id}/pdf", handlers.GetMyInvoicePDFHandler).Methods("GET")
+	me.HandleFunc("/invoices/{id}/payment-intents", handlers.CreateMyPaymentIntentHandler).Methods("POST")
+	me.HandleFunc("/payments", handlers.GetMyPaymentsHandler).Methods("GET")
+	me.HandleFunc("/payments/{id}/receipt.pdf", handlers.GetMyPaymentReceiptPDFHandler).Methods("GET")
+	me.HandleFunc("/files", handlers.GetMyFilesHandler).Methods("GET")
+	me.HandleFunc("/files/{id}/download", handlers.DownloadMyFileHandler).Methods("GET")
+	me.HandleFunc("/records", handlers.GetMyRecordsHandler).Methods("GET")
 
 	api := router.PathPrefix("/api").Subrouter()
 	api.Use(middleware.AuthMiddleware)
+	api.Use(middleware.DenyRole("patient"))
 
 	api.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -29,11 +67,31 @@ func NewRouter() *mux.Router {
 	admin.Use(middleware.RequireRole("admin"))
 	admin.HandleFunc("/users", handlers.GetAllUsersHandler).Methods("GET")
 	admin.HandleFunc("/users/{id}", handlers.DeleteUserHandler).Methods("DELETE")
+	admin.HandleFunc("/immunization-schedule", handlers.CreateVaccinationScheduleEntryHandler).Methods("POST")
+	admin.HandleFunc("/immunization-schedule/{id}", handlers.UpdateVaccinationScheduleEntryHandler).Methods("PUT")
+	admin.HandleFunc("/immunization-schedule/{id}", handlers.DeleteVaccinationScheduleEntryHandler).Methods("DELETE")
+	admin.HandleFunc("/form-templates", handlers.CreateFormTemplateHandler).Methods("POST")
+	admin.HandleFunc("/form-templates/{id}", handlers.UpdateFormTemplateHandler).Methods("PUT")
+	admin.HandleFunc("/services", handlers.CreateServiceItemHandler).Methods("POST")
+	admin.HandleFunc("/services/{id}", handlers.UpdateServiceItemHandler).Methods("PUT")
+	admin.HandleFunc("/payers", handlers.CreatePayerHandler).Methods("POST")
+	admin.HandleFunc("/payers/{id}", handlers.UpdatePayerHandler).Methods("PUT")
+	admin.HandleFunc("/payers/{id}/coverage-rules", handlers.CreateCoverageRuleHandler).Methods("POST")
+	admin.HandleFunc("/coverage-rules/{id}", handlers.DeleteCoverageRuleHandler).Methods("DELETE")
+	admin.HandleFunc("/dunning/run", handlers.RunDunningHandler).Methods("POST")
+	admin.HandleFunc("/payment-intents/{id}/simulate", handlers.SimulatePaymentEventHandler).Methods("POST")
+	admin.HandleFunc("/hl7/errors", handlers.GetHL7ErrorQueueHandler).Methods("GET")
+	admin.HandleFunc("/hl7/errors/{id}", handlers.GetHL7ErrorMessageHandler).Methods("GET")
+	admin.HandleFunc("/hl7/errors/{id}/retry", handlers.RetryHL7ErrorMessageHandler).Methods("POST")
+	admin.HandleFunc("/hl7/errors/{id}/resolve", handlers.ResolveHL7ErrorMessageHandler).Methods("PATCH")
 
 	//  Only Doctor can create medical records
 	doctor := api.PathPrefix("/doctor").Subrouter()
 	doctor.Use(middleware.RequireRole("doctor"))
 	doctor.HandleFunc("/records", handlers.CreateMedicalRecordHandler).Methods("POST")
+	doctor.HandleFunc("/discharge-summaries/{id}/sign", handlers.SignDischargeSummaryHandler).Methods("POST")
+	doctor.HandleFunc("/records/{id}/release", handlers.ReleaseMedicalRecordHandler).Methods("POST")
+	doctor.HandleFunc("/files/{id}/release", handlers.ReleaseFileHandler).Methods("POST")
 
 	// user routes
 	api.HandleFunc("/users", handlers.GetAllUsersHandler).Methods("GET")
@@ -49,6 +107,26 @@ func NewRouter() *mux.Router {
 	api.HandleFunc("/patients", handlers.CreatePatientHandler).Methods("POST")
 	api.HandleFunc("/patients/{id}", handlers.UpdatePatientHandler).Methods("PUT")
 	api.HandleFunc("/patients/{id}", handlers.DeletePatientHandler).Methods("DELETE")
+
+	api.HandleFunc("/patients/{id}/summary", handlers.GetPatientSummaryHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/merge", handlers.MergePatientHandler).Methods("POST")
+
+	// problem list routes
+	api.HandleFunc("/patients/{id}/problems", handlers.GetPatientProblemsHandler).Methods("GET")
+	api.HandleFunc("/problems", handlers.CreateProblemHandler).Methods("POST")
+	api.HandleFunc("/problems/{id}", handlers.GetProblemByIDHandler).Methods("GET")
+	api.HandleFunc("/problems/{id}", handlers.UpdateProblemHandler).Methods("PUT")
+	api.HandleFunc("/problems/{id}", handlers.DeleteProblemHandler).Methods("DELETE")
+	api.HandleFunc("/problems/{id}/resolve", handlers.ResolveProblemHandler).Methods("PATCH")
+
+	// immunization routes
+	api.HandleFunc("/patients/{id}/immunizations", handlers.GetPatientImmunizationsHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/immunizations/due", handlers.GetDueImmunizationsHandler).Methods("GET")
+	api.HandleFunc("/immunizations", handlers.CreateImmunizationHandler).Methods("POST")
+	api.HandleFunc("/immunizations/{id}", handlers.GetImmunizationByIDHandler).Methods("GET")
+	api.HandleFunc("/immunizations/{id}", handlers.UpdateImmunizationHandler).Methods("PUT")
+	api.HandleFunc("/immunizations/{id}", handlers.DeleteImmunizationHandler).Methods("DELETE")
+	api.HandleFunc("/immunization-schedule", handlers.GetVaccinationScheduleHandler).Methods("GET")
 	
 
 	// doctor routes
@@ -102,24 +180,186 @@ func NewRouter() *mux.Router {
 	api.HandleFunc("/invoices/{id}", handlers.GetInvoiceByIDHandler).Methods("GET")
 	api.HandleFunc("/invoices", handlers.CreateInvoiceHandler).Methods("POST")
 	api.HandleFunc("/invoices/{id}", handlers.UpdateInvoiceHandler).Methods("PUT")
-	api.HandleFunc("/invoices/{id}", handlers.DeleteInvoiceHandler).Methods("DELETE")
+	api.HandleFunc("/invoices/{id}", handlers.VoidInvoiceHandler).Methods("DELETE")
+	api.HandleFunc("/invoices/{id}/void", handlers.VoidInvoiceHandler).Methods("POST")
+	api.HandleFunc("/invoices/{id}/issue", handlers.IssueInvoiceHandler).Methods("POST")
+	api.HandleFunc("/invoices/{id}/pdf", handlers.GetInvoicePDFHandler).Methods("GET")
 	api.HandleFunc("/invoices/{id}/paid", handlers.MarkInvoicePaidHandler).Methods("PATCH")
+	api.HandleFunc("/invoices/{id}/lines", handlers.GetInvoiceLinesHandler).Methods("GET")
+	api.HandleFunc("/invoices/{id}/lines", handlers.AddInvoiceLineHandler).Methods("POST")
+	api.HandleFunc("/invoices/{id}/lines/{line_id}", handlers.DeleteInvoiceLineHandler).Methods("DELETE")
+	api.HandleFunc("/invoices/{id}/apply-credit", handlers.ApplyCreditHandler).Methods("POST")
+	api.HandleFunc("/patients/{id}/credits", handlers.GetPatientCreditsHandler).Methods("GET")
+	api.HandleFunc("/invoices/{id}/credit-notes", handlers.GetInvoiceCreditNotesHandler).Methods("GET")
+	api.HandleFunc("/invoices/{id}/credit-notes", handlers.CreateCreditNoteHandler).Methods("POST")
+	api.HandleFunc("/credit-notes/{id}", handlers.GetCreditNoteHandler).Methods("GET")
+	api.HandleFunc("/credit-notes/{id}/void", handlers.VoidCreditNoteHandler).Methods("POST")
+	api.HandleFunc("/services", handlers.GetServiceItemsHandler).Methods("GET")
+	api.HandleFunc("/services/{id}", handlers.GetServiceItemHandler).Methods("GET")
+	api.HandleFunc("/invoices/{id}/bill-to", handlers.GetInvoiceBillToHandler).Methods("GET")
 	api.HandleFunc("/invoices/{id}", handlers.FilterInvoiceHandler).Methods("GET")
 
 	// invoice filtering routes
 	api.HandleFunc("/invoices/patient/{patient_id}", handlers.GetInvoicesByPatientHandler).Methods("GET")
 	api.HandleFunc("/invoices/status/{status}", handlers.GetInvoicesByStatusHandler).Methods("GET")
+	api.HandleFunc("/invoices/number/{number}", handlers.GetInvoiceByNumberHandler).Methods("GET")
 
 	// Payment routes
 	api.HandleFunc("/payments", handlers.GetAllPaymentsHandler).Methods("GET")
 	api.HandleFunc("/payments/{id}", handlers.GetPaymentByIDHandler).Methods("GET")
 	api.HandleFunc("/payments", handlers.CreatePaymentHandler).Methods("POST")
-	api.HandleFunc("/payments/{id}", handlers.DeletePaymentHandler).Methods("DELETE")
+	api.HandleFunc("/payments/{id}", handlers.VoidPaymentHandler).Methods("DELETE")
+	api.HandleFunc("/payments/{id}/void", handlers.VoidPaymentHandler).Methods("POST")
+	api.HandleFunc("/payments/{id}/receipt.pdf", handlers.GetPaymentReceiptPDFHandler).Methods("GET")
+	api.HandleFunc("/payments/{id}/refunds", handlers.GetPaymentRefundsHandler).Methods("GET")
+	api.HandleFunc("/payments/{id}/refunds", handlers.CreateRefundHandler).Methods("POST")
+	api.HandleFunc("/refunds/{id}/void", handlers.VoidRefundHandler).Methods("POST")
+	api.HandleFunc("/invoices/{id}/payment-intents", handlers.GetInvoicePaymentIntentsHandler).Methods("GET")
+	api.HandleFunc("/invoices/{id}/payment-intents", handlers.CreatePaymentIntentHandler).Methods("POST")
+	api.HandleFunc("/payment-intents/{id}", handlers.GetPaymentIntentHandler).Methods("GET")
+	api.HandleFunc("/payment-intents/{id}/capture", handlers.CapturePaymentIntentHandler).Methods("POST")
 	api.HandleFunc("/payments/{id}", handlers.UpdatePaymentHandler).Methods("PUT")
 
 	// Payment filtering
 	api.HandleFunc("/payments/invoice/{invoice_id}", handlers.GetPaymentsByInvoiceIDHandler).Methods("GET")
 
+	// insurance and claims routes
+	api.HandleFunc("/payers", handlers.GetPayersHandler).Methods("GET")
+	api.HandleFunc("/payers/{id}", handlers.GetPayerHandler).Methods("GET")
+	api.HandleFunc("/payers/{id}/coverage-rules", handlers.GetCoverageRulesHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/insurance-policies", handlers.GetPatientInsurancePoliciesHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/insurance-policies", handlers.CreateInsurancePolicyHandler).Methods("POST")
+	api.HandleFunc("/insurance-policies/{id}", handlers.UpdateInsurancePolicyHandler).Methods("PUT")
+	api.HandleFunc("/invoices/{id}/claims", handlers.GetInvoiceClaimsHandler).Methods("GET")
+	api.HandleFunc("/invoices/{id}/claims", handlers.CreateClaimHandler).Methods("POST")
+	api.HandleFunc("/claims", handlers.GetClaimsHandler).Methods("GET")
+	api.HandleFunc("/claims/export.csv", handlers.ExportClaimsHandler).Methods("GET")
+	api.HandleFunc("/claims/{id}", handlers.GetClaimHandler).Methods("GET")
+	api.HandleFunc("/claims/{id}/submit", handlers.SubmitClaimHandler).Methods("POST")
+	api.HandleFunc("/claims/{id}/adjudicate", handlers.AdjudicateClaimHandler).Methods("POST")
+	api.HandleFunc("/claims/{id}/remittances", handlers.ApplyRemittanceHandler).Methods("POST")
+
+	// dunning communication log routes
+	api.HandleFunc("/invoices/{id}/communications", handlers.GetInvoiceCommunicationsHandler).Methods("GET")
+	api.HandleFunc("/invoices/{id}/communications", handlers.CreateInvoiceCommunicationHandler).Methods("POST")
+	api.HandleFunc("/invoice-communications", handlers.GetCommunicationsHandler).Methods("GET")
+	api.HandleFunc("/invoice-communications/{id}/letter.pdf", handlers.GetDunningLetterPDFHandler).Methods("GET")
+	api.HandleFunc("/invoice-communications/{id}/sent", handlers.MarkLetterSentHandler).Methods("POST")
+
+	// financial report routes
+	api.HandleFunc("/reports/ar-aging", handlers.GetARAgingReportHandler).Methods("GET")
+	api.HandleFunc("/reports/revenue", handlers.GetRevenueReportHandler).Methods("GET")
+	api.HandleFunc("/reports/cash-up", handlers.GetCashUpReportHandler).Methods("GET")
+
+	// ward and bed routes
+	api.HandleFunc("/wards", handlers.GetAllWardsHandler).Methods("GET")
+	api.HandleFunc("/wards", handlers.CreateWardHandler).Methods("POST")
+	api.HandleFunc("/wards/{id}/rooms", handlers.CreateRoomHandler).Methods("POST")
+	api.HandleFunc("/rooms/{id}/beds", handlers.CreateBedHandler).Methods("POST")
+	api.HandleFunc("/beds/board", handlers.GetBedBoardHandler).Methods("GET")
+	api.HandleFunc("/beds", handlers.GetBedsHandler).Methods("GET")
+	api.HandleFunc("/beds/{id}/status", handlers.UpdateBedStatusHandler).Methods("PATCH")
+
+	// admission routes
+	api.HandleFunc("/admissions", handlers.GetAdmissionsHandler).Methods("GET")
+	api.HandleFunc("/admissions", handlers.AdmitPatientHandler).Methods("POST")
+	api.HandleFunc("/admissions/{id}", handlers.GetAdmissionByIDHandler).Methods("GET")
+	api.HandleFunc("/admissions/{id}/transfer", handlers.TransferPatientHandler).Methods("POST")
+	api.HandleFunc("/admissions/{id}/discharge", handlers.DischargePatientHandler).Methods("POST")
+	api.HandleFunc("/admissions/{id}/discharge-summary", handlers.GetDischargeSummaryByAdmissionHandler).Methods("GET")
+	api.HandleFunc("/admissions/{id}/discharge-summary", handlers.GenerateDischargeSummaryHandler).Methods("POST")
+	api.HandleFunc("/discharge-summaries/{id}/pdf", handlers.GetDischargeSummaryPDFHandler).Methods("GET")
+
+	// vital signs
+	api.HandleFunc("/vitals", handlers.CreateVitalSignHandler).Methods("POST")
+	api.HandleFunc("/patients/{id}/vitals", handlers.GetPatientVitalSignsHandler).Methods("GET")
+
+	// emergency department routes
+	api.HandleFunc("/ed/board", handlers.GetEDBoardHandler).Methods("GET")
+	api.HandleFunc("/ed/metrics", handlers.GetEDMetricsHandler).Methods("GET")
+	api.HandleFunc("/ed/encounters", handlers.RegisterEDArrivalHandler).Methods("POST")
+	api.HandleFunc("/ed/encounters/{id}", handlers.GetEDEncounterHandler).Methods("GET")
+	api.HandleFunc("/ed/encounters/{id}/triage", handlers.TriageEDEncounterHandler).Methods("POST")
+	api.HandleFunc("/ed/encounters/{id}/assign", handlers.AssignEDDoctorHandler).Methods("POST")
+	api.HandleFunc("/ed/encounters/{id}/depart", handlers.DepartEDEncounterHandler).Methods("POST")
+
+	// referral routes
+	api.HandleFunc("/referrals", handlers.CreateReferralHandler).Methods("POST")
+	api.HandleFunc("/referrals/{id}", handlers.GetReferralHandler).Methods("GET")
+	api.HandleFunc("/referrals/{id}/accept", handlers.AcceptReferralHandler).Methods("POST")
+	api.HandleFunc("/referrals/{id}/decline", handlers.DeclineReferralHandler).Methods("POST")
+	api.HandleFunc("/referrals/{id}/book", handlers.BookReferralHandler).Methods("POST")
+	api.HandleFunc("/referrals/{id}/complete", handlers.CompleteReferralHandler).Methods("POST")
+	api.HandleFunc("/referrals/{id}/cancel", handlers.CancelReferralHandler).Methods("POST")
+	api.HandleFunc("/doctors/{id}/referrals/inbox", handlers.GetReferralInboxHandler).Methods("GET")
+	api.HandleFunc("/doctors/{id}/referrals/sent", handlers.GetSentReferralsHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/referrals", handlers.GetPatientReferralsHandler).Methods("GET")
+	api.HandleFunc("/external-providers", handlers.GetExternalProvidersHandler).Methods("GET")
+	api.HandleFunc("/external-providers", handlers.CreateExternalProviderHandler).Methods("POST")
+
+	// consent routes
+	api.HandleFunc("/patients/{id}/consents", handlers.GetPatientConsentsHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/consents", handlers.CreateConsentHandler).Methods("POST")
+	api.HandleFunc("/consents/{id}", handlers.GetConsentHandler).Methods("GET")
+	api.HandleFunc("/consents/{id}/revoke", handlers.RevokeConsentHandler).Methods("POST")
+
+	// related person and family routes
+	api.HandleFunc("/patients/{id}/related-persons", handlers.GetPatientRelatedPersonsHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/related-persons", handlers.CreateRelatedPersonHandler).Methods("POST")
+	api.HandleFunc("/related-persons/{id}", handlers.GetRelatedPersonHandler).Methods("GET")
+	api.HandleFunc("/related-persons/{id}", handlers.UpdateRelatedPersonHandler).Methods("PUT")
+	api.HandleFunc("/related-persons/{id}", handlers.DeleteRelatedPersonHandler).Methods("DELETE")
+	api.HandleFunc("/patients/{id}/family", handlers.GetFamilyMembersHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/family", handlers.LinkPatientsHandler).Methods("POST")
+	api.HandleFunc("/patient-relationships/{id}", handlers.UnlinkPatientsHandler).Methods("DELETE")
+
+	// electronic signature routes
+	api.HandleFunc("/form-templates", handlers.GetFormTemplatesHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/documents", handlers.GetPatientDocumentsHandler).Methods("GET")
+	api.HandleFunc("/patients/{id}/documents", handlers.GenerateDocumentHandler).Methods("POST")
+	api.HandleFunc("/documents/{id}", handlers.GetDocumentHandler).Methods("GET")
+	api.HandleFunc("/documents/{id}/sign", handlers.SignDocumentHandler).Methods("POST")
+	api.HandleFunc("/documents/{id}/pdf", handlers.GetDocumentPDFHandler).Methods("GET")
+	api.HandleFunc("/documents/{id}/verify", handlers.VerifyDocumentHandler).Methods("GET")
+
+	// pharmacy routes
+	api.HandleFunc("/pharmacy/stock", handlers.GetDrugStockHandler).Methods("GET")
+	api.HandleFunc("/pharmacy/stock", handlers.ReceiveDrugStockHandler).Methods("POST")
+	api.HandleFunc("/pharmacy/stock/{id}", handlers.GetDrugStockItemHandler).Methods("GET")
+	api.HandleFunc("/pharmacy/stock/{id}/adjust", handlers.AdjustDrugStockHandler).Methods("POST")
+	api.HandleFunc("/pharmacy/stock/{id}/movements", handlers.GetStockMovementsHandler).Methods("GET")
+	api.HandleFunc("/pharmacy/dispensings", handlers.GetDispensingsHandler).Methods("GET")
+	api.HandleFunc("/pharmacy/dispensings", handlers.DispenseDrugsHandler).Methods("POST")
+	api.HandleFunc("/pharmacy/alerts", handlers.GetPharmacyAlertsHandler).Methods("GET")
+
+	// inventory routes
+	api.HandleFunc("/inventory/items", handlers.GetInventoryItemsHandler).Methods("GET")
+	api.HandleFunc("/inventory/items", handlers.CreateInventoryItemHandler).Methods("POST")
+	api.HandleFunc("/inventory/items/{id}", handlers.GetInventoryItemHandler).Methods("GET")
+	api.HandleFunc("/inventory/items/{id}", handlers.UpdateInventoryItemHandler).Methods("PUT")
+	api.HandleFunc("/inventory/locations", handlers.GetInventoryLocationsHandler).Methods("GET")
+	api.HandleFunc("/inventory/locations", handlers.CreateInventoryLocationHandler).Methods("POST")
+	api.HandleFunc("/inventory/suppliers", handlers.GetSuppliersHandler).Methods("GET")
+	api.HandleFunc("/inventory/suppliers", handlers.CreateSupplierHandler).Methods("POST")
+	api.HandleFunc("/inventory/suppliers/{id}", handlers.UpdateSupplierHandler).Methods("PUT")
+	api.HandleFunc("/inventory/stock", handlers.GetStockLevelsHandler).Methods("GET")
+	api.HandleFunc("/inventory/movements", handlers.GetInventoryMovementsHandler).Methods("GET")
+	api.HandleFunc("/inventory/movements", handlers.CreateInventoryMovementHandler).Methods("POST")
+	api.HandleFunc("/inventory/purchase-orders", handlers.GetPurchaseOrdersHandler).Methods("GET")
+	api.HandleFunc("/inventory/purchase-orders", handlers.CreatePurchaseOrderHandler).Methods("POST")
+	api.HandleFunc("/inventory/purchase-orders/{id}", handlers.GetPurchaseOrderHandler).Methods("GET")
+	api.HandleFunc("/inventory/purchase-orders/{id}/submit", handlers.SubmitPurchaseOrderHandler).Methods("POST")
+	api.HandleFunc("/inventory/purchase-orders/{id}/cancel", handlers.CancelPurchaseOrderHandler).Methods("POST")
+	api.HandleFunc("/inventory/purchase-orders/{id}/receive", handlers.ReceivePurchaseOrderHandler).Methods("POST")
+	api.HandleFunc("/inventory/reorder-suggestions", handlers.GetReorderSuggestionsHandler).Methods("GET")
+	api.HandleFunc("/inventory/reorder-suggestions/{id}", handlers.UpdateReorderSuggestionHandler).Methods("PATCH")
+
+	// HL7 v2 ingestion (HTTP transport, MLLP listener is started in main)
+	api.HandleFunc("/hl7/messages", handlers.ReceiveHL7MessageHandler).Methods("POST")
+
+	// lab results
+	api.HandleFunc("/lab-results/patient/{patient_id}", handlers.GetLabResultsByPatientHandler).Methods("GET")
+
 	// Health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("API is up and running"))
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,7 +13,10 @@ import (
 	"github.com/rs/cors"
 	"github.com/samichen99/HAP-hospital-management-system/api"
 	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/handlers"
+	"github.com/samichen99/HAP-hospital-management-system/jobs"
 	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
 	"github.com/samichen99/HAP-hospital-management-system/utils"
 )
 
@@ -25,13 +29,22 @@ func main() {
 		"appointments.created",
 		"appointments.updated",
 		"appointments.canceled",
+		jobs.ImmunizationOverdueTopic,
+		jobs.PharmacyAlertsTopic,
 	}
+	topics = append(topics, handlers.AdmissionTopics...)
+	topics = append(topics, handlers.ReferralTopics...)
 	utils.InitKafkaWriters(topics)
 
 	// Init both DBs
 	config.InitDB()
 	//*db := config.GormDB
 
+	// Amounts move from float to integer cents before AutoMigrate sees the new column types
+	if err := repositories.MigrateMoneyColumns(); err != nil {
+		log.Fatalf("Money column migration failed: %v", err)
+	}
+
 	// Run GORM migrations
 	err := config.GormDB.AutoMigrate(
 		&models.User{},
@@ -42,12 +55,84 @@ func main() {
 		&models.File{},
 		&models.Invoice{},
 		&models.Payment{},
+		&models.PatientCredit{},
+		&models.Refund{},
+		&models.CreditNote{},
+		&models.NumberSequence{},
+		&models.MigrationMarker{},
+		&models.InvoiceCommunication{},
+		&models.PaymentIntent{},
+		&models.GatewayEvent{},
+		&models.Payer{},
+		&models.InsurancePolicy{},
+		&models.CoverageRule{},
+		&models.Claim{},
+		&models.ClaimLine{},
+		&models.LabResult{},
+		&models.HL7ErrorMessage{},
+		&models.ExportJob{},
+		&models.ExportFile{},
+		&models.Immunization{},
+		&models.VaccinationScheduleEntry{},
+		&models.ImmunizationReminder{},
+		&models.Problem{},
+		&models.Ward{},
+		&models.Room{},
+		&models.Bed{},
+		&models.Admission{},
+		&models.BedMovement{},
+		&models.VitalSign{},
+		&models.DischargeSummary{},
+		&models.InvoiceLine{},
+		&models.ServiceItem{},
+		&models.DrugStockItem{},
+		&models.Dispensing{},
+		&models.PharmacyStockMovement{},
+		&models.InventoryItem{},
+		&models.InventoryLocation{},
+		&models.StockLevel{},
+		&models.InventoryMovement{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.ReorderSuggestion{},
+		&models.ExternalProvider{},
+		&models.Referral{},
+		&models.ReferralAttachment{},
+		&models.EDEncounter{},
+		&models.Consent{},
+		&models.FormTemplate{},
+		&models.SignedDocument{},
+		&models.RelatedPerson{},
+		&models.PatientRelationship{},
 	)
 	if err != nil {
 		log.Fatalf("Auto migration failed: %v", err)
 	}
 	log.Println("Database connected (SQL + GORM) and migrations applied successfully.")
 
+	if err := repositories.MigrateSingleAmountInvoices(); err != nil {
+		log.Fatalf("Invoice line migration failed: %v", err)
+	}
+	if err := repositories.MigrateInvoiceBalances(); err != nil {
+		log.Fatalf("Invoice balance migration failed: %v", err)
+	}
+	if err := repositories.MigrateInvoiceNumbers(); err != nil {
+		log.Fatalf("Invoice number migration failed: %v", err)
+	}
+
+	if err := repositories.SeedVaccinationSchedule(utils.DefaultVaccinationSchedule); err != nil {
+		log.Printf("Seeding vaccination schedule failed: %v", err)
+	}
+
+	// Online payments are disabled until a gateway and its webhook secret are configured
+	if gateway, err := utils.NewPaymentGateway(); err != nil {
+		log.Printf("Online payments disabled: %v", err)
+	} else {
+		handlers.SetPaymentGateway(gateway)
+		log.Printf("Online payments through the %s gateway", gateway.Name())
+	}
+
 	// Init Router
 	router := api.NewRouter()
 
@@ -66,6 +151,27 @@ func main() {
 	// Start Kafka consumers
 	utils.StartAppointmentConsumers(topics, "appointment-consumer-group")
 
+	// Start background jobs
+	stopImmunizationReminders := jobs.Every("immunization-reminders", 24*time.Hour, jobs.SendImmunizationReminders)
+	stopPharmacyAlerts := jobs.Every("pharmacy-alerts", 24*time.Hour, jobs.SendPharmacyAlerts)
+	stopReorderSuggestions := jobs.Daily("inventory-reorder", 2, jobs.ComputeReorderSuggestions)
+	stopDunning := jobs.Daily("invoice-dunning", 1, jobs.RunDunning)
+
+	// Start HL7 MLLP listener; it is off unless an address and the allowed senders are set
+	var mllp net.Listener
+	mllpAddr := os.Getenv("HL7_MLLP_ADDR")
+	mllpAllowed := utils.ParseIPNets(os.Getenv("HL7_MLLP_ALLOWED_IPS"))
+	switch {
+	case mllpAddr == "":
+		log.Println("HL7 MLLP listener disabled: HL7_MLLP_ADDR is not set")
+	case len(mllpAllowed) == 0:
+		log.Println("HL7 MLLP listener disabled: HL7_MLLP_ALLOWED_IPS is not set")
+	default:
+		if mllp, err = handlers.StartMLLPListener(mllpAddr, mllpAllowed); err != nil {
+			log.Printf("HL7 MLLP listener failed: %v", err)
+		}
+	}
+
 	// Start HTTP server in goroutine
 	go func() {
 		log.Println("HTTP server starting on :8080")
@@ -80,6 +186,13 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
+	stopImmunizationReminders()
+	stopPharmacyAlerts()
+	stopReorderSuggestions()
+	stopDunning()
+	if mllp != nil {
+		_ = mllp.Close()
+	}
 	utils.CloseKafkaWriters()
 	config.CloseDb()
 
@@ -0,0 +1,100 @@
+package fhir
+
+import "time"
+
+type CapabilitySearchParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type CapabilityInteraction struct {
+	Code string `json:"code"`
+}
+
+type CapabilityResource struct {
+	Type        string                  `json:"type"`
+	Interaction []CapabilityInteraction `json:"interaction"`
+	SearchParam []CapabilitySearchParam `json:"searchParam,omitempty"`
+}
+
+type CapabilityRest struct {
+	Mode     string               `json:"mode"`
+	Resource []CapabilityResource `json:"resource"`
+}
+
+type CapabilitySoftware struct {
+	Name string `json:"name"`
+}
+
+type CapabilityStatement struct {
+	ResourceType string             `json:"resourceType"`
+	Status       string             `json:"status"`
+	Date         string             `json:"date"`
+	Kind         string             `json:"kind"`
+	Software     CapabilitySoftware `json:"software"`
+	FhirVersion  string             `json:"fhirVersion"`
+	Format       []string           `json:"format"`
+	Rest         []CapabilityRest   `json:"rest"`
+}
+
+// SearchParams lists the supported search parameters per resource type
+var SearchParams = map[string][]CapabilitySearchParam{
+	"Patient": {
+		{Name: "_id", Type: "token"}, {Name: "identifier", Type: "token"}, {Name: "name", Type: "string"},
+		{Name: "birthdate", Type: "date"}, {Name: "gender", Type: "token"},
+	},
+	"Practitioner": {
+		{Name: "_id", Type: "token"}, {Name: "name", Type: "string"}, {Name: "active", Type: "token"},
+	},
+	"Appointment": {
+		{Name: "_id", Type: "token"}, {Name: "patient", Type: "reference"}, {Name: "practitioner", Type: "reference"},
+		{Name: "status", Type: "token"}, {Name: "date", Type: "date"},
+	},
+	"Encounter": {
+		{Name: "_id", Type: "token"}, {Name: "patient", Type: "reference"}, {Name: "practitioner", Type: "reference"},
+		{Name: "date", Type: "date"},
+	},
+	"Condition": {
+		{Name: "_id", Type: "token"}, {Name: "patient", Type: "reference"}, {Name: "recorder", Type: "reference"},
+	},
+	"MedicationRequest": {
+		{Name: "_id", Type: "token"}, {Name: "patient", Type: "reference"}, {Name: "requester", Type: "reference"},
+	},
+	"DocumentReference": {
+		{Name: "_id", Type: "token"}, {Name: "patient", Type: "reference"},
+	},
+	"Observation": {
+		{Name: "_id", Type: "token"}, {Name: "patient", Type: "reference"}, {Name: "code", Type: "token"},
+		{Name: "date", Type: "date"},
+	},
+}
+
+// ResourceOrder keeps the CapabilityStatement output stable
+var ResourceOrder = []string{
+	"Patient", "Practitioner", "Appointment", "Encounter",
+	"Condition", "MedicationRequest", "DocumentReference", "Observation",
+}
+
+// NewCapabilityStatement describes the read/search surface of the server
+func NewCapabilityStatement() CapabilityStatement {
+	rest := CapabilityRest{Mode: "server"}
+	for _, t := range ResourceOrder {
+		params := append([]CapabilitySearchParam{{Name: "_count", Type: "number"}}, SearchParams[t]...)
+		rest.Resource = append(rest.Resource, CapabilityResource{
+			Type:        t,
+			Interaction: []CapabilityInteraction{{Code: "read"}, {Code: "search-type"}},
+			SearchParam: params,
+		})
+	}
+
+	return CapabilityStatement{
+		ResourceType: "CapabilityStatement",
+		Status:       "active",
+		Date:         time.Now().UTC().Format("2006-01-02"),
+		Kind:         "instance",
+		Software:     CapabilitySoftware{Name: "HMS FHIR API"},
+		FhirVersion:  "4.0.1",
+		Format:       []string{"json"},
+		Rest:         []CapabilityRest{rest},
+	}
+}
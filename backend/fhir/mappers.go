@@ -0,0 +1,328 @@
+package fhir
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// MRNSystem is the identifier system used for hospital medical record numbers
+const MRNSystem = "urn:hms:mrn"
+
+// InvoiceNumberSystem is the identifier system of legal invoice numbers
+const InvoiceNumberSystem = "urn:hms:invoice-number"
+
+func ref(resourceType string, id int) *Reference {
+	return &Reference{Reference: resourceType + "/" + strconv.Itoa(id)}
+}
+
+func instant(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func splitName(full string) HumanName {
+	name := HumanName{Use: "official", Text: full}
+	parts := strings.Fields(full)
+	if len(parts) > 1 {
+		name.Family = parts[len(parts)-1]
+		name.Given = parts[:len(parts)-1]
+	} else if len(parts) == 1 {
+		name.Family = parts[0]
+	}
+	return name
+}
+
+// Gender normalises stored gender values to the FHIR administrative-gender code set
+func Gender(g string) string {
+	switch strings.ToLower(strings.TrimSpace(g)) {
+	case "m", "male":
+		return "male"
+	case "f", "female":
+		return "female"
+	case "o", "other":
+		return "other"
+	case "":
+		return ""
+	default:
+		return "unknown"
+	}
+}
+
+// BirthDate returns the date of birth only when it is a valid FHIR date
+func BirthDate(dob string) string {
+	if _, err := time.Parse("2006-01-02", dob); err != nil {
+		return ""
+	}
+	return dob
+}
+
+func PatientResource(p models.Patient) Patient {
+	res := Patient{
+		ResourceType: "Patient",
+		ID:           strconv.Itoa(p.ID),
+		Gender:       Gender(p.Gender),
+		BirthDate:    BirthDate(p.DateOfBirth),
+	}
+	if p.MRN != "" {
+		res.Identifier = append(res.Identifier, Identifier{Use: "usual", System: MRNSystem, Value: p.MRN})
+	}
+	if p.InsuranceNumber != "" {
+		res.Identifier = append(res.Identifier, Identifier{Use: "secondary", System: "urn:hms:insurance-number", Value: p.InsuranceNumber})
+	}
+	if p.FullName != "" {
+		res.Name = []HumanName{splitName(p.FullName)}
+	}
+	if p.Phone != "" {
+		res.Telecom = []ContactPoint{{System: "phone", Value: p.Phone}}
+	}
+	if p.Address != "" {
+		res.Address = []Address{{Use: "home", Text: p.Address}}
+	}
+	return res
+}
+
+func PractitionerResource(d models.Doctor) Practitioner {
+	active := d.Status
+	res := Practitioner{
+		ResourceType: "Practitioner",
+		ID:           strconv.Itoa(d.ID),
+		Active:       &active,
+	}
+	if d.FullName != "" {
+		res.Name = []HumanName{splitName(d.FullName)}
+	}
+	if d.Phone != "" {
+		res.Telecom = []ContactPoint{{System: "phone", Value: d.Phone, Use: "work"}}
+	}
+	if d.Speciality != "" {
+		res.Qualification = []PractitionerQualification{{Code: CodeableConcept{Text: d.Speciality}}}
+	}
+	return res
+}
+
+// AppointmentStatus maps our appointment statuses to the FHIR appointmentstatus code set
+func AppointmentStatus(status string) string {
+	switch status {
+	case "scheduled":
+		return "booked"
+	case "completed":
+		return "fulfilled"
+	case "cancelled", "canceled":
+		return "cancelled"
+	case "no-show":
+		return "noshow"
+	default:
+		return "proposed"
+	}
+}
+
+func AppointmentResource(a models.Appointment) Appointment {
+	res := Appointment{
+		ResourceType:    "Appointment",
+		ID:              strconv.Itoa(int(a.ID)),
+		Meta:            &Meta{LastUpdated: instant(a.UpdatedAt)},
+		Status:          AppointmentStatus(a.Status),
+		Description:     a.Reason,
+		Start:           instant(a.DateTime),
+		MinutesDuration: a.Duration,
+		Comment:         a.Notes,
+		Participant: []AppointmentParticipant{
+			{Actor: ref("Patient", int(a.PatientID)), Status: "accepted"},
+			{Actor: ref("Practitioner", int(a.DoctorID)), Status: "accepted"},
+		},
+	}
+	if a.Duration > 0 && !a.DateTime.IsZero() {
+		res.End = instant(a.DateTime.Add(time.Duration(a.Duration) * time.Minute))
+	}
+	if res.Meta.LastUpdated == "" {
+		res.Meta = nil
+	}
+	return res
+}
+
+// EncounterResource derives an ambulatory encounter from an appointment
+func EncounterResource(a models.Appointment) Encounter {
+	status := "planned"
+	switch a.Status {
+	case "completed":
+		status = "finished"
+	case "cancelled", "canceled", "no-show":
+		status = "cancelled"
+	}
+	res := Encounter{
+		ResourceType: "Encounter",
+		ID:           strconv.Itoa(int(a.ID)),
+		Status:       status,
+		Class: Coding{
+			System:  "http://terminology.hl7.org/CodeSystem/v3-ActCode",
+			Code:    "AMB",
+			Display: "ambulatory",
+		},
+		Subject:     ref("Patient", int(a.PatientID)),
+		Participant: []EncounterParticipant{{Individual: ref("Practitioner", int(a.DoctorID))}},
+		Appointment: []Reference{*ref("Appointment", int(a.ID))},
+	}
+	if !a.DateTime.IsZero() {
+		res.Period = &Period{Start: instant(a.DateTime)}
+		if a.Duration > 0 {
+			res.Period.End = instant(a.DateTime.Add(time.Duration(a.Duration) * time.Minute))
+		}
+	}
+	if a.Reason != "" {
+		res.ReasonCode = []CodeableConcept{{Text: a.Reason}}
+	}
+	if !a.UpdatedAt.IsZero() {
+		res.Meta = &Meta{LastUpdated: instant(a.UpdatedAt)}
+	}
+	return res
+}
+
+func ConditionResource(m models.MedicalRecord) Condition {
+	res := Condition{
+		ResourceType: "Condition",
+		ID:           strconv.Itoa(m.ID),
+		VerificationStatus: &CodeableConcept{Coding: []Coding{{
+			System: "http://terminology.hl7.org/CodeSystem/condition-ver-status",
+			Code:   "confirmed",
+		}}},
+		Category: []CodeableConcept{{Coding: []Coding{{
+			System: "http://terminology.hl7.org/CodeSystem/condition-category",
+			Code:   "encounter-diagnosis",
+		}}}},
+		Subject:      *ref("Patient", m.PatientID),
+		RecordedDate: instant(m.CreationDate),
+		Recorder:     ref("Practitioner", m.DoctorID),
+	}
+	if m.Diagnosis != "" {
+		res.Code = &CodeableConcept{Text: m.Diagnosis}
+	}
+	return res
+}
+
+func MedicationRequestResource(m models.MedicalRecord) MedicationRequest {
+	return MedicationRequest{
+		ResourceType:              "MedicationRequest",
+		ID:                        strconv.Itoa(m.ID),
+		Status:                    "active",
+		Intent:                    "order",
+		MedicationCodeableConcept: CodeableConcept{Text: m.Prescription},
+		Subject:                   *ref("Patient", m.PatientID),
+		AuthoredOn:                instant(m.CreationDate),
+		Requester:                 ref("Practitioner", m.DoctorID),
+		DosageInstruction:         []Dosage{{Text: m.Prescription}},
+	}
+}
+
+func DocumentReferenceResource(f models.File, baseURL string) DocumentReference {
+	res := DocumentReference{
+		ResourceType: "DocumentReference",
+		ID:           strconv.Itoa(f.ID),
+		Status:       "current",
+		Subject:      ref("Patient", f.PatientID),
+		Date:         instant(f.UploadDate),
+		Description:  f.Description,
+		Content: []DocumentReferenceContent{{Attachment: Attachment{
+			ContentType: f.FileType,
+			URL:         baseURL + "/api/files/download/" + strconv.Itoa(f.ID),
+			Title:       f.FileName,
+			Creation:    instant(f.UploadDate),
+		}}},
+	}
+	if f.DoctorID != 0 {
+		res.Author = []Reference{*ref("Practitioner", f.DoctorID)}
+	}
+	return res
+}
+
+// ObservationStatus maps HL7 v2 OBX-11 result status codes to FHIR observation-status
+func ObservationStatus(code string) string {
+	switch strings.ToUpper(code) {
+	case "P", "I", "R", "S":
+		return "preliminary"
+	case "C":
+		return "corrected"
+	case "X", "D", "W":
+		return "cancelled"
+	default:
+		return "final"
+	}
+}
+
+func ObservationResource(l models.LabResult) Observation {
+	res := Observation{
+		ResourceType: "Observation",
+		ID:           strconv.Itoa(l.ID),
+		Status:       ObservationStatus(l.Status),
+		Category: []CodeableConcept{{Coding: []Coding{{
+			System: "http://terminology.hl7.org/CodeSystem/observation-category",
+			Code:   "laboratory",
+		}}}},
+		Code: CodeableConcept{
+			Coding: []Coding{{Code: l.TestCode, Display: l.TestName}},
+			Text:   l.TestName,
+		},
+		Subject:           ref("Patient", l.PatientID),
+		EffectiveDateTime: instant(l.ObservedAt),
+	}
+	if v, err := strconv.ParseFloat(strings.TrimSpace(l.Value), 64); err == nil {
+		res.ValueQuantity = &Quantity{Value: v, Unit: l.Units}
+	} else if l.Value != "" {
+		res.ValueString = l.Value
+	}
+	if l.AbnormalFlag != "" {
+		res.Interpretation = []CodeableConcept{{Coding: []Coding{{
+			System: "http://terminology.hl7.org/CodeSystem/v3-ObservationInterpretation",
+			Code:   l.AbnormalFlag,
+		}}}}
+	}
+	if l.ReferenceRange != "" {
+		res.ReferenceRange = []ObservationReferenceRange{{Text: l.ReferenceRange}}
+	}
+	return res
+}
+
+// InvoiceStatus maps invoice statuses to the FHIR invoice-status code set
+func InvoiceStatus(status string) string {
+	switch status {
+	case "paid", "credited":
+		return "balanced"
+	case "cancelled", "void":
+		return "cancelled"
+	case "draft":
+		return "draft"
+	default:
+		return "issued"
+	}
+}
+
+func money(m models.Money, currency string) *Money {
+	return &Money{Value: json.Number(m.String()), Currency: currency}
+}
+
+func InvoiceResource(inv models.Invoice) Invoice {
+	res := Invoice{
+		ResourceType: "Invoice",
+		ID:           strconv.Itoa(inv.ID),
+		Status:       InvoiceStatus(inv.Status),
+		Subject:      ref("Patient", inv.PatientID),
+		Date:         instant(inv.IssuedAt),
+		TotalNet:     money(inv.Subtotal-inv.DiscountTotal, inv.Currency),
+		TotalGross:   money(inv.Amount, inv.Currency),
+	}
+	if inv.Number != nil {
+		res.Identifier = []Identifier{{Use: "official", System: InvoiceNumberSystem, Value: *inv.Number}}
+	}
+	if !inv.UpdatedAt.IsZero() {
+		res.Meta = &Meta{LastUpdated: instant(inv.UpdatedAt)}
+	}
+	if inv.Notes != "" {
+		res.Note = []Annotation{{Text: inv.Notes}}
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package fhir
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+func TestPatientResource(t *testing.T) {
+	res := PatientResource(models.Patient{
+		ID:          7,
+		MRN:         "MRN7",
+		FullName:    "Jane Q Public",
+		DateOfBirth: "1990-03-04",
+		Gender:      "F",
+	})
+
+	if res.Gender != "female" {
+		t.Fatalf("expected gender female, got %q", res.Gender)
+	}
+	if res.Name[0].Family != "Public" || len(res.Name[0].Given) != 2 {
+		t.Fatalf("unexpected name %+v", res.Name[0])
+	}
+
+	b, _ := json.Marshal(res)
+	for _, forbidden := range []string{`"telecom"`, `"address"`, `""`} {
+		if strings.Contains(string(b), forbidden) {
+			t.Fatalf("expected empty elements to be omitted, found %s in %s", forbidden, b)
+		}
+	}
+}
+
+func TestAppointmentResourceStatusAndEnd(t *testing.T) {
+	start := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	res := AppointmentResource(models.Appointment{
+		ID: 3, PatientID: 1, DoctorID: 2, DateTime: start, Status: "no-show", Duration: 30,
+	})
+
+	if res.Status != "noshow" {
+		t.Fatalf("expected noshow, got %q", res.Status)
+	}
+	if res.End != "2026-02-01T09:30:00Z" {
+		t.Fatalf("unexpected end %q", res.End)
+	}
+	if len(res.Participant) != 2 || res.Participant[0].Actor.Reference != "Patient/1" {
+		t.Fatalf("unexpected participants %+v", res.Participant)
+	}
+}
+
+func TestConditionResourceWithoutDiagnosis(t *testing.T) {
+	res := ConditionResource(models.MedicalRecord{ID: 4, PatientID: 1, DoctorID: 2})
+	if res.Code != nil {
+		t.Fatalf("expected no code for an empty diagnosis, got %+v", res.Code)
+	}
+	res = ConditionResource(models.MedicalRecord{ID: 4, PatientID: 1, DoctorID: 2, Diagnosis: "Asthma"})
+	if res.Code == nil || res.Code.Text != "Asthma" {
+		t.Fatalf("expected code text Asthma, got %+v", res.Code)
+	}
+}
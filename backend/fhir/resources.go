@@ -0,0 +1,257 @@
+package fhir
+
+import "encoding/json"
+
+// Minimal FHIR R4 datatypes and resources used by the read API.
+// Only the elements we can populate are modelled; every optional element
+// uses omitempty because FHIR forbids empty strings, arrays and objects.
+
+const ContentType = "application/fhir+json"
+
+type Meta struct {
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type Identifier struct {
+	Use    string `json:"use,omitempty"`
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+type HumanName struct {
+	Use    string   `json:"use,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type ContactPoint struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Use    string `json:"use,omitempty"`
+}
+
+type Address struct {
+	Use  string `json:"use,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type Period struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type Quantity struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// Money.Value is a JSON number written from the exact decimal amount
+type Money struct {
+	Value    json.Number `json:"value"`
+	Currency string      `json:"currency,omitempty"`
+}
+
+type Attachment struct {
+	ContentType string `json:"contentType,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Creation    string `json:"creation,omitempty"`
+}
+
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+type Patient struct {
+	ResourceType string         `json:"resourceType"`
+	ID           string         `json:"id"`
+	Meta         *Meta          `json:"meta,omitempty"`
+	Identifier   []Identifier   `json:"identifier,omitempty"`
+	Name         []HumanName    `json:"name,omitempty"`
+	Telecom      []ContactPoint `json:"telecom,omitempty"`
+	Gender       string         `json:"gender,omitempty"`
+	BirthDate    string         `json:"birthDate,omitempty"`
+	Address      []Address      `json:"address,omitempty"`
+}
+
+type PractitionerQualification struct {
+	Code CodeableConcept `json:"code"`
+}
+
+type Practitioner struct {
+	ResourceType  string                      `json:"resourceType"`
+	ID            string                      `json:"id"`
+	Active        *bool                       `json:"active,omitempty"`
+	Name          []HumanName                 `json:"name,omitempty"`
+	Telecom       []ContactPoint              `json:"telecom,omitempty"`
+	Qualification []PractitionerQualification `json:"qualification,omitempty"`
+}
+
+type AppointmentParticipant struct {
+	Actor  *Reference `json:"actor,omitempty"`
+	Status string     `json:"status"`
+}
+
+type Appointment struct {
+	ResourceType    string                   `json:"resourceType"`
+	ID              string                   `json:"id"`
+	Meta            *Meta                    `json:"meta,omitempty"`
+	Status          string                   `json:"status"`
+	Description     string                   `json:"description,omitempty"`
+	Start           string                   `json:"start,omitempty"`
+	End             string                   `json:"end,omitempty"`
+	MinutesDuration int                      `json:"minutesDuration,omitempty"`
+	Comment         string                   `json:"comment,omitempty"`
+	Participant     []AppointmentParticipant `json:"participant"`
+}
+
+type EncounterParticipant struct {
+	Individual *Reference `json:"individual,omitempty"`
+}
+
+type Encounter struct {
+	ResourceType string                 `json:"resourceType"`
+	ID           string                 `json:"id"`
+	Meta         *Meta                  `json:"meta,omitempty"`
+	Status       string                 `json:"status"`
+	Class        Coding                 `json:"class"`
+	Subject      *Reference             `json:"subject,omitempty"`
+	Participant  []EncounterParticipant `json:"participant,omitempty"`
+	Appointment  []Reference            `json:"appointment,omitempty"`
+	Period       *Period                `json:"period,omitempty"`
+	ReasonCode   []CodeableConcept      `json:"reasonCode,omitempty"`
+}
+
+type Condition struct {
+	ResourceType       string            `json:"resourceType"`
+	ID                 string            `json:"id"`
+	ClinicalStatus     *CodeableConcept  `json:"clinicalStatus,omitempty"`
+	VerificationStatus *CodeableConcept  `json:"verificationStatus,omitempty"`
+	Category           []CodeableConcept `json:"category,omitempty"`
+	Code               *CodeableConcept  `json:"code,omitempty"`
+	Subject            Reference         `json:"subject"`
+	RecordedDate       string            `json:"recordedDate,omitempty"`
+	Recorder           *Reference        `json:"recorder,omitempty"`
+}
+
+type Dosage struct {
+	Text string `json:"text,omitempty"`
+}
+
+type MedicationRequest struct {
+	ResourceType              string          `json:"resourceType"`
+	ID                        string          `json:"id"`
+	Status                    string          `json:"status"`
+	Intent                    string          `json:"intent"`
+	MedicationCodeableConcept CodeableConcept `json:"medicationCodeableConcept"`
+	Subject                   Reference       `json:"subject"`
+	AuthoredOn                string          `json:"authoredOn,omitempty"`
+	Requester                 *Reference      `json:"requester,omitempty"`
+	DosageInstruction         []Dosage        `json:"dosageInstruction,omitempty"`
+}
+
+type DocumentReferenceContent struct {
+	Attachment Attachment `json:"attachment"`
+}
+
+type DocumentReference struct {
+	ResourceType string                     `json:"resourceType"`
+	ID           string                     `json:"id"`
+	Status       string                     `json:"status"`
+	Type         *CodeableConcept           `json:"type,omitempty"`
+	Subject      *Reference                 `json:"subject,omitempty"`
+	Date         string                     `json:"date,omitempty"`
+	Author       []Reference                `json:"author,omitempty"`
+	Description  string                     `json:"description,omitempty"`
+	Content      []DocumentReferenceContent `json:"content"`
+}
+
+type ObservationReferenceRange struct {
+	Text string `json:"text,omitempty"`
+}
+
+type Observation struct {
+	ResourceType      string                      `json:"resourceType"`
+	ID                string                      `json:"id"`
+	Status            string                      `json:"status"`
+	Category          []CodeableConcept           `json:"category,omitempty"`
+	Code              CodeableConcept             `json:"code"`
+	Subject           *Reference                  `json:"subject,omitempty"`
+	EffectiveDateTime string                      `json:"effectiveDateTime,omitempty"`
+	ValueQuantity     *Quantity                   `json:"valueQuantity,omitempty"`
+	ValueString       string                      `json:"valueString,omitempty"`
+	Interpretation    []CodeableConcept           `json:"interpretation,omitempty"`
+	ReferenceRange    []ObservationReferenceRange `json:"referenceRange,omitempty"`
+}
+
+type Invoice struct {
+	ResourceType string       `json:"resourceType"`
+	ID           string       `json:"id"`
+	Meta         *Meta        `json:"meta,omitempty"`
+	Identifier   []Identifier `json:"identifier,omitempty"`
+	Status       string       `json:"status"`
+	Subject      *Reference   `json:"subject,omitempty"`
+	Date         string       `json:"date,omitempty"`
+	TotalNet     *Money       `json:"totalNet,omitempty"`
+	TotalGross   *Money       `json:"totalGross,omitempty"`
+	Note         []Annotation `json:"note,omitempty"`
+}
+
+type BundleLink struct {
+	Relation string `json:"relation"`
+	URL      string `json:"url"`
+}
+
+type BundleEntrySearch struct {
+	Mode string `json:"mode,omitempty"`
+}
+
+type BundleEntry struct {
+	FullURL  string             `json:"fullUrl,omitempty"`
+	Resource interface{}        `json:"resource,omitempty"`
+	Search   *BundleEntrySearch `json:"search,omitempty"`
+}
+
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"`
+	Total        *int64        `json:"total,omitempty"`
+	Link         []BundleLink  `json:"link,omitempty"`
+	Entry        []BundleEntry `json:"entry,omitempty"`
+}
+
+type OperationOutcomeIssue struct {
+	Severity    string `json:"severity"`
+	Code        string `json:"code"`
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+type OperationOutcome struct {
+	ResourceType string                  `json:"resourceType"`
+	Issue        []OperationOutcomeIssue `json:"issue"`
+}
+
+// NewOperationOutcome builds a single-issue OperationOutcome
+func NewOperationOutcome(severity, code, diagnostics string) OperationOutcome {
+	return OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        []OperationOutcomeIssue{{Severity: severity, Code: code, Diagnostics: diagnostics}},
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+// Admission event topics
+const (
+	AdmissionAdmittedTopic    = "admissions.admitted"
+	AdmissionTransferredTopic = "admissions.transferred"
+	AdmissionDischargedTopic  = "admissions.discharged"
+)
+
+// AdmissionTopics lists the Kafka topics written by the admission handlers
+var AdmissionTopics = []string{AdmissionAdmittedTopic, AdmissionTransferredTopic, AdmissionDischargedTopic}
+
+func publishAdmissionEvent(topic string, data interface{}, admissionID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := utils.PublishEvent(ctx, topic, "admission-"+strconv.Itoa(admissionID), data); err != nil {
+		log.Printf("Failed to publish %s: %v", topic, err)
+	}
+}
+
+func currentUserID(r *http.Request) int {
+	if claims := claimsFromRequest(r); claims != nil {
+		return claims.UserID
+	}
+	return 0
+}
+
+func writeAdmissionError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrBedNotAvailable),
+		errors.Is(err, repositories.ErrAdmissionNotActive),
+		errors.Is(err, repositories.ErrPatientAlreadyAdmitted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Admission or bed not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// AdmitPatientHandler (POST /admissions)
+func AdmitPatientHandler(w http.ResponseWriter, r *http.Request) {
+	var adm models.Admission
+	if err := json.NewDecoder(r.Body).Decode(&adm); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if adm.PatientID == 0 || adm.DoctorID == 0 || adm.BedID == 0 {
+		http.Error(w, "patient_id, doctor_id and bed_id are required", http.StatusBadRequest)
+		return
+	}
+	adm.ID = 0
+	adm.DischargedAt = nil
+	adm.Movements = nil
+
+	if err := repositories.AdmitPatient(&adm, currentUserID(r)); err != nil {
+		writeAdmissionError(w, err, "Failed to admit patient")
+		return
+	}
+	publishAdmissionEvent(AdmissionAdmittedTopic, adm, adm.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(adm)
+}
+
+// TransferPatientHandler (POST /admissions/{id}/transfer)
+func TransferPatientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid admission ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		BedID    int    `json:"bed_id"`
+		DoctorID int    `json:"doctor_id"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.BedID == 0 {
+		http.Error(w, "bed_id is required", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := repositories.GetAdmissionByID(id)
+	if err != nil {
+		http.Error(w, "Admission not found", http.StatusNotFound)
+		return
+	}
+	adm, err := repositories.TransferPatient(id, payload.BedID, payload.DoctorID, payload.Reason, currentUserID(r))
+	if err != nil {
+		writeAdmissionError(w, err, "Failed to transfer patient")
+		return
+	}
+	publishAdmissionEvent(AdmissionTransferredTopic, map[string]interface{}{
+		"admission_id": adm.ID,
+		"patient_id":   adm.PatientID,
+		"doctor_id":    adm.DoctorID,
+		"from_bed_id":  previous.BedID,
+		"to_bed_id":    adm.BedID,
+		"reason":       payload.Reason,
+	}, adm.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(adm)
+}
+
+// DischargePatientHandler (POST /admissions/{id}/discharge)
+func DischargePatientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid admission ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Notes string `json:"notes"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+
+	adm, err := repositories.DischargePatient(id, payload.Notes, currentUserID(r))
+	if err != nil {
+		writeAdmissionError(w, err, "Failed to discharge patient")
+		return
+	}
+	publishAdmissionEvent(AdmissionDischargedTopic, adm, adm.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(adm)
+}
+
+// GetAdmissionByIDHandler returns an admission with its movement history
+func GetAdmissionByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid admission ID", http.StatusBadRequest)
+		return
+	}
+	adm, err := repositories.GetAdmissionByID(id)
+	if err != nil {
+		http.Error(w, "Admission not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(adm)
+}
+
+// GetAdmissionsHandler (GET /admissions?status=admitted&patient_id=)
+func GetAdmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, _ := strconv.Atoi(r.URL.Query().Get("patient_id"))
+	list, err := repositories.GetAdmissions(r.URL.Query().Get("status"), patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch admissions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
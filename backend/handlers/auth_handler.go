@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/samichen99/HAP-hospital-management-system/middleware"
 	"github.com/samichen99/HAP-hospital-management-system/repositories"
 	"github.com/samichen99/HAP-hospital-management-system/utils"
 )
@@ -43,3 +44,9 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(LoginResponse{Token: token})
 }
+
+// claimsFromRequest returns the JWT claims stored by AuthMiddleware, or nil
+func claimsFromRequest(r *http.Request) *utils.Claims {
+	claims, _ := r.Context().Value(middleware.UserClaimsKey).(*utils.Claims)
+	return claims
+}
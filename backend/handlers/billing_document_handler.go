@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+func formatAmount(m models.Money, currency string) string {
+	return m.String() + " " + currency
+}
+
+// invoiceReference is the number of an issued invoice, or its ID while it is a draft
+func invoiceReference(inv models.Invoice) string {
+	if inv.Number != nil {
+		return *inv.Number
+	}
+	return "DRAFT #" + strconv.Itoa(inv.ID)
+}
+
+// billingDocument is everything printed on an invoice or receipt
+type billingDocument struct {
+	Invoice     models.Invoice
+	Patient     models.Patient
+	BillTo      repositories.InvoiceBillTo
+	Lines       []models.InvoiceLine
+	Payments    []models.Payment
+	CreditNotes []models.CreditNote
+}
+
+func loadBillingDocument(inv models.Invoice) (billingDocument, error) {
+	doc := billingDocument{Invoice: inv}
+	var err error
+	if doc.Patient, err = repositories.GetPatientByID(inv.PatientID); err != nil {
+		return doc, err
+	}
+	if doc.BillTo, err = repositories.GetInvoiceBillTo(inv); err != nil {
+		return doc, err
+	}
+	if doc.Lines, err = repositories.GetInvoiceLines(inv.ID); err != nil {
+		return doc, err
+	}
+	if doc.Payments, err = repositories.GetPaymentsByInvoiceID(inv.ID); err != nil {
+		return doc, err
+	}
+	doc.CreditNotes, err = repositories.GetCreditNotesByInvoiceID(inv.ID)
+	return doc, err
+}
+
+func writeBillTo(pdf *utils.PDFDocument, d billingDocument) {
+	pdf.Heading("Bill to")
+	pdf.KeyValue("Name", d.BillTo.Name)
+	pdf.KeyValue("Address", d.BillTo.Address)
+	if d.BillTo.Phone != "" {
+		pdf.KeyValue("Phone", d.BillTo.Phone)
+	}
+	if d.BillTo.OnBehalfOf != "" {
+		pdf.KeyValue("On behalf of", d.BillTo.OnBehalfOf)
+	}
+
+	pdf.Heading("Patient")
+	pdf.KeyValue("Name", d.Patient.FullName)
+	if d.Patient.MRN != "" {
+		pdf.KeyValue("MRN", d.Patient.MRN)
+	}
+	pdf.KeyValue("Date of birth", d.Patient.DateOfBirth)
+	if d.Patient.InsuranceNumber != "" {
+		pdf.KeyValue("Insurance number", d.Patient.InsuranceNumber)
+	}
+}
+
+// renderInvoicePDF prints the invoice with its lines, taxes, payments, credit notes and balance
+func renderInvoicePDF(d billingDocument, tmpl utils.DocumentTemplate) ([]byte, error) {
+	inv := d.Invoice
+	pdf := utils.NewPDFDocument(utils.DefaultLetterhead(), tmpl.Title+" "+invoiceReference(inv))
+	pdf.KeyValue("Invoice number", invoiceReference(inv))
+	pdf.KeyValue("Issued", inv.IssuedAt.Format(tmpl.DateFormat))
+	pdf.KeyValue("Due", inv.DueDate.Format(tmpl.DateFormat))
+	pdf.KeyValue("Status", inv.Status)
+	if inv.VoidedAt != nil {
+		pdf.KeyValue("Voided", inv.VoidedAt.Format(tmpl.DateFormat)+" - "+inv.VoidReason)
+	}
+	writeBillTo(pdf, d)
+
+	if tmpl.Intro != "" {
+		pdf.Space(3)
+		pdf.Paragraph(tmpl.Intro)
+	}
+
+	pdf.Heading("Charges")
+	rows := make([][]string, 0, len(d.Lines))
+	for _, l := range d.Lines {
+		description := l.Description
+		if l.Code != "" {
+			description = l.Code + " " + description
+		}
+		row := []string{description, strconv.Itoa(l.Quantity), l.UnitPrice.String(), l.Discount.String()}
+		if tmpl.ShowTax {
+			row = append(row, strconv.FormatFloat(l.TaxRate, 'f', -1, 64)+"%", l.TaxAmount.String())
+		}
+		rows = append(rows, append(row, l.Amount.String()))
+	}
+	if tmpl.ShowTax {
+		pdf.Table(
+			[]string{"Description", "Qty", "Unit price", "Discount", "Tax rate", "Tax", "Amount"},
+			[]float64{60, 12, 24, 20, 18, 20, 26},
+			[]string{"L", "R", "R", "R", "R", "R", "R"},
+			rows,
+		)
+	} else {
+		pdf.Table(
+			[]string{"Description", "Qty", "Unit price", "Discount", "Amount"},
+			[]float64{84, 16, 28, 24, 28},
+			[]string{"L", "R", "R", "R", "R"},
+			rows,
+		)
+	}
+
+	pdf.Space(2)
+	pdf.TotalLine("Subtotal", formatAmount(inv.Subtotal, inv.Currency), false)
+	if inv.DiscountTotal != 0 {
+		pdf.TotalLine("Discount", "-"+formatAmount(inv.DiscountTotal, inv.Currency), false)
+	}
+	if tmpl.ShowTax {
+		pdf.TotalLine("Tax", formatAmount(inv.TaxTotal, inv.Currency), false)
+	}
+	pdf.TotalLine("Total", formatAmount(inv.Amount, inv.Currency), true)
+	pdf.TotalLine("Paid", formatAmount(inv.AmountPaid, inv.Currency), false)
+	if inv.AmountCredited != 0 {
+		pdf.TotalLine("Credited", formatAmount(inv.AmountCredited, inv.Currency), false)
+	}
+	pdf.TotalLine("Balance due", formatAmount(inv.BalanceDue, inv.Currency), true)
+
+	var payments [][]string
+	for _, p := range d.Payments {
+		if p.VoidedAt == nil {
+			payments = append(payments, []string{p.PaymentDate.Format(tmpl.DateFormat), p.PaymentMethod, p.Amount.String()})
+		}
+	}
+	if len(payments) > 0 {
+		pdf.Heading("Payments")
+		pdf.Table([]string{"Date", "Method", "Amount"}, []float64{60, 70, 50}, []string{"L", "L", "R"}, payments)
+	}
+	var notes [][]string
+	for _, n := range d.CreditNotes {
+		if n.VoidedAt == nil {
+			notes = append(notes, []string{n.Number, n.IssuedAt.Format(tmpl.DateFormat), n.Reason, n.Amount.String()})
+		}
+	}
+	if len(notes) > 0 {
+		pdf.Heading("Credit notes")
+		pdf.Table([]string{"Number", "Date", "Reason", "Amount"}, []float64{40, 30, 80, 30}, []string{"L", "L", "L", "R"}, notes)
+	}
+
+	if tmpl.Footer != "" {
+		pdf.Space(6)
+		pdf.Paragraph(tmpl.Footer)
+	}
+	return pdf.Bytes()
+}
+
+// renderReceiptPDF prints a receipt for one payment and the balance left on its invoice
+func renderReceiptPDF(p models.Payment, refunds []models.Refund, d billingDocument, tmpl utils.DocumentTemplate) ([]byte, error) {
+	inv := d.Invoice
+	pdf := utils.NewPDFDocument(utils.DefaultLetterhead(), tmpl.Title)
+	pdf.KeyValue("Receipt number", "R-"+strconv.Itoa(p.ID))
+	pdf.KeyValue("Received", p.PaymentDate.Format(tmpl.DateFormat))
+	pdf.KeyValue("Method", p.PaymentMethod)
+	pdf.KeyValue("Invoice", invoiceReference(inv))
+	if p.VoidedAt != nil {
+		pdf.KeyValue("Voided", p.VoidedAt.Format(tmpl.DateFormat)+" - "+p.VoidReason)
+	}
+	writeBillTo(pdf, d)
+
+	if tmpl.Intro != "" {
+		pdf.Space(3)
+		pdf.Paragraph(tmpl.Intro)
+	}
+
+	pdf.Heading("Payment")
+	pdf.TotalLine("Amount received", formatAmount(p.Amount, p.Currency), true)
+	var refunded models.Money
+	var rows [][]string
+	for _, r := range refunds {
+		if r.VoidedAt == nil && r.Status == "confirmed" {
+			refunded += r.Amount
+			rows = append(rows, []string{r.RefundedAt.Format(tmpl.DateFormat), r.Method, r.Reason, r.Amount.String()})
+		}
+	}
+	if len(rows) > 0 {
+		pdf.TotalLine("Refunded", "-"+formatAmount(refunded, p.Currency), false)
+	}
+	pdf.TotalLine("Invoice total", formatAmount(inv.Amount, inv.Currency), false)
+	pdf.TotalLine("Balance due", formatAmount(inv.BalanceDue, inv.Currency), true)
+	if len(rows) > 0 {
+		pdf.Heading("Refunds")
+		pdf.Table([]string{"Date", "Method", "Reason", "Amount"}, []float64{35, 35, 80, 30}, []string{"L", "L", "L", "R"}, rows)
+	}
+
+	if tmpl.Footer != "" {
+		pdf.Space(6)
+		pdf.Paragraph(tmpl.Footer)
+	}
+	return pdf.Bytes()
+}
+
+// writePDF sends a rendered document. With ?archive=true it is also stored as a file of the
+// patient and the new file's ID is returned in X-Archived-File-ID.
+func writePDF(w http.ResponseWriter, r *http.Request, data []byte, fileName string, patientID int, description string) {
+	if r.URL.Query().Get("archive") == "true" {
+		path, err := writeGeneratedFile(fileName, data)
+		if err != nil {
+			http.Error(w, "Failed to store document", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		file := models.File{
+			PatientID:   patientID,
+			DoctorID:    currentDoctorID(r),
+			FileName:    fileName,
+			FileType:    "application/pdf",
+			FileURL:     path,
+			Description: description,
+			UploadDate:  now,
+			// invoices and receipts are the patient's own billing documents
+			ReleasedToPatient: true,
+			ReleasedAt:        &now,
+		}
+		if err := repositories.CreateFile(&file); err != nil {
+			http.Error(w, "Failed to archive document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Archived-File-ID", strconv.Itoa(file.ID))
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "inline; filename=\""+fileName+"\"")
+	_, _ = w.Write(data)
+}
+
+func serveInvoicePDF(w http.ResponseWriter, r *http.Request, inv models.Invoice) {
+	tmpl, err := utils.LoadDocumentTemplate("invoice")
+	if err != nil {
+		http.Error(w, "Failed to load invoice template", http.StatusInternalServerError)
+		return
+	}
+	doc, err := loadBillingDocument(inv)
+	if err != nil {
+		http.Error(w, "Failed to assemble invoice", http.StatusInternalServerError)
+		return
+	}
+	data, err := renderInvoicePDF(doc, tmpl)
+	if err != nil {
+		http.Error(w, "Failed to render invoice", http.StatusInternalServerError)
+		return
+	}
+	fileName := fmt.Sprintf("invoice-%d-%d.pdf", inv.ID, time.Now().Unix())
+	if inv.Number != nil {
+		fileName = fmt.Sprintf("invoice-%s-%d.pdf", *inv.Number, time.Now().Unix())
+	}
+	writePDF(w, r, data, fileName, inv.PatientID, "Invoice "+invoiceReference(inv))
+}
+
+func serveReceiptPDF(w http.ResponseWriter, r *http.Request, p models.Payment, inv models.Invoice) {
+	tmpl, err := utils.LoadDocumentTemplate("receipt")
+	if err != nil {
+		http.Error(w, "Failed to load receipt template", http.StatusInternalServerError)
+		return
+	}
+	doc, err := loadBillingDocument(inv)
+	if err != nil {
+		http.Error(w, "Failed to assemble receipt", http.StatusInternalServerError)
+		return
+	}
+	refunds, err := repositories.GetRefundsByPaymentID(p.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch refunds", http.StatusInternalServerError)
+		return
+	}
+	data, err := renderReceiptPDF(p, refunds, doc, tmpl)
+	if err != nil {
+		http.Error(w, "Failed to render receipt", http.StatusInternalServerError)
+		return
+	}
+	fileName := fmt.Sprintf("receipt-%d-%d.pdf", p.ID, time.Now().Unix())
+	writePDF(w, r, data, fileName, inv.PatientID, "Receipt for payment #"+strconv.Itoa(p.ID)+" on "+invoiceReference(inv))
+}
+
+// GetInvoicePDFHandler (GET /invoices/{id}/pdf?archive=true)
+func GetInvoicePDFHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	inv, err := repositories.GetInvoiceByID(id)
+	if err != nil {
+		writeInvoiceError(w, err, "Error fetching invoice")
+		return
+	}
+	serveInvoicePDF(w, r, inv)
+}
+
+// GetPaymentReceiptPDFHandler (GET /payments/{id}/receipt.pdf?archive=true)
+func GetPaymentReceiptPDFHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	p, err := repositories.GetPaymentByID(id)
+	if err != nil {
+		writePaymentError(w, err, "Error fetching payment")
+		return
+	}
+	inv, err := repositories.GetInvoiceByID(p.InvoiceId)
+	if err != nil {
+		http.Error(w, "Error fetching invoice", http.StatusInternalServerError)
+		return
+	}
+	serveReceiptPDF(w, r, *p, inv)
+}
+
+// GetMyInvoicePDFHandler (GET /api/me/invoices/{id}/pdf)
+func GetMyInvoicePDFHandler(w http.ResponseWriter, r *http.Request) {
+	inv, ok := myInvoice(w, r)
+	if !ok {
+		return
+	}
+	// patients can download but not archive
+	r.URL.RawQuery = ""
+	serveInvoicePDF(w, r, inv)
+}
+
+// GetMyPaymentReceiptPDFHandler (GET /api/me/payments/{id}/receipt.pdf)
+func GetMyPaymentReceiptPDFHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	p, err := repositories.GetPaymentByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error fetching payment", http.StatusInternalServerError)
+		return
+	}
+	inv, err := repositories.GetInvoiceByID(p.InvoiceId)
+	if err != nil || inv.PatientID != patientID {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	r.URL.RawQuery = ""
+	serveReceiptPDF(w, r, *p, inv)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+var claimStatuses = map[string]bool{"draft": true, "submitted": true, "adjudicated": true, "paid": true, "denied": true}
+
+func writeClaimError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrPolicyNotForPatient),
+		errors.Is(err, repositories.ErrPolicyNotEffective),
+		errors.Is(err, repositories.ErrPayerInactive):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, repositories.ErrInvalidAdjudication):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrClaimExists),
+		errors.Is(err, repositories.ErrNoPrimaryClaim),
+		errors.Is(err, repositories.ErrClaimStatus),
+		errors.Is(err, repositories.ErrRemittanceExceedsClaim),
+		errors.Is(err, repositories.ErrInvoiceNotPayable),
+		errors.Is(err, repositories.ErrOverpayment):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+func claimID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid claim ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateClaimHandler (POST /invoices/{id}/claims)
+// body: {"policy_id": 3}
+func CreateClaimHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		PolicyID int `json:"policy_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.PolicyID == 0 {
+		http.Error(w, "policy_id is required", http.StatusBadRequest)
+		return
+	}
+	claim, err := repositories.CreateClaim(invoiceID, payload.PolicyID, currentUserID(r))
+	if err != nil {
+		writeClaimError(w, err, "Failed to create claim")
+		return
+	}
+	writeJSON(w, http.StatusCreated, claim)
+}
+
+// GetInvoiceClaimsHandler (GET /invoices/{id}/claims)
+func GetInvoiceClaimsHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetClaimsByInvoiceID(invoiceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch claims", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetClaimsHandler (GET /claims?status=submitted&payer_id=2)
+func GetClaimsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !claimStatuses[status] {
+		http.Error(w, "status must be draft, submitted, adjudicated, paid or denied", http.StatusBadRequest)
+		return
+	}
+	payerID, _ := strconv.Atoi(r.URL.Query().Get("payer_id"))
+	list, err := repositories.GetClaims(status, payerID)
+	if err != nil {
+		http.Error(w, "Failed to fetch claims", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetClaimHandler (GET /claims/{id}) returns the claim with its lines
+func GetClaimHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := claimID(w, r)
+	if !ok {
+		return
+	}
+	claim, err := repositories.GetClaimByID(id)
+	if err != nil {
+		writeClaimError(w, err, "Failed to fetch claim")
+		return
+	}
+	writeJSON(w, http.StatusOK, claim)
+}
+
+// SubmitClaimHandler (POST /claims/{id}/submit)
+func SubmitClaimHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := claimID(w, r)
+	if !ok {
+		return
+	}
+	claim, err := repositories.SubmitClaim(id)
+	if err != nil {
+		writeClaimError(w, err, "Failed to submit claim")
+		return
+	}
+	writeJSON(w, http.StatusOK, claim)
+}
+
+// AdjudicateClaimHandler (POST /claims/{id}/adjudicate)
+// body: {"lines": [{"claim_line_id": 7, "approved_amount": "40.00"}], "denial_reason": "..."}
+// Lines left out are approved as claimed; an empty body accepts the whole claim and a
+// denial_reason without lines denies it.
+func AdjudicateClaimHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := claimID(w, r)
+	if !ok {
+		return
+	}
+	var payload struct {
+		Lines []struct {
+			ClaimLineID    int          `json:"claim_line_id"`
+			ApprovedAmount models.Money `json:"approved_amount"`
+		} `json:"lines"`
+		DenialReason string `json:"denial_reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var approved map[int]models.Money
+	if len(payload.Lines) > 0 {
+		approved = make(map[int]models.Money, len(payload.Lines))
+		for _, l := range payload.Lines {
+			approved[l.ClaimLineID] = l.ApprovedAmount
+		}
+	}
+	claim, err := repositories.AdjudicateClaim(id, approved, payload.DenialReason)
+	if err != nil {
+		writeClaimError(w, err, "Failed to adjudicate claim")
+		return
+	}
+	writeJSON(w, http.StatusOK, claim)
+}
+
+// ApplyRemittanceHandler (POST /claims/{id}/remittances)
+// body: {"amount": "120.00", "paid_at": "2025-03-01T00:00:00Z", "reference": "EFT 998877"}
+func ApplyRemittanceHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := claimID(w, r)
+	if !ok {
+		return
+	}
+	var payload struct {
+		Amount    models.Money `json:"amount"`
+		PaidAt    time.Time    `json:"paid_at"`
+		Reference string       `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Amount <= 0 {
+		http.Error(w, "amount must be > 0", http.StatusBadRequest)
+		return
+	}
+	if payload.PaidAt.IsZero() {
+		payload.PaidAt = time.Now()
+	}
+	payment, err := repositories.ApplyRemittance(id, payload.Amount, payload.PaidAt, payload.Reference)
+	if err != nil {
+		writeClaimError(w, err, "Failed to apply remittance")
+		return
+	}
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+// ExportClaimsHandler (GET /claims/export.csv?status=submitted&payer_id=2)
+// writes one CSV row per claim line for the clearinghouse
+func ExportClaimsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "submitted"
+	}
+	if !claimStatuses[status] {
+		http.Error(w, "status must be draft, submitted, adjudicated, paid or denied", http.StatusBadRequest)
+		return
+	}
+	payerID, _ := strconv.Atoi(r.URL.Query().Get("payer_id"))
+	rows, err := repositories.GetClaimExportRows(status, payerID)
+	if err != nil {
+		http.Error(w, "Failed to export claims", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"claims-"+status+"-"+time.Now().Format("20060102")+".csv\"")
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{
+		"claim_number", "payer_code", "payer_identifier", "member_id", "group_number",
+		"subscriber_name", "subscriber_relationship", "patient_name", "patient_date_of_birth",
+		"patient_gender", "patient_mrn", "invoice_number", "service_date", "line_number",
+		"code", "description", "quantity", "charged", "payer_amount", "patient_amount", "currency",
+	})
+	for _, row := range rows {
+		_ = out.Write([]string{
+			row.ClaimNumber, row.PayerCode, row.PayerIdentifier, row.MemberID, row.GroupNumber,
+			row.SubscriberName, row.SubscriberRelationship, row.PatientName, row.PatientDateOfBirth,
+			row.PatientGender, row.PatientMRN, row.InvoiceNumber, row.ServiceDate.Format("2006-01-02"),
+			strconv.Itoa(row.LineNumber), row.Code, row.Description, strconv.Itoa(row.Quantity),
+			row.Charged.String(), row.PayerAmount.String(), row.PatientAmount.String(), row.Currency,
+		})
+	}
+	out.Flush()
+}
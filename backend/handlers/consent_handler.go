@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+// missingConsentError is the 403 body returned when a required consent is absent
+type missingConsentError struct {
+	Error          string `json:"error"`
+	PatientID      int    `json:"patient_id"`
+	MissingConsent string `json:"missing_consent"`
+	Scope          string `json:"scope"`
+}
+
+// requireConsent writes 403 with the missing consent type when the patient has not given
+// an active consent of that type for scope, and reports whether the request may continue
+func requireConsent(w http.ResponseWriter, patientID int, consentType, scope string) bool {
+	ok, err := repositories.HasActiveConsent(patientID, consentType, scope)
+	if err != nil {
+		http.Error(w, "Failed to check patient consent", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		writeJSON(w, http.StatusForbidden, missingConsentError{
+			Error:          "patient has not given " + consentType + " consent",
+			PatientID:      patientID,
+			MissingConsent: consentType,
+			Scope:          scope,
+		})
+	}
+	return ok
+}
+
+// requireFHIRConsent is requireConsent for the FHIR API, answering with an OperationOutcome
+func requireFHIRConsent(w http.ResponseWriter, patientID int, consentType, scope string) bool {
+	ok, err := repositories.HasActiveConsent(patientID, consentType, scope)
+	if err != nil {
+		writeFHIRError(w, http.StatusInternalServerError, "exception", "Failed to check patient consent")
+		return false
+	}
+	if !ok {
+		writeFHIRError(w, http.StatusForbidden, "forbidden",
+			"Missing consent: "+consentType+" ("+scope+") for Patient/"+strconv.Itoa(patientID))
+	}
+	return ok
+}
+
+func writeConsentError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrConsentRevoked):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrAttachmentMismatch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Consent not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// CreateConsentHandler (POST /patients/{id}/consents)
+// The scanned, signed form is uploaded through /files first and referenced by file_id.
+func CreateConsentHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	var c models.Consent
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch c.Type {
+	case models.ConsentTreatment, models.ConsentDataSharing, models.ConsentResearch:
+	default:
+		http.Error(w, "type must be treatment, data_sharing or research", http.StatusBadRequest)
+		return
+	}
+	switch c.Scope {
+	case "":
+		c.Scope = models.ConsentScopeAll
+	case models.ConsentScopeAll, models.ConsentScopeFHIRAPI, models.ConsentScopeBulkExport, models.ConsentScopeExternalReferral:
+	default:
+		http.Error(w, "scope must be all, fhir_api, bulk_export or external_referral", http.StatusBadRequest)
+		return
+	}
+	if c.SignerName == "" {
+		http.Error(w, "signer_name is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := repositories.GetPatientByID(patientID); err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+
+	c.ID = 0
+	c.PatientID = patientID
+	c.Status = "granted"
+	if c.GrantedAt.IsZero() {
+		c.GrantedAt = time.Now()
+	}
+	if c.ExpiresAt != nil && !c.ExpiresAt.After(c.GrantedAt) {
+		http.Error(w, "expires_at must be after granted_at", http.StatusBadRequest)
+		return
+	}
+	c.RevokedAt = nil
+	c.RevocationReason = ""
+	c.RecordedBy = currentUserID(r)
+
+	if err := repositories.CreateConsent(&c); err != nil {
+		writeConsentError(w, err, "Failed to record consent")
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// GetPatientConsentsHandler (GET /patients/{id}/consents?type=data_sharing)
+func GetPatientConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetConsentsByPatientID(patientID, r.URL.Query().Get("type"))
+	if err != nil {
+		http.Error(w, "Failed to fetch consents", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetConsentHandler (GET /consents/{id})
+func GetConsentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consent ID", http.StatusBadRequest)
+		return
+	}
+	c, err := repositories.GetConsentByID(id)
+	if err != nil {
+		writeConsentError(w, err, "Failed to fetch consent")
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// RevokeConsentHandler (POST /consents/{id}/revoke)
+func RevokeConsentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consent ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+
+	c, err := repositories.RevokeConsent(id, payload.Reason)
+	if err != nil {
+		writeConsentError(w, err, "Failed to revoke consent")
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+// voidReason reads the mandatory reason of a void from {"reason": "..."} or ?reason=
+func voidReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+	reason := strings.TrimSpace(payload.Reason)
+	if reason == "" {
+		reason = strings.TrimSpace(r.URL.Query().Get("reason"))
+	}
+	if reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return "", false
+	}
+	return reason, true
+}
+
+// CreateRefundHandler (POST /payments/{id}/refunds)
+// body: {"amount": "20.00", "method": "cash", "reason": "..."}; without an amount the rest
+// of the payment is refunded. Online payments are refunded through the payment gateway.
+func CreateRefundHandler(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	var refund models.Refund
+	if err := json.NewDecoder(r.Body).Decode(&refund); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if refund.Method == "" || strings.TrimSpace(refund.Reason) == "" {
+		http.Error(w, "method and reason are required", http.StatusBadRequest)
+		return
+	}
+	if refund.Amount < 0 {
+		http.Error(w, "amount must be > 0", http.StatusBadRequest)
+		return
+	}
+	refund.ID = 0
+	refund.PaymentID = paymentID
+	refund.RecordedBy = currentUserID(r)
+	refund.VoidedAt, refund.VoidReason, refund.VoidedBy = nil, "", nil
+	if refund.RefundedAt.IsZero() {
+		refund.RefundedAt = time.Now()
+	}
+	payment, err := repositories.GetPaymentByID(paymentID)
+	if err != nil {
+		writePaymentError(w, err, "Failed to fetch payment")
+		return
+	}
+	if err := repositories.CreateRefund(&refund); err != nil {
+		writePaymentError(w, err, "Failed to create refund")
+		return
+	}
+	if payment.IntentID != nil {
+		// online payments are refunded at the gateway; the refund is recorded as pending first so
+		// that concurrent refunds cannot exceed the payment, then confirmed or failed by the gateway
+		if err := refundOnlinePayment(r.Context(), *payment.IntentID, refund.Amount); err != nil {
+			log.Println("Error refunding online payment:", err)
+			if err := repositories.FailRefund(refund.ID, "Gateway refund failed: "+err.Error(), refund.RecordedBy); err != nil {
+				log.Printf("Pending refund %d could not be cancelled after the gateway refused it: %v", refund.ID, err)
+				http.Error(w, "Payment gateway refused the refund and the pending refund could not be cancelled", http.StatusInternalServerError)
+				return
+			}
+			http.Error(w, "Payment gateway refused the refund", http.StatusBadGateway)
+			return
+		}
+		confirmed, err := repositories.ConfirmRefund(refund.ID)
+		if err != nil {
+			log.Printf("Refund %d was paid out by the gateway but could not be confirmed: %v", refund.ID, err)
+			http.Error(w, "Refund was paid out but could not be confirmed; it stays pending", http.StatusInternalServerError)
+			return
+		}
+		refund = confirmed
+	}
+	writeJSON(w, http.StatusCreated, refund)
+}
+
+// GetPaymentRefundsHandler (GET /payments/{id}/refunds)
+func GetPaymentRefundsHandler(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetRefundsByPaymentID(paymentID)
+	if err != nil {
+		http.Error(w, "Failed to fetch refunds", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// VoidRefundHandler (POST /refunds/{id}/void)
+func VoidRefundHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid refund ID", http.StatusBadRequest)
+		return
+	}
+	reason, ok := voidReason(w, r)
+	if !ok {
+		return
+	}
+	if err := repositories.VoidRefund(id, reason, currentUserID(r)); err != nil {
+		writePaymentError(w, err, "Failed to void refund")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateCreditNoteHandler (POST /invoices/{id}/credit-notes)
+// body: {"amount": "15.00", "reason": "..."}
+func CreateCreditNoteHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Amount models.Money `json:"amount"`
+		Reason string       `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Amount <= 0 || strings.TrimSpace(payload.Reason) == "" {
+		http.Error(w, "amount > 0 and reason are required", http.StatusBadRequest)
+		return
+	}
+	note := models.CreditNote{
+		InvoiceID: invoiceID,
+		Amount:    payload.Amount,
+		Reason:    payload.Reason,
+		CreatedBy: currentUserID(r),
+	}
+	if err := repositories.CreateCreditNote(&note); err != nil {
+		writePaymentError(w, err, "Failed to create credit note")
+		return
+	}
+	writeJSON(w, http.StatusCreated, note)
+}
+
+// GetInvoiceCreditNotesHandler (GET /invoices/{id}/credit-notes)
+func GetInvoiceCreditNotesHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetCreditNotesByInvoiceID(invoiceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch credit notes", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetCreditNoteHandler (GET /credit-notes/{id})
+func GetCreditNoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid credit note ID", http.StatusBadRequest)
+		return
+	}
+	note, err := repositories.GetCreditNoteByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Credit note not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch credit note", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, note)
+}
+
+// VoidCreditNoteHandler (POST /credit-notes/{id}/void)
+func VoidCreditNoteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid credit note ID", http.StatusBadRequest)
+		return
+	}
+	reason, ok := voidReason(w, r)
+	if !ok {
+		return
+	}
+	if err := repositories.VoidCreditNote(id, reason, currentUserID(r)); err != nil {
+		writePaymentError(w, err, "Failed to void credit note")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
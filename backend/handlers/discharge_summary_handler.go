@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+const summaryDateFormat = "2006-01-02 15:04"
+
+func formatOptional(v interface{}) string {
+	switch x := v.(type) {
+	case *int:
+		if x != nil {
+			return strconv.Itoa(*x)
+		}
+	case *float64:
+		if x != nil {
+			return strconv.FormatFloat(*x, 'f', 1, 64)
+		}
+	}
+	return "-"
+}
+
+// buildDischargeSummaryContent assembles the stay's records, prescriptions, vitals and lab results
+func buildDischargeSummaryContent(adm models.Admission) (models.DischargeSummaryContent, error) {
+	content := models.DischargeSummaryContent{
+		AdmittedAt:      adm.AdmittedAt,
+		DischargedAt:    *adm.DischargedAt,
+		AdmissionReason: adm.Reason,
+		DischargeNotes:  adm.DischargeNotes,
+	}
+
+	patient, err := repositories.GetPatientByID(adm.PatientID)
+	if err != nil {
+		return content, err
+	}
+	content.PatientName = patient.FullName
+	content.MRN = patient.MRN
+	content.DateOfBirth = patient.DateOfBirth
+	content.Gender = patient.Gender
+	if doctor, err := repositories.GetDoctorByID(adm.DoctorID); err == nil {
+		content.DoctorName = doctor.FullName
+	}
+
+	records, err := repositories.GetMedicalRecordsByPatientBetween(adm.PatientID, adm.AdmittedAt, *adm.DischargedAt)
+	if err != nil {
+		return content, err
+	}
+	for _, rec := range records {
+		if rec.Diagnosis != "" {
+			content.Diagnoses = append(content.Diagnoses, models.DischargeDiagnosis{Diagnosis: rec.Diagnosis, RecordedAt: rec.CreationDate})
+		}
+		if rec.Prescription != "" {
+			content.Medications = append(content.Medications, models.DischargeMedication{Prescription: rec.Prescription, PrescribedAt: rec.CreationDate})
+		}
+	}
+
+	if content.Vitals, err = repositories.GetVitalSignsByPatientID(adm.PatientID, adm.ID); err != nil {
+		return content, err
+	}
+
+	labs, err := repositories.GetLabResultsByPatientBetween(adm.PatientID, adm.AdmittedAt, *adm.DischargedAt)
+	if err != nil {
+		return content, err
+	}
+	for _, l := range labs {
+		content.LabResults = append(content.LabResults, models.DischargeLabResult{
+			TestName:       l.TestName,
+			Value:          l.Value,
+			Units:          l.Units,
+			ReferenceRange: l.ReferenceRange,
+			AbnormalFlag:   l.AbnormalFlag,
+			ObservedAt:     l.ObservedAt,
+		})
+	}
+	return content, nil
+}
+
+// renderDischargeSummaryPDF renders the summary; signerName adds the signature block
+func renderDischargeSummaryPDF(s models.DischargeSummary, signerName string) ([]byte, error) {
+	c := s.Content
+	doc := utils.NewPDFDocument(utils.DefaultLetterhead(), "Discharge Summary")
+
+	doc.KeyValue("Patient", c.PatientName)
+	doc.KeyValue("MRN", c.MRN)
+	doc.KeyValue("Date of birth", c.DateOfBirth)
+	doc.KeyValue("Gender", c.Gender)
+	doc.KeyValue("Attending doctor", c.DoctorName)
+	doc.KeyValue("Admitted", c.AdmittedAt.Format(summaryDateFormat))
+	doc.KeyValue("Discharged", c.DischargedAt.Format(summaryDateFormat))
+
+	doc.Heading("Reason for admission")
+	doc.Paragraph(c.AdmissionReason)
+
+	doc.Heading("Diagnoses")
+	if len(c.Diagnoses) == 0 {
+		doc.Paragraph("No diagnoses recorded during the stay.")
+	}
+	for _, d := range c.Diagnoses {
+		doc.Paragraph(d.RecordedAt.Format("2006-01-02") + "  " + d.Diagnosis)
+	}
+
+	doc.Heading("Clinical course")
+	doc.Paragraph(c.ClinicalCourse)
+
+	doc.Heading("Medications")
+	if len(c.Medications) == 0 {
+		doc.Paragraph("No prescriptions during the stay.")
+	}
+	for _, m := range c.Medications {
+		doc.Paragraph(m.PrescribedAt.Format("2006-01-02") + "  " + m.Prescription)
+	}
+
+	if len(c.Vitals) > 0 {
+		doc.Heading("Vital signs")
+		rows := make([][]string, 0, len(c.Vitals))
+		for _, v := range c.Vitals {
+			bp := "-"
+			if v.SystolicBP != nil && v.DiastolicBP != nil {
+				bp = fmt.Sprintf("%d/%d", *v.SystolicBP, *v.DiastolicBP)
+			}
+			rows = append(rows, []string{
+				v.RecordedAt.Format(summaryDateFormat),
+				formatOptional(v.Temperature),
+				formatOptional(v.HeartRate),
+				formatOptional(v.RespiratoryRate),
+				bp,
+				formatOptional(v.SpO2),
+			})
+		}
+		doc.Table(
+			[]string{"Recorded", "Temp (C)", "HR", "RR", "BP", "SpO2 (%)"},
+			[]float64{40, 25, 25, 25, 35, 30},
+			[]string{"L", "R", "R", "R", "C", "R"},
+			rows,
+		)
+	}
+
+	if len(c.LabResults) > 0 {
+		doc.Heading("Lab results")
+		rows := make([][]string, 0, len(c.LabResults))
+		for _, l := range c.LabResults {
+			rows = append(rows, []string{
+				l.ObservedAt.Format(summaryDateFormat),
+				l.TestName,
+				l.Value + " " + l.Units,
+				l.ReferenceRange,
+				l.AbnormalFlag,
+			})
+		}
+		doc.Table(
+			[]string{"Observed", "Test", "Value", "Reference", "Flag"},
+			[]float64{35, 55, 35, 40, 15},
+			[]string{"L", "L", "R", "L", "C"},
+			rows,
+		)
+	}
+
+	doc.Heading("Discharge notes")
+	doc.Paragraph(c.DischargeNotes)
+	doc.Heading("Instructions")
+	doc.Paragraph(c.Instructions)
+	doc.Heading("Follow-up")
+	doc.Paragraph(c.FollowUp)
+
+	if signerName != "" && s.SignedAt != nil {
+		doc.Space(8)
+		doc.KeyValue("Electronically signed", signerName)
+		doc.KeyValue("Signed at", s.SignedAt.Format(summaryDateFormat))
+	} else {
+		doc.Space(8)
+		doc.Paragraph("DRAFT - not signed")
+	}
+	return doc.Bytes()
+}
+
+func dischargeSummaryFileName(s models.DischargeSummary, suffix string) string {
+	return fmt.Sprintf("discharge-summary-%d-%s-%d.pdf", s.AdmissionID, suffix, time.Now().UnixNano())
+}
+
+// GenerateDischargeSummaryHandler (POST /admissions/{id}/discharge-summary)
+// assembles the summary of a discharged admission and renders a draft PDF
+func GenerateDischargeSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid admission ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		ClinicalCourse string `json:"clinical_course"`
+		Instructions   string `json:"instructions"`
+		FollowUp       string `json:"follow_up"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	adm, err := repositories.GetAdmissionByID(id)
+	if err != nil {
+		http.Error(w, "Admission not found", http.StatusNotFound)
+		return
+	}
+	if adm.Status != "discharged" || adm.DischargedAt == nil {
+		http.Error(w, "Admission has not been discharged yet", http.StatusConflict)
+		return
+	}
+	if existing, err := repositories.GetDischargeSummaryByAdmissionID(id); err == nil && existing.Status == "signed" {
+		http.Error(w, repositories.ErrDischargeSummarySigned.Error(), http.StatusConflict)
+		return
+	}
+
+	content, err := buildDischargeSummaryContent(adm)
+	if err != nil {
+		http.Error(w, "Failed to assemble discharge summary", http.StatusInternalServerError)
+		return
+	}
+	content.ClinicalCourse = payload.ClinicalCourse
+	content.Instructions = payload.Instructions
+	content.FollowUp = payload.FollowUp
+
+	summary := models.DischargeSummary{
+		AdmissionID: adm.ID,
+		PatientID:   adm.PatientID,
+		DoctorID:    adm.DoctorID,
+		Content:     content,
+	}
+	data, err := renderDischargeSummaryPDF(summary, "")
+	if err != nil {
+		http.Error(w, "Failed to render discharge summary", http.StatusInternalServerError)
+		return
+	}
+	fileName := dischargeSummaryFileName(summary, "draft")
+	path, err := writeGeneratedFile(fileName, data)
+	if err != nil {
+		http.Error(w, "Failed to store discharge summary", http.StatusInternalServerError)
+		return
+	}
+
+	file := models.File{
+		PatientID:   adm.PatientID,
+		DoctorID:    adm.DoctorID,
+		FileName:    fileName,
+		FileType:    "application/pdf",
+		FileURL:     path,
+		Description: "Discharge summary (draft)",
+		UploadDate:  time.Now(),
+	}
+	replaced, err := repositories.SaveDischargeSummaryDraft(&summary, &file)
+	if err != nil {
+		removeGeneratedFile(path)
+		if errors.Is(err, repositories.ErrDischargeSummarySigned) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to save discharge summary", http.StatusInternalServerError)
+		return
+	}
+	removeGeneratedFile(replaced)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(summary)
+}
+
+// GetDischargeSummaryByAdmissionHandler (GET /admissions/{id}/discharge-summary)
+func GetDischargeSummaryByAdmissionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid admission ID", http.StatusBadRequest)
+		return
+	}
+	summary, err := repositories.GetDischargeSummaryByAdmissionID(id)
+	if err != nil {
+		http.Error(w, "Discharge summary not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(summary)
+}
+
+// GetDischargeSummaryPDFHandler (GET /discharge-summaries/{id}/pdf)
+func GetDischargeSummaryPDFHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid discharge summary ID", http.StatusBadRequest)
+		return
+	}
+	summary, err := repositories.GetDischargeSummaryByID(id)
+	if err != nil || summary.FileID == nil {
+		http.Error(w, "Discharge summary not found", http.StatusNotFound)
+		return
+	}
+	file, err := repositories.GetFileByID(*summary.FileID)
+	if err != nil {
+		http.Error(w, "Discharge summary file not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "inline; filename=\""+file.FileName+"\"")
+	http.ServeFile(w, r, file.FileURL)
+}
+
+// SignDischargeSummaryHandler (POST /doctor/discharge-summaries/{id}/sign)
+// renders the final PDF with the signature block and locks the summary
+func SignDischargeSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid discharge summary ID", http.StatusBadRequest)
+		return
+	}
+	summary, err := repositories.GetDischargeSummaryByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Discharge summary not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error fetching discharge summary", http.StatusInternalServerError)
+		return
+	}
+	if summary.Status == "signed" {
+		http.Error(w, repositories.ErrDischargeSummarySigned.Error(), http.StatusConflict)
+		return
+	}
+	// only the attending doctor of the admission may sign its summary
+	if doctorID := currentDoctorID(r); doctorID == 0 || doctorID != summary.DoctorID {
+		http.Error(w, "Only the attending doctor can sign this discharge summary", http.StatusForbidden)
+		return
+	}
+
+	userID := currentUserID(r)
+	signer := "User #" + strconv.Itoa(userID)
+	if doctor, err := repositories.GetDoctorByUserID(userID); err == nil {
+		signer = doctor.FullName
+	}
+
+	signedAt := time.Now()
+	summary.SignedAt = &signedAt
+	data, err := renderDischargeSummaryPDF(summary, signer)
+	if err != nil {
+		http.Error(w, "Failed to render discharge summary", http.StatusInternalServerError)
+		return
+	}
+	fileName := dischargeSummaryFileName(summary, "signed")
+	path, err := writeGeneratedFile(fileName, data)
+	if err != nil {
+		http.Error(w, "Failed to store discharge summary", http.StatusInternalServerError)
+		return
+	}
+
+	signed, replaced, err := repositories.SignDischargeSummary(id, userID, signedAt, fileName, path)
+	if err != nil {
+		removeGeneratedFile(path)
+		if errors.Is(err, repositories.ErrDischargeSummarySigned) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to sign discharge summary", http.StatusInternalServerError)
+		return
+	}
+
+	removeGeneratedFile(replaced)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(signed)
+}
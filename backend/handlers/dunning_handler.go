@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/jobs"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+var communicationChannels = map[string]bool{"email": true, "letter": true, "phone": true, "system": true}
+
+var communicationStatuses = map[string]bool{"sent": true, "queued": true, "failed": true, "logged": true}
+
+// GetInvoiceCommunicationsHandler (GET /invoices/{id}/communications) returns the reminders,
+// late fees, collections notices and notes of an invoice
+func GetInvoiceCommunicationsHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetInvoiceCommunications(invoiceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch invoice communications", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// CreateInvoiceCommunicationHandler (POST /invoices/{id}/communications) logs a contact made
+// by staff, e.g. a phone call about the balance
+// body: {"channel": "phone", "subject": "Called patient", "body": "Promised to pay by Friday"}
+func CreateInvoiceCommunicationHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Channel   string `json:"channel"`
+		Recipient string `json:"recipient"`
+		Subject   string `json:"subject"`
+		Body      string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !communicationChannels[payload.Channel] || payload.Channel == "system" {
+		http.Error(w, "channel must be email, letter or phone", http.StatusBadRequest)
+		return
+	}
+	if payload.Subject == "" && payload.Body == "" {
+		http.Error(w, "subject or body is required", http.StatusBadRequest)
+		return
+	}
+	inv, err := repositories.GetInvoiceByID(invoiceID)
+	if err != nil {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	userID := currentUserID(r)
+	entry := models.InvoiceCommunication{
+		InvoiceID: inv.ID,
+		PatientID: inv.PatientID,
+		Kind:      "note",
+		Channel:   payload.Channel,
+		Recipient: payload.Recipient,
+		Subject:   payload.Subject,
+		Body:      payload.Body,
+		Status:    "logged",
+		SentAt:    &now,
+		CreatedBy: &userID,
+	}
+	if err := repositories.CreateInvoiceCommunication(&entry); err != nil {
+		http.Error(w, "Failed to log communication", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+// GetCommunicationsHandler (GET /invoice-communications?channel=letter&status=queued)
+// lists log entries across invoices, e.g. the letters waiting to be printed
+func GetCommunicationsHandler(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	status := r.URL.Query().Get("status")
+	if channel != "" && !communicationChannels[channel] {
+		http.Error(w, "channel must be email, letter, phone or system", http.StatusBadRequest)
+		return
+	}
+	if status != "" && !communicationStatuses[status] {
+		http.Error(w, "status must be sent, queued, failed or logged", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetCommunications(channel, status)
+	if err != nil {
+		http.Error(w, "Failed to fetch invoice communications", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetDunningLetterPDFHandler (GET /invoice-communications/{id}/letter.pdf?archive=true)
+// renders a reminder or collections notice as a printable letter
+func GetDunningLetterPDFHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid communication ID", http.StatusBadRequest)
+		return
+	}
+	entry, err := repositories.GetInvoiceCommunicationByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch communication", http.StatusInternalServerError)
+		return
+	}
+	if entry.Kind != "reminder" && entry.Kind != "collections" {
+		http.Error(w, "only reminders and collections notices can be printed", http.StatusUnprocessableEntity)
+		return
+	}
+	pdf := utils.NewPDFDocument(utils.DefaultLetterhead(), entry.Subject)
+	pdf.Paragraph(entry.Recipient)
+	if entry.Address != "" {
+		pdf.Paragraph(entry.Address)
+	}
+	pdf.Space(4)
+	pdf.Paragraph(entry.CreatedAt.Format("2006-01-02"))
+	pdf.Space(4)
+	pdf.Paragraph(entry.Body)
+	data, err := pdf.Bytes()
+	if err != nil {
+		http.Error(w, "Failed to render letter", http.StatusInternalServerError)
+		return
+	}
+	fileName := fmt.Sprintf("dunning-letter-%d-%d.pdf", entry.ID, time.Now().Unix())
+	writePDF(w, r, data, fileName, entry.PatientID, entry.Subject)
+}
+
+// MarkLetterSentHandler (POST /invoice-communications/{id}/sent) records that a queued letter was posted
+func MarkLetterSentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid communication ID", http.StatusBadRequest)
+		return
+	}
+	entry, err := repositories.MarkLetterSent(id, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrCommunicationNotQueued):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to update communication", http.StatusInternalServerError)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// RunDunningHandler (POST /admin/dunning/run) starts a dunning run outside the daily schedule
+// and returns the policy it applies
+func RunDunningHandler(w http.ResponseWriter, r *http.Request) {
+	go jobs.RunDunning(context.Background())
+	writeJSON(w, http.StatusAccepted, utils.LoadDunningPolicy())
+}
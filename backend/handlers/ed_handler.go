@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+func writeEDError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrEDEncounterState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "ED encounter not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// RegisterEDArrivalHandler (POST /ed/encounters)
+// Either patient_id or temporary_patient must be given; the latter registers an
+// unidentified patient that can later be merged via POST /patients/{id}/merge.
+func RegisterEDArrivalHandler(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		PatientID        int        `json:"patient_id"`
+		ArrivalAt        *time.Time `json:"arrival_at"`
+		ArrivalMode      string     `json:"arrival_mode"`
+		ChiefComplaint   string     `json:"chief_complaint"`
+		TemporaryPatient *struct {
+			Gender       string `json:"gender"`
+			EstimatedAge int    `json:"estimated_age"`
+			Description  string `json:"description"`
+		} `json:"temporary_patient"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.ChiefComplaint == "" {
+		http.Error(w, "chief_complaint is required", http.StatusBadRequest)
+		return
+	}
+	if (payload.PatientID == 0) == (payload.TemporaryPatient == nil) {
+		http.Error(w, "exactly one of patient_id or temporary_patient is required", http.StatusBadRequest)
+		return
+	}
+
+	enc := models.EDEncounter{
+		PatientID:      payload.PatientID,
+		ArrivalAt:      time.Now(),
+		ArrivalMode:    payload.ArrivalMode,
+		ChiefComplaint: payload.ChiefComplaint,
+	}
+	if payload.ArrivalAt != nil {
+		enc.ArrivalAt = *payload.ArrivalAt
+	}
+	if enc.ArrivalMode == "" {
+		enc.ArrivalMode = "walk-in"
+	}
+
+	var temp *models.Patient
+	if t := payload.TemporaryPatient; t != nil {
+		temp = &models.Patient{
+			FullName: "Unknown patient " + enc.ArrivalAt.Format("2006-01-02 15:04"),
+			Gender:   t.Gender,
+			Address:  t.Description,
+		}
+		if t.EstimatedAge > 0 {
+			temp.DateOfBirth = strconv.Itoa(enc.ArrivalAt.Year()-t.EstimatedAge) + "-01-01"
+		}
+		if temp.Gender == "" {
+			temp.Gender = "unknown"
+		}
+	}
+
+	if err := repositories.RegisterEDArrival(&enc, temp); err != nil {
+		http.Error(w, "Failed to register ED arrival", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(enc)
+}
+
+// GetEDEncounterHandler (GET /ed/encounters/{id})
+func GetEDEncounterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid encounter ID", http.StatusBadRequest)
+		return
+	}
+	enc, err := repositories.GetEDEncounterByID(id)
+	if err != nil {
+		http.Error(w, "ED encounter not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(enc)
+}
+
+// TriageEDEncounterHandler (POST /ed/encounters/{id}/triage)
+func TriageEDEncounterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid encounter ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		TriageLevel int               `json:"triage_level"`
+		Vitals      *models.VitalSign `json:"vitals"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.TriageLevel < 1 || payload.TriageLevel > 5 {
+		http.Error(w, "triage_level must be between 1 (resuscitation) and 5 (non-urgent)", http.StatusBadRequest)
+		return
+	}
+
+	enc, err := repositories.TriageEDEncounter(id, payload.TriageLevel, payload.Vitals, currentUserID(r))
+	if err != nil {
+		writeEDError(w, err, "Failed to triage patient")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(enc)
+}
+
+// AssignEDDoctorHandler (POST /ed/encounters/{id}/assign)
+func AssignEDDoctorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid encounter ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		DoctorID int `json:"doctor_id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	if payload.DoctorID == 0 {
+		payload.DoctorID = currentDoctorID(r)
+	}
+	if payload.DoctorID == 0 {
+		http.Error(w, "doctor_id is required", http.StatusBadRequest)
+		return
+	}
+
+	enc, err := repositories.AssignEDDoctor(id, payload.DoctorID)
+	if err != nil {
+		writeEDError(w, err, "Failed to assign doctor")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(enc)
+}
+
+// DepartEDEncounterHandler (POST /ed/encounters/{id}/depart)
+func DepartEDEncounterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid encounter ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Disposition string `json:"disposition"`
+		AdmissionID *int   `json:"admission_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch payload.Disposition {
+	case "discharged", "admitted", "transferred", "left_without_being_seen", "deceased":
+	default:
+		http.Error(w, "disposition must be discharged, admitted, transferred, left_without_being_seen or deceased", http.StatusBadRequest)
+		return
+	}
+
+	enc, err := repositories.DepartEDEncounter(id, payload.Disposition, payload.AdmissionID)
+	if err != nil {
+		writeEDError(w, err, "Failed to close ED encounter")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(enc)
+}
+
+// GetEDBoardHandler (GET /ed/board) returns the tracking board ordered by acuity and wait time
+func GetEDBoardHandler(w http.ResponseWriter, r *http.Request) {
+	board, err := repositories.GetEDBoard()
+	if err != nil {
+		http.Error(w, "Failed to fetch ED board", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(board)
+}
+
+// GetEDMetricsHandler (GET /ed/metrics?from=2025-01-01&to=2025-02-01), defaults to the last 30 days
+func GetEDMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -30)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "from must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "to must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+
+	overall, byLevel, err := repositories.GetEDMetrics(from, to)
+	if err != nil {
+		http.Error(w, "Failed to compute ED metrics", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":     from.Format("2006-01-02"),
+		"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"overall":  overall,
+		"by_level": byLevel,
+	})
+}
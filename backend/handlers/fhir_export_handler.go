@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/jobs"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+type exportManifestOutput struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Count int    `json:"count"`
+}
+
+type exportManifest struct {
+	TransactionTime     string                 `json:"transactionTime"`
+	Request             string                 `json:"request"`
+	RequiresAccessToken bool                   `json:"requiresAccessToken"`
+	Output              []exportManifestOutput `json:"output"`
+	Error               []exportManifestOutput `json:"error"`
+}
+
+func newExportJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FHIRExportHandler kicks off a system level export (GET /fhir/R4/$export)
+func FHIRExportHandler(w http.ResponseWriter, r *http.Request) {
+	kickOffExport(w, r, "system")
+}
+
+// FHIRPatientExportHandler kicks off a patient level export (GET /fhir/R4/Patient/$export)
+func FHIRPatientExportHandler(w http.ResponseWriter, r *http.Request) {
+	kickOffExport(w, r, "patient")
+}
+
+func kickOffExport(w http.ResponseWriter, r *http.Request, level string) {
+	if !strings.Contains(r.Header.Get("Prefer"), "respond-async") {
+		writeFHIRError(w, http.StatusBadRequest, "invalid", "Prefer: respond-async header is required")
+		return
+	}
+
+	q := r.URL.Query()
+	switch q.Get("_outputFormat") {
+	case "", "application/fhir+ndjson", "application/ndjson", "ndjson":
+	default:
+		writeFHIRError(w, http.StatusBadRequest, "not-supported", "Only application/fhir+ndjson output is supported")
+		return
+	}
+
+	types := jobs.ExportTypes
+	if v := q.Get("_type"); v != "" {
+		types = nil
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			supported := false
+			for _, s := range jobs.ExportTypes {
+				supported = supported || s == t
+			}
+			if !supported {
+				writeFHIRError(w, http.StatusBadRequest, "not-supported", "Resource type "+t+" cannot be exported")
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	job := models.ExportJob{
+		Level:           level,
+		Types:           strings.Join(types, ","),
+		Status:          "in-progress",
+		Progress:        "queued",
+		RequestURL:      fhirBaseURL(r) + r.URL.RequestURI(),
+		TransactionTime: time.Now().UTC(),
+	}
+	if v := q.Get("_since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeFHIRError(w, http.StatusBadRequest, "invalid", "_since must be a FHIR instant")
+			return
+		}
+		job.Since = &since
+	}
+	if v := q.Get("patient"); v != "" {
+		if level != "patient" {
+			writeFHIRError(w, http.StatusBadRequest, "invalid", "patient parameter is only allowed on Patient/$export")
+			return
+		}
+		id, err := referenceID(v)
+		if err != nil {
+			writeFHIRError(w, http.StatusBadRequest, "invalid", "invalid patient reference")
+			return
+		}
+		if !requireFHIRConsent(w, id, models.ConsentDataSharing, models.ConsentScopeBulkExport) {
+			return
+		}
+		job.PatientID = &id
+	}
+	if claims := claimsFromRequest(r); claims != nil {
+		job.RequestedBy = claims.UserID
+	}
+
+	id, err := newExportJobID()
+	if err != nil {
+		writeFHIRError(w, http.StatusInternalServerError, "exception", "Failed to create export job")
+		return
+	}
+	job.ID = id
+	if err := repositories.CreateExportJob(&job); err != nil {
+		writeFHIRError(w, http.StatusInternalServerError, "exception", "Failed to create export job")
+		return
+	}
+
+	jobs.StartFHIRExport(job, types, fhirBaseURL(r))
+
+	w.Header().Set("Content-Location", fhirBaseURL(r)+"/fhir/R4/$export-status/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// FHIRExportStatusHandler reports job progress or returns the completion manifest
+func FHIRExportStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, err := repositories.GetExportJobByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeFHIRError(w, http.StatusNotFound, "not-found", "Export job not found")
+			return
+		}
+		writeFHIRError(w, http.StatusInternalServerError, "exception", "Failed to fetch export job")
+		return
+	}
+
+	switch job.Status {
+	case "in-progress":
+		w.Header().Set("X-Progress", job.Progress)
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case "failed":
+		writeFHIRError(w, http.StatusInternalServerError, "exception", "Export failed: "+job.Error)
+		return
+	}
+
+	manifest := exportManifest{
+		TransactionTime:     job.TransactionTime.Format(time.RFC3339),
+		Request:             job.RequestURL,
+		RequiresAccessToken: true,
+		Output:              []exportManifestOutput{},
+		Error:               []exportManifestOutput{},
+	}
+	base := fhirBaseURL(r)
+	for _, f := range job.Files {
+		manifest.Output = append(manifest.Output, exportManifestOutput{
+			Type:  f.ResourceType,
+			URL:   base + "/fhir/R4/$export-file/" + job.ID + "/" + f.FileName,
+			Count: f.Count,
+		})
+	}
+	if job.CompletedAt != nil {
+		w.Header().Set("Expires", job.CompletedAt.Add(24*time.Hour).UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(manifest)
+}
+
+// FHIRExportCancelHandler cancels a running job or deletes a finished one
+func FHIRExportCancelHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, err := repositories.GetExportJobByID(id)
+	if err != nil {
+		writeFHIRError(w, http.StatusNotFound, "not-found", "Export job not found")
+		return
+	}
+
+	if !jobs.CancelFHIRExport(job.ID) {
+		if err := repositories.DeleteExportJob(job.ID); err != nil {
+			writeFHIRError(w, http.StatusInternalServerError, "exception", "Failed to delete export job")
+			return
+		}
+		for _, f := range job.Files {
+			_ = os.Remove(f.Path)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// FHIRExportFileHandler serves one NDJSON output file
+func FHIRExportFileHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	job, err := repositories.GetExportJobByID(vars["id"])
+	if err != nil || job.Status != "completed" {
+		writeFHIRError(w, http.StatusNotFound, "not-found", "Export file not found")
+		return
+	}
+	for _, f := range job.Files {
+		if f.FileName == vars["file"] {
+			w.Header().Set("Content-Type", "application/fhir+ndjson")
+			w.Header().Set("X-Resource-Count", strconv.Itoa(f.Count))
+			http.ServeFile(w, r, f.Path)
+			return
+		}
+	}
+	writeFHIRError(w, http.StatusNotFound, "not-found", "Export file not found")
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/fhir"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+)
+
+const (
+	fhirDefaultCount = 20
+	fhirMaxCount     = 100
+)
+
+type fhirEntry struct {
+	id        string
+	patientID int // 0 for resources not tied to a patient
+	resource  interface{}
+}
+
+func writeFHIR(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", fhir.ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeFHIRError(w http.ResponseWriter, status int, code, diagnostics string) {
+	writeFHIR(w, status, fhir.NewOperationOutcome("error", code, diagnostics))
+}
+
+// fhirBaseURL returns the absolute server root, e.g. https://hms.example.org. FHIR_BASE_URL
+// wins when set; otherwise X-Forwarded-Proto and X-Forwarded-Host are only honoured from a
+// trusted proxy, so a client cannot point the links of a Bundle at another server.
+func fhirBaseURL(r *http.Request) string {
+	if base := os.Getenv("FHIR_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if utils.RemoteAddrAllowed(r.RemoteAddr, utils.TrustedProxies()) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwdHost := r.Header.Get("X-Forwarded-Host"); fwdHost != "" {
+			host = fwdHost
+		}
+	}
+	return scheme + "://" + host
+}
+
+// referenceID accepts "123" or "Patient/123" style reference parameters
+func referenceID(v string) (int, error) {
+	if i := strings.LastIndex(v, "/"); i >= 0 {
+		v = v[i+1:]
+	}
+	return strconv.Atoi(v)
+}
+
+// parseFHIRDate applies a FHIR date search value (with optional ge/gt/le/lt/eq prefix) to the search range
+func parseFHIRDate(s *repositories.FHIRSearch, v string) error {
+	prefix := "eq"
+	if len(v) > 2 {
+		switch v[:2] {
+		case "eq", "ge", "gt", "le", "lt":
+			prefix, v = v[:2], v[2:]
+		}
+	}
+
+	var (
+		start time.Time
+		end   time.Time
+		err   error
+	)
+	switch len(v) {
+	case 4:
+		start, err = time.Parse("2006", v)
+		end = start.AddDate(1, 0, 0)
+	case 7:
+		start, err = time.Parse("2006-01", v)
+		end = start.AddDate(0, 1, 0)
+	case 10:
+		start, err = time.Parse("2006-01-02", v)
+		end = start.AddDate(0, 0, 1)
+	default:
+		start, err = time.Parse(time.RFC3339, v)
+		end = start.Add(time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid date %q", v)
+	}
+
+	switch prefix {
+	case "eq":
+		s.DateFrom, s.DateTo = &start, &end
+	case "ge":
+		s.DateFrom = &start
+	case "gt":
+		s.DateFrom = &end
+	case "le":
+		s.DateTo = &end
+	case "lt":
+		s.DateTo = &start
+	default:
+		return fmt.Errorf("unsupported date prefix %q", prefix)
+	}
+	return nil
+}
+
+// fhirStatusesFor maps FHIR appointment status codes back to stored statuses
+func fhirStatusesFor(code string) []string {
+	switch code {
+	case "booked":
+		return []string{"scheduled"}
+	case "fulfilled":
+		return []string{"completed"}
+	case "cancelled":
+		return []string{"cancelled", "canceled"}
+	case "noshow":
+		return []string{"no-show"}
+	default:
+		return []string{code}
+	}
+}
+
+func parseFHIRSearch(q url.Values) (repositories.FHIRSearch, error) {
+	s := repositories.FHIRSearch{Count: fhirDefaultCount}
+
+	if v := q.Get("_count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return s, fmt.Errorf("invalid _count %q", v)
+		}
+		if n > fhirMaxCount {
+			n = fhirMaxCount
+		}
+		s.Count = n
+	}
+	if v := q.Get("_offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return s, fmt.Errorf("invalid _offset %q", v)
+		}
+		s.Offset = n
+	}
+	if v := q.Get("_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return s, fmt.Errorf("invalid _id %q", v)
+		}
+		s.ID = id
+	}
+	for _, name := range []string{"patient", "subject"} {
+		if v := q.Get(name); v != "" {
+			id, err := referenceID(v)
+			if err != nil {
+				return s, fmt.Errorf("invalid %s reference %q", name, v)
+			}
+			s.PatientID = id
+		}
+	}
+	for _, name := range []string{"practitioner", "recorder", "requester", "actor"} {
+		if v := q.Get(name); v != "" {
+			id, err := referenceID(v)
+			if err != nil {
+				return s, fmt.Errorf("invalid %s reference %q", name, v)
+			}
+			s.PractitionerID = id
+		}
+	}
+	if v := q.Get("identifier"); v != "" {
+		if i := strings.LastIndex(v, "|"); i >= 0 {
+			v = v[i+1:]
+		}
+		s.Identifier = v
+	}
+	s.Name = q.Get("name")
+	s.BirthDate = q.Get("birthdate")
+	s.Code = q.Get("code")
+
+	if v := q.Get("gender"); v != "" {
+		switch v {
+		case "male":
+			s.Gender = []string{"male", "m"}
+		case "female":
+			s.Gender = []string{"female", "f"}
+		case "other":
+			s.Gender = []string{"other", "o"}
+		default:
+			s.Gender = []string{v}
+		}
+	}
+	if v := q.Get("status"); v != "" {
+		for _, code := range strings.Split(v, ",") {
+			s.Status = append(s.Status, fhirStatusesFor(code)...)
+		}
+	}
+	if v := q.Get("active"); v != "" {
+		active := v == "true"
+		s.Active = &active
+	}
+	for _, v := range q["date"] {
+		if err := parseFHIRDate(&s, v); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// searchFHIRResources runs a search for a resource type and maps the rows to FHIR resources
+func searchFHIRResources(resourceType string, s repositories.FHIRSearch, base string) ([]fhirEntry, int64, error) {
+	var entries []fhirEntry
+
+	switch resourceType {
+	case "Patient":
+		list, total, err := repositories.SearchPatientsFHIR(s)
+		for _, p := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(p.ID), p.ID, fhir.PatientResource(p)})
+		}
+		return entries, total, err
+	case "Practitioner":
+		list, total, err := repositories.SearchDoctorsFHIR(s)
+		for _, d := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(d.ID), 0, fhir.PractitionerResource(d)})
+		}
+		return entries, total, err
+	case "Appointment":
+		list, total, err := repositories.SearchAppointmentsFHIR(s)
+		for _, a := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(int(a.ID)), int(a.PatientID), fhir.AppointmentResource(a)})
+		}
+		return entries, total, err
+	case "Encounter":
+		list, total, err := repositories.SearchAppointmentsFHIR(s)
+		for _, a := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(int(a.ID)), int(a.PatientID), fhir.EncounterResource(a)})
+		}
+		return entries, total, err
+	case "Condition":
+		list, total, err := repositories.SearchMedicalRecordsFHIR(s, false)
+		for _, m := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(m.ID), m.PatientID, fhir.ConditionResource(m)})
+		}
+		return entries, total, err
+	case "MedicationRequest":
+		list, total, err := repositories.SearchMedicalRecordsFHIR(s, true)
+		for _, m := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(m.ID), m.PatientID, fhir.MedicationRequestResource(m)})
+		}
+		return entries, total, err
+	case "DocumentReference":
+		list, total, err := repositories.SearchFilesFHIR(s)
+		for _, f := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(f.ID), f.PatientID, fhir.DocumentReferenceResource(f, base)})
+		}
+		return entries, total, err
+	case "Observation":
+		list, total, err := repositories.SearchLabResultsFHIR(s)
+		for _, l := range list {
+			entries = append(entries, fhirEntry{strconv.Itoa(l.ID), l.PatientID, fhir.ObservationResource(l)})
+		}
+		return entries, total, err
+	}
+	return nil, 0, fmt.Errorf("unsupported resource type %s", resourceType)
+}
+
+func isSupportedFHIRType(resourceType string) bool {
+	_, ok := fhir.SearchParams[resourceType]
+	return ok
+}
+
+// FHIRCapabilityHandler (GET /fhir/R4/metadata)
+func FHIRCapabilityHandler(w http.ResponseWriter, r *http.Request) {
+	writeFHIR(w, http.StatusOK, fhir.NewCapabilityStatement())
+}
+
+// FHIRReadHandler (GET /fhir/R4/{type}/{id})
+func FHIRReadHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	resourceType := vars["type"]
+	if !isSupportedFHIRType(resourceType) {
+		writeFHIRError(w, http.StatusNotFound, "not-supported", "Resource type "+resourceType+" is not supported")
+		return
+	}
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeFHIRError(w, http.StatusNotFound, "not-found", resourceType+"/"+vars["id"]+" not found")
+		return
+	}
+
+	entries, _, err := searchFHIRResources(resourceType, repositories.FHIRSearch{ID: id, Count: 1}, fhirBaseURL(r))
+	if err != nil {
+		writeFHIRError(w, http.StatusInternalServerError, "exception", "Failed to read resource")
+		return
+	}
+	if len(entries) == 0 {
+		writeFHIRError(w, http.StatusNotFound, "not-found", resourceType+"/"+vars["id"]+" not found")
+		return
+	}
+	if p := entries[0].patientID; p != 0 && !requireFHIRConsent(w, p, models.ConsentDataSharing, models.ConsentScopeFHIRAPI) {
+		return
+	}
+	writeFHIR(w, http.StatusOK, entries[0].resource)
+}
+
+// FHIRSearchHandler (GET /fhir/R4/{type}?params)
+func FHIRSearchHandler(w http.ResponseWriter, r *http.Request) {
+	resourceType := mux.Vars(r)["type"]
+	if !isSupportedFHIRType(resourceType) {
+		writeFHIRError(w, http.StatusNotFound, "not-supported", "Resource type "+resourceType+" is not supported")
+		return
+	}
+
+	query := r.URL.Query()
+	s, err := parseFHIRSearch(query)
+	if err != nil {
+		writeFHIRError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+	// a search for one patient is refused outright; wider searches skip unconsented patients
+	if s.PatientID != 0 && !requireFHIRConsent(w, s.PatientID, models.ConsentDataSharing, models.ConsentScopeFHIRAPI) {
+		return
+	}
+	s.ConsentType = models.ConsentDataSharing
+	s.ConsentScope = models.ConsentScopeFHIRAPI
+
+	base := fhirBaseURL(r)
+	entries, total, err := searchFHIRResources(resourceType, s, base)
+	if err != nil {
+		writeFHIRError(w, http.StatusInternalServerError, "exception", "Search failed")
+		return
+	}
+
+	bundle := fhir.Bundle{ResourceType: "Bundle", Type: "searchset", Total: &total}
+	selfURL := base + r.URL.Path
+	pageURL := func(offset int) string {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("_count", strconv.Itoa(s.Count))
+		q.Set("_offset", strconv.Itoa(offset))
+		return selfURL + "?" + q.Encode()
+	}
+	bundle.Link = append(bundle.Link, fhir.BundleLink{Relation: "self", URL: pageURL(s.Offset)})
+	if s.Count > 0 && int64(s.Offset+s.Count) < total {
+		bundle.Link = append(bundle.Link, fhir.BundleLink{Relation: "next", URL: pageURL(s.Offset + s.Count)})
+	}
+	if s.Offset > 0 {
+		prev := s.Offset - s.Count
+		if prev < 0 {
+			prev = 0
+		}
+		bundle.Link = append(bundle.Link, fhir.BundleLink{Relation: "previous", URL: pageURL(prev)})
+	}
+
+	for _, e := range entries {
+		bundle.Entry = append(bundle.Entry, fhir.BundleEntry{
+			FullURL:  base + "/fhir/R4/" + resourceType + "/" + e.id,
+			Resource: e.resource,
+			Search:   &fhir.BundleEntrySearch{Mode: "match"},
+		})
+	}
+	writeFHIR(w, http.StatusOK, bundle)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"io"
+	"log"
 
 	"github.com/gorilla/mux"
 	"github.com/samichen99/HAP-hospital-management-system/models"
@@ -84,6 +85,16 @@ func DeleteFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	locked, err := repositories.IsFileLocked(id)
+	if err != nil {
+		http.Error(w, "Error checking file", http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		http.Error(w, "File belongs to a signed document and cannot be deleted", http.StatusConflict)
+		return
+	}
+
 	if err := repositories.DeleteFile(id); err != nil {
 		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
 		return
@@ -109,6 +120,16 @@ func UpdateFileHandler(w http.ResponseWriter, r *http.Request) {
 
 	file.ID = id // ensure we’re updating the correct file
 
+	locked, err := repositories.IsFileLocked(id)
+	if err != nil {
+		http.Error(w, "Error checking file", http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		http.Error(w, "File belongs to a signed document and cannot be modified", http.StatusConflict)
+		return
+	}
+
 	if err := repositories.UpdateFile(file); err != nil {
 		http.Error(w, "Failed to update file", http.StatusInternalServerError)
 		return
@@ -190,4 +211,27 @@ func DownloadFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	http.ServeFile(w, r, file.FileURL)
-}
\ No newline at end of file
+}
+
+// writeGeneratedFile stores a document generated by the server in the uploads directory
+// and returns its path, ready to be used as models.File.FileURL
+func writeGeneratedFile(fileName string, data []byte) (string, error) {
+	if err := os.MkdirAll("./uploads", os.ModePerm); err != nil {
+		return "", err
+	}
+	filePath := fmt.Sprintf("./uploads/%s", fileName)
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// removeGeneratedFile deletes a generated document that is no longer referenced
+func removeGeneratedFile(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing generated file %s: %v", path, err)
+	}
+}
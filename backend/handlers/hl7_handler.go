@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+// errHL7Rejected marks messages we refuse outright (AR) rather than fail to apply (AE)
+var errHL7Rejected = errors.New("message rejected")
+
+// HandleInboundHL7 parses and applies one HL7 message and returns the ACK/NAK to send back.
+// Messages that cannot be parsed or matched are parked in the error queue.
+func HandleInboundHL7(raw, source string) (string, string) {
+	msg, err := utils.ParseHL7(raw)
+	if err != nil {
+		parkHL7(raw, source, nil, err)
+		return utils.BuildHL7Ack(nil, "AR", err.Error()), "AR"
+	}
+
+	if err := applyHL7Message(msg); err != nil {
+		parkHL7(raw, source, msg, err)
+		code := "AE"
+		if errors.Is(err, errHL7Rejected) {
+			code = "AR"
+		}
+		return utils.BuildHL7Ack(msg, code, err.Error()), code
+	}
+	return utils.BuildHL7Ack(msg, "AA", ""), "AA"
+}
+
+func applyHL7Message(msg *utils.HL7Message) error {
+	switch {
+	case msg.MessageType == "ADT" && (msg.TriggerEvent == "A01" || msg.TriggerEvent == "A04" || msg.TriggerEvent == "A08"):
+		return applyADT(msg)
+	case msg.MessageType == "ORU" && msg.TriggerEvent == "R01":
+		return applyORU(msg)
+	default:
+		return fmt.Errorf("%w: unsupported message type %s^%s", errHL7Rejected, msg.MessageType, msg.TriggerEvent)
+	}
+}
+
+func parkHL7(raw, source string, msg *utils.HL7Message, cause error) {
+	parked := models.HL7ErrorMessage{
+		Source:     source,
+		RawMessage: raw,
+		Error:      cause.Error(),
+		ReceivedAt: time.Now(),
+	}
+	if msg != nil {
+		parked.MessageControlID = msg.MessageControlID
+		parked.MessageType = msg.MessageType + "^" + msg.TriggerEvent
+	}
+	if err := repositories.ParkHL7Message(&parked); err != nil {
+		log.Printf("[hl7] failed to park message control_id=%s: %v", parked.MessageControlID, err)
+	}
+}
+
+// findPatientForHL7 matches the PID-3 identifier against the patient MRN
+func findPatientForHL7(msg *utils.HL7Message) (models.Patient, string, error) {
+	identifier := msg.Component(msg.Segment("PID").Field(3), 1)
+	if identifier == "" {
+		return models.Patient{}, "", errors.New("PID-3 patient identifier is missing")
+	}
+	patient, err := repositories.GetPatientByMRN(identifier)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return patient, identifier, err
+	}
+	return patient, identifier, nil
+}
+
+func applyADT(msg *utils.HL7Message) error {
+	patient, identifier, err := findPatientForHL7(msg)
+	if err != nil {
+		return err
+	}
+
+	if patient.ID == 0 && msg.TriggerEvent == "A08" {
+		return fmt.Errorf("no patient matches identifier %s", identifier)
+	}
+
+	patient.MRN = identifier
+	pid := msg.Segment("PID")
+
+	given := msg.Component(pid.Field(5), 2)
+	middle := msg.Component(pid.Field(5), 3)
+	family := msg.Component(pid.Field(5), 1)
+	if name := strings.Join(strings.Fields(strings.Join([]string{given, middle, family}, " ")), " "); name != "" {
+		patient.FullName = name
+	}
+	if dob := pid.Field(7); dob != "" {
+		t, err := utils.ParseHL7Time(dob)
+		if err != nil {
+			return fmt.Errorf("invalid PID-7 date of birth: %v", err)
+		}
+		patient.DateOfBirth = t.Format("2006-01-02")
+	}
+	if gender := hl7Gender(pid.Field(8)); gender != "" {
+		patient.Gender = gender
+	}
+	if address := hl7Address(msg, pid.Field(11)); address != "" {
+		patient.Address = address
+	}
+	if phone := msg.Component(pid.Field(13), 1); phone != "" {
+		patient.Phone = phone
+	}
+	if policy := msg.Segment("IN1").Field(36); policy != "" {
+		patient.InsuranceNumber = policy
+	}
+
+	if patient.FullName == "" || patient.DateOfBirth == "" {
+		return errors.New("PID-5 name and PID-7 date of birth are required")
+	}
+
+	if patient.ID == 0 {
+		return repositories.CreatePatient(&patient)
+	}
+	return repositories.UpdatePatient(&patient)
+}
+
+func applyORU(msg *utils.HL7Message) error {
+	patient, identifier, err := findPatientForHL7(msg)
+	if err != nil {
+		return err
+	}
+	if patient.ID == 0 {
+		return fmt.Errorf("no patient matches identifier %s", identifier)
+	}
+
+	var (
+		results     []models.LabResult
+		orderNumber string
+		orderTime   time.Time
+	)
+	for _, seg := range msg.Segments {
+		switch seg.Name {
+		case "OBR":
+			orderNumber = seg.Field(3)
+			if orderNumber == "" {
+				orderNumber = seg.Field(2)
+			}
+			orderTime, _ = utils.ParseHL7Time(seg.Field(7))
+		case "OBX":
+			result := models.LabResult{
+				PatientID:      patient.ID,
+				OrderNumber:    orderNumber,
+				TestCode:       msg.Component(seg.Field(3), 1),
+				TestName:       msg.Component(seg.Field(3), 2),
+				Value:          seg.Field(5),
+				Units:          msg.Component(seg.Field(6), 1),
+				ReferenceRange: seg.Field(7),
+				AbnormalFlag:   seg.Field(8),
+				Status:         seg.Field(11),
+				ObservedAt:     orderTime,
+				Source:         msg.SendingApp,
+			}
+			if t, err := utils.ParseHL7Time(seg.Field(14)); err == nil {
+				result.ObservedAt = t
+			}
+			if result.ObservedAt.IsZero() {
+				result.ObservedAt = time.Now()
+			}
+			if result.TestCode == "" {
+				return errors.New("OBX-3 observation identifier is missing")
+			}
+			results = append(results, result)
+		}
+	}
+
+	if len(results) == 0 {
+		return errors.New("ORU message contains no OBX results")
+	}
+	return repositories.CreateLabResults(results)
+}
+
+func hl7Gender(code string) string {
+	switch strings.ToUpper(code) {
+	case "M":
+		return "male"
+	case "F":
+		return "female"
+	case "O", "A":
+		return "other"
+	case "U":
+		return "unknown"
+	default:
+		return ""
+	}
+}
+
+func hl7Address(msg *utils.HL7Message, field string) string {
+	var parts []string
+	for i := 1; i <= 6; i++ {
+		if c := msg.Component(field, i); c != "" {
+			parts = append(parts, c)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ReceiveHL7MessageHandler accepts a raw ER7 message over HTTP (for testing without MLLP)
+func ReceiveHL7MessageHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil || len(body) == 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ack, code := HandleInboundHL7(string(body), "http")
+
+	w.Header().Set("Content-Type", "x-application/hl7-v2+er7")
+	switch code {
+	case "AR":
+		w.WriteHeader(http.StatusBadRequest)
+	case "AE":
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+	_, _ = w.Write([]byte(ack))
+}
+
+// GetHL7ErrorQueueHandler lists parked messages (admin only)
+func GetHL7ErrorQueueHandler(w http.ResponseWriter, r *http.Request) {
+	includeResolved := r.URL.Query().Get("include_resolved") == "true"
+	messages, err := repositories.GetHL7ErrorQueue(includeResolved)
+	if err != nil {
+		http.Error(w, "Failed to fetch HL7 error queue", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(messages)
+}
+
+// GetHL7ErrorMessageHandler returns a single parked message (admin only)
+func GetHL7ErrorMessageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+	msg, err := repositories.GetHL7ErrorMessageByID(id)
+	if err != nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(msg)
+}
+
+// RetryHL7ErrorMessageHandler reprocesses a parked message, e.g. after the patient was registered
+func RetryHL7ErrorMessageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+	parked, err := repositories.GetHL7ErrorMessageByID(id)
+	if err != nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	msg, err := utils.ParseHL7(parked.RawMessage)
+	if err == nil {
+		err = applyHL7Message(msg)
+	}
+	if err != nil {
+		http.Error(w, "Retry failed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := repositories.ResolveHL7ErrorMessage(id); err != nil {
+		http.Error(w, "Failed to resolve message", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": "HL7 message reprocessed successfully"})
+}
+
+// ResolveHL7ErrorMessageHandler dismisses a parked message without reprocessing it
+func ResolveHL7ErrorMessageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.ResolveHL7ErrorMessage(id); err != nil {
+		http.Error(w, "Failed to resolve message", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": "HL7 message marked as resolved"})
+}
+
+// GetLabResultsByPatientHandler
+func GetLabResultsByPatientHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["patient_id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	results, err := repositories.GetLabResultsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch lab results", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
+
+// StartMLLPListener accepts HL7 v2 connections framed with MLLP and replies with ACK/NAK.
+// Connections from addresses outside allowed are closed without reading.
+func StartMLLPListener(addr string, allowed []*net.IPNet) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[hl7] MLLP listener started on %s", addr)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				log.Printf("[hl7] accept error: %v", err)
+				continue
+			}
+			if !utils.RemoteAddrAllowed(conn.RemoteAddr().String(), allowed) {
+				log.Printf("[hl7] refused MLLP connection from %s", conn.RemoteAddr())
+				_ = conn.Close()
+				continue
+			}
+			go serveMLLPConn(conn)
+		}
+	}()
+	return ln, nil
+}
+
+func serveMLLPConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+
+		// skip anything until the start block
+		if _, err := reader.ReadBytes(utils.MLLPStartBlock); err != nil {
+			if err != io.EOF {
+				log.Printf("[hl7] read error from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		frame, err := reader.ReadBytes(utils.MLLPEndBlock)
+		if err != nil {
+			log.Printf("[hl7] incomplete MLLP frame from %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		if b, err := reader.ReadByte(); err == nil && b != utils.MLLPCarriage {
+			_ = reader.UnreadByte()
+		}
+
+		ack, code := HandleInboundHL7(string(frame[:len(frame)-1]), "mllp")
+		log.Printf("[hl7] processed message from %s ack=%s", conn.RemoteAddr(), code)
+
+		if _, err := conn.Write(utils.WrapMLLP(ack)); err != nil {
+			log.Printf("[hl7] failed to write ACK to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+)
+
+// CreateImmunizationHandler
+func CreateImmunizationHandler(w http.ResponseWriter, r *http.Request) {
+	var imm models.Immunization
+	if err := json.NewDecoder(r.Body).Decode(&imm); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if imm.PatientID == 0 || imm.VaccineCode == "" {
+		http.Error(w, "patient_id and vaccine_code are required", http.StatusBadRequest)
+		return
+	}
+	if imm.DoseNumber <= 0 {
+		http.Error(w, "dose_number must be > 0", http.StatusBadRequest)
+		return
+	}
+	if imm.DateAdministered.IsZero() {
+		imm.DateAdministered = time.Now()
+	}
+
+	if err := repositories.CreateImmunization(&imm); err != nil {
+		http.Error(w, "Failed to create immunization", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(imm)
+}
+
+// GetImmunizationByIDHandler
+func GetImmunizationByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid immunization ID", http.StatusBadRequest)
+		return
+	}
+	imm, err := repositories.GetImmunizationByID(id)
+	if err != nil {
+		http.Error(w, "Immunization not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(imm)
+}
+
+// UpdateImmunizationHandler
+func UpdateImmunizationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid immunization ID", http.StatusBadRequest)
+		return
+	}
+	var imm models.Immunization
+	if err := json.NewDecoder(r.Body).Decode(&imm); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	imm.ID = id
+
+	if err := repositories.UpdateImmunization(imm); err != nil {
+		http.Error(w, "Failed to update immunization", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": "Immunization updated successfully"})
+}
+
+// DeleteImmunizationHandler
+func DeleteImmunizationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid immunization ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.DeleteImmunization(id); err != nil {
+		http.Error(w, "Failed to delete immunization", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPatientImmunizationsHandler (GET /patients/{id}/immunizations)
+func GetPatientImmunizationsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetImmunizationsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch immunizations", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// GetDueImmunizationsHandler (GET /patients/{id}/immunizations/due?horizon_days=90)
+func GetDueImmunizationsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	horizon := 90
+	if v := r.URL.Query().Get("horizon_days"); v != "" {
+		if horizon, err = strconv.Atoi(v); err != nil || horizon < 0 {
+			http.Error(w, "horizon_days must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	patient, err := repositories.GetPatientByID(patientID)
+	if err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	dob, err := utils.ParseDateOfBirth(patient.DateOfBirth)
+	if err != nil {
+		http.Error(w, "Patient has no valid date_of_birth", http.StatusUnprocessableEntity)
+		return
+	}
+
+	schedule, err := repositories.GetVaccinationSchedule(true)
+	if err != nil {
+		http.Error(w, "Failed to fetch vaccination schedule", http.StatusInternalServerError)
+		return
+	}
+	given, err := repositories.GetImmunizationsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch immunizations", http.StatusInternalServerError)
+		return
+	}
+
+	due := utils.ComputeDueImmunizations(dob, schedule, given, time.Now(), horizon)
+	if due == nil {
+		due = []utils.DueImmunization{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(due)
+}
+
+// GetVaccinationScheduleHandler
+func GetVaccinationScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := repositories.GetVaccinationSchedule(r.URL.Query().Get("include_inactive") != "true")
+	if err != nil {
+		http.Error(w, "Failed to fetch vaccination schedule", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// CreateVaccinationScheduleEntryHandler (admin)
+func CreateVaccinationScheduleEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var entry models.VaccinationScheduleEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if entry.VaccineCode == "" || entry.VaccineName == "" || entry.DoseNumber <= 0 || entry.AgeDays < 0 {
+		http.Error(w, "vaccine_code, vaccine_name, dose_number and age_days are required", http.StatusBadRequest)
+		return
+	}
+	entry.Active = true
+
+	if err := repositories.CreateVaccinationScheduleEntry(&entry); err != nil {
+		http.Error(w, "Failed to create schedule entry", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(entry)
+}
+
+// UpdateVaccinationScheduleEntryHandler (admin)
+func UpdateVaccinationScheduleEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule entry ID", http.StatusBadRequest)
+		return
+	}
+	var entry models.VaccinationScheduleEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	entry.ID = id
+
+	if err := repositories.UpdateVaccinationScheduleEntry(entry); err != nil {
+		http.Error(w, "Failed to update schedule entry", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entry)
+}
+
+// DeleteVaccinationScheduleEntryHandler (admin)
+func DeleteVaccinationScheduleEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule entry ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.DeleteVaccinationScheduleEntry(id); err != nil {
+		http.Error(w, "Failed to delete schedule entry", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+func writeInsuranceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrPayerCodeExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrUnknownPayer):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, utils.ErrInvalidCoverage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// CreatePayerHandler (POST /admin/payers)
+func CreatePayerHandler(w http.ResponseWriter, r *http.Request) {
+	var payer models.Payer
+	if err := json.NewDecoder(r.Body).Decode(&payer); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payer.Code == "" || payer.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+	payer.ID = 0
+	payer.Active = true
+	if err := repositories.CreatePayer(&payer); err != nil {
+		writeInsuranceError(w, err, "Failed to create payer")
+		return
+	}
+	writeJSON(w, http.StatusCreated, payer)
+}
+
+// UpdatePayerHandler (PUT /admin/payers/{id}) also (de)activates payers
+func UpdatePayerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payer ID", http.StatusBadRequest)
+		return
+	}
+	existing, err := repositories.GetPayerByID(id)
+	if err != nil {
+		writeInsuranceError(w, err, "Failed to fetch payer")
+		return
+	}
+	var payer models.Payer
+	if err := json.NewDecoder(r.Body).Decode(&payer); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payer.Code == "" || payer.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+	payer.ID = id
+	payer.CreatedAt = existing.CreatedAt
+	if err := repositories.UpdatePayer(&payer); err != nil {
+		writeInsuranceError(w, err, "Failed to update payer")
+		return
+	}
+	writeJSON(w, http.StatusOK, payer)
+}
+
+// GetPayersHandler (GET /payers?all=true) lists active payers, or all of them
+func GetPayersHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := repositories.GetPayers(r.URL.Query().Get("all") != "true")
+	if err != nil {
+		http.Error(w, "Failed to fetch payers", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetPayerHandler (GET /payers/{id})
+func GetPayerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payer ID", http.StatusBadRequest)
+		return
+	}
+	payer, err := repositories.GetPayerByID(id)
+	if err != nil {
+		writeInsuranceError(w, err, "Failed to fetch payer")
+		return
+	}
+	writeJSON(w, http.StatusOK, payer)
+}
+
+// validateInsurancePolicy checks the fields every policy needs
+func validateInsurancePolicy(p *models.InsurancePolicy) string {
+	if p.PayerID == 0 || p.MemberID == "" {
+		return "payer_id and member_id are required"
+	}
+	if p.EffectiveFrom.IsZero() {
+		return "effective_from is required"
+	}
+	if p.EffectiveTo != nil && p.EffectiveTo.Before(p.EffectiveFrom) {
+		return "effective_to must not be before effective_from"
+	}
+	if p.Priority == 0 {
+		p.Priority = 1
+	}
+	if p.Priority < 0 {
+		return "priority must be positive"
+	}
+	p.SubscriberRelationship = strings.ToLower(p.SubscriberRelationship)
+	if p.SubscriberRelationship == "" {
+		p.SubscriberRelationship = "self"
+	}
+	return ""
+}
+
+// CreateInsurancePolicyHandler (POST /patients/{id}/insurance-policies)
+func CreateInsurancePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	var policy models.InsurancePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateInsurancePolicy(&policy); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if _, err := repositories.GetPatientByID(patientID); err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	policy.ID = 0
+	policy.PatientID = patientID
+	policy.Active = true
+	if err := repositories.CreateInsurancePolicy(&policy); err != nil {
+		writeInsuranceError(w, err, "Failed to create insurance policy")
+		return
+	}
+	writeJSON(w, http.StatusCreated, policy)
+}
+
+// GetPatientInsurancePoliciesHandler (GET /patients/{id}/insurance-policies)
+func GetPatientInsurancePoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetInsurancePoliciesByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch insurance policies", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// UpdateInsurancePolicyHandler (PUT /insurance-policies/{id}) also ends or deactivates policies
+func UpdateInsurancePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid insurance policy ID", http.StatusBadRequest)
+		return
+	}
+	existing, err := repositories.GetInsurancePolicyByID(id)
+	if err != nil {
+		writeInsuranceError(w, err, "Failed to fetch insurance policy")
+		return
+	}
+	var policy models.InsurancePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateInsurancePolicy(&policy); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	policy.ID = id
+	policy.PatientID = existing.PatientID
+	policy.CreatedAt = existing.CreatedAt
+	if err := repositories.UpdateInsurancePolicy(&policy); err != nil {
+		writeInsuranceError(w, err, "Failed to update insurance policy")
+		return
+	}
+	writeJSON(w, http.StatusOK, policy)
+}
+
+// CreateCoverageRuleHandler (POST /admin/payers/{id}/coverage-rules)
+// body: {"service_category": "laboratory", "covered": true, "co_pay": "10.00", "co_insurance": 20}
+func CreateCoverageRuleHandler(w http.ResponseWriter, r *http.Request) {
+	payerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payer ID", http.StatusBadRequest)
+		return
+	}
+	var rule models.CoverageRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := utils.ValidateCoverageRule(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule.ID = 0
+	rule.PayerID = payerID
+	if err := repositories.CreateCoverageRule(&rule); err != nil {
+		writeInsuranceError(w, err, "Failed to create coverage rule")
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// GetCoverageRulesHandler (GET /payers/{id}/coverage-rules)
+func GetCoverageRulesHandler(w http.ResponseWriter, r *http.Request) {
+	payerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payer ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetCoverageRules(payerID)
+	if err != nil {
+		http.Error(w, "Failed to fetch coverage rules", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// DeleteCoverageRuleHandler (DELETE /admin/coverage-rules/{id})
+func DeleteCoverageRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid coverage rule ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.DeleteCoverageRule(id); err != nil {
+		writeInsuranceError(w, err, "Failed to delete coverage rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
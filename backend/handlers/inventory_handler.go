@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+func writeInventoryError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrInvalidMovement),
+		errors.Is(err, repositories.ErrPurchaseOrderLineItem):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrInsufficientStock),
+		errors.Is(err, repositories.ErrPurchaseOrderState),
+		errors.Is(err, repositories.ErrOverReceipt):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// CreateInventoryItemHandler (POST /inventory/items)
+func CreateInventoryItemHandler(w http.ResponseWriter, r *http.Request) {
+	var item models.InventoryItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if item.SKU == "" || item.Name == "" || item.Unit == "" {
+		http.Error(w, "sku, name and unit are required", http.StatusBadRequest)
+		return
+	}
+	item.ID = 0
+	item.Active = true
+	if err := repositories.CreateInventoryItem(&item); err != nil {
+		http.Error(w, "Failed to create inventory item", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// GetInventoryItemsHandler (GET /inventory/items?category=)
+func GetInventoryItemsHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := repositories.GetInventoryItems(r.URL.Query().Get("category"))
+	if err != nil {
+		http.Error(w, "Failed to fetch inventory items", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// GetInventoryItemHandler (GET /inventory/items/{id})
+func GetInventoryItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	item, err := repositories.GetInventoryItemByID(id)
+	if err != nil {
+		http.Error(w, "Inventory item not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// UpdateInventoryItemHandler (PUT /inventory/items/{id})
+func UpdateInventoryItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	var item models.InventoryItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	item.ID = id
+	if err := repositories.UpdateInventoryItem(item); err != nil {
+		writeInventoryError(w, err, "Failed to update inventory item")
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// CreateInventoryLocationHandler (POST /inventory/locations)
+func CreateInventoryLocationHandler(w http.ResponseWriter, r *http.Request) {
+	var loc models.InventoryLocation
+	if err := json.NewDecoder(r.Body).Decode(&loc); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if loc.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if loc.Type == "" {
+		loc.Type = "warehouse"
+	}
+	loc.ID = 0
+	if err := repositories.CreateInventoryLocation(&loc); err != nil {
+		http.Error(w, "Failed to create location", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, loc)
+}
+
+// GetInventoryLocationsHandler (GET /inventory/locations)
+func GetInventoryLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	locations, err := repositories.GetInventoryLocations()
+	if err != nil {
+		http.Error(w, "Failed to fetch locations", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, locations)
+}
+
+// CreateSupplierHandler (POST /inventory/suppliers)
+func CreateSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	var s models.Supplier
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if s.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	s.ID = 0
+	s.Active = true
+	if err := repositories.CreateSupplier(&s); err != nil {
+		http.Error(w, "Failed to create supplier", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// GetSuppliersHandler (GET /inventory/suppliers)
+func GetSuppliersHandler(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := repositories.GetSuppliers()
+	if err != nil {
+		http.Error(w, "Failed to fetch suppliers", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, suppliers)
+}
+
+// UpdateSupplierHandler (PUT /inventory/suppliers/{id})
+func UpdateSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid supplier ID", http.StatusBadRequest)
+		return
+	}
+	var s models.Supplier
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	s.ID = id
+	if err := repositories.UpdateSupplier(s); err != nil {
+		writeInventoryError(w, err, "Failed to update supplier")
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// GetStockLevelsHandler (GET /inventory/stock?item_id=&location_id=)
+func GetStockLevelsHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, _ := strconv.Atoi(r.URL.Query().Get("item_id"))
+	locationID, _ := strconv.Atoi(r.URL.Query().Get("location_id"))
+	levels, err := repositories.GetStockLevels(itemID, locationID)
+	if err != nil {
+		http.Error(w, "Failed to fetch stock levels", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, levels)
+}
+
+// CreateInventoryMovementHandler (POST /inventory/movements) records a receipt, issue, transfer or adjustment
+func CreateInventoryMovementHandler(w http.ResponseWriter, r *http.Request) {
+	var m models.InventoryMovement
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if m.ItemID == 0 {
+		http.Error(w, "item_id is required", http.StatusBadRequest)
+		return
+	}
+	if m.Type == "adjustment" && m.Reason == "" {
+		http.Error(w, "reason is required for adjustments", http.StatusBadRequest)
+		return
+	}
+	m.ID = 0
+	m.PurchaseOrderLineID = nil
+	m.PerformedBy = currentUserID(r)
+
+	if err := repositories.RecordInventoryMovement(&m); err != nil {
+		writeInventoryError(w, err, "Failed to record movement")
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// GetInventoryMovementsHandler (GET /inventory/movements?item_id=&location_id=&since=2025-01-01)
+func GetInventoryMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	itemID, _ := strconv.Atoi(q.Get("item_id"))
+	locationID, _ := strconv.Atoi(q.Get("location_id"))
+	var since *time.Time
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "since must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		since = &t
+	}
+	movements, err := repositories.GetInventoryMovements(itemID, locationID, since)
+	if err != nil {
+		http.Error(w, "Failed to fetch movements", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, movements)
+}
+
+// CreatePurchaseOrderHandler (POST /inventory/purchase-orders)
+func CreatePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var po models.PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&po); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if po.SupplierID == 0 || po.LocationID == 0 || len(po.Lines) == 0 {
+		http.Error(w, "supplier_id, location_id and lines are required", http.StatusBadRequest)
+		return
+	}
+	for i := range po.Lines {
+		if po.Lines[i].ItemID == 0 || po.Lines[i].QuantityOrdered <= 0 {
+			http.Error(w, "every line needs an item_id and a quantity_ordered > 0", http.StatusBadRequest)
+			return
+		}
+		po.Lines[i].ID = 0
+		po.Lines[i].QuantityReceived = 0
+	}
+	po.ID = 0
+	po.OrderedAt = nil
+	po.CreatedBy = currentUserID(r)
+
+	if err := repositories.CreatePurchaseOrder(&po); err != nil {
+		http.Error(w, "Failed to create purchase order", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, po)
+}
+
+// GetPurchaseOrdersHandler (GET /inventory/purchase-orders?status=&supplier_id=)
+func GetPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	supplierID, _ := strconv.Atoi(r.URL.Query().Get("supplier_id"))
+	list, err := repositories.GetPurchaseOrders(r.URL.Query().Get("status"), supplierID)
+	if err != nil {
+		http.Error(w, "Failed to fetch purchase orders", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetPurchaseOrderHandler (GET /inventory/purchase-orders/{id})
+func GetPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return
+	}
+	po, err := repositories.GetPurchaseOrderByID(id)
+	if err != nil {
+		http.Error(w, "Purchase order not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+// SubmitPurchaseOrderHandler (POST /inventory/purchase-orders/{id}/submit) sends a draft to the supplier
+func SubmitPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return
+	}
+	po, err := repositories.SetPurchaseOrderStatus(id, []string{"draft"}, "ordered")
+	if err != nil {
+		writeInventoryError(w, err, "Failed to submit purchase order")
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+// CancelPurchaseOrderHandler (POST /inventory/purchase-orders/{id}/cancel)
+func CancelPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return
+	}
+	po, err := repositories.SetPurchaseOrderStatus(id, []string{"draft", "ordered"}, "cancelled")
+	if err != nil {
+		writeInventoryError(w, err, "Failed to cancel purchase order")
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+// ReceivePurchaseOrderHandler (POST /inventory/purchase-orders/{id}/receive)
+func ReceivePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Lines []repositories.ReceiptLine `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Lines) == 0 {
+		http.Error(w, "lines are required", http.StatusBadRequest)
+		return
+	}
+	for _, l := range payload.Lines {
+		if l.LineID == 0 || l.Quantity <= 0 {
+			http.Error(w, "every line needs a line_id and a quantity > 0", http.StatusBadRequest)
+			return
+		}
+	}
+
+	po, err := repositories.ReceivePurchaseOrder(id, payload.Lines, currentUserID(r))
+	if err != nil {
+		writeInventoryError(w, err, "Failed to receive purchase order")
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+// GetReorderSuggestionsHandler (GET /inventory/reorder-suggestions?status=open)
+func GetReorderSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := repositories.GetReorderSuggestions(r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "Failed to fetch reorder suggestions", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// UpdateReorderSuggestionHandler (PATCH /inventory/reorder-suggestions/{id}) marks a suggestion ordered or dismissed
+func UpdateReorderSuggestionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid suggestion ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Status != "ordered" && payload.Status != "dismissed" {
+		http.Error(w, "status must be ordered or dismissed", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.SetReorderSuggestionStatus(id, payload.Status); err != nil {
+		writeInventoryError(w, err, "Failed to update reorder suggestion")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Reorder suggestion updated"})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,43 +10,123 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/samichen99/HAP-hospital-management-system/models"
 	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
 )
 
+func writeInvoiceError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrNotGuarantor),
+		errors.Is(err, repositories.ErrUnknownServiceItem),
+		errors.Is(err, repositories.ErrServiceItemInactive),
+		errors.Is(err, repositories.ErrCurrencyMismatch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, utils.ErrInvalidChargeLine), errors.Is(err, utils.ErrInvalidLocationCode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repositories.ErrInvoiceNotOpen),
+		errors.Is(err, repositories.ErrInvoiceLineHasSource),
+		errors.Is(err, repositories.ErrTotalBelowPaid),
+		errors.Is(err, repositories.ErrInvoiceHasPayments),
+		errors.Is(err, repositories.ErrInvoiceNotPayable),
+		errors.Is(err, repositories.ErrAlreadyVoided),
+		errors.Is(err, repositories.ErrInvoiceIssued),
+		errors.Is(err, repositories.ErrInvoiceNotDraft),
+		errors.Is(err, repositories.ErrInvoiceEmpty):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// invoiceLineInput is what clients may set on a line; prices of catalogue items and all
+// totals are filled in server-side
+type invoiceLineInput struct {
+	ServiceItemID *int         `json:"service_item_id"`
+	Description   string       `json:"description"`
+	Quantity      int          `json:"quantity"`
+	UnitPrice     models.Money `json:"unit_price"`
+	Discount      models.Money `json:"discount"`
+	TaxRate       float64      `json:"tax_rate"`
+}
+
+func (in invoiceLineInput) line() (models.InvoiceLine, string) {
+	if in.ServiceItemID == nil && in.Description == "" {
+		return models.InvoiceLine{}, "each line needs a service_item_id or a description"
+	}
+	return models.InvoiceLine{
+		ServiceItemID: in.ServiceItemID,
+		Description:   in.Description,
+		Quantity:      in.Quantity,
+		UnitPrice:     in.UnitPrice,
+		Discount:      in.Discount,
+		TaxRate:       in.TaxRate,
+	}, ""
+}
+
 // CreateInvoiceHandler
+// The amount is computed from the lines; any amount sent by the client is ignored.
+// The invoice is created as a draft unless "issue": true is sent.
 func CreateInvoiceHandler(w http.ResponseWriter, r *http.Request) {
-	var inv models.Invoice
-	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+	var payload struct {
+		models.Invoice
+		Lines []invoiceLineInput `json:"lines"`
+		Issue bool               `json:"issue"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	inv := payload.Invoice
 
 	// basic validations
 	if inv.PatientID == 0 {
 		http.Error(w, "patient_id is required", http.StatusBadRequest)
 		return
 	}
-	if inv.Amount <= 0 {
-		http.Error(w, "amount must be > 0", http.StatusBadRequest)
+	if len(payload.Lines) == 0 {
+		http.Error(w, "at least one line is required", http.StatusBadRequest)
+		return
+	}
+	lines := make([]models.InvoiceLine, 0, len(payload.Lines))
+	for _, in := range payload.Lines {
+		line, msg := in.line()
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		lines = append(lines, line)
+	}
+	inv.ID = 0
+	currency, err := utils.NormalizeCurrency(inv.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if inv.Status == "" {
-		inv.Status = "unpaid"
+	inv.Currency = currency
+	if inv.Location, err = utils.NormalizeLocationCode(inv.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	inv.IssuedAt = time.Now()
 	if inv.DueDate.IsZero() {
 		// default due date +14 days
 		inv.DueDate = time.Now().AddDate(0, 0, 14)
 	}
 
-	id, err := repositories.CreateInvoice(inv)
+	inv, err = repositories.CreateInvoice(inv, lines, payload.Issue)
 	if err != nil {
-		http.Error(w, "Failed to create invoice", http.StatusInternalServerError)
+		writeInvoiceError(w, err, "Failed to create invoice")
 		return
 	}
-	inv.ID = id
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(inv)
+	_ = json.NewEncoder(w).Encode(struct {
+		models.Invoice
+		Lines []models.InvoiceLine `json:"lines"`
+	}{inv, lines})
 }
 
 // GetInvoiceByIDHandler
@@ -86,42 +167,97 @@ func UpdateInvoiceHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
 		return
 	}
-	var inv models.Invoice
-	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+	// only these fields of a draft can be edited; the rest is derived or set at creation
+	var payload struct {
+		PatientID     int       `json:"patient_id"`
+		AppointmentID *int      `json:"appointment_id"`
+		GuarantorID   *int      `json:"guarantor_id"`
+		Location      string    `json:"location"`
+		DueDate       time.Time `json:"due_date"`
+		Notes         string    `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	inv.ID = id
-
-	if inv.Amount <= 0 {
-		http.Error(w, "amount must be > 0", http.StatusBadRequest)
+	if payload.PatientID == 0 || payload.DueDate.IsZero() {
+		http.Error(w, "patient_id and due_date are required", http.StatusBadRequest)
 		return
 	}
-	if inv.Status == "" {
-		http.Error(w, "status is required", http.StatusBadRequest)
+	inv := models.Invoice{
+		ID:            id,
+		PatientID:     payload.PatientID,
+		AppointmentID: payload.AppointmentID,
+		GuarantorID:   payload.GuarantorID,
+		DueDate:       payload.DueDate,
+		Notes:         payload.Notes,
+	}
+	if inv.Location, err = utils.NormalizeLocationCode(payload.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if err := repositories.UpdateInvoice(inv); err != nil {
-		http.Error(w, "Failed to update invoice", http.StatusInternalServerError)
+		writeInvoiceError(w, err, "Failed to update invoice")
+		return
+	}
+	// totals come from the lines, not from the request
+	inv, err = repositories.GetInvoiceByID(id)
+	if err != nil {
+		http.Error(w, "Error fetching invoice", http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(inv)
 }
 
-// DeleteInvoiceHandler
-func DeleteInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+// IssueInvoiceHandler (POST /invoices/{id}/issue) assigns the legal number of a draft invoice
+func IssueInvoiceHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
 		return
 	}
-	if err := repositories.DeleteInvoice(id); err != nil {
-		http.Error(w, "Failed to delete invoice", http.StatusInternalServerError)
+	inv, err := repositories.IssueInvoice(id)
+	if err != nil {
+		writeInvoiceError(w, err, "Failed to issue invoice")
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, http.StatusOK, inv)
+}
+
+// GetInvoiceByNumberHandler (GET /invoices/number/{number})
+func GetInvoiceByNumberHandler(w http.ResponseWriter, r *http.Request) {
+	inv, err := repositories.GetInvoiceByNumber(mux.Vars(r)["number"])
+	if err != nil {
+		writeInvoiceError(w, err, "Error fetching invoice")
+		return
+	}
+	writeJSON(w, http.StatusOK, inv)
+}
+
+// VoidInvoiceHandler (POST /invoices/{id}/void, DELETE /invoices/{id})
+// Invoices are never deleted; a reason is required in the body or ?reason=.
+func VoidInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	reason, ok := voidReason(w, r)
+	if !ok {
+		return
+	}
+	if err := repositories.VoidInvoice(id, reason, currentUserID(r)); err != nil {
+		writeInvoiceError(w, err, "Failed to void invoice")
+		return
+	}
+	inv, err := repositories.GetInvoiceByID(id)
+	if err != nil {
+		http.Error(w, "Error fetching invoice", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, inv)
 }
 
 // Filters
@@ -158,7 +294,8 @@ func GetInvoicesByStatusHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(list)
 }
 
-// MarkInvoicePaidHandler (PATCH /invoices/{id}/paid)
+// MarkInvoicePaidHandler (PATCH /invoices/{id}/paid) records a payment for the outstanding balance
+// body: {"paid_at": "...", "method": "cash"}
 func MarkInvoicePaidHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
@@ -167,14 +304,22 @@ func MarkInvoicePaidHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	var body struct {
 		PaidAt *time.Time `json:"paid_at,omitempty"`
+		Method string     `json:"method,omitempty"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&body)
 	paidAt := time.Now()
 	if body.PaidAt != nil && !body.PaidAt.IsZero() {
 		paidAt = *body.PaidAt
 	}
-	if err := repositories.MarkInvoicePaid(id, paidAt); err != nil {
-		http.Error(w, "Failed to mark invoice paid", http.StatusInternalServerError)
+	if body.Method == "" {
+		body.Method = "cash"
+	}
+	if !isCashierPaymentMethod(body.Method) {
+		http.Error(w, "method must be cash, card, transfer or cheque", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.MarkInvoicePaid(id, paidAt, body.Method, currentUserID(r)); err != nil {
+		writePaymentError(w, err, "Failed to mark invoice paid")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -199,4 +344,63 @@ func FilterInvoiceHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(invoices)
-}
\ No newline at end of file
+}
+// GetInvoiceLinesHandler (GET /invoices/{id}/lines)
+func GetInvoiceLinesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	lines, err := repositories.GetInvoiceLines(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch invoice lines", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(lines)
+}
+
+// AddInvoiceLineHandler (POST /invoices/{id}/lines)
+func AddInvoiceLineHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	var in invoiceLineInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	line, msg := in.line()
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err := repositories.AddInvoiceLine(id, &line); err != nil {
+		writeInvoiceError(w, err, "Failed to add invoice line")
+		return
+	}
+	writeJSON(w, http.StatusCreated, line)
+}
+
+// DeleteInvoiceLineHandler (DELETE /invoices/{id}/lines/{line_id})
+func DeleteInvoiceLineHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	lineID, err := strconv.Atoi(vars["line_id"])
+	if err != nil {
+		http.Error(w, "Invalid line ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.DeleteInvoiceLine(id, lineID); err != nil {
+		writeInvoiceError(w, err, "Failed to delete invoice line")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
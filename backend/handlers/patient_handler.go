@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/samichen99/HAP-hospital-management-system/models"
 	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
 )
 
 
@@ -123,3 +125,79 @@ func SearchPatientsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(patients)
 }
+
+// PatientSummary aggregates the clinical and billing overview of a patient
+type PatientSummary struct {
+	Patient           models.Patient      `json:"patient"`
+	ActiveProblems    []models.Problem    `json:"active_problems"`
+	LatestAppointment *models.Appointment `json:"latest_appointment"`
+	OpenInvoices      []models.Invoice    `json:"open_invoices"`
+	RecentFiles       []models.File       `json:"recent_files"`
+}
+
+// GetPatientSummaryHandler :
+func GetPatientSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+
+	patient, err := repositories.GetPatientByID(id)
+	if err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	summary := PatientSummary{Patient: patient}
+
+	if summary.ActiveProblems, err = repositories.GetProblemsByPatientID(id, "active"); err != nil {
+		http.Error(w, "Failed to fetch problem list", http.StatusInternalServerError)
+		return
+	}
+	if summary.LatestAppointment, err = repositories.GetLatestAppointmentByPatientID(id); err != nil {
+		http.Error(w, "Failed to fetch appointments", http.StatusInternalServerError)
+		return
+	}
+	if summary.OpenInvoices, err = repositories.GetOpenInvoicesByPatientID(id); err != nil {
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+	if summary.RecentFiles, err = repositories.GetRecentFilesByPatientID(id, 5); err != nil {
+		http.Error(w, "Failed to fetch files", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// MergePatientHandler (POST /patients/{id}/merge) merges a temporary patient into an identified one
+func MergePatientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		TargetPatientID int `json:"target_patient_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.TargetPatientID == 0 {
+		http.Error(w, "target_patient_id is required", http.StatusBadRequest)
+		return
+	}
+
+	target, err := repositories.MergePatient(id, payload.TargetPatientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrPatientNotTemporary):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Patient not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to merge patient", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+// paymentGateway takes online payments; nil while no gateway is configured
+var paymentGateway utils.PaymentGateway
+
+// SetPaymentGateway installs the gateway used for online payments and webhooks
+func SetPaymentGateway(g utils.PaymentGateway) {
+	paymentGateway = g
+}
+
+// gatewayTimeout bounds every call to the payment gateway
+const gatewayTimeout = 15 * time.Second
+
+func requireGateway(w http.ResponseWriter) bool {
+	if paymentGateway == nil {
+		http.Error(w, "Online payments are not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func writeGatewayError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrInvoiceNotPayable),
+		errors.Is(err, repositories.ErrOverpayment),
+		errors.Is(err, repositories.ErrIntentStatus),
+		errors.Is(err, utils.ErrIntentNotCapturable),
+		errors.Is(err, utils.ErrRefundNotAllowed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, utils.ErrUnknownIntent):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// openPaymentIntent opens a checkout at the gateway for an invoice and stores the intent
+func openPaymentIntent(w http.ResponseWriter, r *http.Request, invoiceID int) {
+	if !requireGateway(w) {
+		return
+	}
+	var payload struct {
+		Amount        models.Money `json:"amount"`
+		CaptureMethod string       `json:"capture_method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Amount < 0 {
+		http.Error(w, "amount must be > 0", http.StatusBadRequest)
+		return
+	}
+	if payload.CaptureMethod == "" {
+		payload.CaptureMethod = "automatic"
+	}
+	if payload.CaptureMethod != "automatic" && payload.CaptureMethod != "manual" {
+		http.Error(w, "capture_method must be automatic or manual", http.StatusBadRequest)
+		return
+	}
+	inv, amount, err := repositories.CheckIntentAmount(invoiceID, payload.Amount)
+	if err != nil {
+		writeGatewayError(w, err, "Failed to check invoice")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), gatewayTimeout)
+	defer cancel()
+	ext, err := paymentGateway.CreateIntent(ctx, utils.GatewayIntentRequest{
+		Reference:       invoiceReference(inv),
+		Description:     "Invoice " + invoiceReference(inv),
+		Amount:          amount,
+		Currency:        inv.Currency,
+		CaptureManually: payload.CaptureMethod == "manual",
+	})
+	if err != nil {
+		log.Println("Error creating gateway payment intent:", err)
+		http.Error(w, "Payment gateway is unavailable", http.StatusBadGateway)
+		return
+	}
+	userID := currentUserID(r)
+	intent := models.PaymentIntent{
+		InvoiceID:     inv.ID,
+		PatientID:     inv.PatientID,
+		Gateway:       paymentGateway.Name(),
+		ExternalID:    ext.ExternalID,
+		Amount:        amount,
+		Currency:      inv.Currency,
+		Status:        "pending",
+		CaptureMethod: payload.CaptureMethod,
+		CheckoutURL:   ext.CheckoutURL,
+		CreatedBy:     &userID,
+	}
+	if err := repositories.CreatePaymentIntent(&intent); err != nil {
+		http.Error(w, "Failed to create payment intent", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, intent)
+}
+
+// CreatePaymentIntentHandler (POST /invoices/{id}/payment-intents) opens an online checkout
+// body: {"amount": "50.00", "capture_method": "automatic"}; no amount pays the whole balance
+func CreatePaymentIntentHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	openPaymentIntent(w, r, invoiceID)
+}
+
+// CreateMyPaymentIntentHandler (POST /api/me/invoices/{id}/payment-intents) lets patients pay
+// their own invoice online
+func CreateMyPaymentIntentHandler(w http.ResponseWriter, r *http.Request) {
+	inv, ok := myInvoice(w, r)
+	if !ok {
+		return
+	}
+	openPaymentIntent(w, r, inv.ID)
+}
+
+// GetInvoicePaymentIntentsHandler (GET /invoices/{id}/payment-intents)
+func GetInvoicePaymentIntentsHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetPaymentIntentsByInvoiceID(invoiceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch payment intents", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetPaymentIntentHandler (GET /payment-intents/{id}) returns the intent with its webhook events
+func GetPaymentIntentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment intent ID", http.StatusBadRequest)
+		return
+	}
+	intent, err := repositories.GetPaymentIntentByID(id)
+	if err != nil {
+		writeGatewayError(w, err, "Failed to fetch payment intent")
+		return
+	}
+	events, err := repositories.GetGatewayEvents(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch gateway events", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"intent": intent, "events": events})
+}
+
+// CapturePaymentIntentHandler (POST /payment-intents/{id}/capture) captures an authorized
+// intent and records its payment. A capture that failed half-way can be sent again.
+// body: {"amount": "40.00"}; no amount captures what was authorized
+func CapturePaymentIntentHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireGateway(w) {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment intent ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Amount models.Money `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	intent, err := repositories.BeginCapture(id, paymentGateway.Name())
+	if err != nil {
+		writeGatewayError(w, err, "Failed to start capture")
+		return
+	}
+	if payload.Amount == 0 {
+		payload.Amount = intent.Amount
+	}
+	if payload.Amount < 0 || payload.Amount > intent.Amount {
+		http.Error(w, "amount must be > 0 and at most the authorized amount", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), gatewayTimeout)
+	defer cancel()
+	captured, err := paymentGateway.Capture(ctx, intent.ExternalID, payload.Amount)
+	if err != nil {
+		log.Println("Error capturing gateway payment intent:", err)
+		writeGatewayError(w, err, "Failed to capture payment")
+		return
+	}
+	intent, err = repositories.CaptureIntent(id, captured.Amount)
+	if err != nil {
+		log.Printf("Payment intent %d was captured at the gateway but its payment was not recorded: %v", id, err)
+		writeGatewayError(w, err, "Failed to record captured payment; send the capture again")
+		return
+	}
+	writeJSON(w, http.StatusOK, intent)
+}
+
+// refundOnlinePayment returns a refund of an online payment through the gateway
+func refundOnlinePayment(ctx context.Context, intentID int, amount models.Money) error {
+	if paymentGateway == nil {
+		return errors.New("online payments are not configured")
+	}
+	intent, err := repositories.GetPaymentIntentByID(intentID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, gatewayTimeout)
+	defer cancel()
+	_, err = paymentGateway.Refund(ctx, intent.ExternalID, amount)
+	return err
+}
+
+// processWebhook verifies and applies a gateway webhook; shared by the public endpoint and
+// the mock simulator
+func processWebhook(w http.ResponseWriter, payload []byte, header http.Header) {
+	ev, err := paymentGateway.ParseWebhook(payload, header, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	record, duplicate, err := repositories.ApplyGatewayEvent(paymentGateway.Name(), ev, payload)
+	if err != nil {
+		// a non-2xx answer makes the gateway deliver the event again later
+		http.Error(w, "Failed to process event", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"event_id": record.EventID, "duplicate": duplicate, "result": record.Result})
+}
+
+// PaymentWebhookHandler (POST /webhooks/payments) receives signed events from the payment
+// gateway. It is not behind login; the signature authenticates the sender.
+func PaymentWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireGateway(w) {
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	processWebhook(w, payload, r.Header)
+}
+
+// SimulatePaymentEventHandler (POST /admin/payment-intents/{id}/simulate) completes, fails or
+// disputes a checkout of the mock gateway by feeding it a signed webhook
+// body: {"type": "payment.succeeded", "reason": ""}
+func SimulatePaymentEventHandler(w http.ResponseWriter, r *http.Request) {
+	mock, ok := paymentGateway.(*utils.MockGateway)
+	if !ok {
+		http.Error(w, "Only the mock gateway can simulate events", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid payment intent ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Type == "" {
+		http.Error(w, "type is required", http.StatusBadRequest)
+		return
+	}
+	intent, err := repositories.GetPaymentIntentByID(id)
+	if err != nil {
+		writeGatewayError(w, err, "Failed to fetch payment intent")
+		return
+	}
+	body, header, err := mock.SimulateEvent(payload.Type, intent.ExternalID, payload.Reason)
+	if err != nil {
+		writeGatewayError(w, err, "Failed to simulate event")
+		return
+	}
+	processWebhook(w, body, header)
+}
@@ -2,27 +2,110 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/samichen99/HAP-hospital-management-system/models"
 	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
 )
 
-// CreatePaymentHandler
+func writePaymentError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrOverpayment),
+		errors.Is(err, repositories.ErrInvoiceNotPayable),
+		errors.Is(err, repositories.ErrInsufficientCredit),
+		errors.Is(err, repositories.ErrCreditAlreadyUsed),
+		errors.Is(err, repositories.ErrCreditPayment),
+		errors.Is(err, repositories.ErrRemittancePayment),
+		errors.Is(err, repositories.ErrOnlinePayment),
+		errors.Is(err, repositories.ErrPaymentVoided),
+		errors.Is(err, repositories.ErrPaymentRefunded),
+		errors.Is(err, repositories.ErrAlreadyVoided),
+		errors.Is(err, repositories.ErrRefundExceedsPayment),
+		errors.Is(err, repositories.ErrOnlineRefund),
+		errors.Is(err, repositories.ErrRefundNotPending),
+		errors.Is(err, repositories.ErrCreditExceedsInvoice):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrCurrencyMismatch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// isCashierPaymentMethod reports whether staff may record a payment with this method. The
+// credit, insurance and unrecorded methods are set by the server only: for patient credit,
+// claim remittances and migrated payments.
+func isCashierPaymentMethod(method string) bool {
+	switch method {
+	case "cash", "card", "transfer", "cheque":
+		return true
+	default:
+		return false
+	}
+}
+
+// CreatePaymentHandler applies a payment to an invoice.
+// body: payment fields plus "overpayment": "reject" (default) or "credit" to keep any
+// amount above the balance as patient credit
 func CreatePaymentHandler(w http.ResponseWriter, r *http.Request) {
-	var payment models.Payment
-	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+	var payload struct {
+		models.Payment
+		Overpayment string `json:"overpayment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
-	if err := repositories.CreatePayment(payment); err != nil {
-		http.Error(w, "Failed to create payment", http.StatusInternalServerError)
+	payment := payload.Payment
+	if payment.InvoiceId == 0 || payment.PaymentMethod == "" {
+		http.Error(w, "invoice_id and method are required", http.StatusBadRequest)
+		return
+	}
+	if !isCashierPaymentMethod(payment.PaymentMethod) {
+		http.Error(w, "method must be cash, card, transfer or cheque", http.StatusBadRequest)
+		return
+	}
+	if payment.Amount <= 0 {
+		http.Error(w, "amount must be > 0", http.StatusBadRequest)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Payment created successfully"})
+	if payload.Overpayment != "" && payload.Overpayment != "reject" && payload.Overpayment != "credit" {
+		http.Error(w, "overpayment must be reject or credit", http.StatusBadRequest)
+		return
+	}
+	payment.ID = 0
+	// remittances are recorded through their claim
+	payment.ClaimID, payment.IntentID = nil, nil
+	payment.VoidedAt, payment.VoidReason, payment.VoidedBy = nil, "", nil
+	receivedBy := currentUserID(r)
+	payment.ReceivedBy = &receivedBy
+	if payment.PaymentDate.IsZero() {
+		payment.PaymentDate = time.Now()
+	}
+
+	credit, err := repositories.ApplyPayment(&payment, payload.Overpayment == "credit")
+	if err != nil {
+		writePaymentError(w, err, "Failed to create payment")
+		return
+	}
+	inv, err := repositories.GetInvoiceByID(payment.InvoiceId)
+	if err != nil {
+		http.Error(w, "Error fetching invoice", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"payment": payment,
+		"credit":  credit,
+		"invoice": inv,
+	})
 }
 
 // GetPaymentByIDHandler
@@ -67,19 +150,24 @@ func GetPaymentsByInvoiceIDHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(payments)
 }
 
-// DeletePaymentHandler
-func DeletePaymentHandler(w http.ResponseWriter, r *http.Request) {
+// VoidPaymentHandler (POST /payments/{id}/void, DELETE /payments/{id})
+// Payments are never deleted; a reason is required in the body or ?reason=.
+func VoidPaymentHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := mux.Vars(r)["id"]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
 		return
 	}
-	if err := repositories.DeletePayment(id); err != nil {
-		http.Error(w, "Failed to delete payment", http.StatusInternalServerError)
+	reason, ok := voidReason(w, r)
+	if !ok {
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]string{"message": "Payment deleted successfully"})
+	if err := repositories.VoidPayment(id, reason, currentUserID(r)); err != nil {
+		writePaymentError(w, err, "Failed to void payment")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Payment voided successfully"})
 }
 
 // UpdatePaymentHandler handles updating an existing payment
@@ -100,11 +188,61 @@ func UpdatePaymentHandler(w http.ResponseWriter, r *http.Request) {
 
 	payment.ID = id 
 
+	if payment.Amount < 0 {
+		http.Error(w, "amount must not be negative", http.StatusBadRequest)
+		return
+	}
+	if payment.PaymentMethod != "" && !isCashierPaymentMethod(payment.PaymentMethod) {
+		http.Error(w, "method must be cash, card, transfer or cheque", http.StatusBadRequest)
+		return
+	}
 	if err := repositories.UpdatePayment(payment); err != nil {
-		http.Error(w, "Failed to update payment", http.StatusInternalServerError)
+		writePaymentError(w, err, "Failed to update payment")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Payment updated successfully"})
 }
+
+// ApplyCreditHandler (POST /invoices/{id}/apply-credit)
+// body: {"amount": "20.00"}; without an amount as much credit as the balance allows is used
+func ApplyCreditHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Amount models.Money `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Amount < 0 {
+		http.Error(w, "amount must be > 0", http.StatusBadRequest)
+		return
+	}
+	payment, err := repositories.ApplyCredit(id, payload.Amount)
+	if err != nil {
+		writePaymentError(w, err, "Failed to apply credit")
+		return
+	}
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+// GetPatientCreditsHandler (GET /patients/{id}/credits)
+func GetPatientCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	summary, err := repositories.GetPatientCredits(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch patient credit", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/jobs"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+func writePharmacyError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrInsufficientStock),
+		errors.Is(err, repositories.ErrStockExpired),
+		errors.Is(err, repositories.ErrInvoiceNotOpen):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrNoPrescription):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Prescription, stock item or invoice not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// ReceiveDrugStockHandler (POST /pharmacy/stock) registers a received batch
+func ReceiveDrugStockHandler(w http.ResponseWriter, r *http.Request) {
+	var item models.DrugStockItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if item.DrugCode == "" || item.DrugName == "" || item.BatchNumber == "" || item.Location == "" {
+		http.Error(w, "drug_code, drug_name, batch_number and location are required", http.StatusBadRequest)
+		return
+	}
+	if item.ExpiryDate.IsZero() {
+		http.Error(w, "expiry_date is required", http.StatusBadRequest)
+		return
+	}
+	if item.Quantity <= 0 {
+		http.Error(w, "quantity must be > 0", http.StatusBadRequest)
+		return
+	}
+	item.ID = 0
+
+	if err := repositories.ReceiveDrugStock(&item, currentUserID(r)); err != nil {
+		http.Error(w, "Failed to receive stock", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+// GetDrugStockHandler (GET /pharmacy/stock?drug_code=&location=&include_empty=true)
+func GetDrugStockHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	items, err := repositories.GetDrugStock(q.Get("drug_code"), q.Get("location"), q.Get("include_empty") == "true")
+	if err != nil {
+		http.Error(w, "Failed to fetch stock", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// GetDrugStockItemHandler (GET /pharmacy/stock/{id})
+func GetDrugStockItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid stock item ID", http.StatusBadRequest)
+		return
+	}
+	item, err := repositories.GetDrugStockItemByID(id)
+	if err != nil {
+		http.Error(w, "Stock item not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+// AdjustDrugStockHandler (POST /pharmacy/stock/{id}/adjust)
+func AdjustDrugStockHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid stock item ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Quantity int    `json:"quantity"`
+		Type     string `json:"type"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Quantity == 0 || payload.Reason == "" {
+		http.Error(w, "quantity (non-zero) and reason are required", http.StatusBadRequest)
+		return
+	}
+	switch payload.Type {
+	case "":
+		payload.Type = "adjustment"
+	case "adjustment", "return", "expired", "damaged":
+	default:
+		http.Error(w, "type must be adjustment, return, expired or damaged", http.StatusBadRequest)
+		return
+	}
+
+	item, err := repositories.AdjustDrugStock(id, payload.Quantity, payload.Type, payload.Reason, currentUserID(r))
+	if err != nil {
+		writePharmacyError(w, err, "Failed to adjust stock")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+// GetStockMovementsHandler (GET /pharmacy/stock/{id}/movements)
+func GetStockMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid stock item ID", http.StatusBadRequest)
+		return
+	}
+	movements, err := repositories.GetStockMovements(id)
+	if err != nil {
+		http.Error(w, "Failed to fetch stock movements", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(movements)
+}
+
+// DispenseDrugsHandler (POST /pharmacy/dispensings)
+func DispenseDrugsHandler(w http.ResponseWriter, r *http.Request) {
+	var req repositories.DispenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MedicalRecordID == 0 || len(req.Items) == 0 {
+		http.Error(w, "medical_record_id and items are required", http.StatusBadRequest)
+		return
+	}
+	for _, it := range req.Items {
+		if it.StockItemID == 0 || it.Quantity <= 0 {
+			http.Error(w, "every item needs a stock_item_id and a quantity > 0", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := repositories.DispenseDrugs(req, currentUserID(r))
+	if err != nil {
+		writePharmacyError(w, err, "Failed to dispense")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// GetDispensingsHandler (GET /pharmacy/dispensings?patient_id=&medical_record_id=)
+func GetDispensingsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, _ := strconv.Atoi(r.URL.Query().Get("patient_id"))
+	recordID, _ := strconv.Atoi(r.URL.Query().Get("medical_record_id"))
+	list, err := repositories.GetDispensings(patientID, recordID)
+	if err != nil {
+		http.Error(w, "Failed to fetch dispensings", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// GetPharmacyAlertsHandler (GET /pharmacy/alerts?days=60)
+func GetPharmacyAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	days := jobs.NearExpiryDays()
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 {
+		days = v
+	}
+	lowStock, err := repositories.GetLowStockAlerts()
+	if err != nil {
+		http.Error(w, "Failed to fetch low stock alerts", http.StatusInternalServerError)
+		return
+	}
+	nearExpiry, err := repositories.GetNearExpiryStock(days)
+	if err != nil {
+		http.Error(w, "Failed to fetch near-expiry stock", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"low_stock":   lowStock,
+		"near_expiry": nearExpiry,
+	})
+}
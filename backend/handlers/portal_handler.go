@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+// currentPatientID returns the patient linked to a logged in portal user, or 0
+func currentPatientID(r *http.Request) int {
+	claims := claimsFromRequest(r)
+	if claims == nil || claims.Role != "patient" {
+		return 0
+	}
+	user, err := repositories.GetUserByID(claims.UserID)
+	if err != nil || user.PatientID == nil {
+		return 0
+	}
+	return *user.PatientID
+}
+
+// portalPatientID resolves the caller's patient record and answers 403 when the account is not linked
+func portalPatientID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id := currentPatientID(r)
+	if id == 0 {
+		http.Error(w, "account is not linked to a patient", http.StatusForbidden)
+		return 0, false
+	}
+	return id, true
+}
+
+// registrationLimiter throttles self-registration per client address and per MRN, so that
+// MRN and date of birth pairs cannot be guessed
+var registrationLimiter = utils.NewAttemptLimiter(5, 15*time.Minute)
+
+// RegisterPatientHandler (POST /auth/register-patient)
+// Patients create their own portal account by proving who they are with MRN and date of birth.
+// A wrong identity and an already registered patient get the same answer.
+func RegisterPatientHandler(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		MRN         string `json:"mrn"`
+		DateOfBirth string `json:"date_of_birth"`
+		Username    string `json:"username"`
+		Email       string `json:"email"`
+		Password    string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.MRN == "" || payload.DateOfBirth == "" || payload.Username == "" || payload.Email == "" {
+		http.Error(w, "mrn, date_of_birth, username and email are required", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Password) < 8 {
+		http.Error(w, "password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+	if !registrationLimiter.Allow(time.Now(), "ip:"+clientIP(r), "mrn:"+strings.ToUpper(strings.TrimSpace(payload.MRN))) {
+		http.Error(w, "Too many registration attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+	dob, err := utils.ParseDateOfBirth(payload.DateOfBirth)
+	if err != nil {
+		http.Error(w, "date_of_birth must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	hashed, err := utils.HashPassword(payload.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	user := models.User{Username: payload.Username, Email: payload.Email, Password: hashed}
+	err = repositories.RegisterPatientUser(&user, payload.MRN, dob.Format("2006-01-02"))
+	switch {
+	case errors.Is(err, repositories.ErrIdentityNotVerified), errors.Is(err, repositories.ErrPatientAlreadyRegistered):
+		http.Error(w, "Could not register with these details; contact the hospital if you already have an account", http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, repositories.ErrUserExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Could not create account", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":         user.ID,
+		"username":   user.Username,
+		"email":      user.Email,
+		"role":       user.Role,
+		"patient_id": user.PatientID,
+	})
+}
+
+// ReleaseMedicalRecordHandler (POST /doctor/records/{id}/release)
+// body: {"released": false} withdraws a record from the portal again
+func ReleaseMedicalRecordHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid record ID", http.StatusBadRequest)
+		return
+	}
+	payload := struct {
+		Released *bool `json:"released"`
+	}{}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	released := payload.Released == nil || *payload.Released
+
+	record, err := repositories.ReleaseMedicalRecord(id, released)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Medical record not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to release medical record", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// ReleaseFileHandler (POST /doctor/files/{id}/release)
+// body: {"released": false} withdraws a file from the portal again
+func ReleaseFileHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	payload := struct {
+		Released *bool `json:"released"`
+	}{}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	released := payload.Released == nil || *payload.Released
+
+	file, err := repositories.ReleaseFile(id, released)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to release file", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, file)
+}
+
+// GetMyProfileHandler (GET /api/me)
+func GetMyProfileHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	patient, err := repositories.GetPatientByID(patientID)
+	if err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, patient)
+}
+
+// GetMyAppointmentsHandler (GET /api/me/appointments)
+func GetMyAppointmentsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	list, err := repositories.GetAppointmentsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch appointments", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetPortalDoctorsHandler (GET /api/me/doctors) lists the doctors patients can book with
+func GetPortalDoctorsHandler(w http.ResponseWriter, r *http.Request) {
+	doctors, err := repositories.GetAllDoctors()
+	if err != nil {
+		http.Error(w, "Failed to fetch doctors", http.StatusInternalServerError)
+		return
+	}
+	type portalDoctor struct {
+		ID         int    `json:"id"`
+		FullName   string `json:"full_name"`
+		Speciality string `json:"speciality"`
+	}
+	list := make([]portalDoctor, 0, len(doctors))
+	for _, d := range doctors {
+		if d.Status {
+			list = append(list, portalDoctor{ID: d.ID, FullName: d.FullName, Speciality: d.Speciality})
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// BookMyAppointmentHandler (POST /api/me/appointments)
+func BookMyAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	var payload struct {
+		DoctorID int       `json:"doctor_id"`
+		DateTime time.Time `json:"date_time"`
+		Reason   string    `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.DoctorID == 0 || payload.DateTime.IsZero() {
+		http.Error(w, "doctor_id and date_time are required", http.StatusBadRequest)
+		return
+	}
+
+	policy := utils.LoadBookingPolicy()
+	appointment := models.Appointment{
+		PatientID: uint(patientID),
+		DoctorID:  uint(payload.DoctorID),
+		DateTime:  payload.DateTime,
+		Status:    "scheduled",
+		Reason:    payload.Reason,
+		Notes:     "Booked online",
+		Duration:  policy.DurationMinutes,
+	}
+	err := repositories.BookPortalAppointment(&appointment, policy, time.Now())
+	switch {
+	case errors.Is(err, utils.ErrBookingTooSoon), errors.Is(err, utils.ErrBookingTooFar):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, utils.ErrTooManyAppointments), errors.Is(err, repositories.ErrSlotUnavailable):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Doctor not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to book appointment", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := utils.PublishAppointmentEvent(ctx, "appointments.created", appointment); err != nil {
+		log.Printf("Failed to publish appointment.created: %v", err)
+	}
+	writeJSON(w, http.StatusCreated, appointment)
+}
+
+// CancelMyAppointmentHandler (POST /api/me/appointments/{id}/cancel)
+func CancelMyAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+		return
+	}
+	appointment, err := repositories.GetAppointmentByID(id)
+	if err != nil || int(appointment.PatientID) != patientID {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+	if err := utils.LoadBookingPolicy().CheckCancellation(appointment.Status, appointment.DateTime, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err := repositories.UpdateAppointmentStatus(id, "cancelled"); err != nil {
+		http.Error(w, "Failed to cancel appointment", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := utils.PublishAppointmentEvent(ctx, "appointments.status_updated", map[string]interface{}{
+		"id":     id,
+		"status": "cancelled",
+	}); err != nil {
+		log.Printf("Failed to publish appointments.status_updated: %v", err)
+	}
+	appointment.Status = "cancelled"
+	writeJSON(w, http.StatusOK, appointment)
+}
+
+// GetMyInvoicesHandler (GET /api/me/invoices)
+func GetMyInvoicesHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	list, err := repositories.GetInvoicesByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+	// drafts are still being prepared by billing staff
+	issued := make([]models.Invoice, 0, len(list))
+	for _, inv := range list {
+		if inv.Status != "draft" {
+			issued = append(issued, inv)
+		}
+	}
+	writeJSON(w, http.StatusOK, issued)
+}
+
+// myInvoice loads an invoice and answers 404 unless it belongs to the caller
+func myInvoice(w http.ResponseWriter, r *http.Request) (models.Invoice, bool) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return models.Invoice{}, false
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return models.Invoice{}, false
+	}
+	inv, err := repositories.GetInvoiceByID(id)
+	if err != nil || inv.PatientID != patientID || inv.Status == "draft" {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return models.Invoice{}, false
+	}
+	return inv, true
+}
+
+// GetMyInvoiceHandler (GET /api/me/invoices/{id}) returns the invoice with its lines
+func GetMyInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	inv, ok := myInvoice(w, r)
+	if !ok {
+		return
+	}
+	lines, err := repositories.GetInvoiceLines(inv.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch invoice lines", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"invoice": inv,
+		"lines":   lines,
+	})
+}
+
+// GetMyPaymentsHandler (GET /api/me/payments)
+func GetMyPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	list, err := repositories.GetPaymentsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetMyFilesHandler (GET /api/me/files) lists only the files released to the patient
+func GetMyFilesHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	list, err := repositories.GetReleasedFilesByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch files", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// DownloadMyFileHandler (GET /api/me/files/{id}/download)
+func DownloadMyFileHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	file, err := repositories.GetFileByID(id)
+	if err != nil || file.PatientID != patientID || !file.ReleasedToPatient {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+file.FileName+"\"")
+	http.ServeFile(w, r, file.FileURL)
+}
+
+// GetMyRecordsHandler (GET /api/me/records) lists only the records a doctor released to the patient
+func GetMyRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, ok := portalPatientID(w, r)
+	if !ok {
+		return
+	}
+	list, err := repositories.GetReleasedMedicalRecordsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch medical records", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetBookingPolicyHandler (GET /api/me/booking-policy)
+func GetBookingPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, utils.LoadBookingPolicy())
+}
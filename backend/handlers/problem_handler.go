@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+)
+
+func isValidProblemStatus(status string) bool {
+	return status == "active" || status == "resolved"
+}
+
+// CreateProblemHandler
+func CreateProblemHandler(w http.ResponseWriter, r *http.Request) {
+	var problem models.Problem
+	if err := json.NewDecoder(r.Body).Decode(&problem); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if problem.PatientID == 0 || problem.DoctorID == 0 || problem.Condition == "" {
+		http.Error(w, "patient_id, doctor_id and condition are required", http.StatusBadRequest)
+		return
+	}
+	if problem.Status == "" {
+		problem.Status = "active"
+	}
+	if !isValidProblemStatus(problem.Status) {
+		http.Error(w, "status must be active or resolved", http.StatusBadRequest)
+		return
+	}
+	if problem.Status == "resolved" && problem.ResolvedDate == nil {
+		now := time.Now()
+		problem.ResolvedDate = &now
+	}
+
+	if err := repositories.CreateProblem(&problem); err != nil {
+		http.Error(w, "Failed to create problem", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// GetProblemByIDHandler
+func GetProblemByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid problem ID", http.StatusBadRequest)
+		return
+	}
+	problem, err := repositories.GetProblemByID(id)
+	if err != nil {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// GetPatientProblemsHandler (GET /patients/{id}/problems?status=active)
+func GetPatientProblemsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !isValidProblemStatus(status) {
+		http.Error(w, "status must be active or resolved", http.StatusBadRequest)
+		return
+	}
+	problems, err := repositories.GetProblemsByPatientID(patientID, status)
+	if err != nil {
+		http.Error(w, "Failed to fetch problems", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(problems)
+}
+
+// UpdateProblemHandler
+func UpdateProblemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid problem ID", http.StatusBadRequest)
+		return
+	}
+	var problem models.Problem
+	if err := json.NewDecoder(r.Body).Decode(&problem); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if problem.Status != "" && !isValidProblemStatus(problem.Status) {
+		http.Error(w, "status must be active or resolved", http.StatusBadRequest)
+		return
+	}
+	problem.ID = id
+
+	if err := repositories.UpdateProblem(problem); err != nil {
+		http.Error(w, "Failed to update problem", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": "Problem updated successfully"})
+}
+
+// ResolveProblemHandler (PATCH /problems/{id}/resolve)
+func ResolveProblemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid problem ID", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		ResolvedDate *time.Time `json:"resolved_date,omitempty"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	resolvedAt := time.Now()
+	if body.ResolvedDate != nil && !body.ResolvedDate.IsZero() {
+		resolvedAt = *body.ResolvedDate
+	}
+
+	if err := repositories.ResolveProblem(id, resolvedAt); err != nil {
+		http.Error(w, "Failed to resolve problem", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": "Problem resolved successfully"})
+}
+
+// DeleteProblemHandler
+func DeleteProblemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid problem ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.DeleteProblem(id); err != nil {
+		http.Error(w, "Failed to delete problem", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+// Referral event topics
+const (
+	ReferralSentTopic    = "referrals.sent"
+	ReferralUpdatedTopic = "referrals.updated"
+)
+
+// ReferralTopics lists the Kafka topics written by the referral handlers
+var ReferralTopics = []string{ReferralSentTopic, ReferralUpdatedTopic}
+
+func publishReferralEvent(topic string, ref models.Referral) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := utils.PublishEvent(ctx, topic, "referral-"+strconv.Itoa(ref.ID), ref); err != nil {
+		log.Printf("Failed to publish %s: %v", topic, err)
+	}
+}
+
+func writeReferralError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrReferralState), errors.Is(err, repositories.ErrSlotUnavailable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrAttachmentMismatch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Referral not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// currentDoctorID returns the doctor profile of the logged in user, or 0
+func currentDoctorID(r *http.Request) int {
+	claims := claimsFromRequest(r)
+	if claims == nil || claims.Role != "doctor" {
+		return 0
+	}
+	doctor, err := repositories.GetDoctorByUserID(claims.UserID)
+	if err != nil {
+		return 0
+	}
+	return doctor.ID
+}
+
+func isAdmin(r *http.Request) bool {
+	claims := claimsFromRequest(r)
+	return claims != nil && claims.Role == "admin"
+}
+
+// canRespondToReferral allows the receiving doctor (or an admin) to act on internal
+// referrals; replies from external providers are recorded by staff
+func canRespondToReferral(r *http.Request, ref models.Referral) bool {
+	if ref.ToDoctorID == nil || isAdmin(r) {
+		return true
+	}
+	return currentDoctorID(r) == *ref.ToDoctorID
+}
+
+func loadReferralForAction(w http.ResponseWriter, r *http.Request) (models.Referral, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid referral ID", http.StatusBadRequest)
+		return models.Referral{}, false
+	}
+	ref, err := repositories.GetReferralByID(id)
+	if err != nil {
+		http.Error(w, "Referral not found", http.StatusNotFound)
+		return ref, false
+	}
+	return ref, true
+}
+
+// CreateReferralHandler (POST /referrals)
+func CreateReferralHandler(w http.ResponseWriter, r *http.Request) {
+	var ref models.Referral
+	if err := json.NewDecoder(r.Body).Decode(&ref); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if ref.FromDoctorID == 0 {
+		ref.FromDoctorID = currentDoctorID(r)
+	}
+	if ref.PatientID == 0 || ref.FromDoctorID == 0 || ref.Reason == "" {
+		http.Error(w, "patient_id, from_doctor_id and reason are required", http.StatusBadRequest)
+		return
+	}
+	if (ref.ToDoctorID == nil) == (ref.ExternalProviderID == nil) {
+		http.Error(w, "exactly one of to_doctor_id or external_provider_id is required", http.StatusBadRequest)
+		return
+	}
+	switch ref.Urgency {
+	case "":
+		ref.Urgency = "routine"
+	case "routine", "urgent", "emergency":
+	default:
+		http.Error(w, "urgency must be routine, urgent or emergency", http.StatusBadRequest)
+		return
+	}
+	for i, a := range ref.Attachments {
+		if (a.MedicalRecordID == nil) == (a.FileID == nil) {
+			http.Error(w, "every attachment needs exactly one of medical_record_id or file_id", http.StatusBadRequest)
+			return
+		}
+		ref.Attachments[i].ID = 0
+		ref.Attachments[i].ReferralID = 0
+	}
+	if ref.ExternalProviderID != nil &&
+		!requireConsent(w, ref.PatientID, models.ConsentDataSharing, models.ConsentScopeExternalReferral) {
+		return
+	}
+
+	ref.ID = 0
+	ref.Status = "sent"
+	ref.SentAt = time.Now()
+	ref.ResponseNote = ""
+	ref.AppointmentID = nil
+	ref.RespondedAt = nil
+	ref.CompletedAt = nil
+
+	if err := repositories.CreateReferral(&ref); err != nil {
+		writeReferralError(w, err, "Failed to create referral")
+		return
+	}
+	publishReferralEvent(ReferralSentTopic, ref)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(ref)
+}
+
+// GetReferralHandler (GET /referrals/{id})
+func GetReferralHandler(w http.ResponseWriter, r *http.Request) {
+	ref, ok := loadReferralForAction(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ref)
+}
+
+type referralResponse struct {
+	Note     string     `json:"note"`
+	DateTime *time.Time `json:"date_time,omitempty"`
+	Duration int        `json:"duration"`
+}
+
+// AcceptReferralHandler (POST /referrals/{id}/accept)
+// Passing date_time books the appointment with the receiving doctor right away.
+func AcceptReferralHandler(w http.ResponseWriter, r *http.Request) {
+	ref, ok := loadReferralForAction(w, r)
+	if !ok {
+		return
+	}
+	if !canRespondToReferral(r, ref) {
+		http.Error(w, "Only the receiving doctor can accept this referral", http.StatusForbidden)
+		return
+	}
+	var payload referralResponse
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	if payload.DateTime != nil && ref.ToDoctorID == nil {
+		http.Error(w, "Appointments can only be booked for internal referrals", http.StatusBadRequest)
+		return
+	}
+
+	var appt *models.Appointment
+	updated, err := repositories.TransitionReferral(ref.ID, []string{"sent"}, func(tx *gorm.DB, ref *models.Referral) error {
+		now := time.Now()
+		ref.Status = "accepted"
+		ref.ResponseNote = payload.Note
+		ref.RespondedAt = &now
+		if payload.DateTime == nil {
+			return nil
+		}
+		a, err := repositories.BookReferralAppointment(tx, ref, *payload.DateTime, referralDuration(payload.Duration))
+		appt = &a
+		return err
+	})
+	if err != nil {
+		writeReferralError(w, err, "Failed to accept referral")
+		return
+	}
+	if appt != nil {
+		publishBookedAppointment(*appt)
+	}
+	publishReferralEvent(ReferralUpdatedTopic, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(updated)
+}
+
+// DeclineReferralHandler (POST /referrals/{id}/decline)
+func DeclineReferralHandler(w http.ResponseWriter, r *http.Request) {
+	ref, ok := loadReferralForAction(w, r)
+	if !ok {
+		return
+	}
+	if !canRespondToReferral(r, ref) {
+		http.Error(w, "Only the receiving doctor can decline this referral", http.StatusForbidden)
+		return
+	}
+	var payload referralResponse
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Note == "" {
+		http.Error(w, "note with the reason for declining is required", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := repositories.TransitionReferral(ref.ID, []string{"sent"}, func(tx *gorm.DB, ref *models.Referral) error {
+		now := time.Now()
+		ref.Status = "declined"
+		ref.ResponseNote = payload.Note
+		ref.RespondedAt = &now
+		return nil
+	})
+	if err != nil {
+		writeReferralError(w, err, "Failed to decline referral")
+		return
+	}
+	publishReferralEvent(ReferralUpdatedTopic, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(updated)
+}
+
+// BookReferralHandler (POST /referrals/{id}/book) books an accepted referral into the receiver's schedule
+func BookReferralHandler(w http.ResponseWriter, r *http.Request) {
+	ref, ok := loadReferralForAction(w, r)
+	if !ok {
+		return
+	}
+	if ref.ToDoctorID == nil {
+		http.Error(w, "Appointments can only be booked for internal referrals", http.StatusBadRequest)
+		return
+	}
+	if !canRespondToReferral(r, ref) {
+		http.Error(w, "Only the receiving doctor can book this referral", http.StatusForbidden)
+		return
+	}
+	var payload referralResponse
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.DateTime == nil {
+		http.Error(w, "date_time is required", http.StatusBadRequest)
+		return
+	}
+
+	var appt models.Appointment
+	updated, err := repositories.TransitionReferral(ref.ID, []string{"accepted"}, func(tx *gorm.DB, ref *models.Referral) error {
+		var err error
+		appt, err = repositories.BookReferralAppointment(tx, ref, *payload.DateTime, referralDuration(payload.Duration))
+		return err
+	})
+	if err != nil {
+		writeReferralError(w, err, "Failed to book referral")
+		return
+	}
+	publishBookedAppointment(appt)
+	publishReferralEvent(ReferralUpdatedTopic, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(updated)
+}
+
+// CompleteReferralHandler (POST /referrals/{id}/complete)
+func CompleteReferralHandler(w http.ResponseWriter, r *http.Request) {
+	ref, ok := loadReferralForAction(w, r)
+	if !ok {
+		return
+	}
+	if !canRespondToReferral(r, ref) {
+		http.Error(w, "Only the receiving doctor can complete this referral", http.StatusForbidden)
+		return
+	}
+	var payload referralResponse
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+
+	updated, err := repositories.TransitionReferral(ref.ID, []string{"accepted", "appointment_booked"}, func(tx *gorm.DB, ref *models.Referral) error {
+		now := time.Now()
+		ref.Status = "completed"
+		ref.CompletedAt = &now
+		if payload.Note != "" {
+			ref.ResponseNote = payload.Note
+		}
+		return nil
+	})
+	if err != nil {
+		writeReferralError(w, err, "Failed to complete referral")
+		return
+	}
+	publishReferralEvent(ReferralUpdatedTopic, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(updated)
+}
+
+// CancelReferralHandler (POST /referrals/{id}/cancel) lets the referring doctor withdraw a referral
+func CancelReferralHandler(w http.ResponseWriter, r *http.Request) {
+	ref, ok := loadReferralForAction(w, r)
+	if !ok {
+		return
+	}
+	if !isAdmin(r) && currentDoctorID(r) != ref.FromDoctorID {
+		http.Error(w, "Only the referring doctor can cancel this referral", http.StatusForbidden)
+		return
+	}
+
+	updated, err := repositories.TransitionReferral(ref.ID, []string{"sent", "accepted"}, func(tx *gorm.DB, ref *models.Referral) error {
+		ref.Status = "cancelled"
+		return nil
+	})
+	if err != nil {
+		writeReferralError(w, err, "Failed to cancel referral")
+		return
+	}
+	publishReferralEvent(ReferralUpdatedTopic, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(updated)
+}
+
+// GetReferralInboxHandler (GET /doctors/{id}/referrals/inbox?status=)
+// Without a status only referrals that still need action (sent, accepted) are listed.
+func GetReferralInboxHandler(w http.ResponseWriter, r *http.Request) {
+	doctorID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetReferralInbox(doctorID, r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "Failed to fetch referral inbox", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// GetSentReferralsHandler (GET /doctors/{id}/referrals/sent?status=)
+func GetSentReferralsHandler(w http.ResponseWriter, r *http.Request) {
+	doctorID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid doctor ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetReferralsSentBy(doctorID, r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "Failed to fetch sent referrals", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// GetPatientReferralsHandler (GET /patients/{id}/referrals)
+func GetPatientReferralsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetReferralsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch referrals", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// CreateExternalProviderHandler (POST /external-providers)
+func CreateExternalProviderHandler(w http.ResponseWriter, r *http.Request) {
+	var p models.ExternalProvider
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if p.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	p.ID = 0
+	if err := repositories.CreateExternalProvider(&p); err != nil {
+		http.Error(w, "Failed to create external provider", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// GetExternalProvidersHandler (GET /external-providers)
+func GetExternalProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	providers, err := repositories.GetExternalProviders()
+	if err != nil {
+		http.Error(w, "Failed to fetch external providers", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(providers)
+}
+
+func referralDuration(d int) int {
+	if d <= 0 {
+		return 30
+	}
+	return d
+}
+
+func publishBookedAppointment(appt models.Appointment) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := utils.PublishAppointmentEvent(ctx, "appointments.created", appt); err != nil {
+		log.Printf("Failed to publish appointment.created: %v", err)
+	}
+}
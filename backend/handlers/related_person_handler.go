@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"gorm.io/gorm"
+)
+
+func writeRelatedPersonError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrRelatedPersonInUse), errors.Is(err, repositories.ErrAlreadyLinked):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repositories.ErrInvalidRelationship):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// validateRelatedPerson checks the fields every related person needs
+func validateRelatedPerson(p models.RelatedPerson) string {
+	if p.FullName == "" || p.Relationship == "" {
+		return "full_name and relationship are required"
+	}
+	if !p.IsEmergencyContact && !p.IsGuardian && !p.IsGuarantor && !p.IsNextOfKin {
+		return "at least one of is_emergency_contact, is_guardian, is_guarantor or is_next_of_kin is required"
+	}
+	if (p.IsEmergencyContact || p.IsGuardian) && p.Phone == "" && p.AlternatePhone == "" {
+		return "emergency contacts and guardians need a phone number"
+	}
+	if p.IsGuarantor && p.Address == "" {
+		return "guarantors need a billing address"
+	}
+	return ""
+}
+
+// CreateRelatedPersonHandler (POST /patients/{id}/related-persons)
+func CreateRelatedPersonHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	var p models.RelatedPerson
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateRelatedPerson(p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if _, err := repositories.GetPatientByID(patientID); err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	p.ID = 0
+	p.PatientID = patientID
+	if p.Priority == 0 {
+		p.Priority = 1
+	}
+	if err := repositories.CreateRelatedPerson(&p); err != nil {
+		http.Error(w, "Failed to create related person", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// GetPatientRelatedPersonsHandler (GET /patients/{id}/related-persons?role=guarantor)
+func GetPatientRelatedPersonsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	role := r.URL.Query().Get("role")
+	switch role {
+	case "", "emergency_contact", "guardian", "guarantor", "next_of_kin":
+	default:
+		http.Error(w, "role must be emergency_contact, guardian, guarantor or next_of_kin", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetRelatedPersonsByPatientID(patientID, role)
+	if err != nil {
+		http.Error(w, "Failed to fetch related persons", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetRelatedPersonHandler (GET /related-persons/{id})
+func GetRelatedPersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid related person ID", http.StatusBadRequest)
+		return
+	}
+	p, err := repositories.GetRelatedPersonByID(id)
+	if err != nil {
+		writeRelatedPersonError(w, err, "Failed to fetch related person")
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// UpdateRelatedPersonHandler (PUT /related-persons/{id})
+func UpdateRelatedPersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid related person ID", http.StatusBadRequest)
+		return
+	}
+	existing, err := repositories.GetRelatedPersonByID(id)
+	if err != nil {
+		writeRelatedPersonError(w, err, "Failed to fetch related person")
+		return
+	}
+	var p models.RelatedPerson
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateRelatedPerson(p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	p.ID = id
+	p.PatientID = existing.PatientID
+	p.CreatedAt = existing.CreatedAt
+	if p.Priority == 0 {
+		p.Priority = existing.Priority
+	}
+	if err := repositories.UpdateRelatedPerson(&p); err != nil {
+		http.Error(w, "Failed to update related person", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// DeleteRelatedPersonHandler (DELETE /related-persons/{id})
+func DeleteRelatedPersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid related person ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.DeleteRelatedPerson(id); err != nil {
+		writeRelatedPersonError(w, err, "Failed to delete related person")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LinkPatientsHandler (POST /patients/{id}/family)
+// body: {"related_patient_id": 12, "relationship": "parent"} meaning patient 12 is a parent of {id}
+func LinkPatientsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		RelatedPatientID int    `json:"related_patient_id"`
+		Relationship     string `json:"relationship"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	link, err := repositories.LinkPatients(patientID, payload.RelatedPatientID, payload.Relationship)
+	if err != nil {
+		writeRelatedPersonError(w, err, "Failed to link patients")
+		return
+	}
+	writeJSON(w, http.StatusCreated, link)
+}
+
+// GetFamilyMembersHandler (GET /patients/{id}/family)
+func GetFamilyMembersHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetFamilyMembers(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch family members", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// UnlinkPatientsHandler (DELETE /patient-relationships/{id}) removes the link in both directions
+func UnlinkPatientsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid relationship ID", http.StatusBadRequest)
+		return
+	}
+	if err := repositories.UnlinkPatients(id); err != nil {
+		writeRelatedPersonError(w, err, "Failed to unlink patients")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetInvoiceBillToHandler (GET /invoices/{id}/bill-to) returns who the invoice is addressed to
+func GetInvoiceBillToHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+	inv, err := repositories.GetInvoiceByID(id)
+	if err != nil {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+	billTo, err := repositories.GetInvoiceBillTo(inv)
+	if err != nil {
+		http.Error(w, "Failed to resolve invoice addressee", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, billTo)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+)
+
+var reportIntervals = map[string]bool{"day": true, "week": true, "month": true, "quarter": true, "year": true}
+
+// reportPeriod reads ?from=2025-01-01&to=2025-01-31 as a half-open range that includes the
+// whole of the to day. Without them the report covers the current month.
+func reportPeriod(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 1, 0)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "from must be YYYY-MM-DD", http.StatusBadRequest)
+			return from, to, false
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "to must be YYYY-MM-DD", http.StatusBadRequest)
+			return from, to, false
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// writeReport answers with JSON, or with CSV when ?format=csv
+func writeReport(w http.ResponseWriter, r *http.Request, name string, rows interface{}, header []string, records [][]string) {
+	if r.URL.Query().Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"-"+time.Now().Format("20060102")+".csv\"")
+	out := csv.NewWriter(w)
+	_ = out.Write(header)
+	_ = out.WriteAll(records)
+}
+
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+// GetARAgingReportHandler (GET /reports/ar-aging?as_of=2025-03-31&by=patient&format=csv)
+// buckets open balances by days past due
+func GetARAgingReportHandler(w http.ResponseWriter, r *http.Request) {
+	asOf := time.Now()
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "as_of must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		asOf = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	by := r.URL.Query().Get("by")
+	if by != "" && by != "patient" {
+		http.Error(w, "by must be patient or empty", http.StatusBadRequest)
+		return
+	}
+	rows, err := repositories.GetARAging(asOf, by == "patient")
+	if err != nil {
+		http.Error(w, "Failed to build AR aging report", http.StatusInternalServerError)
+		return
+	}
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, []string{
+			row.Currency, optionalID(row.PatientID), row.PatientName, strconv.Itoa(row.Invoices),
+			row.Current.String(), row.Days0To30.String(), row.Days31To60.String(),
+			row.Days61To90.String(), row.Over90.String(), row.Total.String(),
+		})
+	}
+	writeReport(w, r, "ar-aging", rows, []string{
+		"currency", "patient_id", "patient_name", "invoices", "current",
+		"days_0_30", "days_31_60", "days_61_90", "days_over_90", "total",
+	}, records)
+}
+
+// GetRevenueReportHandler (GET /reports/revenue?group_by=period&interval=month&from=...&to=...&format=csv)
+// group_by is period (the default), doctor, service or payment_method
+func GetRevenueReportHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := reportPeriod(w, r)
+	if !ok {
+		return
+	}
+	switch groupBy := r.URL.Query().Get("group_by"); groupBy {
+	case "", "period":
+		interval := r.URL.Query().Get("interval")
+		if interval == "" {
+			interval = "month"
+		}
+		if !reportIntervals[interval] {
+			http.Error(w, "interval must be day, week, month, quarter or year", http.StatusBadRequest)
+			return
+		}
+		rows, err := repositories.GetRevenueByPeriod(from, to, interval)
+		if err != nil {
+			http.Error(w, "Failed to build revenue report", http.StatusInternalServerError)
+			return
+		}
+		records := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			records = append(records, []string{
+				row.Period.Format("2006-01-02"), row.Currency, row.Invoiced.String(), row.Credited.String(),
+				row.NetRevenue.String(), row.Collected.String(), row.Refunded.String(), row.NetCollected.String(),
+			})
+		}
+		writeReport(w, r, "revenue-by-"+interval, rows, []string{
+			"period", "currency", "invoiced", "credited", "net_revenue", "collected", "refunded", "net_collected",
+		}, records)
+	case "doctor":
+		rows, err := repositories.GetRevenueByDoctor(from, to)
+		if err != nil {
+			http.Error(w, "Failed to build revenue report", http.StatusInternalServerError)
+			return
+		}
+		records := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			records = append(records, []string{
+				optionalID(row.DoctorID), row.DoctorName, row.Currency, strconv.Itoa(row.Invoices),
+				row.Invoiced.String(), row.Credited.String(), row.NetRevenue.String(), row.Collected.String(),
+			})
+		}
+		writeReport(w, r, "revenue-by-doctor", rows, []string{
+			"doctor_id", "doctor_name", "currency", "invoices", "invoiced", "credited", "net_revenue", "collected",
+		}, records)
+	case "service":
+		rows, err := repositories.GetRevenueByService(from, to)
+		if err != nil {
+			http.Error(w, "Failed to build revenue report", http.StatusInternalServerError)
+			return
+		}
+		records := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			records = append(records, []string{
+				optionalID(row.ServiceItemID), row.Service, row.Name, row.Category, row.Currency,
+				strconv.Itoa(row.Quantity), row.Gross.String(), row.Discount.String(), row.Tax.String(), row.Amount.String(),
+			})
+		}
+		writeReport(w, r, "revenue-by-service", rows, []string{
+			"service_item_id", "service", "name", "category", "currency", "quantity", "gross", "discount", "tax", "amount",
+		}, records)
+	case "payment_method":
+		rows, err := repositories.GetRevenueByPaymentMethod(from, to)
+		if err != nil {
+			http.Error(w, "Failed to build revenue report", http.StatusInternalServerError)
+			return
+		}
+		records := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			records = append(records, []string{
+				row.Method, row.Currency, strconv.Itoa(row.Payments), row.Collected.String(),
+				strconv.Itoa(row.Refunds), row.Refunded.String(), row.NetCollected.String(),
+			})
+		}
+		writeReport(w, r, "revenue-by-payment-method", rows, []string{
+			"method", "currency", "payments", "collected", "refunds", "refunded", "net_collected",
+		}, records)
+	default:
+		http.Error(w, "group_by must be period, doctor, service or payment_method", http.StatusBadRequest)
+	}
+}
+
+// GetCashUpReportHandler (GET /reports/cash-up?date=2025-03-01&cashier_id=4&format=csv)
+// totals what each cashier took and refunded on one day, today by default
+func GetCashUpReportHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if v := r.URL.Query().Get("date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		day = t
+	}
+	cashierID := 0
+	if v := r.URL.Query().Get("cashier_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid cashier ID", http.StatusBadRequest)
+			return
+		}
+		cashierID = id
+	}
+	rows, err := repositories.GetCashUp(day, day.AddDate(0, 0, 1), cashierID)
+	if err != nil {
+		http.Error(w, "Failed to build cash-up report", http.StatusInternalServerError)
+		return
+	}
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, []string{
+			optionalID(row.CashierID), row.Cashier, row.Method, row.Currency, strconv.Itoa(row.Payments),
+			row.Received.String(), strconv.Itoa(row.Refunds), row.Refunded.String(), row.Net.String(),
+		})
+	}
+	writeReport(w, r, "cash-up-"+day.Format("20060102"), rows, []string{
+		"cashier_id", "cashier", "method", "currency", "payments", "received", "refunds", "refunded", "net",
+	}, records)
+}
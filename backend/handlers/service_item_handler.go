@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+// validateServiceItem checks a catalogue item and normalizes its currency
+func validateServiceItem(item *models.ServiceItem) string {
+	if item.Code == "" || item.Name == "" {
+		return "code and name are required"
+	}
+	currency, err := utils.NormalizeCurrency(item.Currency)
+	if err != nil {
+		return err.Error()
+	}
+	item.Currency = currency
+	if item.UnitPrice < 0 || item.TaxRate < 0 {
+		return "unit_price and tax_rate must not be negative"
+	}
+	return ""
+}
+
+// CreateServiceItemHandler (POST /admin/services)
+func CreateServiceItemHandler(w http.ResponseWriter, r *http.Request) {
+	var item models.ServiceItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateServiceItem(&item); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	item.ID = 0
+	item.Active = true
+	if err := repositories.CreateServiceItem(&item); err != nil {
+		if errors.Is(err, repositories.ErrServiceCodeExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create service item", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// UpdateServiceItemHandler (PUT /admin/services/{id}) also (de)activates items
+func UpdateServiceItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid service item ID", http.StatusBadRequest)
+		return
+	}
+	existing, err := repositories.GetServiceItemByID(id)
+	if err != nil {
+		http.Error(w, "Service item not found", http.StatusNotFound)
+		return
+	}
+	var item models.ServiceItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateServiceItem(&item); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	item.ID = id
+	item.CreatedAt = existing.CreatedAt
+	if err := repositories.UpdateServiceItem(&item); err != nil {
+		if errors.Is(err, repositories.ErrServiceCodeExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update service item", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// GetServiceItemsHandler (GET /services?category=lab&all=true)
+// Only active items are listed unless all=true.
+func GetServiceItemsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	list, err := repositories.GetServiceItems(q.Get("all") != "true", q.Get("category"))
+	if err != nil {
+		http.Error(w, "Failed to fetch service items", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetServiceItemHandler (GET /services/{id})
+func GetServiceItemHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid service item ID", http.StatusBadRequest)
+		return
+	}
+	item, err := repositories.GetServiceItemByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Service item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch service item", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+func writeSignedDocumentError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrDocumentSigned):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Document not found", http.StatusNotFound)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// clientIP returns the caller's address; X-Forwarded-For only counts when the request came
+// through one of the TRUSTED_PROXIES
+func clientIP(r *http.Request) string {
+	return utils.ClientIP(r.RemoteAddr, r.Header.Get("X-Forwarded-For"), utils.TrustedProxies())
+}
+
+// fillFormTemplate replaces the template placeholders with the patient's details
+func fillFormTemplate(body string, p models.Patient, now time.Time) string {
+	return strings.NewReplacer(
+		"{{patient_name}}", p.FullName,
+		"{{mrn}}", p.MRN,
+		"{{date_of_birth}}", p.DateOfBirth,
+		"{{date}}", now.Format("2006-01-02"),
+	).Replace(body)
+}
+
+// signaturePayload collects the hashed fields of a signed document
+func signaturePayload(doc models.SignedDocument) utils.SignaturePayload {
+	p := utils.SignaturePayload{
+		DocumentID:         doc.ID,
+		PatientID:          doc.PatientID,
+		Title:              doc.Title,
+		Content:            doc.Content,
+		SignerName:         doc.SignerName,
+		SignerRelationship: doc.SignerRelationship,
+		WitnessName:        doc.WitnessName,
+		SignatureType:      doc.SignatureType,
+		TypedSignature:     doc.TypedSignature,
+		SignatureImage:     doc.SignatureImage,
+		SignerIP:           doc.SignerIP,
+	}
+	if doc.SignedAt != nil {
+		p.SignedAt = *doc.SignedAt
+	}
+	if doc.SignedByUserID != nil {
+		p.SignedByUserID = *doc.SignedByUserID
+	}
+	return p
+}
+
+// renderSignedDocumentPDF renders the form text followed by the signature block
+func renderSignedDocumentPDF(doc models.SignedDocument, patient models.Patient) ([]byte, error) {
+	pdf := utils.NewPDFDocument(utils.DefaultLetterhead(), doc.Title)
+	pdf.KeyValue("Patient", patient.FullName)
+	pdf.KeyValue("MRN", patient.MRN)
+	pdf.KeyValue("Date of birth", patient.DateOfBirth)
+	pdf.Space(4)
+	pdf.Paragraph(doc.Content)
+
+	pdf.Heading("Signature")
+	if doc.SignatureType == "drawn" {
+		pdf.Image(fmt.Sprintf("signature-%d", doc.ID), doc.SignatureImage, utils.SignatureImageType(doc.SignatureImage), 60)
+	} else {
+		pdf.KeyValue("Typed signature", doc.TypedSignature)
+	}
+	pdf.KeyValue("Signed by", doc.SignerName)
+	if doc.SignerRelationship != "" {
+		pdf.KeyValue("Relationship", doc.SignerRelationship)
+	}
+	if doc.WitnessName != "" {
+		pdf.KeyValue("Witness", doc.WitnessName)
+	}
+	pdf.KeyValue("Signed at", doc.SignedAt.UTC().Format(time.RFC3339))
+	pdf.KeyValue("IP address", doc.SignerIP)
+	pdf.KeyValue("Recorded by user", strconv.Itoa(*doc.SignedByUserID))
+	pdf.KeyValue("Document ID", strconv.Itoa(doc.ID))
+	pdf.KeyValue("SHA-256", doc.ContentHash)
+	return pdf.Bytes()
+}
+
+// CreateFormTemplateHandler (POST /admin/form-templates)
+func CreateFormTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var t models.FormTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if t.Code == "" || t.Name == "" || t.Body == "" {
+		http.Error(w, "code, name and body are required", http.StatusBadRequest)
+		return
+	}
+	t.ID = 0
+	t.Active = true
+	if err := repositories.CreateFormTemplate(&t); err != nil {
+		http.Error(w, "Failed to create form template", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// UpdateFormTemplateHandler (PUT /admin/form-templates/{id})
+func UpdateFormTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+	existing, err := repositories.GetFormTemplateByID(id)
+	if err != nil {
+		http.Error(w, "Form template not found", http.StatusNotFound)
+		return
+	}
+	var t models.FormTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if t.Code == "" || t.Name == "" || t.Body == "" {
+		http.Error(w, "code, name and body are required", http.StatusBadRequest)
+		return
+	}
+	t.ID = id
+	t.CreatedAt = existing.CreatedAt
+	if err := repositories.UpdateFormTemplate(&t); err != nil {
+		http.Error(w, "Failed to update form template", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// GetFormTemplatesHandler (GET /form-templates?all=true)
+func GetFormTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := repositories.GetFormTemplates(r.URL.Query().Get("all") != "true")
+	if err != nil {
+		http.Error(w, "Failed to fetch form templates", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GenerateDocumentHandler (POST /patients/{id}/documents) fills a template for the patient
+func GenerateDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		TemplateID int `json:"template_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.TemplateID == 0 {
+		http.Error(w, "template_id is required", http.StatusBadRequest)
+		return
+	}
+	tmpl, err := repositories.GetFormTemplateByID(payload.TemplateID)
+	if err != nil || !tmpl.Active {
+		http.Error(w, "Form template not found", http.StatusNotFound)
+		return
+	}
+	patient, err := repositories.GetPatientByID(patientID)
+	if err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+
+	doc := models.SignedDocument{
+		TemplateID: tmpl.ID,
+		PatientID:  patientID,
+		Title:      tmpl.Name,
+		Content:    fillFormTemplate(tmpl.Body, patient, time.Now()),
+		Status:     "pending",
+		CreatedBy:  currentUserID(r),
+	}
+	if err := repositories.CreateSignedDocument(&doc); err != nil {
+		http.Error(w, "Failed to generate document", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, doc)
+}
+
+// GetPatientDocumentsHandler (GET /patients/{id}/documents)
+func GetPatientDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	list, err := repositories.GetSignedDocumentsByPatientID(patientID)
+	if err != nil {
+		http.Error(w, "Failed to fetch documents", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetDocumentHandler (GET /documents/{id})
+func GetDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+	doc, err := repositories.GetSignedDocumentByID(id)
+	if err != nil {
+		writeSignedDocumentError(w, err, "Failed to fetch document")
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// SignDocumentHandler (POST /documents/{id}/sign)
+// Accepts a drawn signature (base64 PNG/JPEG, data URLs allowed) or a typed one. The
+// timestamp, caller IP and logged in user are recorded and covered by the content hash.
+func SignDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		SignerName         string `json:"signer_name"`
+		SignerRelationship string `json:"signer_relationship"`
+		WitnessName        string `json:"witness_name"`
+		SignatureType      string `json:"signature_type"`
+		SignatureImage     string `json:"signature_image"`
+		TypedSignature     string `json:"typed_signature"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.SignerName == "" {
+		http.Error(w, "signer_name is required", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := repositories.GetSignedDocumentByID(id)
+	if err != nil {
+		writeSignedDocumentError(w, err, "Failed to fetch document")
+		return
+	}
+	if doc.Status != "pending" {
+		http.Error(w, repositories.ErrDocumentSigned.Error(), http.StatusConflict)
+		return
+	}
+
+	switch payload.SignatureType {
+	case "drawn":
+		img, _, err := utils.DecodeSignatureImage(payload.SignatureImage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		doc.SignatureImage = img
+	case "typed":
+		if strings.TrimSpace(payload.TypedSignature) == "" {
+			http.Error(w, "typed_signature is required", http.StatusBadRequest)
+			return
+		}
+		doc.TypedSignature = payload.TypedSignature
+	default:
+		http.Error(w, "signature_type must be drawn or typed", http.StatusBadRequest)
+		return
+	}
+
+	patient, err := repositories.GetPatientByID(doc.PatientID)
+	if err != nil {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	tmpl, err := repositories.GetFormTemplateByID(doc.TemplateID)
+	if err != nil {
+		http.Error(w, "Form template not found", http.StatusNotFound)
+		return
+	}
+
+	userID := currentUserID(r)
+	signedAt := time.Now().UTC().Truncate(time.Microsecond)
+	doc.SignerName = payload.SignerName
+	doc.SignerRelationship = payload.SignerRelationship
+	doc.WitnessName = payload.WitnessName
+	doc.SignatureType = payload.SignatureType
+	doc.SignedAt = &signedAt
+	doc.SignedByUserID = &userID
+	doc.SignerIP = clientIP(r)
+	doc.ContentHash = signaturePayload(doc).Hash()
+
+	data, err := renderSignedDocumentPDF(doc, patient)
+	if err != nil {
+		http.Error(w, "Failed to render document", http.StatusInternalServerError)
+		return
+	}
+	doc.FileHash = utils.HashBytes(data)
+	fileName := fmt.Sprintf("document_%d_signed_%d.pdf", doc.ID, signedAt.Unix())
+	path, err := writeGeneratedFile(fileName, data)
+	if err != nil {
+		http.Error(w, "Failed to store document", http.StatusInternalServerError)
+		return
+	}
+
+	file := models.File{
+		PatientID:   doc.PatientID,
+		DoctorID:    currentDoctorID(r),
+		FileName:    fileName,
+		FileType:    "application/pdf",
+		FileURL:     path,
+		Description: doc.Title + " (signed)",
+		UploadDate:  signedAt,
+		// the patient signed the document and gets a copy of it
+		ReleasedToPatient: true,
+		ReleasedAt:        &signedAt,
+	}
+	var consent *models.Consent
+	if tmpl.ConsentType != "" {
+		consent = &models.Consent{
+			PatientID:          doc.PatientID,
+			Type:               tmpl.ConsentType,
+			Scope:              tmpl.ConsentScope,
+			Status:             "granted",
+			GrantedAt:          signedAt,
+			SignerName:         doc.SignerName,
+			SignerRelationship: doc.SignerRelationship,
+			WitnessName:        doc.WitnessName,
+			RecordedBy:         userID,
+			Notes:              "Signed electronically, document #" + strconv.Itoa(doc.ID),
+		}
+		if consent.Scope == "" {
+			consent.Scope = models.ConsentScopeAll
+		}
+	}
+
+	if err := repositories.SignDocument(&doc, &file, consent); err != nil {
+		_ = os.Remove(path)
+		writeSignedDocumentError(w, err, "Failed to sign document")
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// GetDocumentPDFHandler (GET /documents/{id}/pdf)
+func GetDocumentPDFHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+	doc, err := repositories.GetSignedDocumentByID(id)
+	if err != nil || doc.FileID == nil {
+		http.Error(w, "Signed document not found", http.StatusNotFound)
+		return
+	}
+	file, err := repositories.GetFileByID(*doc.FileID)
+	if err != nil {
+		http.Error(w, "Document file not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "inline; filename=\""+file.FileName+"\"")
+	http.ServeFile(w, r, file.FileURL)
+}
+
+// VerifyDocumentHandler (GET /documents/{id}/verify)
+// recomputes the content hash from the stored fields and the hash of the stored PDF
+func VerifyDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+	doc, err := repositories.GetSignedDocumentByID(id)
+	if err != nil {
+		writeSignedDocumentError(w, err, "Failed to fetch document")
+		return
+	}
+	if doc.Status != "signed" {
+		http.Error(w, "Document has not been signed", http.StatusConflict)
+		return
+	}
+
+	result := struct {
+		DocumentID          int        `json:"document_id"`
+		Valid               bool       `json:"valid"`
+		ContentHash         string     `json:"content_hash"`
+		ComputedContentHash string     `json:"computed_content_hash"`
+		FileHash            string     `json:"file_hash"`
+		ComputedFileHash    string     `json:"computed_file_hash"`
+		SignedAt            *time.Time `json:"signed_at"`
+		SignerName          string     `json:"signer_name"`
+	}{
+		DocumentID:          doc.ID,
+		ContentHash:         doc.ContentHash,
+		ComputedContentHash: signaturePayload(doc).Hash(),
+		FileHash:            doc.FileHash,
+		SignedAt:            doc.SignedAt,
+		SignerName:          doc.SignerName,
+	}
+	if doc.FileID != nil {
+		if file, err := repositories.GetFileByID(*doc.FileID); err == nil {
+			if data, err := os.ReadFile(file.FileURL); err == nil {
+				result.ComputedFileHash = utils.HashBytes(data)
+			}
+		}
+	}
+	result.Valid = result.ContentHash == result.ComputedContentHash && result.FileHash == result.ComputedFileHash
+	writeJSON(w, http.StatusOK, result)
+}
@@ -15,7 +15,7 @@ import (
 
 func isValidRole(role string) bool {
 	switch role {
-	case "admin", "doctor", "staff":
+	case "admin", "doctor", "staff", "patient":
 		return true
 	default:
 		return false
@@ -64,6 +64,18 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized role", http.StatusBadRequest)
 		return
 	}
+	if user.Role == "patient" {
+		if user.PatientID == nil {
+			http.Error(w, "patient_id is required for the patient role", http.StatusBadRequest)
+			return
+		}
+		if _, err := repositories.GetPatientByID(*user.PatientID); err != nil {
+			http.Error(w, "Patient not found", http.StatusBadRequest)
+			return
+		}
+	} else {
+		user.PatientID = nil
+	}
 
 	// Hash password before saving
 	hashed, err := utils.HashPassword(user.Password)
@@ -123,17 +135,30 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	user.ID = id
 
+	// the patient link is set when the account is created and cannot be changed here
+	user.PatientID = nil
+	if user.Role != "" {
+		if !isValidRole(user.Role) {
+			http.Error(w, "unauthorized role", http.StatusBadRequest)
+			return
+		}
+		existing, err := repositories.GetUserByID(id)
+		if err != nil {
+			http.Error(w, "Error retrieving user", http.StatusInternalServerError)
+			return
+		}
+		if (user.Role == "patient") != (existing.PatientID != nil) {
+			http.Error(w, "role cannot switch between patient and staff accounts", http.StatusBadRequest)
+			return
+		}
+	}
+
 	err = repositories.UpdateUser(user)
 	if err != nil {
 		http.Error(w, "Error updating user", http.StatusInternalServerError)
 		return
 	}
 
-	if !isValidRole(user.Role) {
-		http.Error(w, "unauthorized role", http.StatusBadRequest)
-		return
-	}
-
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintln(w, "User updated successfully")
 }
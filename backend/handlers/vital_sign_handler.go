@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+)
+
+// CreateVitalSignHandler (POST /vitals)
+func CreateVitalSignHandler(w http.ResponseWriter, r *http.Request) {
+	var v models.VitalSign
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if v.PatientID == 0 {
+		http.Error(w, "patient_id is required", http.StatusBadRequest)
+		return
+	}
+	v.ID = 0
+	if v.RecordedAt.IsZero() {
+		v.RecordedAt = time.Now()
+	}
+	if v.RecordedBy == 0 {
+		v.RecordedBy = currentUserID(r)
+	}
+
+	if err := repositories.CreateVitalSign(&v); err != nil {
+		http.Error(w, "Failed to record vital signs", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// GetPatientVitalSignsHandler (GET /patients/{id}/vitals?admission_id=)
+func GetPatientVitalSignsHandler(w http.ResponseWriter, r *http.Request) {
+	patientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid patient ID", http.StatusBadRequest)
+		return
+	}
+	admissionID, _ := strconv.Atoi(r.URL.Query().Get("admission_id"))
+
+	vitals, err := repositories.GetVitalSignsByPatientID(patientID, admissionID)
+	if err != nil {
+		http.Error(w, "Failed to fetch vital signs", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(vitals)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+)
+
+// CreateWardHandler
+func CreateWardHandler(w http.ResponseWriter, r *http.Request) {
+	var ward models.Ward
+	if err := json.NewDecoder(r.Body).Decode(&ward); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if ward.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	ward.Rooms = nil
+
+	if err := repositories.CreateWard(&ward); err != nil {
+		http.Error(w, "Failed to create ward", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(ward)
+}
+
+// GetAllWardsHandler
+func GetAllWardsHandler(w http.ResponseWriter, r *http.Request) {
+	wards, err := repositories.GetAllWards()
+	if err != nil {
+		http.Error(w, "Failed to fetch wards", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(wards)
+}
+
+// CreateRoomHandler (POST /wards/{id}/rooms)
+func CreateRoomHandler(w http.ResponseWriter, r *http.Request) {
+	wardID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ward ID", http.StatusBadRequest)
+		return
+	}
+	var room models.Room
+	if err := json.NewDecoder(r.Body).Decode(&room); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if room.Number == "" {
+		http.Error(w, "number is required", http.StatusBadRequest)
+		return
+	}
+	room.WardID = wardID
+	room.Beds = nil
+
+	if err := repositories.CreateRoom(&room); err != nil {
+		http.Error(w, "Failed to create room", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(room)
+}
+
+// CreateBedHandler (POST /rooms/{id}/beds)
+func CreateBedHandler(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid room ID", http.StatusBadRequest)
+		return
+	}
+	var bed models.Bed
+	if err := json.NewDecoder(r.Body).Decode(&bed); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if bed.Label == "" {
+		http.Error(w, "label is required", http.StatusBadRequest)
+		return
+	}
+	bed.RoomID = roomID
+	bed.Status = "free"
+
+	if err := repositories.CreateBed(&bed); err != nil {
+		http.Error(w, "Failed to create bed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(bed)
+}
+
+// GetBedsHandler (GET /beds?ward_id=&status=)
+func GetBedsHandler(w http.ResponseWriter, r *http.Request) {
+	wardID, _ := strconv.Atoi(r.URL.Query().Get("ward_id"))
+	beds, err := repositories.GetBeds(wardID, r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "Failed to fetch beds", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(beds)
+}
+
+// UpdateBedStatusHandler (PATCH /beds/{id}/status) for housekeeping: free, cleaning, blocked
+func UpdateBedStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid bed ID", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch payload.Status {
+	case "free", "cleaning", "blocked":
+	default:
+		http.Error(w, "status must be free, cleaning or blocked; occupancy changes through admissions", http.StatusBadRequest)
+		return
+	}
+
+	if err := repositories.SetBedStatus(id, payload.Status); err != nil {
+		if errors.Is(err, repositories.ErrBedNotAvailable) {
+			http.Error(w, "Bed is occupied or does not exist", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update bed status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": "Bed status updated successfully"})
+}
+
+type bedBoardBed struct {
+	models.Bed
+	Occupant *repositories.BedOccupant `json:"occupant,omitempty"`
+}
+
+type bedBoardRoom struct {
+	ID     int           `json:"id"`
+	Number string        `json:"number"`
+	Beds   []bedBoardBed `json:"beds"`
+}
+
+type bedBoardWard struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Department string         `json:"department"`
+	Floor      string         `json:"floor"`
+	Counts     map[string]int `json:"counts"`
+	Rooms      []bedBoardRoom `json:"rooms"`
+}
+
+// GetBedBoardHandler (GET /beds/board) returns every ward with live bed status and occupants
+func GetBedBoardHandler(w http.ResponseWriter, r *http.Request) {
+	wards, err := repositories.GetAllWards()
+	if err != nil {
+		http.Error(w, "Failed to fetch wards", http.StatusInternalServerError)
+		return
+	}
+	occupants, err := repositories.GetBedOccupants()
+	if err != nil {
+		http.Error(w, "Failed to fetch admissions", http.StatusInternalServerError)
+		return
+	}
+	byBed := make(map[int]*repositories.BedOccupant, len(occupants))
+	for i := range occupants {
+		byBed[occupants[i].BedID] = &occupants[i]
+	}
+
+	board := make([]bedBoardWard, 0, len(wards))
+	for _, ward := range wards {
+		bw := bedBoardWard{
+			ID:         ward.ID,
+			Name:       ward.Name,
+			Department: ward.Department,
+			Floor:      ward.Floor,
+			Counts:     map[string]int{"free": 0, "occupied": 0, "cleaning": 0, "blocked": 0},
+			Rooms:      []bedBoardRoom{},
+		}
+		for _, room := range ward.Rooms {
+			br := bedBoardRoom{ID: room.ID, Number: room.Number, Beds: []bedBoardBed{}}
+			for _, bed := range room.Beds {
+				bw.Counts[bed.Status]++
+				br.Beds = append(br.Beds, bedBoardBed{Bed: bed, Occupant: byBed[bed.ID]})
+			}
+			bw.Rooms = append(bw.Rooms, br)
+		}
+		board = append(board, bw)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(board)
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+)
+
+// RunDunning flags invoices that passed their due date as overdue, then works through the
+// overdue ones: it sends the reminders the policy schedules, issues the late-fee invoice and hands
+// invoices to collections. Everything it does is written to the invoice's communication log.
+func RunDunning(ctx context.Context) {
+	now := time.Now()
+	if n, err := repositories.MarkOverdueInvoices(now); err == nil && n > 0 {
+		log.Printf("[jobs] %d invoices are now overdue", n)
+	}
+
+	policy := utils.LoadDunningPolicy()
+	invoices, err := repositories.GetDunningCandidates()
+	if err != nil {
+		return
+	}
+	mailer := utils.NewMailer()
+	reminded, fees, escalated := 0, 0, 0
+	for _, inv := range invoices {
+		if ctx.Err() != nil {
+			return
+		}
+		days := utils.DaysOverdue(inv.DueDate, now)
+		if policy.Escalates(days) {
+			updated, err := repositories.EscalateToCollections(inv.ID, now)
+			if err != nil {
+				continue
+			}
+			escalated++
+			sendDunningNotice(mailer, updated, 0, "", now)
+			continue
+		}
+
+		stage := policy.ReminderStage(days, inv.DunningStage)
+		charged, err := repositories.InvoiceHasLateFee(inv.ID)
+		if err != nil {
+			continue
+		}
+		fee := policy.LateFeeFor(inv.BalanceDue, days, charged)
+		if stage == 0 && fee == 0 {
+			continue
+		}
+		updated, feeInvoice, err := repositories.AdvanceDunning(inv.ID, stage, fee, now)
+		if err != nil {
+			if !errors.Is(err, repositories.ErrDunningNotDue) {
+				log.Printf("[jobs] dunning failed invoice=%d: %v", inv.ID, err)
+			}
+			continue
+		}
+		lateFee := ""
+		if feeInvoice != nil {
+			fees++
+			lateFee = feeInvoice.Amount.String() + " " + feeInvoice.Currency + " (invoice " + invoiceReference(*feeInvoice) + ")"
+			logCommunication(&models.InvoiceCommunication{
+				InvoiceID: inv.ID,
+				PatientID: inv.PatientID,
+				Kind:      "late_fee",
+				Channel:   "system",
+				Stage:     stage,
+				Subject:   "Late payment fee of " + lateFee + " issued",
+				Status:    "logged",
+			})
+		}
+		if stage > 0 {
+			reminded++
+			sendDunningNotice(mailer, updated, stage, lateFee, now)
+		}
+	}
+	log.Printf("[jobs] dunning reminders=%d late_fees=%d collections=%d", reminded, fees, escalated)
+}
+
+// sendDunningNotice emails reminder stage (0 for the collections notice) to the invoice's
+// contact, mentioning lateFee when one was just charged. Without an email address, or when
+// sending fails, a letter is queued for printing.
+func sendDunningNotice(mailer utils.Mailer, inv models.Invoice, stage int, lateFee string, now time.Time) {
+	kind := "reminder"
+	if stage == 0 {
+		kind = "collections"
+	}
+	entry := models.InvoiceCommunication{
+		InvoiceID: inv.ID,
+		PatientID: inv.PatientID,
+		Kind:      kind,
+		Stage:     stage,
+	}
+	contact, err := repositories.GetDunningContact(inv)
+	if err != nil {
+		entry.Channel = "system"
+		entry.Status = "failed"
+		entry.Error = "could not look up the contact: " + err.Error()
+		logCommunication(&entry)
+		return
+	}
+	notice := utils.ComposeDunningNotice(stage, contact.Name, invoiceReference(inv), inv.BalanceDue.String()+" "+inv.Currency, lateFee, inv.DueDate)
+	entry.Recipient = contact.Name
+	entry.Subject = notice.Subject
+	entry.Body = notice.Body
+
+	if contact.Email != "" {
+		email := entry
+		email.Channel = "email"
+		email.Recipient = contact.Email
+		if err := mailer.Send(contact.Email, notice.Subject, notice.Body); err != nil {
+			email.Status = "failed"
+			email.Error = err.Error()
+		} else {
+			email.Status = "sent"
+			email.SentAt = &now
+		}
+		logCommunication(&email)
+		if email.Status == "sent" {
+			return
+		}
+	}
+	entry.Channel = "letter"
+	entry.Address = contact.Address
+	entry.Status = "queued"
+	if contact.Address == "" {
+		entry.Status = "failed"
+		entry.Error = "no email or postal address on file"
+	}
+	logCommunication(&entry)
+}
+
+// invoiceReference is the number of an issued invoice, or its ID for legacy invoices without one
+func invoiceReference(inv models.Invoice) string {
+	if inv.Number != nil {
+		return *inv.Number
+	}
+	return "#" + strconv.Itoa(inv.ID)
+}
+
+func logCommunication(c *models.InvoiceCommunication) {
+	if err := repositories.CreateInvoiceCommunication(c); err != nil {
+		log.Printf("[jobs] failed to log %s for invoice=%d: %v", c.Kind, c.InvoiceID, err)
+	}
+}
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/samichen99/HAP-hospital-management-system/fhir"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+)
+
+// ExportTypes are the resource types supported by $export, in output order
+var ExportTypes = []string{"Patient", "Appointment", "Condition", "DocumentReference", "Invoice"}
+
+var exportCancels sync.Map // job id -> context.CancelFunc
+
+// ExportDir returns the directory where NDJSON output files are written
+func ExportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "./exports"
+}
+
+// StartFHIRExport runs an export job in the background
+func StartFHIRExport(job models.ExportJob, types []string, baseURL string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	exportCancels.Store(job.ID, cancel)
+
+	go func() {
+		defer exportCancels.Delete(job.ID)
+		defer cancel()
+		runFHIRExport(ctx, job, types, baseURL)
+	}()
+}
+
+// CancelFHIRExport stops a running export. It returns false when the job is not running.
+func CancelFHIRExport(id string) bool {
+	v, ok := exportCancels.Load(id)
+	if !ok {
+		return false
+	}
+	v.(context.CancelFunc)()
+	return true
+}
+
+func runFHIRExport(ctx context.Context, job models.ExportJob, types []string, baseURL string) {
+	dir := filepath.Join(ExportDir(), job.ID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		_ = repositories.UpdateExportJobStatus(job.ID, "failed", "", err.Error())
+		return
+	}
+
+	// patients who have not consented to data sharing are left out of bulk exports
+	filter := repositories.ExportFilter{
+		Since:        job.Since,
+		ConsentType:  models.ConsentDataSharing,
+		ConsentScope: models.ConsentScopeBulkExport,
+	}
+	if job.PatientID != nil {
+		filter.PatientID = *job.PatientID
+	}
+
+	for i, resourceType := range types {
+		progress := fmt.Sprintf("exporting %s (%d/%d)", resourceType, i+1, len(types))
+		_ = repositories.UpdateExportJobStatus(job.ID, "in-progress", progress, "")
+
+		fileName := resourceType + ".ndjson"
+		path := filepath.Join(dir, fileName)
+		count, err := exportResourceType(ctx, resourceType, path, filter, baseURL)
+		if ctx.Err() != nil {
+			log.Printf("[export] job=%s cancelled", job.ID)
+			_ = os.RemoveAll(dir)
+			_ = repositories.DeleteExportJob(job.ID)
+			return
+		}
+		if err != nil {
+			log.Printf("[export] job=%s type=%s failed: %v", job.ID, resourceType, err)
+			_ = repositories.UpdateExportJobStatus(job.ID, "failed", progress, err.Error())
+			return
+		}
+
+		if count == 0 {
+			_ = os.Remove(path)
+			continue
+		}
+		if err := repositories.CreateExportFile(&models.ExportFile{
+			JobID:        job.ID,
+			ResourceType: resourceType,
+			FileName:     fileName,
+			Path:         path,
+			Count:        count,
+		}); err != nil {
+			_ = repositories.UpdateExportJobStatus(job.ID, "failed", progress, err.Error())
+			return
+		}
+	}
+
+	_ = repositories.UpdateExportJobStatus(job.ID, "completed", "done", "")
+	log.Printf("[export] job=%s completed", job.ID)
+}
+
+// exportResourceType streams one resource type into an NDJSON file and returns the number of lines
+func exportResourceType(ctx context.Context, resourceType, path string, f repositories.ExportFilter, baseURL string) (int, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w) // Encode appends the newline NDJSON needs
+	count := 0
+	write := func(resource interface{}) error {
+		count++
+		return enc.Encode(resource)
+	}
+
+	switch resourceType {
+	case "Patient":
+		err = repositories.StreamForExport(ctx, f, "id", "updated_at", func(p models.Patient) error {
+			return write(fhir.PatientResource(p))
+		})
+	case "Appointment":
+		err = repositories.StreamForExport(ctx, f, "patient_id", "updated_at", func(a models.Appointment) error {
+			return write(fhir.AppointmentResource(a))
+		})
+	case "Condition":
+		err = repositories.StreamForExport(ctx, f, "patient_id", "updated_at", func(m models.MedicalRecord) error {
+			return write(fhir.ConditionResource(m))
+		})
+	case "DocumentReference":
+		err = repositories.StreamForExport(ctx, f, "patient_id", "updated_at", func(file models.File) error {
+			return write(fhir.DocumentReferenceResource(file, baseURL))
+		})
+	case "Invoice":
+		err = repositories.StreamForExport(ctx, f, "patient_id", "updated_at", func(inv models.Invoice) error {
+			return write(fhir.InvoiceResource(inv))
+		})
+	default:
+		err = fmt.Errorf("unsupported export type %s", resourceType)
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, w.Flush()
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+)
+
+// ImmunizationOverdueTopic receives one event per overdue dose for the reminder pipeline
+const ImmunizationOverdueTopic = "immunizations.overdue"
+
+// SendImmunizationReminders publishes newly overdue doses for every patient
+func SendImmunizationReminders(ctx context.Context) {
+	schedule, err := repositories.GetVaccinationSchedule(true)
+	if err != nil || len(schedule) == 0 {
+		return
+	}
+	patients, err := repositories.GetAllPatients()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	sent := 0
+	for _, patient := range patients {
+		if ctx.Err() != nil {
+			return
+		}
+		dob, err := utils.ParseDateOfBirth(patient.DateOfBirth)
+		if err != nil {
+			continue
+		}
+		given, err := repositories.GetImmunizationsByPatientID(patient.ID)
+		if err != nil {
+			continue
+		}
+
+		for _, due := range utils.ComputeDueImmunizations(dob, schedule, given, now, 0) {
+			if due.Status != "overdue" {
+				continue
+			}
+			alreadySent, err := repositories.ImmunizationReminderSent(patient.ID, due.VaccineCode, due.DoseNumber)
+			if err != nil || alreadySent {
+				continue
+			}
+
+			pubCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			err = utils.PublishEvent(pubCtx, ImmunizationOverdueTopic, "patient-"+strconv.Itoa(patient.ID), map[string]interface{}{
+				"patient_id":   patient.ID,
+				"patient_name": patient.FullName,
+				"phone":        patient.Phone,
+				"vaccine_code": due.VaccineCode,
+				"vaccine_name": due.VaccineName,
+				"dose_number":  due.DoseNumber,
+				"due_date":     due.DueDate.Format("2006-01-02"),
+				"days_overdue": due.DaysOverdue,
+			})
+			cancel()
+			if err != nil {
+				log.Printf("[jobs] failed to publish immunization reminder patient=%d: %v", patient.ID, err)
+				continue
+			}
+			if err := repositories.RecordImmunizationReminder(patient.ID, due.VaccineCode, due.DoseNumber); err == nil {
+				sent++
+			}
+		}
+	}
+	log.Printf("[jobs] immunization reminders sent=%d", sent)
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+)
+
+func envDays(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// ComputeReorderSuggestions derives daily consumption from the issues of the last
+// INVENTORY_USAGE_DAYS (default 30) and suggests orders covering the supplier lead time
+// plus INVENTORY_COVER_DAYS (default 14)
+func ComputeReorderSuggestions(ctx context.Context) {
+	usageDays := envDays("INVENTORY_USAGE_DAYS", 30)
+	coverDays := envDays("INVENTORY_COVER_DAYS", 14)
+
+	now := time.Now()
+	stats, err := repositories.GetConsumptionStats(now.AddDate(0, 0, -usageDays))
+	if err != nil || ctx.Err() != nil {
+		return
+	}
+
+	var suggestions []models.ReorderSuggestion
+	for _, s := range stats {
+		daily := float64(s.Issued) / float64(usageDays)
+		qty := utils.SuggestReorderQuantity(s.OnHand, s.OnOrder, s.ReorderLevel, s.ReorderQuantity, daily, s.LeadTimeDays, coverDays)
+		if qty == 0 {
+			continue
+		}
+		suggestions = append(suggestions, models.ReorderSuggestion{
+			ItemID:            s.ItemID,
+			LocationID:        s.LocationID,
+			OnHand:            s.OnHand,
+			OnOrder:           s.OnOrder,
+			DailyUsage:        daily,
+			SuggestedQuantity: qty,
+			Status:            "open",
+			ComputedAt:        now,
+		})
+	}
+	if err := repositories.ReplaceReorderSuggestions(suggestions); err != nil {
+		return
+	}
+	log.Printf("[jobs] reorder suggestions computed=%d", len(suggestions))
+}
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/repositories"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+)
+
+// PharmacyAlertsTopic receives low-stock and near-expiry alerts
+const PharmacyAlertsTopic = "pharmacy.alerts"
+
+// NearExpiryDays is the warning window for expiring batches (PHARMACY_EXPIRY_WARNING_DAYS, default 60)
+func NearExpiryDays() int {
+	return envDays("PHARMACY_EXPIRY_WARNING_DAYS", 60)
+}
+
+// SendPharmacyAlerts publishes the current low-stock and near-expiry situation
+func SendPharmacyAlerts(ctx context.Context) {
+	lowStock, err := repositories.GetLowStockAlerts()
+	if err != nil {
+		return
+	}
+	nearExpiry, err := repositories.GetNearExpiryStock(NearExpiryDays())
+	if err != nil {
+		return
+	}
+	if len(lowStock) == 0 && len(nearExpiry) == 0 {
+		return
+	}
+
+	pubCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := utils.PublishEvent(pubCtx, PharmacyAlertsTopic, "pharmacy", map[string]interface{}{
+		"low_stock":   lowStock,
+		"near_expiry": nearExpiry,
+	}); err != nil {
+		log.Printf("[jobs] failed to publish pharmacy alerts: %v", err)
+		return
+	}
+	log.Printf("[jobs] pharmacy alerts low_stock=%d near_expiry=%d", len(lowStock), len(nearExpiry))
+}
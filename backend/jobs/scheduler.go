@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn once shortly after startup and then on every interval tick.
+// The returned function stops the job.
+func Every(name string, interval time.Duration, fn func(ctx context.Context)) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		log.Printf("[jobs] %s scheduled every %s", name, interval)
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				start := time.Now()
+				fn(ctx)
+				log.Printf("[jobs] %s finished in %s", name, time.Since(start).Round(time.Millisecond))
+				timer.Reset(interval)
+			}
+		}
+	}()
+	return cancel
+}
+
+// Daily runs fn every day at the given local hour (0-23). The returned function stops the job.
+func Daily(name string, hour int, fn func(ctx context.Context)) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			log.Printf("[jobs] %s next run at %s", name, next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				start := time.Now()
+				fn(ctx)
+				log.Printf("[jobs] %s finished in %s", name, time.Since(start).Round(time.Millisecond))
+			}
+		}
+	}()
+	return cancel
+}
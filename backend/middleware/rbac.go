@@ -12,7 +12,7 @@ func RequireRole(role string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get claims from context (set by AuthMiddleware)
-			claims, ok := r.Context().Value("user").(*utils.Claims)
+			claims, ok := r.Context().Value(UserClaimsKey).(*utils.Claims)
 			if !ok {
 				http.Error(w, "unauthorized", http.StatusUnauthorized)
 				return
@@ -31,3 +31,16 @@ func RequireRole(role string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// DenyRole rejects users with the given role, e.g. keeps patient accounts out of the staff API
+func DenyRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if claims, ok := r.Context().Value(UserClaimsKey).(*utils.Claims); ok && claims.Role == role {
+				http.Error(w, "forbidden: insufficient permissions", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Admission links an inpatient stay of a patient to a bed and attending doctor
+type Admission struct {
+	ID             int           `gorm:"primaryKey" json:"id"`
+	PatientID      int           `gorm:"not null;index" json:"patient_id"`
+	DoctorID       int           `gorm:"not null;index" json:"doctor_id"`
+	BedID          int           `gorm:"not null;index" json:"bed_id"`
+	Status         string        `gorm:"not null;index" json:"status"`
+	Reason         string        `json:"reason"`
+	AdmittedAt     time.Time     `gorm:"not null" json:"admitted_at"`
+	DischargedAt   *time.Time    `json:"discharged_at,omitempty"`
+	DischargeNotes string        `json:"discharge_notes"`
+	Movements      []BedMovement `gorm:"foreignKey:AdmissionID" json:"movements,omitempty"`
+}
+
+// BedMovement is one entry of the admission movement history (admit, transfer, discharge)
+type BedMovement struct {
+	ID          int       `gorm:"primaryKey" json:"id"`
+	AdmissionID int       `gorm:"not null;index" json:"admission_id"`
+	Type        string    `gorm:"not null" json:"type"`
+	FromBedID   *int      `json:"from_bed_id,omitempty"`
+	ToBedID     *int      `json:"to_bed_id,omitempty"`
+	Reason      string    `json:"reason"`
+	PerformedBy int       `json:"performed_by"`
+	MovedAt     time.Time `gorm:"not null" json:"moved_at"`
+}
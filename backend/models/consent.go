@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Consent types
+const (
+	ConsentTreatment   = "treatment"
+	ConsentDataSharing = "data_sharing"
+	ConsentResearch    = "research"
+)
+
+// Consent scopes checked by the code paths that share patient data. A consent with
+// an empty scope or scope "all" covers every use of its type.
+const (
+	ConsentScopeAll              = "all"
+	ConsentScopeFHIRAPI          = "fhir_api"
+	ConsentScopeBulkExport       = "bulk_export"
+	ConsentScopeExternalReferral = "external_referral"
+)
+
+// Consent records a patient's decision for one consent type. Revoking keeps the row
+// for the audit trail; a new grant is recorded as a new consent.
+type Consent struct {
+	ID                 int        `gorm:"primaryKey" json:"id"`
+	PatientID          int        `gorm:"not null;index" json:"patient_id"`
+	Type               string     `gorm:"not null;index" json:"type"`
+	Scope              string     `gorm:"not null;default:'all'" json:"scope"`
+	Status             string     `gorm:"not null;index" json:"status"`
+	GrantedAt          time.Time  `gorm:"not null" json:"granted_at"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+	RevocationReason   string     `json:"revocation_reason,omitempty"`
+	SignerName         string     `gorm:"not null" json:"signer_name"`
+	SignerRelationship string     `json:"signer_relationship"`
+	WitnessName        string     `json:"witness_name"`
+	FileID             *int       `json:"file_id,omitempty"`
+	RecordedBy         int        `gorm:"index" json:"recorded_by"`
+	Notes              string     `json:"notes"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Refund is money given back against a payment. Refunds and credit notes are never deleted;
+// they are voided with a reason instead. Refunds of online payments stay pending until the
+// gateway has paid them out.
+type Refund struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	PaymentID  int        `gorm:"not null;index" json:"payment_id"`
+	InvoiceID  int        `gorm:"not null;index" json:"invoice_id"`
+	Amount     Money      `gorm:"not null" json:"amount"`
+	Currency   string     `gorm:"size:3;not null" json:"currency"`
+	Method     string     `gorm:"not null" json:"method"`
+	Reason     string     `gorm:"not null" json:"reason"`
+	RefundedAt time.Time  `gorm:"not null" json:"refunded_at"`
+	RecordedBy int        `json:"recorded_by"`
+	Status     string     `gorm:"not null;default:'confirmed'" json:"status"` // pending, confirmed
+	IntentID   *int       `gorm:"index" json:"payment_intent_id,omitempty"`
+	VoidedAt   *time.Time `json:"voided_at,omitempty"`
+	VoidReason string     `json:"void_reason,omitempty"`
+	VoidedBy   *int       `json:"voided_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreditNote reduces what is owed on an invoice. Number comes from its own gapless
+// sequence per fiscal year, e.g. CN-2025-000001, and stays taken when the note is voided.
+type CreditNote struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	Number     string     `gorm:"not null;uniqueIndex" json:"number"`
+	InvoiceID  int        `gorm:"not null;index" json:"invoice_id"`
+	PatientID  int        `gorm:"not null;index" json:"patient_id"`
+	Amount     Money      `gorm:"not null" json:"amount"`
+	Currency   string     `gorm:"size:3;not null" json:"currency"`
+	Reason     string     `gorm:"not null" json:"reason"`
+	IssuedAt   time.Time  `gorm:"not null" json:"issued_at"`
+	CreatedBy  int        `json:"created_by"`
+	VoidedAt   *time.Time `json:"voided_at,omitempty"`
+	VoidReason string     `json:"void_reason,omitempty"`
+	VoidedBy   *int       `json:"voided_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NumberSequence holds the last number handed out for a named document series. Numbers are
+// taken inside the transaction that creates the document, so the series has no gaps.
+type NumberSequence struct {
+	Name  string `gorm:"primaryKey" json:"name"`
+	Value int64  `gorm:"not null" json:"value"`
+}
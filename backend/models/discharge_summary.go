@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// DischargeSummary is the discharge letter of an admission. Drafts can be regenerated,
+// signed summaries are locked together with their PDF file.
+type DischargeSummary struct {
+	ID          int                     `gorm:"primaryKey" json:"id"`
+	AdmissionID int                     `gorm:"not null;uniqueIndex" json:"admission_id"`
+	PatientID   int                     `gorm:"not null;index" json:"patient_id"`
+	DoctorID    int                     `gorm:"not null;index" json:"doctor_id"`
+	Status      string                  `gorm:"not null;default:draft" json:"status"`
+	Content     DischargeSummaryContent `gorm:"type:text;serializer:json" json:"content"`
+	FileID      *int                    `json:"file_id,omitempty"`
+	SignedAt    *time.Time              `json:"signed_at,omitempty"`
+	SignedBy    *int                    `json:"signed_by,omitempty"`
+	CreatedAt   time.Time               `json:"created_at"`
+	UpdatedAt   time.Time               `json:"updated_at"`
+}
+
+// DischargeSummaryContent is the structured document rendered into the PDF
+type DischargeSummaryContent struct {
+	PatientName     string                `json:"patient_name"`
+	MRN             string                `json:"mrn"`
+	DateOfBirth     string                `json:"date_of_birth"`
+	Gender          string                `json:"gender"`
+	DoctorName      string                `json:"doctor_name"`
+	AdmittedAt      time.Time             `json:"admitted_at"`
+	DischargedAt    time.Time             `json:"discharged_at"`
+	AdmissionReason string                `json:"admission_reason"`
+	DischargeNotes  string                `json:"discharge_notes"`
+	Diagnoses       []DischargeDiagnosis  `json:"diagnoses"`
+	Medications     []DischargeMedication `json:"medications"`
+	Vitals          []VitalSign           `json:"vitals"`
+	LabResults      []DischargeLabResult  `json:"lab_results"`
+	ClinicalCourse  string                `json:"clinical_course"`
+	Instructions    string                `json:"instructions"`
+	FollowUp        string                `json:"follow_up"`
+}
+
+type DischargeDiagnosis struct {
+	Diagnosis  string    `json:"diagnosis"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+type DischargeMedication struct {
+	Prescription string    `json:"prescription"`
+	PrescribedAt time.Time `json:"prescribed_at"`
+}
+
+type DischargeLabResult struct {
+	TestName       string    `json:"test_name"`
+	Value          string    `json:"value"`
+	Units          string    `json:"units"`
+	ReferenceRange string    `json:"reference_range"`
+	AbnormalFlag   string    `json:"abnormal_flag"`
+	ObservedAt     time.Time `json:"observed_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// EDEncounter tracks an emergency department visit from arrival to departure.
+// Status goes waiting -> triaged -> in_treatment -> departed.
+type EDEncounter struct {
+	ID                int        `gorm:"primaryKey" json:"id"`
+	PatientID         int        `gorm:"not null;index" json:"patient_id"`
+	ArrivalAt         time.Time  `gorm:"not null;index" json:"arrival_at"`
+	ArrivalMode       string     `json:"arrival_mode"`
+	ChiefComplaint    string     `gorm:"not null" json:"chief_complaint"`
+	TriageLevel       *int       `gorm:"index" json:"triage_level,omitempty"`
+	TriagedAt         *time.Time `json:"triaged_at,omitempty"`
+	TriagedBy         *int       `json:"triaged_by,omitempty"`
+	TriageVitalSignID *int       `json:"triage_vital_sign_id,omitempty"`
+	TriageVitals      *VitalSign `gorm:"foreignKey:TriageVitalSignID" json:"triage_vitals,omitempty"`
+	DoctorID          *int       `gorm:"index" json:"doctor_id,omitempty"`
+	SeenAt            *time.Time `json:"seen_at,omitempty"`
+	Status            string     `gorm:"not null;index" json:"status"`
+	Disposition       string     `json:"disposition"`
+	AdmissionID       *int       `json:"admission_id,omitempty"`
+	DepartedAt        *time.Time `json:"departed_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ExportJob tracks an asynchronous FHIR bulk data ($export) request
+type ExportJob struct {
+	ID              string       `gorm:"primaryKey;size:32" json:"id"`
+	Level           string       `gorm:"not null" json:"level"`
+	PatientID       *int         `gorm:"index" json:"patient_id,omitempty"`
+	Types           string       `gorm:"not null" json:"types"`
+	Since           *time.Time   `json:"since,omitempty"`
+	Status          string       `gorm:"not null;index" json:"status"`
+	Progress        string       `json:"progress"`
+	Error           string       `json:"error,omitempty"`
+	RequestURL      string       `gorm:"not null" json:"request_url"`
+	RequestedBy     int          `gorm:"index" json:"requested_by"`
+	TransactionTime time.Time    `gorm:"not null" json:"transaction_time"`
+	CreatedAt       time.Time    `json:"created_at"`
+	CompletedAt     *time.Time   `json:"completed_at,omitempty"`
+	Files           []ExportFile `gorm:"foreignKey:JobID" json:"files,omitempty"`
+}
+
+// ExportFile is one NDJSON output file of an export job
+type ExportFile struct {
+	ID           int    `gorm:"primaryKey" json:"id"`
+	JobID        string `gorm:"size:32;not null;index" json:"job_id"`
+	ResourceType string `gorm:"not null" json:"resource_type"`
+	FileName     string `gorm:"not null" json:"file_name"`
+	Path         string `gorm:"not null" json:"-"`
+	Count        int    `gorm:"not null" json:"count"`
+}
@@ -11,4 +11,8 @@ type File struct {
 	FileURL     string    `gorm:"not null" json:"file_url"`
 	Description string    `json:"description"`
 	UploadDate  time.Time `gorm:"not null" json:"upload_date"`
+	UpdatedAt   time.Time `gorm:"index;default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	ReleasedToPatient bool       `gorm:"not null;default:false" json:"released_to_patient"` // downloadable in the patient portal
+	ReleasedAt        *time.Time `json:"released_at,omitempty"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// HL7ErrorMessage is an inbound HL7 message that could not be parsed or matched
+type HL7ErrorMessage struct {
+	ID               int        `gorm:"primaryKey" json:"id"`
+	MessageControlID string     `gorm:"index" json:"message_control_id"`
+	MessageType      string     `json:"message_type"`
+	Source           string     `gorm:"not null" json:"source"`
+	RawMessage       string     `gorm:"type:text;not null" json:"raw_message"`
+	Error            string     `gorm:"not null" json:"error"`
+	Resolved         bool       `gorm:"not null;default:false" json:"resolved"`
+	ReceivedAt       time.Time  `gorm:"not null" json:"received_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type Immunization struct {
+	ID               int       `gorm:"primaryKey" json:"id"`
+	PatientID        int       `gorm:"not null;index" json:"patient_id"`
+	VaccineCode      string    `gorm:"not null;index" json:"vaccine_code"`
+	VaccineName      string    `json:"vaccine_name"`
+	LotNumber        string    `json:"lot_number"`
+	DoseNumber       int       `gorm:"not null" json:"dose_number"`
+	Site             string    `json:"site"`
+	AdministeredBy   int       `gorm:"index" json:"administered_by"`
+	DateAdministered time.Time `gorm:"not null" json:"date_administered"`
+	Notes            string    `json:"notes"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// VaccinationScheduleEntry is one recommended dose of the configurable schedule
+type VaccinationScheduleEntry struct {
+	ID          int    `gorm:"primaryKey" json:"id"`
+	VaccineCode string `gorm:"not null;uniqueIndex:idx_schedule_dose" json:"vaccine_code"`
+	VaccineName string `gorm:"not null" json:"vaccine_name"`
+	DoseNumber  int    `gorm:"not null;uniqueIndex:idx_schedule_dose" json:"dose_number"`
+	AgeDays     int    `gorm:"not null" json:"age_days"`
+	GraceDays   int    `gorm:"not null;default:30" json:"grace_days"`
+	Active      bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// ImmunizationReminder records that an overdue dose was sent to the reminder pipeline
+type ImmunizationReminder struct {
+	ID          int       `gorm:"primaryKey" json:"id"`
+	PatientID   int       `gorm:"not null;uniqueIndex:idx_reminder_dose" json:"patient_id"`
+	VaccineCode string    `gorm:"not null;uniqueIndex:idx_reminder_dose" json:"vaccine_code"`
+	DoseNumber  int       `gorm:"not null;uniqueIndex:idx_reminder_dose" json:"dose_number"`
+	SentAt      time.Time `gorm:"not null" json:"sent_at"`
+}
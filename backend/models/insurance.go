@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Payer is an insurance company or other third party that pays claims.
+// PayerIdentifier is the ID the clearinghouse knows the payer by.
+type Payer struct {
+	ID              int       `gorm:"primaryKey" json:"id"`
+	Code            string    `gorm:"not null;uniqueIndex" json:"code"`
+	Name            string    `gorm:"not null" json:"name"`
+	PayerIdentifier string    `json:"payer_identifier,omitempty"`
+	Address         string    `json:"address,omitempty"`
+	Phone           string    `json:"phone,omitempty"`
+	Email           string    `json:"email,omitempty"`
+	Active          bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// InsurancePolicy is a patient's coverage with a payer. Priority 1 is the primary policy,
+// 2 the secondary and so on. EffectiveTo is empty for open-ended coverage.
+type InsurancePolicy struct {
+	ID                     int        `gorm:"primaryKey" json:"id"`
+	PatientID              int        `gorm:"not null;index" json:"patient_id"`
+	PayerID                int        `gorm:"not null;index" json:"payer_id"`
+	MemberID               string     `gorm:"not null" json:"member_id"`
+	GroupNumber            string     `json:"group_number,omitempty"`
+	SubscriberName         string     `json:"subscriber_name,omitempty"`
+	SubscriberRelationship string     `gorm:"not null;default:'self'" json:"subscriber_relationship"`
+	Priority               int        `gorm:"not null;default:1" json:"priority"`
+	EffectiveFrom          time.Time  `gorm:"not null" json:"effective_from"`
+	EffectiveTo            *time.Time `json:"effective_to,omitempty"`
+	Active                 bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+// CoverageRule says how a payer shares the cost of a service category (all categories when
+// empty). The patient pays CoPay once per claim, then CoInsurance percent of the rest; services
+// that are not covered are the patient's responsibility in full.
+type CoverageRule struct {
+	ID              int       `gorm:"primaryKey" json:"id"`
+	PayerID         int       `gorm:"not null;index" json:"payer_id"`
+	ServiceCategory string    `gorm:"not null;default:''" json:"service_category"`
+	Covered         bool      `gorm:"not null;default:true" json:"covered"`
+	CoPay           Money     `gorm:"not null;default:0" json:"co_pay"`
+	CoInsurance     float64   `gorm:"not null;default:0" json:"co_insurance"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Claim asks a payer to pay its share of an invoice. Status moves from draft to submitted,
+// then adjudicated (or denied) and finally paid once remittances cover the approved amount.
+type Claim struct {
+	ID             int         `gorm:"primaryKey" json:"id"`
+	Number         string      `gorm:"not null;uniqueIndex" json:"number"`
+	InvoiceID      int         `gorm:"not null;index" json:"invoice_id"`
+	PatientID      int         `gorm:"not null;index" json:"patient_id"`
+	PolicyID       int         `gorm:"not null;index" json:"policy_id"`
+	PayerID        int         `gorm:"not null;index" json:"payer_id"`
+	Currency       string      `gorm:"size:3;not null" json:"currency"`
+	Status         string      `gorm:"not null;index" json:"status"`
+	TotalCharged   Money       `gorm:"not null" json:"total_charged"`
+	PayerAmount    Money       `gorm:"not null" json:"payer_amount"`
+	PatientAmount  Money       `gorm:"not null" json:"patient_amount"`
+	ApprovedAmount Money       `gorm:"not null;default:0" json:"approved_amount"`
+	PaidAmount     Money       `gorm:"not null;default:0" json:"paid_amount"`
+	SubmittedAt    *time.Time  `json:"submitted_at,omitempty"`
+	AdjudicatedAt  *time.Time  `json:"adjudicated_at,omitempty"`
+	PaidAt         *time.Time  `json:"paid_at,omitempty"`
+	DenialReason   string      `json:"denial_reason,omitempty"`
+	CreatedBy      int         `json:"created_by"`
+	Lines          []ClaimLine `gorm:"foreignKey:ClaimID" json:"lines,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// ClaimLine is the split of one invoice line between payer and patient
+type ClaimLine struct {
+	ID             int    `gorm:"primaryKey" json:"id"`
+	ClaimID        int    `gorm:"not null;index" json:"claim_id"`
+	InvoiceLineID  int    `gorm:"not null;index" json:"invoice_line_id"`
+	Code           string `json:"code,omitempty"`
+	Description    string `gorm:"not null" json:"description"`
+	Quantity       int    `gorm:"not null" json:"quantity"`
+	Charged        Money  `gorm:"not null" json:"charged"`
+	PayerAmount    Money  `gorm:"not null" json:"payer_amount"`
+	PatientAmount  Money  `gorm:"not null" json:"patient_amount"`
+	ApprovedAmount Money  `gorm:"not null;default:0" json:"approved_amount"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+// InventoryItem is a general medical supply (gloves, syringes, dressings, ...)
+type InventoryItem struct {
+	ID              int       `gorm:"primaryKey" json:"id"`
+	SKU             string    `gorm:"not null;uniqueIndex" json:"sku"`
+	Name            string    `gorm:"not null" json:"name"`
+	Category        string    `gorm:"index" json:"category"`
+	Unit            string    `gorm:"not null" json:"unit"`
+	ReorderLevel    int       `json:"reorder_level"`
+	ReorderQuantity int       `json:"reorder_quantity"`
+	LeadTimeDays    int       `json:"lead_time_days"`
+	Active          bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// InventoryLocation is a warehouse, store room or ward cupboard holding stock
+type InventoryLocation struct {
+	ID     int    `gorm:"primaryKey" json:"id"`
+	Name   string `gorm:"not null;uniqueIndex" json:"name"`
+	Type   string `gorm:"not null" json:"type"`
+	WardID *int   `gorm:"index" json:"ward_id,omitempty"`
+}
+
+// StockLevel is the quantity on hand of an item at a location
+type StockLevel struct {
+	ItemID     int       `gorm:"primaryKey" json:"item_id"`
+	LocationID int       `gorm:"primaryKey" json:"location_id"`
+	Quantity   int       `gorm:"not null" json:"quantity"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// InventoryMovement records a stock change. Quantity is positive for receipt,
+// issue and transfer; adjustments carry a signed quantity on ToLocationID.
+type InventoryMovement struct {
+	ID                  int       `gorm:"primaryKey" json:"id"`
+	ItemID              int       `gorm:"not null;index" json:"item_id"`
+	Type                string    `gorm:"not null;index" json:"type"`
+	FromLocationID      *int      `gorm:"index" json:"from_location_id,omitempty"`
+	ToLocationID        *int      `gorm:"index" json:"to_location_id,omitempty"`
+	Quantity            int       `gorm:"not null" json:"quantity"`
+	PurchaseOrderLineID *int      `json:"purchase_order_line_id,omitempty"`
+	Reference           string    `json:"reference"`
+	Reason              string    `json:"reason"`
+	PerformedBy         int       `json:"performed_by"`
+	CreatedAt           time.Time `gorm:"index" json:"created_at"`
+}
+
+type Supplier struct {
+	ID          int    `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"not null;uniqueIndex" json:"name"`
+	ContactName string `json:"contact_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Address     string `json:"address"`
+	Active      bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// PurchaseOrder goes draft -> ordered -> partially_received -> received (or cancelled)
+type PurchaseOrder struct {
+	ID         int                 `gorm:"primaryKey" json:"id"`
+	SupplierID int                 `gorm:"not null;index" json:"supplier_id"`
+	LocationID int                 `gorm:"not null" json:"location_id"`
+	Status     string              `gorm:"not null;index" json:"status"`
+	OrderedAt  *time.Time          `json:"ordered_at,omitempty"`
+	ExpectedAt *time.Time          `json:"expected_at,omitempty"`
+	Notes      string              `json:"notes"`
+	CreatedBy  int                 `json:"created_by"`
+	Lines      []PurchaseOrderLine `gorm:"foreignKey:PurchaseOrderID" json:"lines"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+type PurchaseOrderLine struct {
+	ID               int   `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID  int   `gorm:"not null;index" json:"purchase_order_id"`
+	ItemID           int   `gorm:"not null;index" json:"item_id"`
+	QuantityOrdered  int   `gorm:"not null" json:"quantity_ordered"`
+	QuantityReceived int   `gorm:"not null;default:0" json:"quantity_received"`
+	UnitCost         Money `json:"unit_cost"` // in the default currency
+}
+
+// ReorderSuggestion is produced by the nightly reorder job from recent consumption
+type ReorderSuggestion struct {
+	ID                int       `gorm:"primaryKey" json:"id"`
+	ItemID            int       `gorm:"not null;index" json:"item_id"`
+	LocationID        int       `gorm:"not null;index" json:"location_id"`
+	OnHand            int       `json:"on_hand"`
+	OnOrder           int       `json:"on_order"`
+	DailyUsage        float64   `json:"daily_usage"`
+	SuggestedQuantity int       `json:"suggested_quantity"`
+	Status            string    `gorm:"not null;index" json:"status"`
+	ComputedAt        time.Time `json:"computed_at"`
+}
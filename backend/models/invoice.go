@@ -2,14 +2,70 @@ package models
 
 import "time"
 
+// Invoice is a bill to a patient, with all amounts in Currency. It starts as an editable draft
+// and is fixed once issued; its totals, balance and status are derived from the records behind it.
 type Invoice struct {
-	ID            int        `gorm:"primaryKey" json:"id"`
-	PatientID     int        `gorm:"not null;index" json:"patient_id"`
-	AppointmentID *int       `gorm:"index" json:"appointment_id,omitempty"`
-	Amount        float64    `gorm:"not null" json:"amount"`
-	Status        string     `gorm:"not null" json:"status"`
-	DueDate       time.Time  `gorm:"not null" json:"due_date"`
-	IssuedAt      time.Time  `gorm:"not null" json:"issued_at"`
-	PaidAt        *time.Time `gorm:"default:null" json:"paid_at,omitempty"`
-	Notes         string     `json:"notes"`
+	ID             int        `gorm:"primaryKey" json:"id"`
+	Number         *string    `gorm:"size:40;uniqueIndex" json:"number,omitempty"`
+	Location       string     `gorm:"size:10;not null;default:''" json:"location,omitempty"`
+	FiscalYear     int        `gorm:"not null;default:0" json:"fiscal_year,omitempty"`
+	PatientID      int        `gorm:"not null;index" json:"patient_id"`
+	AppointmentID  *int       `gorm:"index" json:"appointment_id,omitempty"`
+	GuarantorID    *int       `gorm:"index" json:"guarantor_id,omitempty"`
+	Currency       string     `gorm:"size:3;not null" json:"currency"`
+	Subtotal       Money      `gorm:"not null;default:0" json:"subtotal"`
+	DiscountTotal  Money      `gorm:"not null;default:0" json:"discount_total"`
+	TaxTotal       Money      `gorm:"not null;default:0" json:"tax_total"`
+	Amount         Money      `gorm:"not null" json:"amount"`
+	AmountPaid     Money      `gorm:"not null;default:0" json:"amount_paid"`
+	AmountCredited Money      `gorm:"not null;default:0" json:"amount_credited"`
+	BalanceDue     Money      `gorm:"not null;default:0" json:"balance_due"`
+	Status         string     `gorm:"not null" json:"status"`
+	DueDate        time.Time  `gorm:"not null" json:"due_date"`
+	IssuedAt       time.Time  `gorm:"not null" json:"issued_at"`
+	PaidAt         *time.Time `gorm:"default:null" json:"paid_at,omitempty"`
+	Notes          string     `json:"notes"`
+	VoidedAt       *time.Time `json:"voided_at,omitempty"`
+	VoidReason     string     `json:"void_reason,omitempty"`
+	VoidedBy       *int       `json:"voided_by,omitempty"`
+	DunningStage   int        `gorm:"not null;default:0" json:"dunning_stage,omitempty"`
+	LastDunnedAt   *time.Time `json:"last_dunned_at,omitempty"`
+	CollectionsAt  *time.Time `json:"collections_at,omitempty"`
+	UpdatedAt      time.Time  `gorm:"index;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// InvoiceLine is one billable item of an invoice. SourceType/SourceID point back
+// at the record that produced the charge (e.g. a pharmacy dispensing).
+// Amount is the line total: Quantity * UnitPrice - Discount + TaxAmount.
+type InvoiceLine struct {
+	ID            int       `gorm:"primaryKey" json:"id"`
+	InvoiceID     int       `gorm:"not null;index" json:"invoice_id"`
+	ServiceItemID *int      `gorm:"index" json:"service_item_id,omitempty"`
+	Code          string    `json:"code,omitempty"`
+	Description   string    `gorm:"not null" json:"description"`
+	Quantity      int       `gorm:"not null" json:"quantity"`
+	UnitPrice     Money     `gorm:"not null" json:"unit_price"`
+	Discount      Money     `gorm:"not null;default:0" json:"discount"`
+	TaxRate       float64   `gorm:"not null;default:0" json:"tax_rate"`
+	TaxAmount     Money     `gorm:"not null;default:0" json:"tax_amount"`
+	Amount        Money     `gorm:"not null" json:"amount"`
+	SourceType    string    `gorm:"index:idx_invoice_line_source" json:"source_type,omitempty"`
+	SourceID      *int      `gorm:"index:idx_invoice_line_source" json:"source_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ServiceItem is a chargeable service or procedure in the price catalogue.
+// TaxRate is a percentage applied after any line discount.
+type ServiceItem struct {
+	ID          int       `gorm:"primaryKey" json:"id"`
+	Code        string    `gorm:"not null;uniqueIndex" json:"code"`
+	Name        string    `gorm:"not null" json:"name"`
+	Category    string    `gorm:"index" json:"category"`
+	Description string    `json:"description,omitempty"`
+	Currency    string    `gorm:"size:3;not null" json:"currency"`
+	UnitPrice   Money     `gorm:"not null" json:"unit_price"`
+	TaxRate     float64   `gorm:"not null;default:0" json:"tax_rate"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// InvoiceCommunication is one entry of an invoice's communication log: a reminder email or
+// letter, a late fee, the hand-over to collections or a note of a call with the patient.
+// Letters are queued until staff mark them as posted.
+type InvoiceCommunication struct {
+	ID        int        `gorm:"primaryKey" json:"id"`
+	InvoiceID int        `gorm:"not null;index" json:"invoice_id"`
+	PatientID int        `gorm:"not null;index" json:"patient_id"`
+	Kind      string     `gorm:"not null" json:"kind"`    // reminder, late_fee, collections, note
+	Channel   string     `gorm:"not null" json:"channel"` // email, letter, phone, system
+	Stage     int        `gorm:"not null;default:0" json:"stage,omitempty"`
+	Recipient string     `json:"recipient,omitempty"`
+	Address   string     `json:"address,omitempty"`
+	Subject   string     `json:"subject,omitempty"`
+	Body      string     `json:"body,omitempty"`
+	Status    string     `gorm:"not null;index" json:"status"` // sent, queued, failed, logged
+	Error     string     `json:"error,omitempty"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	CreatedBy *int       `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type LabResult struct {
+	ID             int       `gorm:"primaryKey" json:"id"`
+	PatientID      int       `gorm:"not null;index" json:"patient_id"`
+	OrderNumber    string    `gorm:"index" json:"order_number"`
+	TestCode       string    `gorm:"not null" json:"test_code"`
+	TestName       string    `json:"test_name"`
+	Value          string    `json:"value"`
+	Units          string    `json:"units"`
+	ReferenceRange string    `json:"reference_range"`
+	AbnormalFlag   string    `json:"abnormal_flag"`
+	Status         string    `json:"status"`
+	ObservedAt     time.Time `gorm:"not null" json:"observed_at"`
+	Source         string    `json:"source"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -3,10 +3,13 @@ package models
 import "time"
 
 type MedicalRecord struct {
-	ID           int       `gorm:"primaryKey" json:"id"`
-	PatientID    int       `gorm:"not null;index" json:"patient_id"`
-	DoctorID     int       `gorm:"not null;index" json:"doctor_id"`
-	Diagnosis    string    `gorm:"not null" json:"diagnosis"`
-	Prescription string    `json:"prescription"`
-	CreationDate time.Time `gorm:"autoCreateTime;not null" json:"creation_date"`
+	ID                int        `gorm:"primaryKey" json:"id"`
+	PatientID         int        `gorm:"not null;index" json:"patient_id"`
+	DoctorID          int        `gorm:"not null;index" json:"doctor_id"`
+	Diagnosis         string     `gorm:"not null" json:"diagnosis"`
+	Prescription      string     `json:"prescription"`
+	ReleasedToPatient bool       `gorm:"not null;default:false" json:"released_to_patient"` // visible in the patient portal
+	ReleasedAt        *time.Time `json:"released_at,omitempty"`
+	CreationDate      time.Time  `gorm:"autoCreateTime;not null" json:"creation_date"`
+	UpdatedAt         time.Time  `gorm:"index;default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// MigrationMarker records that a one-time data migration has been applied
+type MigrationMarker struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
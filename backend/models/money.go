@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidMoney = errors.New("invalid amount: expected a decimal with at most two decimal places")
+
+// Money is an amount in minor currency units (cents). It is stored as a bigint so sums are
+// exact, and encoded in JSON as a decimal string such as "12.50". JSON numbers are accepted
+// on input as long as they have no more than two decimal places.
+type Money int64
+
+// ParseMoney parses a decimal amount like "12.5", "-3.05" or "100"
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || len(frac) > 2 || !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidMoney
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w > math.MaxInt64/100-1 {
+		return 0, ErrInvalidMoney
+	}
+	f, _ := strconv.ParseInt(frac, 10, 64)
+	v := w*100 + f
+	if neg {
+		v = -v
+	}
+	return Money(v), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with two decimal places
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Times multiplies a unit price by a quantity
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Percent returns rate percent of the amount, rounded half away from zero to whole cents
+func (m Money) Percent(rate float64) Money {
+	return Money(math.Round(float64(m) * rate / 100))
+}
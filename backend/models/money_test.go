@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	for in, want := range map[string]Money{"12.5": 1250, "-3.05": -305, "100": 10000, ".99": 99, "0.1": 10} {
+		got, err := ParseMoney(in)
+		if err != nil || got != want {
+			t.Errorf("ParseMoney(%q): expected %d, got %d (%v)", in, want, got, err)
+		}
+	}
+	for _, in := range []string{"", ".", "1.234", "1e3", "abc", "1,50"} {
+		if _, err := ParseMoney(in); err == nil {
+			t.Errorf("ParseMoney(%q): expected an error", in)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var v struct {
+		A Money `json:"a"`
+		B Money `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": "0.10", "b": 0.2}`), &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, _ := json.Marshal(map[string]Money{"sum": v.A + v.B, "neg": -5})
+	if string(out) != `{"neg":"-0.05","sum":"0.30"}` {
+		t.Errorf("unexpected JSON: %s", out)
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	if got := Money(5497).Percent(10); got != 550 {
+		t.Errorf("expected 550, got %d", got)
+	}
+	if got := Money(-5).Percent(50); got != -3 {
+		t.Errorf("expected -3, got %d", got)
+	}
+}
@@ -1,11 +1,17 @@
 package models
 
+import "time"
+
 type Patient struct {
-	ID              int    `gorm:"primaryKey" json:"id"`
-	FullName        string `gorm:"not null" json:"full_name"`
-	DateOfBirth     string `gorm:"not null" json:"date_of_birth"`
-	Gender          string `gorm:"not null" json:"gender"`
-	Phone           string `gorm:"not null" json:"phone"`
-	Address         string `gorm:"not null" json:"address"`
-	InsuranceNumber string `json:"insurance_number"`
+	ID              int       `gorm:"primaryKey" json:"id"`
+	MRN             string    `gorm:"uniqueIndex:idx_patients_mrn_unique,where:mrn <> ''" json:"mrn"`
+	FullName        string    `gorm:"not null" json:"full_name"`
+	DateOfBirth     string    `gorm:"not null" json:"date_of_birth"`
+	Gender          string    `gorm:"not null" json:"gender"`
+	Phone           string    `gorm:"not null" json:"phone"`
+	Address         string    `gorm:"not null" json:"address"`
+	InsuranceNumber string    `json:"insurance_number"`
+	IsTemporary     bool      `gorm:"not null;default:false" json:"is_temporary"`
+	MergedIntoID    *int      `gorm:"index" json:"merged_into_id,omitempty"`
+	UpdatedAt       time.Time `gorm:"index;default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -2,11 +2,33 @@ package models
 
 import "time"
 
+// Payment is money received against an invoice, in the invoice's currency. Voided payments
+// are kept but no longer count towards the invoice.
 type Payment struct {
-	ID            int       `gorm:"primaryKey" json:"id"`
-	InvoiceId     int       `gorm:"not null;index" json:"invoice_id"`
-	Amount        float64   `gorm:"not null" json:"amount"`
-	PaymentDate   time.Time `gorm:"not null" json:"date"`
-	PaymentMethod string    `gorm:"not null" json:"method"`
-	Notes         string    `json:"notes"`
+	ID            int        `gorm:"primaryKey" json:"id"`
+	InvoiceId     int        `gorm:"not null;index" json:"invoice_id"`
+	Amount        Money      `gorm:"not null" json:"amount"`
+	Currency      string     `gorm:"size:3;not null" json:"currency"`
+	PaymentDate   time.Time  `gorm:"not null" json:"date"`
+	PaymentMethod string     `gorm:"not null" json:"method"`
+	Notes         string     `json:"notes"`
+	ClaimID       *int       `gorm:"index" json:"claim_id,omitempty"`          // insurance remittances
+	IntentID      *int       `gorm:"index" json:"payment_intent_id,omitempty"` // online payments
+	ReceivedBy    *int       `gorm:"index" json:"received_by,omitempty"`       // user who took the money
+	VoidedAt      *time.Time `json:"voided_at,omitempty"`
+	VoidReason    string     `json:"void_reason,omitempty"`
+	VoidedBy      *int       `json:"voided_by,omitempty"`
+}
+
+// PatientCredit is an entry in a patient's credit ledger: positive amounts are overpayments
+// kept as credit, negative amounts are credit used to pay an invoice. PaymentID is the
+// payment that produced or consumed the credit. The balance is the sum of the entries.
+type PatientCredit struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	PatientID int       `gorm:"not null;index" json:"patient_id"`
+	Currency  string    `gorm:"size:3;not null" json:"currency"`
+	Amount    Money     `gorm:"not null" json:"amount"`
+	PaymentID *int      `gorm:"index" json:"payment_id,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// PaymentIntent is an online payment of an invoice through a payment gateway. It is pending
+// until the patient completes the checkout (authorized when it still has to be captured, and
+// capturing while the capture is under way) and then succeeded, which records Payment, or failed. A disputed charge voids its payment; it
+// is recorded again if the dispute is won.
+type PaymentIntent struct {
+	ID            int       `gorm:"primaryKey" json:"id"`
+	InvoiceID     int       `gorm:"not null;index" json:"invoice_id"`
+	PatientID     int       `gorm:"not null;index" json:"patient_id"`
+	Gateway       string    `gorm:"not null;uniqueIndex:idx_payment_intent_external" json:"gateway"`
+	ExternalID    string    `gorm:"not null;uniqueIndex:idx_payment_intent_external" json:"external_id"`
+	Amount        Money     `gorm:"not null" json:"amount"`
+	Currency      string    `gorm:"size:3;not null" json:"currency"`
+	Status        string    `gorm:"not null;index" json:"status"` // pending, authorized, capturing, succeeded, failed, disputed, dispute_lost
+	CaptureMethod string    `gorm:"not null;default:automatic" json:"capture_method"`
+	CheckoutURL   string    `json:"checkout_url,omitempty"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	PaymentID     *int      `gorm:"index" json:"payment_id,omitempty"`
+	CreatedBy     *int      `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// GatewayEvent is a webhook event received from a payment gateway. Each event is stored once
+// per gateway and event ID, so a redelivered webhook is acknowledged without being applied again.
+type GatewayEvent struct {
+	ID         int       `gorm:"primaryKey" json:"id"`
+	Gateway    string    `gorm:"not null;uniqueIndex:idx_gateway_event" json:"gateway"`
+	EventID    string    `gorm:"not null;uniqueIndex:idx_gateway_event" json:"event_id"`
+	Type       string    `gorm:"not null" json:"type"`
+	ExternalID string    `gorm:"index" json:"external_id"`
+	IntentID   *int      `gorm:"index" json:"payment_intent_id,omitempty"`
+	Payload    string    `json:"payload"`
+	Result     string    `json:"result"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// DrugStockItem is one batch of a drug held at a pharmacy location
+type DrugStockItem struct {
+	ID           int       `gorm:"primaryKey" json:"id"`
+	DrugCode     string    `gorm:"not null;index" json:"drug_code"`
+	DrugName     string    `gorm:"not null" json:"drug_name"`
+	Form         string    `json:"form"`
+	Strength     string    `json:"strength"`
+	BatchNumber  string    `gorm:"not null" json:"batch_number"`
+	ExpiryDate   time.Time `gorm:"not null;index" json:"expiry_date"`
+	Quantity     int       `gorm:"not null" json:"quantity"`
+	Unit         string    `json:"unit"`
+	Location     string    `gorm:"not null;index" json:"location"`
+	ReorderLevel int       `json:"reorder_level"`
+	UnitPrice    Money     `json:"unit_price"` // in the default currency
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Dispensing records drugs handed out against a prescription (medical record)
+type Dispensing struct {
+	ID              int       `gorm:"primaryKey" json:"id"`
+	MedicalRecordID int       `gorm:"not null;index" json:"medical_record_id"`
+	PatientID       int       `gorm:"not null;index" json:"patient_id"`
+	StockItemID     int       `gorm:"not null;index" json:"stock_item_id"`
+	Quantity        int       `gorm:"not null" json:"quantity"`
+	DispensedBy     int       `json:"dispensed_by"`
+	DispensedAt     time.Time `gorm:"not null" json:"dispensed_at"`
+	Notes           string    `json:"notes"`
+	InvoiceLineID   *int      `json:"invoice_line_id,omitempty"`
+}
+
+// PharmacyStockMovement is the audit trail of every stock change.
+// Quantity is signed: positive for receipts, negative for dispensing.
+type PharmacyStockMovement struct {
+	ID           int       `gorm:"primaryKey" json:"id"`
+	StockItemID  int       `gorm:"not null;index" json:"stock_item_id"`
+	Type         string    `gorm:"not null" json:"type"`
+	Quantity     int       `gorm:"not null" json:"quantity"`
+	BalanceAfter int       `gorm:"not null" json:"balance_after"`
+	DispensingID *int      `json:"dispensing_id,omitempty"`
+	Reason       string    `json:"reason"`
+	PerformedBy  int       `json:"performed_by"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
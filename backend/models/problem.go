@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Problem is an entry of a patient's curated problem list
+type Problem struct {
+	ID           int        `gorm:"primaryKey" json:"id"`
+	PatientID    int        `gorm:"not null;index" json:"patient_id"`
+	DoctorID     int        `gorm:"not null;index" json:"doctor_id"`
+	Condition    string     `gorm:"not null" json:"condition"`
+	Code         string     `gorm:"index" json:"code"`
+	CodeSystem   string     `json:"code_system"`
+	OnsetDate    *time.Time `json:"onset_date,omitempty"`
+	Status       string     `gorm:"not null;index" json:"status"`
+	ResolvedDate *time.Time `json:"resolved_date,omitempty"`
+	Notes        string     `json:"notes"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
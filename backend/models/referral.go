@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// ExternalProvider is a clinic or specialist outside the hospital that patients can be referred to
+type ExternalProvider struct {
+	ID           int    `gorm:"primaryKey" json:"id"`
+	Name         string `gorm:"not null" json:"name"`
+	Organization string `json:"organization"`
+	Speciality   string `json:"speciality"`
+	Phone        string `json:"phone"`
+	Email        string `json:"email"`
+	Address      string `json:"address"`
+}
+
+// Referral goes sent -> accepted | declined, accepted -> appointment_booked -> completed.
+// The sender can cancel it while it is sent or accepted.
+type Referral struct {
+	ID                 int                  `gorm:"primaryKey" json:"id"`
+	PatientID          int                  `gorm:"not null;index" json:"patient_id"`
+	FromDoctorID       int                  `gorm:"not null;index" json:"from_doctor_id"`
+	ToDoctorID         *int                 `gorm:"index" json:"to_doctor_id,omitempty"`
+	ExternalProviderID *int                 `gorm:"index" json:"external_provider_id,omitempty"`
+	Reason             string               `gorm:"not null" json:"reason"`
+	ClinicalNotes      string               `json:"clinical_notes"`
+	Urgency            string               `gorm:"not null" json:"urgency"`
+	Status             string               `gorm:"not null;index" json:"status"`
+	ResponseNote       string               `json:"response_note"`
+	AppointmentID      *uint                `json:"appointment_id,omitempty"`
+	SentAt             time.Time            `gorm:"not null" json:"sent_at"`
+	RespondedAt        *time.Time           `json:"responded_at,omitempty"`
+	CompletedAt        *time.Time           `json:"completed_at,omitempty"`
+	Attachments        []ReferralAttachment `gorm:"foreignKey:ReferralID" json:"attachments,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+}
+
+// ReferralAttachment links a medical record or file of the patient to a referral
+type ReferralAttachment struct {
+	ID              int  `gorm:"primaryKey" json:"id"`
+	ReferralID      int  `gorm:"not null;index" json:"referral_id"`
+	MedicalRecordID *int `json:"medical_record_id,omitempty"`
+	FileID          *int `json:"file_id,omitempty"`
+}
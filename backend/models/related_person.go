@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// RelatedPerson is someone connected to a patient who is contacted or billed on their behalf.
+// One person can hold several roles, e.g. a mother who is guardian, guarantor and emergency contact.
+type RelatedPerson struct {
+	ID                 int       `gorm:"primaryKey" json:"id"`
+	PatientID          int       `gorm:"not null;index" json:"patient_id"`
+	LinkedPatientID    *int      `gorm:"index" json:"linked_patient_id,omitempty"`
+	FullName           string    `gorm:"not null" json:"full_name"`
+	Relationship       string    `gorm:"not null" json:"relationship"`
+	IsEmergencyContact bool      `gorm:"not null;default:false" json:"is_emergency_contact"`
+	IsGuardian         bool      `gorm:"not null;default:false" json:"is_guardian"`
+	IsGuarantor        bool      `gorm:"not null;default:false" json:"is_guarantor"`
+	IsNextOfKin        bool      `gorm:"not null;default:false" json:"is_next_of_kin"`
+	Priority           int       `gorm:"not null;default:1" json:"priority"`
+	Phone              string    `json:"phone"`
+	AlternatePhone     string    `json:"alternate_phone"`
+	Email              string    `json:"email"`
+	Address            string    `json:"address"`
+	Notes              string    `json:"notes"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// PatientRelationship is a family link between two registered patients: RelatedPatient is
+// the Relationship (e.g. parent) of Patient. Links are stored in both directions
+// (parent/child, spouse/spouse) and created and removed together.
+type PatientRelationship struct {
+	ID               int       `gorm:"primaryKey" json:"id"`
+	PatientID        int       `gorm:"not null;uniqueIndex:idx_patient_relationship" json:"patient_id"`
+	RelatedPatientID int       `gorm:"not null;uniqueIndex:idx_patient_relationship;index" json:"related_patient_id"`
+	Relationship     string    `gorm:"not null;uniqueIndex:idx_patient_relationship" json:"relationship"`
+	CreatedAt        time.Time `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// FormTemplate is the text of a form patients sign electronically. Body may contain the
+// placeholders {{patient_name}}, {{mrn}}, {{date_of_birth}} and {{date}}. When ConsentType
+// is set, signing the form also records a consent of that type and scope.
+type FormTemplate struct {
+	ID           int       `gorm:"primaryKey" json:"id"`
+	Code         string    `gorm:"not null;uniqueIndex" json:"code"`
+	Name         string    `gorm:"not null" json:"name"`
+	Body         string    `gorm:"type:text;not null" json:"body"`
+	ConsentType  string    `json:"consent_type,omitempty"`
+	ConsentScope string    `json:"consent_scope,omitempty"`
+	Active       bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SignedDocument is a form generated for a patient. It is pending until signed; once signed
+// the content and signature are frozen and ContentHash covers them (see utils.SignaturePayload).
+type SignedDocument struct {
+	ID                 int        `gorm:"primaryKey" json:"id"`
+	TemplateID         int        `gorm:"not null;index" json:"template_id"`
+	PatientID          int        `gorm:"not null;index" json:"patient_id"`
+	Title              string     `gorm:"not null" json:"title"`
+	Content            string     `gorm:"type:text;not null" json:"content"`
+	Status             string     `gorm:"not null;index" json:"status"`
+	SignerName         string     `json:"signer_name,omitempty"`
+	SignerRelationship string     `json:"signer_relationship,omitempty"`
+	WitnessName        string     `json:"witness_name,omitempty"`
+	SignatureType      string     `json:"signature_type,omitempty"`
+	TypedSignature     string     `json:"typed_signature,omitempty"`
+	SignatureImage     []byte     `json:"-"`
+	SignedAt           *time.Time `json:"signed_at,omitempty"`
+	SignedByUserID     *int       `json:"signed_by_user_id,omitempty"`
+	SignerIP           string     `json:"signer_ip,omitempty"`
+	ContentHash        string     `json:"content_hash,omitempty"`
+	FileHash           string     `json:"file_hash,omitempty"`
+	FileID             *int       `json:"file_id,omitempty"`
+	ConsentID          *int       `json:"consent_id,omitempty"`
+	CreatedBy          int        `gorm:"index" json:"created_by"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
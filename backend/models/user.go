@@ -8,5 +8,6 @@ type User struct {
 	Email        string    `gorm:"uniqueIndex;not null" json:"email"`
 	Password     string    `gorm:"not null" json:"password"`
 	Role         string    `gorm:"not null" json:"role"`
+	PatientID    *int      `gorm:"uniqueIndex" json:"patient_id,omitempty"` // set for the patient role
 	CreationDate time.Time `gorm:"autoCreateTime" json:"creation_date"`
 }
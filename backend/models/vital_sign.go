@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// VitalSign is one set of bedside observations for a patient
+type VitalSign struct {
+	ID              int       `gorm:"primaryKey" json:"id"`
+	PatientID       int       `gorm:"not null;index" json:"patient_id"`
+	AdmissionID     *int      `gorm:"index" json:"admission_id,omitempty"`
+	RecordedAt      time.Time `gorm:"not null;index" json:"recorded_at"`
+	Temperature     *float64  `json:"temperature,omitempty"`
+	HeartRate       *int      `json:"heart_rate,omitempty"`
+	RespiratoryRate *int      `json:"respiratory_rate,omitempty"`
+	SystolicBP      *int      `json:"systolic_bp,omitempty"`
+	DiastolicBP     *int      `json:"diastolic_bp,omitempty"`
+	SpO2            *int      `json:"spo2,omitempty"`
+	RecordedBy      int       `json:"recorded_by"`
+	Notes           string    `json:"notes"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type Ward struct {
+	ID         int    `gorm:"primaryKey" json:"id"`
+	Name       string `gorm:"uniqueIndex;not null" json:"name"`
+	Department string `json:"department"`
+	Floor      string `json:"floor"`
+	Rooms      []Room `gorm:"foreignKey:WardID" json:"rooms,omitempty"`
+}
+
+type Room struct {
+	ID     int    `gorm:"primaryKey" json:"id"`
+	WardID int    `gorm:"not null;index" json:"ward_id"`
+	Number string `gorm:"not null" json:"number"`
+	Beds   []Bed  `gorm:"foreignKey:RoomID" json:"beds,omitempty"`
+}
+
+// Bed status values: free, occupied, cleaning, blocked
+type Bed struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	RoomID    int       `gorm:"not null;index" json:"room_id"`
+	WardID    int       `gorm:"not null;index" json:"ward_id"`
+	Label     string    `gorm:"not null" json:"label"`
+	Status    string    `gorm:"not null;default:free;index" json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAdmissionNotActive     = errors.New("admission is not active")
+	ErrPatientAlreadyAdmitted = errors.New("patient already has an active admission")
+)
+
+// lockFreeBed locks a bed row and checks that it can receive a patient
+func lockFreeBed(tx *gorm.DB, bedID int) (models.Bed, error) {
+	var bed models.Bed
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bed, bedID).Error; err != nil {
+		return bed, err
+	}
+	if bed.Status != "free" {
+		return bed, ErrBedNotAvailable
+	}
+	return bed, nil
+}
+
+func lockActiveAdmission(tx *gorm.DB, id int) (models.Admission, error) {
+	var adm models.Admission
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&adm, id).Error; err != nil {
+		return adm, err
+	}
+	if adm.Status != "admitted" {
+		return adm, ErrAdmissionNotActive
+	}
+	return adm, nil
+}
+
+// AdmitPatient creates an admission, occupies the bed and records the movement atomically.
+// The patient row is locked first so that concurrent admissions of the same patient run one
+// after the other and the second sees the first.
+func AdmitPatient(adm *models.Admission, performedBy int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var patient models.Patient
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&patient, adm.PatientID).Error; err != nil {
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&models.Admission{}).
+			Where("patient_id = ? AND status = ?", adm.PatientID, "admitted").
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrPatientAlreadyAdmitted
+		}
+
+		if _, err := lockFreeBed(tx, adm.BedID); err != nil {
+			return err
+		}
+
+		adm.Status = "admitted"
+		if adm.AdmittedAt.IsZero() {
+			adm.AdmittedAt = time.Now()
+		}
+		if err := tx.Create(adm).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Bed{}).Where("id = ?", adm.BedID).Update("status", "occupied").Error; err != nil {
+			return err
+		}
+		bedID := adm.BedID
+		return tx.Create(&models.BedMovement{
+			AdmissionID: adm.ID,
+			Type:        "admit",
+			ToBedID:     &bedID,
+			Reason:      adm.Reason,
+			PerformedBy: performedBy,
+			MovedAt:     adm.AdmittedAt,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error admitting patient:", err)
+	}
+	return err
+}
+
+// TransferPatient moves an active admission to another free bed; the old bed goes to cleaning.
+// doctorID changes the attending doctor when non-zero.
+func TransferPatient(admissionID, toBedID, doctorID int, reason string, performedBy int) (models.Admission, error) {
+	var adm models.Admission
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if adm, err = lockActiveAdmission(tx, admissionID); err != nil {
+			return err
+		}
+		if adm.BedID == toBedID {
+			return ErrBedNotAvailable
+		}
+		if _, err := lockFreeBed(tx, toBedID); err != nil {
+			return err
+		}
+
+		fromBedID := adm.BedID
+		if err := tx.Model(&models.Bed{}).Where("id = ?", fromBedID).Update("status", "cleaning").Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Bed{}).Where("id = ?", toBedID).Update("status", "occupied").Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"bed_id": toBedID}
+		adm.BedID = toBedID
+		if doctorID != 0 {
+			updates["doctor_id"] = doctorID
+			adm.DoctorID = doctorID
+		}
+		if err := tx.Model(&models.Admission{}).Where("id = ?", adm.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.BedMovement{
+			AdmissionID: adm.ID,
+			Type:        "transfer",
+			FromBedID:   &fromBedID,
+			ToBedID:     &toBedID,
+			Reason:      reason,
+			PerformedBy: performedBy,
+			MovedAt:     time.Now(),
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error transferring patient:", err)
+	}
+	return adm, err
+}
+
+// DischargePatient ends an active admission and releases the bed for cleaning
+func DischargePatient(admissionID int, notes string, performedBy int) (models.Admission, error) {
+	var adm models.Admission
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if adm, err = lockActiveAdmission(tx, admissionID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		adm.Status = "discharged"
+		adm.DischargedAt = &now
+		adm.DischargeNotes = notes
+		if err := tx.Model(&models.Admission{}).Where("id = ?", adm.ID).Updates(map[string]interface{}{
+			"status":          adm.Status,
+			"discharged_at":   now,
+			"discharge_notes": notes,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Bed{}).Where("id = ?", adm.BedID).Update("status", "cleaning").Error; err != nil {
+			return err
+		}
+		fromBedID := adm.BedID
+		return tx.Create(&models.BedMovement{
+			AdmissionID: adm.ID,
+			Type:        "discharge",
+			FromBedID:   &fromBedID,
+			Reason:      notes,
+			PerformedBy: performedBy,
+			MovedAt:     now,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error discharging patient:", err)
+	}
+	return adm, err
+}
+
+// GetAdmissionByID retrieves an admission with its movement history
+func GetAdmissionByID(id int) (models.Admission, error) {
+	var adm models.Admission
+	if err := config.GormDB.
+		Preload("Movements", func(db *gorm.DB) *gorm.DB { return db.Order("moved_at") }).
+		First(&adm, id).Error; err != nil {
+		log.Println("Error fetching admission:", err)
+		return adm, err
+	}
+	return adm, nil
+}
+
+// GetAdmissions lists admissions, optionally filtered by status and patient
+func GetAdmissions(status string, patientID int) ([]models.Admission, error) {
+	var list []models.Admission
+	q := config.GormDB.Order("admitted_at DESC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if patientID != 0 {
+		q = q.Where("patient_id = ?", patientID)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching admissions:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// BedOccupant is the current occupant of a bed on the bed board
+type BedOccupant struct {
+	BedID       int       `json:"bed_id"`
+	AdmissionID int       `json:"admission_id"`
+	PatientID   int       `json:"patient_id"`
+	PatientName string    `json:"patient_name"`
+	DoctorID    int       `json:"doctor_id"`
+	DoctorName  string    `json:"doctor_name"`
+	AdmittedAt  time.Time `json:"admitted_at"`
+}
+
+// GetBedOccupants returns the active admissions keyed for the bed board
+func GetBedOccupants() ([]BedOccupant, error) {
+	var occupants []BedOccupant
+	if err := config.GormDB.Table("admissions a").
+		Select("a.bed_id, a.id AS admission_id, a.patient_id, p.full_name AS patient_name, a.doctor_id, d.full_name AS doctor_name, a.admitted_at").
+		Joins("JOIN patients p ON p.id = a.patient_id").
+		Joins("LEFT JOIN doctors d ON d.id = a.doctor_id").
+		Where("a.status = ?", "admitted").
+		Scan(&occupants).Error; err != nil {
+		log.Println("Error fetching bed occupants:", err)
+		return nil, err
+	}
+	return occupants, nil
+}
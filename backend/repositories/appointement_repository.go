@@ -1,12 +1,19 @@
 package repositories
 
 import (
+	"errors"
 	"log"
+	"time"
 
 	"github.com/samichen99/HAP-hospital-management-system/config"
 	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrSlotUnavailable = errors.New("the doctor is not available at this time")
+
 // inserts a new appointment
 func CreateAppointment(appointment *models.Appointment) error {
 	if err := config.GormDB.Create(appointment).Error; err != nil {
@@ -105,3 +112,69 @@ func UpdateAppointmentStatus(id int, status string) error {
 	log.Println("Appointment status updated successfully. ID:", id)
 	return nil
 }
+
+// returns the most recent appointment of a patient, nil when there is none
+func GetLatestAppointmentByPatientID(patientID int) (*models.Appointment, error) {
+	var appointments []models.Appointment
+	if err := config.GormDB.Where("patient_id = ?", patientID).
+		Order("date_time DESC").
+		Limit(1).
+		Find(&appointments).Error; err != nil {
+		log.Printf("Error fetching latest appointment for patient %d: %v", patientID, err)
+		return nil, err
+	}
+	if len(appointments) == 0 {
+		return nil, nil
+	}
+	return &appointments[0], nil
+}
+
+// reserveDoctorSlot locks the doctor row, so that concurrent bookings with the same doctor run
+// one after the other, and returns ErrSlotUnavailable when the doctor is inactive or already
+// has a scheduled appointment overlapping the slot
+func reserveDoctorSlot(tx *gorm.DB, doctorID uint, at time.Time, duration int) error {
+	var doctor models.Doctor
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doctor, doctorID).Error; err != nil {
+		return err
+	}
+	if !doctor.Status {
+		return ErrSlotUnavailable
+	}
+	end := at.Add(time.Duration(duration) * time.Minute)
+	var clashes int64
+	if err := tx.Model(&models.Appointment{}).
+		Where("doctor_id = ? AND status = ?", doctorID, "scheduled").
+		Where("date_time < ? AND date_time + duration * INTERVAL '1 minute' > ?", end, at).
+		Count(&clashes).Error; err != nil {
+		return err
+	}
+	if clashes > 0 {
+		return ErrSlotUnavailable
+	}
+	return nil
+}
+
+// BookPortalAppointment books an appointment requested by a patient through the portal.
+// The doctor row is locked so two patients cannot take the same slot, and the booking
+// policy is checked against the patient's upcoming appointments inside the transaction.
+func BookPortalAppointment(appointment *models.Appointment, policy utils.BookingPolicy, now time.Time) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var open int64
+		if err := tx.Model(&models.Appointment{}).
+			Where("patient_id = ? AND status = ? AND date_time > ?", appointment.PatientID, "scheduled", now).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if err := policy.CheckBooking(appointment.DateTime, now, int(open)); err != nil {
+			return err
+		}
+		if err := reserveDoctorSlot(tx, appointment.DoctorID, appointment.DateTime, appointment.Duration); err != nil {
+			return err
+		}
+		return tx.Create(appointment).Error
+	})
+	if err != nil {
+		log.Printf("Error booking portal appointment: %v", err)
+	}
+	return err
+}
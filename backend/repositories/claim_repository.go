@@ -0,0 +1,434 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPolicyNotForPatient    = errors.New("insurance policy belongs to another patient")
+	ErrPolicyNotEffective     = errors.New("insurance policy was not in effect when the invoice was issued")
+	ErrPayerInactive          = errors.New("payer no longer accepts claims")
+	ErrClaimExists            = errors.New("invoice already has an open claim with this policy")
+	ErrNoPrimaryClaim         = errors.New("invoice has no claim with a higher-priority policy yet")
+	ErrClaimStatus            = errors.New("claim is not in a state that allows this")
+	ErrInvalidAdjudication    = errors.New("approved amounts must be between 0 and the charged amount of each line")
+	ErrRemittanceExceedsClaim = errors.New("remittance exceeds the approved amount still unpaid")
+)
+
+// ClaimPrefix starts every claim number
+const ClaimPrefix = "CLM"
+
+// lineCategory is the coverage category of an invoice line: the catalogue category of its
+// service, "pharmacy" for dispensed drugs and empty otherwise
+func lineCategory(db *gorm.DB, line models.InvoiceLine) (string, error) {
+	if line.ServiceItemID != nil {
+		var item models.ServiceItem
+		if err := db.First(&item, *line.ServiceItemID).Error; err != nil {
+			return "", err
+		}
+		return item.Category, nil
+	}
+	if line.SourceType == "dispensing" {
+		return "pharmacy", nil
+	}
+	return "", nil
+}
+
+// remainingCharges returns, per invoice line, what the closest earlier-priority claim on the
+// invoice left to the patient: its patient share, or once adjudicated whatever the payer did
+// not approve. It returns ErrNoPrimaryClaim when no such claim exists.
+func remainingCharges(tx *gorm.DB, invoiceID, priority int) (map[int]models.Money, error) {
+	var earlier models.Claim
+	err := tx.Joins("JOIN insurance_policies p ON p.id = claims.policy_id").
+		Where("claims.invoice_id = ? AND claims.status <> ? AND p.priority < ?", invoiceID, "denied", priority).
+		Order("p.priority DESC, claims.id DESC").
+		First(&earlier).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoPrimaryClaim
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines []models.ClaimLine
+	if err := tx.Where("claim_id = ?", earlier.ID).Find(&lines).Error; err != nil {
+		return nil, err
+	}
+	left := make(map[int]models.Money, len(lines))
+	for _, l := range lines {
+		if earlier.Status == "adjudicated" || earlier.Status == "paid" {
+			left[l.InvoiceLineID] = l.Charged - l.ApprovedAmount
+		} else {
+			left[l.InvoiceLineID] = l.PatientAmount
+		}
+	}
+	return left, nil
+}
+
+// CreateClaim splits the lines of an issued invoice into payer and patient responsibility
+// under the payer's coverage rules and records a draft claim. A secondary (or later) policy
+// only claims what the earlier-priority claim left to the patient.
+func CreateClaim(invoiceID, policyID, userID int) (models.Claim, error) {
+	var claim models.Claim
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		inv, err := lockPayableInvoice(tx, invoiceID)
+		if err != nil {
+			return err
+		}
+		var policy models.InsurancePolicy
+		if err := tx.First(&policy, policyID).Error; err != nil {
+			return err
+		}
+		if policy.PatientID != inv.PatientID {
+			return ErrPolicyNotForPatient
+		}
+		if !policy.Active || inv.IssuedAt.Before(policy.EffectiveFrom) ||
+			policy.EffectiveTo != nil && inv.IssuedAt.After(*policy.EffectiveTo) {
+			return ErrPolicyNotEffective
+		}
+		var payer models.Payer
+		if err := tx.First(&payer, policy.PayerID).Error; err != nil {
+			return err
+		}
+		if !payer.Active {
+			return ErrPayerInactive
+		}
+		var open int64
+		if err := tx.Model(&models.Claim{}).
+			Where("invoice_id = ? AND policy_id = ? AND status <> ?", invoiceID, policyID, "denied").
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrClaimExists
+		}
+
+		// charges is nil for the primary policy, which claims the full line amounts
+		var charges map[int]models.Money
+		if policy.Priority > 1 {
+			if charges, err = remainingCharges(tx, invoiceID, policy.Priority); err != nil {
+				return err
+			}
+		}
+
+		var rules []models.CoverageRule
+		if err := tx.Where("payer_id = ?", payer.ID).Find(&rules).Error; err != nil {
+			return err
+		}
+		var lines []models.InvoiceLine
+		if err := tx.Where("invoice_id = ?", invoiceID).Order("id").Find(&lines).Error; err != nil {
+			return err
+		}
+		claim = models.Claim{
+			InvoiceID: inv.ID,
+			PatientID: inv.PatientID,
+			PolicyID:  policy.ID,
+			PayerID:   payer.ID,
+			Currency:  inv.Currency,
+			Status:    "draft",
+			CreatedBy: userID,
+		}
+		charged := make([]models.Money, len(lines))
+		lineRules := make([]*models.CoverageRule, len(lines))
+		for i, l := range lines {
+			charged[i] = l.Amount
+			if charges != nil {
+				charged[i] = charges[l.ID]
+			}
+			category, err := lineCategory(tx, l)
+			if err != nil {
+				return err
+			}
+			lineRules[i] = utils.MatchCoverageRule(rules, category)
+		}
+		payerAmounts, patientAmounts := utils.SplitCharges(charged, lineRules)
+		for i, l := range lines {
+			claim.Lines = append(claim.Lines, models.ClaimLine{
+				InvoiceLineID: l.ID,
+				Code:          l.Code,
+				Description:   l.Description,
+				Quantity:      l.Quantity,
+				Charged:       charged[i],
+				PayerAmount:   payerAmounts[i],
+				PatientAmount: patientAmounts[i],
+			})
+			claim.TotalCharged += charged[i]
+			claim.PayerAmount += payerAmounts[i]
+			claim.PatientAmount += patientAmounts[i]
+		}
+
+		year := utils.FiscalYear(time.Now(), utils.FiscalYearStartMonth())
+		n, err := nextSequenceValue(tx, fmt.Sprintf("claim:%d", year))
+		if err != nil {
+			return err
+		}
+		claim.Number = utils.FormatDocumentNumber(ClaimPrefix, year, n)
+		return tx.Create(&claim).Error
+	})
+	if err != nil {
+		log.Println("Error creating claim:", err)
+	}
+	return claim, err
+}
+
+// GetClaims lists claims, newest first, optionally filtered by status and payer
+func GetClaims(status string, payerID int) ([]models.Claim, error) {
+	var list []models.Claim
+	q := config.GormDB.Order("created_at DESC, id DESC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if payerID != 0 {
+		q = q.Where("payer_id = ?", payerID)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching claims:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetClaimByID retrieves a claim with its lines
+func GetClaimByID(id int) (models.Claim, error) {
+	var claim models.Claim
+	if err := config.GormDB.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&claim, id).Error; err != nil {
+		log.Println("Error fetching claim:", err)
+		return claim, err
+	}
+	return claim, nil
+}
+
+// GetClaimsByInvoiceID lists the claims made for an invoice
+func GetClaimsByInvoiceID(invoiceID int) ([]models.Claim, error) {
+	var list []models.Claim
+	if err := config.GormDB.Where("invoice_id = ?", invoiceID).Order("created_at, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching claims by invoice:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+func lockClaim(tx *gorm.DB, id int, status string) (models.Claim, error) {
+	var claim models.Claim
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claim, id).Error; err != nil {
+		return claim, err
+	}
+	if claim.Status != status {
+		return claim, ErrClaimStatus
+	}
+	return claim, nil
+}
+
+// SubmitClaim marks a draft claim as sent to the payer
+func SubmitClaim(id int) (models.Claim, error) {
+	var claim models.Claim
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if claim, err = lockClaim(tx, id, "draft"); err != nil {
+			return err
+		}
+		now := time.Now()
+		claim.Status = "submitted"
+		claim.SubmittedAt = &now
+		return tx.Model(&models.Claim{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":       claim.Status,
+			"submitted_at": now,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error submitting claim:", err)
+	}
+	return claim, err
+}
+
+// AdjudicateClaim records the payer's decision on a submitted claim. approved maps claim line
+// IDs to the amount the payer accepted; lines left out are approved at their payer amount.
+// A nil map accepts the claim as submitted, or denies it in full when a denial reason is
+// given. A claim with nothing approved is denied.
+func AdjudicateClaim(id int, approved map[int]models.Money, denialReason string) (models.Claim, error) {
+	var claim models.Claim
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if claim, err = lockClaim(tx, id, "submitted"); err != nil {
+			return err
+		}
+		if err := tx.Where("claim_id = ?", id).Order("id").Find(&claim.Lines).Error; err != nil {
+			return err
+		}
+		for lineID := range approved {
+			found := false
+			for _, l := range claim.Lines {
+				found = found || l.ID == lineID
+			}
+			if !found {
+				return fmt.Errorf("%w: claim has no line %d", ErrInvalidAdjudication, lineID)
+			}
+		}
+
+		claim.ApprovedAmount = 0
+		for i := range claim.Lines {
+			l := &claim.Lines[i]
+			amount, ok := approved[l.ID]
+			if !ok && (approved != nil || denialReason == "") {
+				amount = l.PayerAmount
+			}
+			if amount < 0 || amount > l.Charged {
+				return ErrInvalidAdjudication
+			}
+			l.ApprovedAmount = amount
+			claim.ApprovedAmount += amount
+			if err := tx.Model(&models.ClaimLine{}).Where("id = ?", l.ID).Update("approved_amount", amount).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		claim.AdjudicatedAt = &now
+		claim.Status = "adjudicated"
+		if claim.ApprovedAmount == 0 {
+			claim.Status = "denied"
+			if denialReason == "" {
+				denialReason = "Denied by payer"
+			}
+		}
+		claim.DenialReason = denialReason
+		return tx.Model(&models.Claim{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":          claim.Status,
+			"approved_amount": claim.ApprovedAmount,
+			"adjudicated_at":  now,
+			"denial_reason":   claim.DenialReason,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error adjudicating claim:", err)
+	}
+	return claim, err
+}
+
+// ApplyRemittance records money received from the payer for an adjudicated claim as an
+// insurance payment on the invoice. The claim is paid once the approved amount is covered.
+func ApplyRemittance(claimID int, amount models.Money, paidAt time.Time, reference string) (models.Payment, error) {
+	var payment models.Payment
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		claim, err := lockClaim(tx, claimID, "adjudicated")
+		if err != nil {
+			return err
+		}
+		if amount <= 0 || claim.PaidAmount+amount > claim.ApprovedAmount {
+			return ErrRemittanceExceedsClaim
+		}
+		inv, err := lockPayableInvoice(tx, claim.InvoiceID)
+		if err != nil {
+			return err
+		}
+		st, err := invoiceSettlement(tx, inv.ID)
+		if err != nil {
+			return err
+		}
+		if amount > st.balance(inv.Amount) {
+			return ErrOverpayment
+		}
+
+		notes := "Remittance for claim " + claim.Number
+		if reference != "" {
+			notes += ", reference " + reference
+		}
+		payment = models.Payment{
+			InvoiceId:     inv.ID,
+			Amount:        amount,
+			Currency:      claim.Currency,
+			PaymentDate:   paidAt,
+			PaymentMethod: "insurance",
+			Notes:         notes,
+			ClaimID:       &claim.ID,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"paid_amount": claim.PaidAmount + amount}
+		if claim.PaidAmount+amount == claim.ApprovedAmount {
+			updates["status"] = "paid"
+			updates["paid_at"] = paidAt
+		}
+		if err := tx.Model(&models.Claim{}).Where("id = ?", claim.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error applying remittance:", err)
+	}
+	return payment, err
+}
+
+// reverseRemittance takes a voided remittance off its claim, reopening a paid claim
+func reverseRemittance(tx *gorm.DB, claimID int, amount models.Money) error {
+	return tx.Model(&models.Claim{}).Where("id = ?", claimID).Updates(map[string]interface{}{
+		"paid_amount": gorm.Expr("paid_amount - ?", amount),
+		"status":      gorm.Expr("CASE WHEN status = 'paid' THEN 'adjudicated' ELSE status END"),
+		"paid_at":     nil,
+	}).Error
+}
+
+// ClaimExportRow is one claim line with everything a clearinghouse needs to bill it
+type ClaimExportRow struct {
+	ClaimNumber            string       `json:"claim_number"`
+	PayerCode              string       `json:"payer_code"`
+	PayerIdentifier        string       `json:"payer_identifier"`
+	MemberID               string       `json:"member_id"`
+	GroupNumber            string       `json:"group_number"`
+	SubscriberName         string       `json:"subscriber_name"`
+	SubscriberRelationship string       `json:"subscriber_relationship"`
+	PatientName            string       `json:"patient_name"`
+	PatientDateOfBirth     string       `json:"patient_date_of_birth"`
+	PatientGender          string       `json:"patient_gender"`
+	PatientMRN             string       `json:"patient_mrn"`
+	InvoiceNumber          string       `json:"invoice_number"`
+	ServiceDate            time.Time    `json:"service_date"`
+	LineNumber             int          `json:"line_number"`
+	Code                   string       `json:"code"`
+	Description            string       `json:"description"`
+	Quantity               int          `json:"quantity"`
+	Charged                models.Money `json:"charged"`
+	PayerAmount            models.Money `json:"payer_amount"`
+	PatientAmount          models.Money `json:"patient_amount"`
+	Currency               string       `json:"currency"`
+}
+
+// GetClaimExportRows flattens the claims with a given status (and payer, when set) for export
+func GetClaimExportRows(status string, payerID int) ([]ClaimExportRow, error) {
+	var rows []ClaimExportRow
+	q := config.GormDB.Table("claim_lines cl").
+		Select(`c.number AS claim_number, p.code AS payer_code, p.payer_identifier,
+			ip.member_id, ip.group_number, ip.subscriber_name, ip.subscriber_relationship,
+			pt.full_name AS patient_name, pt.date_of_birth AS patient_date_of_birth,
+			pt.gender AS patient_gender, pt.mrn AS patient_mrn,
+			COALESCE(i.number, '') AS invoice_number, i.issued_at AS service_date,
+			ROW_NUMBER() OVER (PARTITION BY c.id ORDER BY cl.id) AS line_number,
+			cl.code, cl.description, cl.quantity, cl.charged, cl.payer_amount, cl.patient_amount, c.currency`).
+		Joins("JOIN claims c ON c.id = cl.claim_id").
+		Joins("JOIN payers p ON p.id = c.payer_id").
+		Joins("JOIN insurance_policies ip ON ip.id = c.policy_id").
+		Joins("JOIN patients pt ON pt.id = c.patient_id").
+		Joins("JOIN invoices i ON i.id = c.invoice_id").
+		Where("c.status = ?", status).
+		Order("c.number, cl.id")
+	if payerID != 0 {
+		q = q.Where("c.payer_id = ?", payerID)
+	}
+	if err := q.Scan(&rows).Error; err != nil {
+		log.Println("Error exporting claims:", err)
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrConsentRevoked = errors.New("consent has already been revoked")
+
+// CreateConsent stores a consent after checking that the scanned form belongs to the patient
+func CreateConsent(c *models.Consent) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if c.FileID != nil {
+			var count int64
+			if err := tx.Model(&models.File{}).
+				Where("id = ? AND patient_id = ?", *c.FileID, c.PatientID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrAttachmentMismatch
+			}
+		}
+		return tx.Create(c).Error
+	})
+	if err != nil {
+		log.Println("Error creating consent:", err)
+	}
+	return err
+}
+
+// GetConsentByID retrieves a consent by ID
+func GetConsentByID(id int) (models.Consent, error) {
+	var c models.Consent
+	if err := config.GormDB.First(&c, id).Error; err != nil {
+		log.Println("Error fetching consent:", err)
+		return c, err
+	}
+	return c, nil
+}
+
+// GetConsentsByPatientID lists a patient's consents, newest first
+func GetConsentsByPatientID(patientID int, consentType string) ([]models.Consent, error) {
+	var list []models.Consent
+	q := config.GormDB.Where("patient_id = ?", patientID)
+	if consentType != "" {
+		q = q.Where("type = ?", consentType)
+	}
+	if err := q.Order("granted_at DESC, id DESC").Find(&list).Error; err != nil {
+		log.Println("Error fetching consents by patient ID:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// RevokeConsent marks a granted consent as revoked
+func RevokeConsent(id int, reason string) (models.Consent, error) {
+	var c models.Consent
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&c, id).Error; err != nil {
+			return err
+		}
+		if c.Status == "revoked" {
+			return ErrConsentRevoked
+		}
+		now := time.Now()
+		c.Status = "revoked"
+		c.RevokedAt = &now
+		c.RevocationReason = reason
+		return tx.Model(&c).Select("status", "revoked_at", "revocation_reason").Updates(&c).Error
+	})
+	if err != nil {
+		log.Println("Error revoking consent:", err)
+	}
+	return c, err
+}
+
+// consentedPatients is a subquery of the patients holding an active consent of the
+// given type that covers scope
+func consentedPatients(consentType, scope string) *gorm.DB {
+	return config.GormDB.Model(&models.Consent{}).
+		Select("patient_id").
+		Where("type = ? AND status = ?", consentType, "granted").
+		Where("scope IN ?", []string{"", models.ConsentScopeAll, scope}).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// HasActiveConsent reports whether the patient currently holds a consent of the given type covering scope
+func HasActiveConsent(patientID int, consentType, scope string) (bool, error) {
+	var count int64
+	if err := config.GormDB.Table("(?) AS c", consentedPatients(consentType, scope)).
+		Where("c.patient_id = ?", patientID).
+		Count(&count).Error; err != nil {
+		log.Println("Error checking consent:", err)
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,252 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRefundExceedsPayment = errors.New("refunds cannot exceed the payment amount")
+	ErrOnlineRefund         = errors.New("online refunds are paid out by the payment gateway and cannot be voided")
+	ErrRefundNotPending     = errors.New("refund is not pending")
+	ErrCreditExceedsInvoice = errors.New("credit notes cannot exceed the invoice amount")
+)
+
+// CreditNotePrefix starts every credit note number
+const CreditNotePrefix = "CN"
+
+// refundedAmount returns how much of a payment has been refunded, pending refunds included
+func refundedAmount(db *gorm.DB, paymentID int) (models.Money, error) {
+	var sums struct{ Refunded models.Money }
+	err := db.Model(&models.Refund{}).
+		Select("COALESCE(SUM(amount), 0)::bigint AS refunded").
+		Where("payment_id = ? AND voided_at IS NULL", paymentID).
+		Scan(&sums).Error
+	return sums.Refunded, err
+}
+
+// CreateRefund gives back all or part of a payment. Amount 0 refunds whatever is left of it.
+// A refund of an online payment is recorded as pending: it already counts against what is
+// left to refund but not towards the invoice until ConfirmRefund.
+func CreateRefund(refund *models.Refund) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error; err != nil {
+			return err
+		}
+		if payment.VoidedAt != nil {
+			return ErrPaymentVoided
+		}
+		if payment.PaymentMethod == "credit" {
+			return ErrCreditPayment
+		}
+		if payment.ClaimID != nil {
+			return ErrRemittancePayment
+		}
+		var inv models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, payment.InvoiceId).Error; err != nil {
+			return err
+		}
+		refunded, err := refundedAmount(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		remaining := payment.Amount - refunded
+		if refund.Amount == 0 {
+			refund.Amount = remaining
+		}
+		if refund.Amount <= 0 || refund.Amount > remaining {
+			return ErrRefundExceedsPayment
+		}
+		refund.InvoiceID = inv.ID
+		refund.Currency = payment.Currency
+		refund.IntentID = payment.IntentID
+		refund.Status = "confirmed"
+		if payment.IntentID != nil {
+			refund.Status = "pending"
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error creating refund:", err)
+	}
+	return err
+}
+
+// GetRefundsByPaymentID lists the refunds of a payment, voided ones included
+func GetRefundsByPaymentID(paymentID int) ([]models.Refund, error) {
+	var list []models.Refund
+	if err := config.GormDB.Where("payment_id = ?", paymentID).Order("refunded_at, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching refunds:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// ConfirmRefund marks a pending online refund as paid out by the gateway and counts it
+// towards its invoice
+func ConfirmRefund(id int) (models.Refund, error) {
+	var refund models.Refund
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, id).Error; err != nil {
+			return err
+		}
+		if refund.Status != "pending" || refund.VoidedAt != nil {
+			return ErrRefundNotPending
+		}
+		var inv models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, refund.InvoiceID).Error; err != nil {
+			return err
+		}
+		refund.Status = "confirmed"
+		if err := tx.Model(&models.Refund{}).Where("id = ?", id).Update("status", refund.Status).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error confirming refund:", err)
+	}
+	return refund, err
+}
+
+// FailRefund voids a pending online refund the gateway did not pay out
+func FailRefund(id int, reason string, userID int) error {
+	return voidRefund(id, reason, userID, true)
+}
+
+// VoidRefund cancels a refund recorded in error; the payment counts in full again. Online
+// refunds cannot be voided: the gateway has paid them out, or FailRefund voids them.
+func VoidRefund(id int, reason string, userID int) error {
+	return voidRefund(id, reason, userID, false)
+}
+
+func voidRefund(id int, reason string, userID int, pending bool) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var refund models.Refund
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, id).Error; err != nil {
+			return err
+		}
+		if refund.VoidedAt != nil {
+			return ErrAlreadyVoided
+		}
+		if pending && refund.Status != "pending" {
+			return ErrRefundNotPending
+		}
+		if !pending && refund.IntentID != nil {
+			return ErrOnlineRefund
+		}
+		var inv models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, refund.InvoiceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Refund{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"voided_at":   time.Now(),
+			"void_reason": reason,
+			"voided_by":   userID,
+		}).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error voiding refund:", err)
+	}
+	return err
+}
+
+// CreateCreditNote issues a numbered credit note that reduces what is owed on an invoice.
+// Crediting a paid invoice leaves a negative balance to be refunded.
+func CreateCreditNote(note *models.CreditNote) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		inv, err := lockPayableInvoice(tx, note.InvoiceID)
+		if err != nil {
+			return err
+		}
+		st, err := invoiceSettlement(tx, inv.ID)
+		if err != nil {
+			return err
+		}
+		if note.Amount <= 0 || note.Amount > inv.Amount-st.Credited {
+			return ErrCreditExceedsInvoice
+		}
+
+		note.IssuedAt = time.Now()
+		year := utils.FiscalYear(note.IssuedAt, utils.FiscalYearStartMonth())
+		n, err := nextSequenceValue(tx, fmt.Sprintf("credit_note:%d", year))
+		if err != nil {
+			return err
+		}
+		note.Number = utils.FormatDocumentNumber(CreditNotePrefix, year, n)
+		note.PatientID = inv.PatientID
+		note.Currency = inv.Currency
+		if err := tx.Create(note).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error creating credit note:", err)
+	}
+	return err
+}
+
+// GetCreditNoteByID retrieves a credit note by ID
+func GetCreditNoteByID(id int) (models.CreditNote, error) {
+	var note models.CreditNote
+	if err := config.GormDB.First(&note, id).Error; err != nil {
+		log.Println("Error fetching credit note:", err)
+		return note, err
+	}
+	return note, nil
+}
+
+// GetCreditNotesByInvoiceID lists the credit notes of an invoice, voided ones included
+func GetCreditNotesByInvoiceID(invoiceID int) ([]models.CreditNote, error) {
+	var list []models.CreditNote
+	if err := config.GormDB.Where("invoice_id = ?", invoiceID).Order("issued_at, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching credit notes:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// VoidCreditNote cancels a credit note; its number stays used
+func VoidCreditNote(id int, reason string, userID int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var note models.CreditNote
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&note, id).Error; err != nil {
+			return err
+		}
+		if note.VoidedAt != nil {
+			return ErrAlreadyVoided
+		}
+		var inv models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, note.InvoiceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CreditNote{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"voided_at":   time.Now(),
+			"void_reason": reason,
+			"voided_by":   userID,
+		}).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error voiding credit note:", err)
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDischargeSummarySigned = errors.New("discharge summary is signed and can no longer be changed")
+
+// GetDischargeSummaryByID retrieves a discharge summary by ID
+func GetDischargeSummaryByID(id int) (models.DischargeSummary, error) {
+	var summary models.DischargeSummary
+	if err := config.GormDB.First(&summary, id).Error; err != nil {
+		log.Println("Error fetching discharge summary:", err)
+		return summary, err
+	}
+	return summary, nil
+}
+
+// GetDischargeSummaryByAdmissionID retrieves the discharge summary of an admission
+func GetDischargeSummaryByAdmissionID(admissionID int) (models.DischargeSummary, error) {
+	var summary models.DischargeSummary
+	if err := config.GormDB.Where("admission_id = ?", admissionID).First(&summary).Error; err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// SaveDischargeSummaryDraft creates or regenerates the draft summary of an admission
+// together with its PDF file record. Signed summaries are rejected. It returns the path of the
+// draft PDF that was replaced, if any, so that the caller can delete it.
+func SaveDischargeSummaryDraft(summary *models.DischargeSummary, file *models.File) (string, error) {
+	var replaced string
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var existing models.DischargeSummary
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("admission_id = ?", summary.AdmissionID).
+			First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(file).Error; err != nil {
+				return err
+			}
+			summary.ID = 0
+			summary.Status = "draft"
+			summary.FileID = &file.ID
+			return tx.Create(summary).Error
+		case err != nil:
+			return err
+		}
+
+		if existing.Status == "signed" {
+			return ErrDischargeSummarySigned
+		}
+		if existing.FileID != nil {
+			var previous models.File
+			if err := tx.First(&previous, *existing.FileID).Error; err != nil {
+				return err
+			}
+			replaced = previous.FileURL
+			file.ID = *existing.FileID
+			if err := tx.Save(file).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+
+		summary.ID = existing.ID
+		summary.Status = "draft"
+		summary.FileID = &file.ID
+		summary.CreatedAt = existing.CreatedAt
+		return tx.Save(summary).Error
+	})
+	if err != nil {
+		log.Println("Error saving discharge summary:", err)
+		return "", err
+	}
+	return replaced, nil
+}
+
+// SignDischargeSummary locks a draft summary and points its file at the signed PDF. It returns
+// the path of the draft PDF that was replaced, if any.
+func SignDischargeSummary(id, signedBy int, signedAt time.Time, fileName, fileURL string) (models.DischargeSummary, string, error) {
+	var summary models.DischargeSummary
+	var replaced string
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&summary, id).Error; err != nil {
+			return err
+		}
+		if summary.Status == "signed" {
+			return ErrDischargeSummarySigned
+		}
+		if summary.FileID != nil {
+			var draft models.File
+			if err := tx.First(&draft, *summary.FileID).Error; err != nil {
+				return err
+			}
+			replaced = draft.FileURL
+			if err := tx.Model(&models.File{}).Where("id = ?", *summary.FileID).Updates(map[string]interface{}{
+				"file_name":   fileName,
+				"file_url":    fileURL,
+				"description": "Discharge summary (signed)",
+				"upload_date": signedAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		summary.Status = "signed"
+		summary.SignedAt = &signedAt
+		summary.SignedBy = &signedBy
+		return tx.Model(&models.DischargeSummary{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":    summary.Status,
+			"signed_at": signedAt,
+			"signed_by": signedBy,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error signing discharge summary:", err)
+		return summary, "", err
+	}
+	return summary, replaced, nil
+}
@@ -66,3 +66,13 @@ func SearchDoctors(queryStr string) ([]models.Doctor, error) {
 	}
 	return doctors, nil
 }
+
+// GetDoctorByUserID repo
+func GetDoctorByUserID(userID int) (models.Doctor, error) {
+	var doctor models.Doctor
+	if err := config.GormDB.Where("user_id = ?", userID).First(&doctor).Error; err != nil {
+		log.Println("Error fetching doctor by user ID:", err)
+		return doctor, err
+	}
+	return doctor, nil
+}
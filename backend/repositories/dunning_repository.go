@@ -0,0 +1,266 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrDunningNotDue          = errors.New("invoice is no longer overdue or was already reminded at this stage")
+	ErrCommunicationNotQueued = errors.New("only queued letters can be marked as sent")
+)
+
+// DunningContact is who overdue notices for an invoice are addressed to
+type DunningContact struct {
+	Name    string
+	Email   string
+	Address string
+}
+
+// GetDunningCandidates lists overdue invoices with a balance, oldest due date first
+func GetDunningCandidates() ([]models.Invoice, error) {
+	var list []models.Invoice
+	if err := config.GormDB.Where("status = ? AND balance_due > 0", "overdue").
+		Order("due_date, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching dunning candidates:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetDunningContact addresses notices to the invoice's guarantor, or else to the patient at
+// their address and the email of their portal account
+func GetDunningContact(inv models.Invoice) (DunningContact, error) {
+	var contact DunningContact
+	if inv.GuarantorID != nil {
+		var person models.RelatedPerson
+		if err := config.GormDB.First(&person, *inv.GuarantorID).Error; err != nil {
+			log.Println("Error fetching guarantor:", err)
+			return contact, err
+		}
+		return DunningContact{Name: person.FullName, Email: person.Email, Address: person.Address}, nil
+	}
+	var patient models.Patient
+	if err := config.GormDB.First(&patient, inv.PatientID).Error; err != nil {
+		log.Println("Error fetching patient:", err)
+		return contact, err
+	}
+	contact = DunningContact{Name: patient.FullName, Address: patient.Address}
+	var user models.User
+	err := config.GormDB.Where("patient_id = ?", inv.PatientID).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Error fetching patient account:", err)
+		return contact, err
+	}
+	contact.Email = user.Email
+	return contact, nil
+}
+
+// lateFeeLines counts the late-fee lines charged for an invoice or making it a late-fee
+// invoice itself; either way it must not be charged another fee
+func lateFeeLines(db *gorm.DB, invoiceID int) (int64, error) {
+	var count int64
+	err := db.Model(&models.InvoiceLine{}).
+		Where("source_type = ? AND (source_id = ? OR invoice_id = ?)", "late_fee", invoiceID, invoiceID).
+		Count(&count).Error
+	return count, err
+}
+
+// InvoiceHasLateFee reports whether an overdue invoice was already charged a late fee or is
+// itself a late-fee invoice, so that fees are never charged on fees
+func InvoiceHasLateFee(invoiceID int) (bool, error) {
+	count, err := lateFeeLines(config.GormDB, invoiceID)
+	if err != nil {
+		log.Println("Error checking late fee:", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// raiseLateFee issues a separate invoice charging lateFee for an overdue invoice, addressed to
+// the same patient and guarantor. Its one line points back at the overdue invoice, which is
+// left as issued.
+func raiseLateFee(tx *gorm.DB, overdue models.Invoice, lateFee models.Money, now time.Time) (models.Invoice, error) {
+	reference := "#" + strconv.Itoa(overdue.ID)
+	if overdue.Number != nil {
+		reference = *overdue.Number
+	}
+	fee := models.Invoice{
+		Location:    overdue.Location,
+		PatientID:   overdue.PatientID,
+		GuarantorID: overdue.GuarantorID,
+		Currency:    overdue.Currency,
+		Subtotal:    lateFee,
+		Amount:      lateFee,
+		BalanceDue:  lateFee,
+		Status:      "draft",
+		DueDate:     now.AddDate(0, 0, 14),
+		IssuedAt:    now,
+		Notes:       "Late payment fee for invoice " + reference,
+	}
+	if err := tx.Create(&fee).Error; err != nil {
+		return fee, err
+	}
+	line := models.InvoiceLine{
+		InvoiceID:   fee.ID,
+		Code:        "LATE-FEE",
+		Description: "Late payment fee for invoice " + reference,
+		Quantity:    1,
+		UnitPrice:   lateFee,
+		Amount:      lateFee,
+		SourceType:  "late_fee",
+		SourceID:    &overdue.ID,
+	}
+	if err := tx.Create(&line).Error; err != nil {
+		return fee, err
+	}
+	return fee, issueInvoice(tx, &fee, now)
+}
+
+// AdvanceDunning records in dunning_stage that reminder stage of an overdue invoice is being
+// sent (stage 0 sends none) and charges lateFee on a separate invoice, at most once per overdue
+// invoice and never on a late-fee invoice.
+// It returns the updated invoice and the late-fee invoice when one was raised, or
+// ErrDunningNotDue when the invoice was settled or already reminded.
+func AdvanceDunning(invoiceID, stage int, lateFee models.Money, now time.Time) (models.Invoice, *models.Invoice, error) {
+	var inv models.Invoice
+	var feeInvoice *models.Invoice
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, invoiceID).Error; err != nil {
+			return err
+		}
+		if inv.Status != "overdue" || inv.BalanceDue <= 0 || stage > 0 && stage <= inv.DunningStage {
+			return ErrDunningNotDue
+		}
+		if lateFee > 0 {
+			count, err := lateFeeLines(tx, invoiceID)
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				fee, err := raiseLateFee(tx, inv, lateFee, now)
+				if err != nil {
+					return err
+				}
+				feeInvoice = &fee
+			}
+		}
+		if stage > 0 {
+			if err := tx.Model(&models.Invoice{}).Where("id = ?", invoiceID).Updates(map[string]interface{}{
+				"dunning_stage":  stage,
+				"last_dunned_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if stage == 0 && feeInvoice == nil {
+			return ErrDunningNotDue
+		}
+		return tx.First(&inv, invoiceID).Error
+	})
+	if err != nil {
+		feeInvoice = nil
+		if !errors.Is(err, ErrDunningNotDue) {
+			log.Println("Error advancing dunning:", err)
+		}
+	}
+	return inv, feeInvoice, err
+}
+
+// EscalateToCollections hands an overdue invoice to collections. It stays there until it is
+// settled; payments keep being accepted.
+func EscalateToCollections(invoiceID int, now time.Time) (models.Invoice, error) {
+	var inv models.Invoice
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, invoiceID).Error; err != nil {
+			return err
+		}
+		if inv.Status != "overdue" || inv.BalanceDue <= 0 {
+			return ErrDunningNotDue
+		}
+		inv.Status = "collections"
+		inv.CollectionsAt = &now
+		return tx.Model(&models.Invoice{}).Where("id = ?", invoiceID).Updates(map[string]interface{}{
+			"status":         inv.Status,
+			"collections_at": now,
+		}).Error
+	})
+	if err != nil && !errors.Is(err, ErrDunningNotDue) {
+		log.Println("Error escalating invoice to collections:", err)
+	}
+	return inv, err
+}
+
+// CreateInvoiceCommunication adds an entry to an invoice's communication log
+func CreateInvoiceCommunication(c *models.InvoiceCommunication) error {
+	if err := config.GormDB.Create(c).Error; err != nil {
+		log.Println("Error creating invoice communication:", err)
+		return err
+	}
+	return nil
+}
+
+// GetInvoiceCommunications returns the communication log of an invoice, oldest first
+func GetInvoiceCommunications(invoiceID int) ([]models.InvoiceCommunication, error) {
+	var list []models.InvoiceCommunication
+	if err := config.GormDB.Where("invoice_id = ?", invoiceID).Order("created_at, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching invoice communications:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetCommunications lists log entries across invoices, optionally filtered by channel and
+// status (e.g. the queue of letters to print), oldest first
+func GetCommunications(channel, status string) ([]models.InvoiceCommunication, error) {
+	var list []models.InvoiceCommunication
+	q := config.GormDB.Order("created_at, id")
+	if channel != "" {
+		q = q.Where("channel = ?", channel)
+	}
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching invoice communications:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetInvoiceCommunicationByID retrieves a log entry by ID
+func GetInvoiceCommunicationByID(id int) (models.InvoiceCommunication, error) {
+	var c models.InvoiceCommunication
+	if err := config.GormDB.First(&c, id).Error; err != nil {
+		log.Println("Error fetching invoice communication:", err)
+		return c, err
+	}
+	return c, nil
+}
+
+// MarkLetterSent records that a queued letter was printed and posted
+func MarkLetterSent(id int, now time.Time) (models.InvoiceCommunication, error) {
+	var c models.InvoiceCommunication
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&c, id).Error; err != nil {
+			return err
+		}
+		if c.Status != "queued" {
+			return ErrCommunicationNotQueued
+		}
+		c.Status = "sent"
+		c.SentAt = &now
+		return tx.Model(&c).Updates(map[string]interface{}{"status": c.Status, "sent_at": now}).Error
+	})
+	if err != nil {
+		log.Println("Error marking letter sent:", err)
+	}
+	return c, err
+}
@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrEDEncounterState = errors.New("ED encounter has already departed")
+
+// RegisterEDArrival creates an ED encounter. When temp is set an unidentified patient is
+// registered first with a temporary identity (MRN TMP-<id>) that can be merged later.
+func RegisterEDArrival(enc *models.EDEncounter, temp *models.Patient) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if temp != nil {
+			temp.IsTemporary = true
+			if err := tx.Create(temp).Error; err != nil {
+				return err
+			}
+			temp.MRN = fmt.Sprintf("TMP-%d", temp.ID)
+			if err := tx.Model(temp).Update("mrn", temp.MRN).Error; err != nil {
+				return err
+			}
+			enc.PatientID = temp.ID
+		}
+		enc.Status = "waiting"
+		return tx.Create(enc).Error
+	})
+	if err != nil {
+		log.Println("Error registering ED arrival:", err)
+	}
+	return err
+}
+
+// GetEDEncounterByID retrieves an ED encounter with its triage vitals
+func GetEDEncounterByID(id int) (models.EDEncounter, error) {
+	var enc models.EDEncounter
+	if err := config.GormDB.Preload("TriageVitals").First(&enc, id).Error; err != nil {
+		log.Println("Error fetching ED encounter:", err)
+		return enc, err
+	}
+	return enc, nil
+}
+
+// updateActiveEDEncounter locks an encounter that has not departed yet and applies fn
+func updateActiveEDEncounter(id int, fn func(tx *gorm.DB, enc *models.EDEncounter) error) (models.EDEncounter, error) {
+	var enc models.EDEncounter
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&enc, id).Error; err != nil {
+			return err
+		}
+		if enc.Status == "departed" {
+			return ErrEDEncounterState
+		}
+		if err := fn(tx, &enc); err != nil {
+			return err
+		}
+		return tx.Omit("TriageVitals").Save(&enc).Error
+	})
+	if err != nil {
+		log.Println("Error updating ED encounter:", err)
+	}
+	return enc, err
+}
+
+// TriageEDEncounter records (or revises) the triage level and triage vitals
+func TriageEDEncounter(id, level int, vitals *models.VitalSign, triagedBy int) (models.EDEncounter, error) {
+	return updateActiveEDEncounter(id, func(tx *gorm.DB, enc *models.EDEncounter) error {
+		now := time.Now()
+		if vitals != nil {
+			vitals.ID = 0
+			vitals.PatientID = enc.PatientID
+			vitals.RecordedBy = triagedBy
+			if vitals.RecordedAt.IsZero() {
+				vitals.RecordedAt = now
+			}
+			if err := tx.Create(vitals).Error; err != nil {
+				return err
+			}
+			enc.TriageVitalSignID = &vitals.ID
+			enc.TriageVitals = vitals
+		}
+		enc.TriageLevel = &level
+		enc.TriagedAt = &now
+		enc.TriagedBy = &triagedBy
+		if enc.Status == "waiting" {
+			enc.Status = "triaged"
+		}
+		return nil
+	})
+}
+
+// AssignEDDoctor records the treating doctor; the first assignment is the door-to-doctor time
+func AssignEDDoctor(id, doctorID int) (models.EDEncounter, error) {
+	return updateActiveEDEncounter(id, func(tx *gorm.DB, enc *models.EDEncounter) error {
+		enc.DoctorID = &doctorID
+		if enc.SeenAt == nil {
+			now := time.Now()
+			enc.SeenAt = &now
+		}
+		enc.Status = "in_treatment"
+		return nil
+	})
+}
+
+// DepartEDEncounter closes the encounter with a disposition
+func DepartEDEncounter(id int, disposition string, admissionID *int) (models.EDEncounter, error) {
+	return updateActiveEDEncounter(id, func(tx *gorm.DB, enc *models.EDEncounter) error {
+		now := time.Now()
+		enc.Status = "departed"
+		enc.Disposition = disposition
+		enc.AdmissionID = admissionID
+		enc.DepartedAt = &now
+		return nil
+	})
+}
+
+// EDBoardEntry is one row of the ED tracking board
+type EDBoardEntry struct {
+	EncounterID    int        `json:"encounter_id"`
+	PatientID      int        `json:"patient_id"`
+	PatientName    string     `json:"patient_name"`
+	MRN            string     `json:"mrn"`
+	IsTemporary    bool       `json:"is_temporary"`
+	ChiefComplaint string     `json:"chief_complaint"`
+	TriageLevel    *int       `json:"triage_level,omitempty"`
+	Status         string     `json:"status"`
+	DoctorID       *int       `json:"doctor_id,omitempty"`
+	DoctorName     *string    `json:"doctor_name,omitempty"`
+	ArrivalAt      time.Time  `json:"arrival_at"`
+	SeenAt         *time.Time `json:"seen_at,omitempty"`
+	WaitMinutes    int        `json:"wait_minutes" gorm:"-"`
+}
+
+// GetEDBoard returns the patients currently in the department. Untriaged arrivals come
+// first (they still need to be assessed), then by ESI level and longest wait.
+func GetEDBoard() ([]EDBoardEntry, error) {
+	var entries []EDBoardEntry
+	if err := config.GormDB.Table("ed_encounters e").
+		Select("e.id AS encounter_id, e.patient_id, p.full_name AS patient_name, p.mrn, p.is_temporary, "+
+			"e.chief_complaint, e.triage_level, e.status, e.doctor_id, d.full_name AS doctor_name, e.arrival_at, e.seen_at").
+		Joins("JOIN patients p ON p.id = e.patient_id").
+		Joins("LEFT JOIN doctors d ON d.id = e.doctor_id").
+		Where("e.status <> ?", "departed").
+		Order("COALESCE(e.triage_level, 0), e.arrival_at").
+		Scan(&entries).Error; err != nil {
+		log.Println("Error fetching ED board:", err)
+		return nil, err
+	}
+	now := time.Now()
+	for i := range entries {
+		until := now
+		if entries[i].SeenAt != nil {
+			until = *entries[i].SeenAt
+		}
+		entries[i].WaitMinutes = int(until.Sub(entries[i].ArrivalAt).Minutes())
+	}
+	return entries, nil
+}
+
+// EDTimeMetrics summarises arrival-to-triage and door-to-doctor times in minutes
+type EDTimeMetrics struct {
+	TriageLevel          *int     `json:"triage_level,omitempty"`
+	Arrivals             int      `json:"arrivals"`
+	Seen                 int      `json:"seen"`
+	LeftWithoutBeingSeen int      `json:"left_without_being_seen"`
+	AvgDoorToTriage      *float64 `json:"avg_door_to_triage_minutes"`
+	AvgDoorToDoctor      *float64 `json:"avg_door_to_doctor_minutes"`
+	MedianDoorToDoctor   *float64 `json:"median_door_to_doctor_minutes"`
+	P90DoorToDoctor      *float64 `json:"p90_door_to_doctor_minutes"`
+}
+
+const edMetricsSelect = `COUNT(*) AS arrivals, COUNT(seen_at) AS seen,
+	COUNT(*) FILTER (WHERE disposition = 'left_without_being_seen') AS left_without_being_seen,
+	AVG(EXTRACT(EPOCH FROM triaged_at - arrival_at) / 60) AS avg_door_to_triage,
+	AVG(EXTRACT(EPOCH FROM seen_at - arrival_at) / 60) AS avg_door_to_doctor,
+	percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM seen_at - arrival_at) / 60) AS median_door_to_doctor,
+	percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM seen_at - arrival_at) / 60) AS p90_door_to_doctor`
+
+// GetEDMetrics computes the overall metrics and a breakdown per triage level for arrivals in [from, to)
+func GetEDMetrics(from, to time.Time) (EDTimeMetrics, []EDTimeMetrics, error) {
+	var overall EDTimeMetrics
+	var byLevel []EDTimeMetrics
+
+	q := config.GormDB.Model(&models.EDEncounter{}).Where("arrival_at >= ? AND arrival_at < ?", from, to)
+	if err := q.Session(&gorm.Session{}).Select(edMetricsSelect).Scan(&overall).Error; err != nil {
+		log.Println("Error computing ED metrics:", err)
+		return overall, nil, err
+	}
+	if err := q.Session(&gorm.Session{}).
+		Select("triage_level, " + edMetricsSelect).
+		Group("triage_level").
+		Order("triage_level NULLS LAST").
+		Scan(&byLevel).Error; err != nil {
+		log.Println("Error computing ED metrics by level:", err)
+		return overall, nil, err
+	}
+	return overall, byLevel, nil
+}
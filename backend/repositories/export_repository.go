@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// CreateExportJob inserts a new bulk export job
+func CreateExportJob(job *models.ExportJob) error {
+	if err := config.GormDB.Create(job).Error; err != nil {
+		log.Println("Error creating export job:", err)
+		return err
+	}
+	return nil
+}
+
+// GetExportJobByID retrieves an export job with its output files
+func GetExportJobByID(id string) (models.ExportJob, error) {
+	var job models.ExportJob
+	if err := config.GormDB.Preload("Files").First(&job, "id = ?", id).Error; err != nil {
+		log.Println("Error fetching export job:", err)
+		return job, err
+	}
+	return job, nil
+}
+
+// UpdateExportJobStatus sets the status, progress text and error of a job
+func UpdateExportJobStatus(id, status, progress, errMsg string) error {
+	updates := map[string]interface{}{
+		"status":   status,
+		"progress": progress,
+		"error":    errMsg,
+	}
+	if status != "in-progress" {
+		updates["completed_at"] = time.Now()
+	}
+	if err := config.GormDB.Model(&models.ExportJob{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log.Println("Error updating export job:", err)
+		return err
+	}
+	return nil
+}
+
+// CreateExportFile records one output file of a job
+func CreateExportFile(file *models.ExportFile) error {
+	if err := config.GormDB.Create(file).Error; err != nil {
+		log.Println("Error creating export file:", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteExportJob removes a job and its file records
+func DeleteExportJob(id string) error {
+	if err := config.GormDB.Where("job_id = ?", id).Delete(&models.ExportFile{}).Error; err != nil {
+		log.Println("Error deleting export files:", err)
+		return err
+	}
+	if err := config.GormDB.Delete(&models.ExportJob{}, "id = ?", id).Error; err != nil {
+		log.Println("Error deleting export job:", err)
+		return err
+	}
+	return nil
+}
+
+// ExportFilter restricts the rows streamed for an export
+type ExportFilter struct {
+	Since     *time.Time
+	PatientID int
+	// ConsentType, when set, limits the export to patients holding that consent for ConsentScope
+	ConsentType  string
+	ConsentScope string
+}
+
+// StreamForExport iterates over a table with a database cursor and calls fn for every row,
+// so exports never hold a full table in memory. patientColumn is "id" for the patients table.
+func StreamForExport[T any](ctx context.Context, f ExportFilter, patientColumn, sinceColumn string, fn func(T) error) error {
+	q := config.GormDB.WithContext(ctx).Model(new(T)).Order("id")
+	if f.Since != nil {
+		q = q.Where(sinceColumn+" > ?", *f.Since)
+	}
+	if f.PatientID != 0 {
+		q = q.Where(patientColumn+" = ?", f.PatientID)
+	}
+	if f.ConsentType != "" {
+		q = q.Where(patientColumn+" IN (?)", consentedPatients(f.ConsentType, f.ConsentScope))
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		log.Println("Error opening export cursor:", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row T
+		if err := config.GormDB.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+)
+
+// FHIRSearch carries the FHIR search parameters supported by the read API.
+// Zero values mean "not filtered". DateTo is exclusive.
+type FHIRSearch struct {
+	ID             int
+	PatientID      int
+	PractitionerID int
+	Identifier     string
+	Name           string
+	BirthDate      string
+	Gender         []string
+	Status         []string
+	Code           string
+	Active         *bool
+	DateFrom       *time.Time
+	DateTo         *time.Time
+	Count          int
+	Offset         int
+	// ConsentType, when set, hides rows of patients without that consent for ConsentScope
+	ConsentType  string
+	ConsentScope string
+}
+
+// paginate counts the matches and loads one page into dest
+func paginate(q *gorm.DB, s FHIRSearch, order string, dest interface{}) (int64, error) {
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return 0, err
+	}
+	if err := q.Order(order).Limit(s.Count).Offset(s.Offset).Find(dest).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func applyConsent(q *gorm.DB, column string, s FHIRSearch) *gorm.DB {
+	if s.ConsentType != "" {
+		q = q.Where(column+" IN (?)", consentedPatients(s.ConsentType, s.ConsentScope))
+	}
+	return q
+}
+
+func applyDateRange(q *gorm.DB, column string, s FHIRSearch) *gorm.DB {
+	if s.DateFrom != nil {
+		q = q.Where(column+" >= ?", *s.DateFrom)
+	}
+	if s.DateTo != nil {
+		q = q.Where(column+" < ?", *s.DateTo)
+	}
+	return q
+}
+
+// SearchPatientsFHIR searches patients by _id, identifier, name, birthdate and gender
+func SearchPatientsFHIR(s FHIRSearch) ([]models.Patient, int64, error) {
+	var patients []models.Patient
+	q := config.GormDB.Model(&models.Patient{})
+	if s.ID != 0 {
+		q = q.Where("id = ?", s.ID)
+	}
+	if s.Identifier != "" {
+		q = q.Where("mrn = ? OR insurance_number = ?", s.Identifier, s.Identifier)
+	}
+	if s.Name != "" {
+		q = q.Where("full_name ILIKE ?", "%"+s.Name+"%")
+	}
+	if s.BirthDate != "" {
+		q = q.Where("date_of_birth = ?", s.BirthDate)
+	}
+	if len(s.Gender) > 0 {
+		q = q.Where("LOWER(gender) IN ?", s.Gender)
+	}
+	q = applyConsent(q, "id", s)
+	total, err := paginate(q, s, "id", &patients)
+	if err != nil {
+		log.Println("Error searching FHIR patients:", err)
+		return nil, 0, err
+	}
+	return patients, total, nil
+}
+
+// SearchDoctorsFHIR searches practitioners by _id, name and active
+func SearchDoctorsFHIR(s FHIRSearch) ([]models.Doctor, int64, error) {
+	var doctors []models.Doctor
+	q := config.GormDB.Model(&models.Doctor{})
+	if s.ID != 0 {
+		q = q.Where("id = ?", s.ID)
+	}
+	if s.Name != "" {
+		q = q.Where("full_name ILIKE ?", "%"+s.Name+"%")
+	}
+	if s.Active != nil {
+		q = q.Where("status = ?", *s.Active)
+	}
+	total, err := paginate(q, s, "id", &doctors)
+	if err != nil {
+		log.Println("Error searching FHIR practitioners:", err)
+		return nil, 0, err
+	}
+	return doctors, total, nil
+}
+
+// SearchAppointmentsFHIR searches appointments (also used for Encounter)
+func SearchAppointmentsFHIR(s FHIRSearch) ([]models.Appointment, int64, error) {
+	var appointments []models.Appointment
+	q := config.GormDB.Model(&models.Appointment{})
+	if s.ID != 0 {
+		q = q.Where("id = ?", s.ID)
+	}
+	if s.PatientID != 0 {
+		q = q.Where("patient_id = ?", s.PatientID)
+	}
+	if s.PractitionerID != 0 {
+		q = q.Where("doctor_id = ?", s.PractitionerID)
+	}
+	if len(s.Status) > 0 {
+		q = q.Where("status IN ?", s.Status)
+	}
+	q = applyDateRange(q, "date_time", s)
+	q = applyConsent(q, "patient_id", s)
+	total, err := paginate(q, s, "date_time DESC, id", &appointments)
+	if err != nil {
+		log.Println("Error searching FHIR appointments:", err)
+		return nil, 0, err
+	}
+	return appointments, total, nil
+}
+
+// SearchMedicalRecordsFHIR searches medical records (Condition / MedicationRequest).
+// withPrescription restricts the result to records that carry a prescription.
+func SearchMedicalRecordsFHIR(s FHIRSearch, withPrescription bool) ([]models.MedicalRecord, int64, error) {
+	var records []models.MedicalRecord
+	q := config.GormDB.Model(&models.MedicalRecord{})
+	if s.ID != 0 {
+		q = q.Where("id = ?", s.ID)
+	}
+	if s.PatientID != 0 {
+		q = q.Where("patient_id = ?", s.PatientID)
+	}
+	if s.PractitionerID != 0 {
+		q = q.Where("doctor_id = ?", s.PractitionerID)
+	}
+	if withPrescription {
+		q = q.Where("prescription <> ''")
+	}
+	q = applyConsent(q, "patient_id", s)
+	total, err := paginate(q, s, "creation_date DESC, id", &records)
+	if err != nil {
+		log.Println("Error searching FHIR medical records:", err)
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// SearchFilesFHIR searches files (DocumentReference)
+func SearchFilesFHIR(s FHIRSearch) ([]models.File, int64, error) {
+	var files []models.File
+	q := config.GormDB.Model(&models.File{})
+	if s.ID != 0 {
+		q = q.Where("id = ?", s.ID)
+	}
+	if s.PatientID != 0 {
+		q = q.Where("patient_id = ?", s.PatientID)
+	}
+	q = applyConsent(q, "patient_id", s)
+	total, err := paginate(q, s, "upload_date DESC, id", &files)
+	if err != nil {
+		log.Println("Error searching FHIR document references:", err)
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+// SearchLabResultsFHIR searches lab results (Observation)
+func SearchLabResultsFHIR(s FHIRSearch) ([]models.LabResult, int64, error) {
+	var results []models.LabResult
+	q := config.GormDB.Model(&models.LabResult{})
+	if s.ID != 0 {
+		q = q.Where("id = ?", s.ID)
+	}
+	if s.PatientID != 0 {
+		q = q.Where("patient_id = ?", s.PatientID)
+	}
+	if s.Code != "" {
+		// token search may be system|code; only the code part is stored
+		code := s.Code
+		if i := strings.LastIndex(code, "|"); i >= 0 {
+			code = code[i+1:]
+		}
+		q = q.Where("test_code = ?", code)
+	}
+	q = applyDateRange(q, "observed_at", s)
+	q = applyConsent(q, "patient_id", s)
+	total, err := paginate(q, s, "observed_at DESC, id", &results)
+	if err != nil {
+		log.Println("Error searching FHIR observations:", err)
+		return nil, 0, err
+	}
+	return results, total, nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"log"
+	"time"
 
 	"github.com/samichen99/HAP-hospital-management-system/config"
 	"github.com/samichen99/HAP-hospital-management-system/models"
@@ -46,9 +47,10 @@ func GetFilesByPatientID(patientID int) ([]models.File, error) {
 	return files, nil
 }
 
-// UpdateFile updates an existing file record
+// UpdateFile updates an existing file record; the portal release flag is changed only through
+// ReleaseFile
 func UpdateFile(file models.File) error {
-	if err := config.GormDB.Save(&file).Error; err != nil {
+	if err := config.GormDB.Omit("released_to_patient", "released_at").Save(&file).Error; err != nil {
 		log.Println("Error updating file:", err)
 		return err
 	}
@@ -63,3 +65,68 @@ func DeleteFile(id int) error {
 	}
 	return nil
 }
+
+// GetRecentFilesByPatientID retrieves the latest uploaded files of a patient
+func GetRecentFilesByPatientID(patientID, limit int) ([]models.File, error) {
+	var files []models.File
+	if err := config.GormDB.Where("patient_id = ?", patientID).
+		Order("upload_date DESC").
+		Limit(limit).
+		Find(&files).Error; err != nil {
+		log.Println("Error fetching recent files by patient ID:", err)
+		return nil, err
+	}
+	return files, nil
+}
+
+// IsFileLocked reports whether a file belongs to a signed discharge summary or signed
+// document and must not be changed
+func IsFileLocked(fileID int) (bool, error) {
+	var summaries, documents int64
+	if err := config.GormDB.Model(&models.DischargeSummary{}).
+		Where("file_id = ? AND status = ?", fileID, "signed").
+		Count(&summaries).Error; err != nil {
+		log.Println("Error checking file lock:", err)
+		return false, err
+	}
+	if err := config.GormDB.Model(&models.SignedDocument{}).
+		Where("file_id = ?", fileID).
+		Count(&documents).Error; err != nil {
+		log.Println("Error checking file lock:", err)
+		return false, err
+	}
+	return summaries+documents > 0, nil
+}
+
+// ReleaseFile shows (or hides again) a file in the patient portal
+func ReleaseFile(id int, released bool) (models.File, error) {
+	var file models.File
+	if err := config.GormDB.First(&file, id).Error; err != nil {
+		return file, err
+	}
+	file.ReleasedToPatient = released
+	file.ReleasedAt = nil
+	if released {
+		now := time.Now()
+		file.ReleasedAt = &now
+	}
+	err := config.GormDB.Model(&file).
+		Select("released_to_patient", "released_at").
+		Updates(&file).Error
+	if err != nil {
+		log.Println("Error releasing file:", err)
+	}
+	return file, err
+}
+
+// GetReleasedFilesByPatientID retrieves the files a patient may download in the portal
+func GetReleasedFilesByPatientID(patientID int) ([]models.File, error) {
+	var files []models.File
+	if err := config.GormDB.Where("patient_id = ? AND released_to_patient", patientID).
+		Order("upload_date DESC").
+		Find(&files).Error; err != nil {
+		log.Println("Error fetching released files by patient ID:", err)
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// ParkHL7Message stores a failed inbound message in the error queue
+func ParkHL7Message(msg *models.HL7ErrorMessage) error {
+	if err := config.GormDB.Create(msg).Error; err != nil {
+		log.Println("Error parking HL7 message:", err)
+		return err
+	}
+	return nil
+}
+
+// GetHL7ErrorQueue lists parked messages, optionally including resolved ones
+func GetHL7ErrorQueue(includeResolved bool) ([]models.HL7ErrorMessage, error) {
+	var messages []models.HL7ErrorMessage
+	q := config.GormDB.Order("received_at DESC")
+	if !includeResolved {
+		q = q.Where("resolved = ?", false)
+	}
+	if err := q.Find(&messages).Error; err != nil {
+		log.Println("Error fetching HL7 error queue:", err)
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetHL7ErrorMessageByID retrieves a parked message by ID
+func GetHL7ErrorMessageByID(id int) (models.HL7ErrorMessage, error) {
+	var msg models.HL7ErrorMessage
+	if err := config.GormDB.First(&msg, id).Error; err != nil {
+		log.Println("Error fetching HL7 error message:", err)
+		return msg, err
+	}
+	return msg, nil
+}
+
+// ResolveHL7ErrorMessage marks a parked message as handled
+func ResolveHL7ErrorMessage(id int) error {
+	if err := config.GormDB.Model(&models.HL7ErrorMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"resolved":    true,
+			"resolved_at": time.Now(),
+		}).Error; err != nil {
+		log.Println("Error resolving HL7 error message:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm/clause"
+)
+
+// CreateImmunization inserts a new immunization record
+func CreateImmunization(imm *models.Immunization) error {
+	if err := config.GormDB.Create(imm).Error; err != nil {
+		log.Println("Error creating immunization:", err)
+		return err
+	}
+	return nil
+}
+
+// GetImmunizationByID retrieves an immunization by ID
+func GetImmunizationByID(id int) (models.Immunization, error) {
+	var imm models.Immunization
+	if err := config.GormDB.First(&imm, id).Error; err != nil {
+		log.Println("Error fetching immunization:", err)
+		return imm, err
+	}
+	return imm, nil
+}
+
+// GetImmunizationsByPatientID retrieves a patient's vaccination history, oldest first
+func GetImmunizationsByPatientID(patientID int) ([]models.Immunization, error) {
+	var list []models.Immunization
+	if err := config.GormDB.Where("patient_id = ?", patientID).
+		Order("date_administered ASC").
+		Find(&list).Error; err != nil {
+		log.Println("Error fetching immunizations by patient ID:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateImmunization updates an existing immunization record
+func UpdateImmunization(imm models.Immunization) error {
+	result := config.GormDB.Model(&models.Immunization{}).Where("id = ?", imm.ID).Updates(imm)
+	if result.Error != nil {
+		log.Println("Error updating immunization:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("no immunization found with the given ID")
+	}
+	return nil
+}
+
+// DeleteImmunization deletes an immunization by ID
+func DeleteImmunization(id int) error {
+	if err := config.GormDB.Delete(&models.Immunization{}, id).Error; err != nil {
+		log.Println("Error deleting immunization:", err)
+		return err
+	}
+	return nil
+}
+
+// GetVaccinationSchedule returns the schedule ordered by age
+func GetVaccinationSchedule(activeOnly bool) ([]models.VaccinationScheduleEntry, error) {
+	var entries []models.VaccinationScheduleEntry
+	q := config.GormDB.Order("age_days ASC, vaccine_code ASC, dose_number ASC")
+	if activeOnly {
+		q = q.Where("active = ?", true)
+	}
+	if err := q.Find(&entries).Error; err != nil {
+		log.Println("Error fetching vaccination schedule:", err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CreateVaccinationScheduleEntry adds a dose to the schedule
+func CreateVaccinationScheduleEntry(entry *models.VaccinationScheduleEntry) error {
+	if err := config.GormDB.Create(entry).Error; err != nil {
+		log.Println("Error creating schedule entry:", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateVaccinationScheduleEntry saves a schedule entry
+func UpdateVaccinationScheduleEntry(entry models.VaccinationScheduleEntry) error {
+	if err := config.GormDB.Save(&entry).Error; err != nil {
+		log.Println("Error updating schedule entry:", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteVaccinationScheduleEntry removes a dose from the schedule
+func DeleteVaccinationScheduleEntry(id int) error {
+	if err := config.GormDB.Delete(&models.VaccinationScheduleEntry{}, id).Error; err != nil {
+		log.Println("Error deleting schedule entry:", err)
+		return err
+	}
+	return nil
+}
+
+// SeedVaccinationSchedule inserts the default schedule when the table is empty
+func SeedVaccinationSchedule(defaults []models.VaccinationScheduleEntry) error {
+	var count int64
+	if err := config.GormDB.Model(&models.VaccinationScheduleEntry{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	entries := make([]models.VaccinationScheduleEntry, len(defaults))
+	copy(entries, defaults)
+	for i := range entries {
+		entries[i].Active = true
+	}
+	return config.GormDB.Create(&entries).Error
+}
+
+// ImmunizationReminderSent reports whether an overdue dose was already reminded
+func ImmunizationReminderSent(patientID int, vaccineCode string, doseNumber int) (bool, error) {
+	var count int64
+	if err := config.GormDB.Model(&models.ImmunizationReminder{}).
+		Where("patient_id = ? AND vaccine_code = ? AND dose_number = ?", patientID, vaccineCode, doseNumber).
+		Count(&count).Error; err != nil {
+		log.Println("Error checking immunization reminder:", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RecordImmunizationReminder stores that an overdue dose entered the reminder pipeline
+func RecordImmunizationReminder(patientID int, vaccineCode string, doseNumber int) error {
+	if err := config.GormDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ImmunizationReminder{
+		PatientID:   patientID,
+		VaccineCode: vaccineCode,
+		DoseNumber:  doseNumber,
+		SentAt:      time.Now(),
+	}).Error; err != nil {
+		log.Println("Error recording immunization reminder:", err)
+		return err
+	}
+	return nil
+}
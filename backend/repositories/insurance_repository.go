@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPayerCodeExists = errors.New("a payer with this code already exists")
+	ErrUnknownPayer    = errors.New("payer not found")
+)
+
+// CreatePayer registers an insurance company or other third-party payer
+func CreatePayer(payer *models.Payer) error {
+	var count int64
+	if err := config.GormDB.Model(&models.Payer{}).Where("code = ?", payer.Code).Count(&count).Error; err != nil {
+		log.Println("Error checking payer code:", err)
+		return err
+	}
+	if count > 0 {
+		return ErrPayerCodeExists
+	}
+	if err := config.GormDB.Create(payer).Error; err != nil {
+		log.Println("Error creating payer:", err)
+		return err
+	}
+	return nil
+}
+
+// GetPayers lists payers by name, optionally only active ones
+func GetPayers(activeOnly bool) ([]models.Payer, error) {
+	var list []models.Payer
+	q := config.GormDB.Order("name")
+	if activeOnly {
+		q = q.Where("active = ?", true)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching payers:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetPayerByID retrieves a payer by ID
+func GetPayerByID(id int) (models.Payer, error) {
+	var payer models.Payer
+	if err := config.GormDB.First(&payer, id).Error; err != nil {
+		log.Println("Error fetching payer:", err)
+		return payer, err
+	}
+	return payer, nil
+}
+
+// UpdatePayer saves a payer; deactivated payers keep their policies and claims
+func UpdatePayer(payer *models.Payer) error {
+	var count int64
+	if err := config.GormDB.Model(&models.Payer{}).Where("code = ? AND id <> ?", payer.Code, payer.ID).Count(&count).Error; err != nil {
+		log.Println("Error checking payer code:", err)
+		return err
+	}
+	if count > 0 {
+		return ErrPayerCodeExists
+	}
+	if err := config.GormDB.Save(payer).Error; err != nil {
+		log.Println("Error updating payer:", err)
+		return err
+	}
+	return nil
+}
+
+// CreateInsurancePolicy adds a policy to a patient
+func CreateInsurancePolicy(policy *models.InsurancePolicy) error {
+	if err := config.GormDB.First(&models.Payer{}, policy.PayerID).Error; err != nil {
+		return ErrUnknownPayer
+	}
+	if err := config.GormDB.Create(policy).Error; err != nil {
+		log.Println("Error creating insurance policy:", err)
+		return err
+	}
+	return nil
+}
+
+// GetInsurancePoliciesByPatientID lists a patient's policies, primary first
+func GetInsurancePoliciesByPatientID(patientID int) ([]models.InsurancePolicy, error) {
+	var list []models.InsurancePolicy
+	if err := config.GormDB.Where("patient_id = ?", patientID).
+		Order("active DESC, priority, effective_from DESC").
+		Find(&list).Error; err != nil {
+		log.Println("Error fetching insurance policies:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetInsurancePolicyByID retrieves a policy by ID
+func GetInsurancePolicyByID(id int) (models.InsurancePolicy, error) {
+	var policy models.InsurancePolicy
+	if err := config.GormDB.First(&policy, id).Error; err != nil {
+		log.Println("Error fetching insurance policy:", err)
+		return policy, err
+	}
+	return policy, nil
+}
+
+// UpdateInsurancePolicy saves a policy. Policies that claims refer to are ended or
+// deactivated rather than deleted.
+func UpdateInsurancePolicy(policy *models.InsurancePolicy) error {
+	if err := config.GormDB.First(&models.Payer{}, policy.PayerID).Error; err != nil {
+		return ErrUnknownPayer
+	}
+	if err := config.GormDB.Omit("patient_id").Save(policy).Error; err != nil {
+		log.Println("Error updating insurance policy:", err)
+		return err
+	}
+	return nil
+}
+
+// CreateCoverageRule adds a cost sharing rule to a payer
+func CreateCoverageRule(rule *models.CoverageRule) error {
+	if err := config.GormDB.First(&models.Payer{}, rule.PayerID).Error; err != nil {
+		return ErrUnknownPayer
+	}
+	if err := config.GormDB.Create(rule).Error; err != nil {
+		log.Println("Error creating coverage rule:", err)
+		return err
+	}
+	return nil
+}
+
+// GetCoverageRules lists a payer's coverage rules by category
+func GetCoverageRules(payerID int) ([]models.CoverageRule, error) {
+	var list []models.CoverageRule
+	if err := config.GormDB.Where("payer_id = ?", payerID).Order("service_category, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching coverage rules:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteCoverageRule removes a coverage rule. Existing claims keep the split they were created with.
+func DeleteCoverageRule(id int) error {
+	result := config.GormDB.Delete(&models.CoverageRule{}, id)
+	if result.Error != nil {
+		log.Println("Error deleting coverage rule:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidMovement       = errors.New("invalid inventory movement")
+	ErrPurchaseOrderState    = errors.New("purchase order is not in a valid state for this action")
+	ErrOverReceipt           = errors.New("received quantity exceeds the ordered quantity")
+	ErrPurchaseOrderLineItem = errors.New("line does not belong to this purchase order")
+)
+
+// CreateInventoryItem inserts a new inventory item
+func CreateInventoryItem(item *models.InventoryItem) error {
+	if err := config.GormDB.Create(item).Error; err != nil {
+		log.Println("Error creating inventory item:", err)
+		return err
+	}
+	return nil
+}
+
+// GetInventoryItems lists items, optionally by category
+func GetInventoryItems(category string) ([]models.InventoryItem, error) {
+	var items []models.InventoryItem
+	q := config.GormDB.Order("name")
+	if category != "" {
+		q = q.Where("category = ?", category)
+	}
+	if err := q.Find(&items).Error; err != nil {
+		log.Println("Error fetching inventory items:", err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetInventoryItemByID retrieves an inventory item
+func GetInventoryItemByID(id int) (models.InventoryItem, error) {
+	var item models.InventoryItem
+	if err := config.GormDB.First(&item, id).Error; err != nil {
+		log.Println("Error fetching inventory item:", err)
+		return item, err
+	}
+	return item, nil
+}
+
+// UpdateInventoryItem updates an inventory item
+func UpdateInventoryItem(item models.InventoryItem) error {
+	result := config.GormDB.Model(&models.InventoryItem{}).Where("id = ?", item.ID).
+		Select("sku", "name", "category", "unit", "reorder_level", "reorder_quantity", "lead_time_days", "active").
+		Updates(item)
+	if result.Error != nil {
+		log.Println("Error updating inventory item:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateInventoryLocation inserts a new location
+func CreateInventoryLocation(loc *models.InventoryLocation) error {
+	if err := config.GormDB.Create(loc).Error; err != nil {
+		log.Println("Error creating inventory location:", err)
+		return err
+	}
+	return nil
+}
+
+// GetInventoryLocations lists all locations
+func GetInventoryLocations() ([]models.InventoryLocation, error) {
+	var locations []models.InventoryLocation
+	if err := config.GormDB.Order("name").Find(&locations).Error; err != nil {
+		log.Println("Error fetching inventory locations:", err)
+		return nil, err
+	}
+	return locations, nil
+}
+
+// CreateSupplier inserts a new supplier
+func CreateSupplier(s *models.Supplier) error {
+	if err := config.GormDB.Create(s).Error; err != nil {
+		log.Println("Error creating supplier:", err)
+		return err
+	}
+	return nil
+}
+
+// GetSuppliers lists suppliers
+func GetSuppliers() ([]models.Supplier, error) {
+	var suppliers []models.Supplier
+	if err := config.GormDB.Order("name").Find(&suppliers).Error; err != nil {
+		log.Println("Error fetching suppliers:", err)
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+// UpdateSupplier updates a supplier
+func UpdateSupplier(s models.Supplier) error {
+	result := config.GormDB.Model(&models.Supplier{}).Where("id = ?", s.ID).
+		Select("name", "contact_name", "email", "phone", "address", "active").
+		Updates(s)
+	if result.Error != nil {
+		log.Println("Error updating supplier:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// applyStockDelta changes the quantity on hand of an item at a location, refusing negative stock
+func applyStockDelta(tx *gorm.DB, itemID, locationID, delta int) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.StockLevel{ItemID: itemID, LocationID: locationID}).Error; err != nil {
+		return err
+	}
+	var level models.StockLevel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("item_id = ? AND location_id = ?", itemID, locationID).
+		First(&level).Error; err != nil {
+		return err
+	}
+	if level.Quantity+delta < 0 {
+		return fmt.Errorf("%w: %d on hand at location %d", ErrInsufficientStock, level.Quantity, locationID)
+	}
+	return tx.Model(&models.StockLevel{}).
+		Where("item_id = ? AND location_id = ?", itemID, locationID).
+		Updates(map[string]interface{}{"quantity": level.Quantity + delta, "updated_at": time.Now()}).Error
+}
+
+// recordMovement applies a movement to the stock levels and stores it
+func recordMovement(tx *gorm.DB, m *models.InventoryMovement) error {
+	switch m.Type {
+	case "receipt":
+		if m.ToLocationID == nil || m.Quantity <= 0 {
+			return ErrInvalidMovement
+		}
+		if err := applyStockDelta(tx, m.ItemID, *m.ToLocationID, m.Quantity); err != nil {
+			return err
+		}
+	case "issue":
+		if m.FromLocationID == nil || m.Quantity <= 0 {
+			return ErrInvalidMovement
+		}
+		if err := applyStockDelta(tx, m.ItemID, *m.FromLocationID, -m.Quantity); err != nil {
+			return err
+		}
+	case "transfer":
+		if m.FromLocationID == nil || m.ToLocationID == nil || m.Quantity <= 0 || *m.FromLocationID == *m.ToLocationID {
+			return ErrInvalidMovement
+		}
+		if err := applyStockDelta(tx, m.ItemID, *m.FromLocationID, -m.Quantity); err != nil {
+			return err
+		}
+		if err := applyStockDelta(tx, m.ItemID, *m.ToLocationID, m.Quantity); err != nil {
+			return err
+		}
+	case "adjustment":
+		if m.ToLocationID == nil || m.Quantity == 0 {
+			return ErrInvalidMovement
+		}
+		if err := applyStockDelta(tx, m.ItemID, *m.ToLocationID, m.Quantity); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidMovement
+	}
+	return tx.Create(m).Error
+}
+
+// RecordInventoryMovement applies a receipt, issue, transfer or adjustment atomically
+func RecordInventoryMovement(m *models.InventoryMovement) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		return recordMovement(tx, m)
+	})
+	if err != nil {
+		log.Println("Error recording inventory movement:", err)
+	}
+	return err
+}
+
+// GetStockLevels lists stock levels, optionally for one item and/or location
+func GetStockLevels(itemID, locationID int) ([]models.StockLevel, error) {
+	var levels []models.StockLevel
+	q := config.GormDB.Order("item_id, location_id")
+	if itemID != 0 {
+		q = q.Where("item_id = ?", itemID)
+	}
+	if locationID != 0 {
+		q = q.Where("location_id = ?", locationID)
+	}
+	if err := q.Find(&levels).Error; err != nil {
+		log.Println("Error fetching stock levels:", err)
+		return nil, err
+	}
+	return levels, nil
+}
+
+// GetInventoryMovements lists movements touching an item and/or location, newest first
+func GetInventoryMovements(itemID, locationID int, since *time.Time) ([]models.InventoryMovement, error) {
+	var movements []models.InventoryMovement
+	q := config.GormDB.Order("created_at DESC, id DESC")
+	if itemID != 0 {
+		q = q.Where("item_id = ?", itemID)
+	}
+	if locationID != 0 {
+		q = q.Where("from_location_id = ? OR to_location_id = ?", locationID, locationID)
+	}
+	if since != nil {
+		q = q.Where("created_at >= ?", *since)
+	}
+	if err := q.Find(&movements).Error; err != nil {
+		log.Println("Error fetching inventory movements:", err)
+		return nil, err
+	}
+	return movements, nil
+}
+
+// CreatePurchaseOrder stores a draft purchase order with its lines
+func CreatePurchaseOrder(po *models.PurchaseOrder) error {
+	po.Status = "draft"
+	if err := config.GormDB.Create(po).Error; err != nil {
+		log.Println("Error creating purchase order:", err)
+		return err
+	}
+	return nil
+}
+
+// GetPurchaseOrderByID retrieves a purchase order with its lines
+func GetPurchaseOrderByID(id int) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	if err := config.GormDB.Preload("Lines").First(&po, id).Error; err != nil {
+		log.Println("Error fetching purchase order:", err)
+		return po, err
+	}
+	return po, nil
+}
+
+// GetPurchaseOrders lists purchase orders, optionally by status and supplier
+func GetPurchaseOrders(status string, supplierID int) ([]models.PurchaseOrder, error) {
+	var list []models.PurchaseOrder
+	q := config.GormDB.Preload("Lines").Order("created_at DESC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if supplierID != 0 {
+		q = q.Where("supplier_id = ?", supplierID)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching purchase orders:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+func lockPurchaseOrder(tx *gorm.DB, id int) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&po, id).Error; err != nil {
+		return po, err
+	}
+	err := tx.Where("purchase_order_id = ?", id).Order("id").Find(&po.Lines).Error
+	return po, err
+}
+
+// SetPurchaseOrderStatus moves a purchase order from one of the allowed states to a new one
+func SetPurchaseOrderStatus(id int, from []string, to string) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = lockPurchaseOrder(tx, id); err != nil {
+			return err
+		}
+		allowed := false
+		for _, s := range from {
+			allowed = allowed || po.Status == s
+		}
+		if !allowed {
+			return ErrPurchaseOrderState
+		}
+		updates := map[string]interface{}{"status": to}
+		if to == "ordered" {
+			now := time.Now()
+			po.OrderedAt = &now
+			updates["ordered_at"] = now
+		}
+		po.Status = to
+		return tx.Model(&models.PurchaseOrder{}).Where("id = ?", id).Updates(updates).Error
+	})
+	if err != nil {
+		log.Println("Error updating purchase order status:", err)
+	}
+	return po, err
+}
+
+// ReceiptLine is a quantity received against a purchase order line
+type ReceiptLine struct {
+	LineID   int `json:"line_id"`
+	Quantity int `json:"quantity"`
+}
+
+// ReceivePurchaseOrder books received quantities into stock at the order's location
+// and derives the order status from what is still outstanding
+func ReceivePurchaseOrder(id int, receipts []ReceiptLine, performedBy int) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = lockPurchaseOrder(tx, id); err != nil {
+			return err
+		}
+		if po.Status != "ordered" && po.Status != "partially_received" {
+			return ErrPurchaseOrderState
+		}
+
+		lines := make(map[int]*models.PurchaseOrderLine, len(po.Lines))
+		for i := range po.Lines {
+			lines[po.Lines[i].ID] = &po.Lines[i]
+		}
+		for _, rec := range receipts {
+			line, ok := lines[rec.LineID]
+			if !ok {
+				return ErrPurchaseOrderLineItem
+			}
+			if line.QuantityReceived+rec.Quantity > line.QuantityOrdered {
+				return ErrOverReceipt
+			}
+			line.QuantityReceived += rec.Quantity
+			if err := tx.Model(&models.PurchaseOrderLine{}).Where("id = ?", line.ID).
+				Update("quantity_received", line.QuantityReceived).Error; err != nil {
+				return err
+			}
+			locationID, lineID := po.LocationID, line.ID
+			if err := recordMovement(tx, &models.InventoryMovement{
+				ItemID:              line.ItemID,
+				Type:                "receipt",
+				ToLocationID:        &locationID,
+				Quantity:            rec.Quantity,
+				PurchaseOrderLineID: &lineID,
+				Reference:           fmt.Sprintf("PO-%d", po.ID),
+				PerformedBy:         performedBy,
+			}); err != nil {
+				return err
+			}
+		}
+
+		po.Status = "received"
+		for _, line := range po.Lines {
+			if line.QuantityReceived < line.QuantityOrdered {
+				po.Status = "partially_received"
+				break
+			}
+		}
+		return tx.Model(&models.PurchaseOrder{}).Where("id = ?", id).Update("status", po.Status).Error
+	})
+	if err != nil {
+		log.Println("Error receiving purchase order:", err)
+	}
+	return po, err
+}
+
+// ConsumptionStat is the input of the reorder computation for one item at one location
+type ConsumptionStat struct {
+	ItemID          int
+	LocationID      int
+	OnHand          int
+	Issued          int
+	OnOrder         int
+	ReorderLevel    int
+	ReorderQuantity int
+	LeadTimeDays    int
+}
+
+// GetConsumptionStats returns stock on hand, units issued since the given time and units
+// still outstanding on open purchase orders for every active item/location pair
+func GetConsumptionStats(since time.Time) ([]ConsumptionStat, error) {
+	var stats []ConsumptionStat
+	err := config.GormDB.Raw(`
+		SELECT s.item_id, s.location_id, s.quantity AS on_hand,
+			COALESCE((SELECT SUM(m.quantity) FROM inventory_movements m
+				WHERE m.item_id = s.item_id AND m.from_location_id = s.location_id
+				AND m.type = 'issue' AND m.created_at >= ?), 0) AS issued,
+			COALESCE((SELECT SUM(l.quantity_ordered - l.quantity_received) FROM purchase_order_lines l
+				JOIN purchase_orders po ON po.id = l.purchase_order_id
+				WHERE l.item_id = s.item_id AND po.location_id = s.location_id
+				AND po.status IN ('draft', 'ordered', 'partially_received')), 0) AS on_order,
+			i.reorder_level, i.reorder_quantity, i.lead_time_days
+		FROM stock_levels s
+		JOIN inventory_items i ON i.id = s.item_id
+		WHERE i.active`, since).Scan(&stats).Error
+	if err != nil {
+		log.Println("Error computing consumption stats:", err)
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ReplaceReorderSuggestions swaps the open suggestions for a freshly computed set
+func ReplaceReorderSuggestions(suggestions []models.ReorderSuggestion) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ?", "open").Delete(&models.ReorderSuggestion{}).Error; err != nil {
+			return err
+		}
+		if len(suggestions) == 0 {
+			return nil
+		}
+		return tx.Create(&suggestions).Error
+	})
+	if err != nil {
+		log.Println("Error saving reorder suggestions:", err)
+	}
+	return err
+}
+
+// GetReorderSuggestions lists suggestions by status (default open)
+func GetReorderSuggestions(status string) ([]models.ReorderSuggestion, error) {
+	var list []models.ReorderSuggestion
+	if status == "" {
+		status = "open"
+	}
+	if err := config.GormDB.Where("status = ?", status).Order("location_id, item_id").Find(&list).Error; err != nil {
+		log.Println("Error fetching reorder suggestions:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// SetReorderSuggestionStatus marks an open suggestion as ordered or dismissed
+func SetReorderSuggestionStatus(id int, status string) error {
+	result := config.GormDB.Model(&models.ReorderSuggestion{}).
+		Where("id = ? AND status = ?", id, "open").
+		Update("status", status)
+	if result.Error != nil {
+		log.Println("Error updating reorder suggestion:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -1,20 +1,156 @@
 package repositories
 
 import (
+	"errors"
 	"log"
 	"time"
 
 	"github.com/samichen99/HAP-hospital-management-system/config"
 	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// CreateInvoice inserts a new invoice record and returns its ID
-func CreateInvoice(inv models.Invoice) (int, error) {
-	if err := config.GormDB.Create(&inv).Error; err != nil {
+var (
+	ErrInvoiceLineHasSource = errors.New("invoice line was generated by another record and cannot be removed here")
+	ErrCurrencyMismatch     = errors.New("amount is not in the invoice currency")
+	ErrTotalBelowPaid       = errors.New("invoice total cannot drop below the amount already paid")
+	ErrInvoiceHasPayments   = errors.New("invoice has payments or credit notes; void those first")
+	ErrInvoiceIssued        = errors.New("issued invoices cannot be changed")
+	ErrInvoiceNotDraft      = errors.New("only draft invoices can be issued")
+	ErrInvoiceEmpty         = errors.New("invoice has no lines")
+)
+
+// priceInvoiceLine fills a line from the service catalogue when it references a service item
+// and computes its discount, tax and total. Catalogue prices must be in the invoice currency.
+func priceInvoiceLine(db *gorm.DB, line *models.InvoiceLine, currency string) error {
+	if line.ServiceItemID != nil {
+		var item models.ServiceItem
+		if err := db.First(&item, *line.ServiceItemID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownServiceItem
+			}
+			return err
+		}
+		if !item.Active {
+			return ErrServiceItemInactive
+		}
+		if item.Currency != currency {
+			return ErrCurrencyMismatch
+		}
+		line.Code = item.Code
+		if line.Description == "" {
+			line.Description = item.Name
+		}
+		line.UnitPrice = item.UnitPrice
+		line.TaxRate = item.TaxRate
+	}
+	if line.Quantity == 0 {
+		line.Quantity = 1
+	}
+	t, err := utils.ComputeLine(line.Quantity, line.UnitPrice, line.Discount, line.TaxRate)
+	if err != nil {
+		return err
+	}
+	line.TaxAmount = t.Tax
+	line.Amount = t.Total
+	return nil
+}
+
+// lineTotals is the breakdown of an already priced line
+func lineTotals(l models.InvoiceLine) utils.ChargeTotals {
+	return utils.ChargeTotals{
+		Subtotal: l.UnitPrice.Times(l.Quantity),
+		Discount: l.Discount,
+		Tax:      l.TaxAmount,
+		Total:    l.Amount,
+	}
+}
+
+// recalculateInvoiceTotals sums the lines of a locked invoice into its total columns
+// (amount = subtotal - discount_total + tax_total) and refreshes its balance. The total may
+// not drop below what has already been paid.
+func recalculateInvoiceTotals(db *gorm.DB, invoiceID int) error {
+	var lines []models.InvoiceLine
+	if err := db.Where("invoice_id = ?", invoiceID).Find(&lines).Error; err != nil {
+		return err
+	}
+	var totals utils.ChargeTotals
+	for _, l := range lines {
+		totals = totals.Add(lineTotals(l))
+	}
+	var inv models.Invoice
+	if err := db.First(&inv, invoiceID).Error; err != nil {
+		return err
+	}
+	st, err := invoiceSettlement(db, invoiceID)
+	if err != nil {
+		return err
+	}
+	if totals.Total < st.netPaid()+st.Credited {
+		return ErrTotalBelowPaid
+	}
+	inv.Subtotal = totals.Subtotal
+	inv.DiscountTotal = totals.Discount
+	inv.TaxTotal = totals.Tax
+	inv.Amount = totals.Total
+	if err := db.Model(&models.Invoice{}).Where("id = ?", invoiceID).Updates(map[string]interface{}{
+		"subtotal":       inv.Subtotal,
+		"discount_total": inv.DiscountTotal,
+		"tax_total":      inv.TaxTotal,
+		"amount":         inv.Amount,
+	}).Error; err != nil {
+		return err
+	}
+	return refreshInvoiceBalance(db, &inv)
+}
+
+// CreateInvoice inserts a new draft invoice with its lines and returns it with the computed
+// totals, issued straight away when issue is set. Invoices for minors are addressed to their
+// guarantor unless one is given.
+func CreateInvoice(inv models.Invoice, lines []models.InvoiceLine, issue bool) (models.Invoice, error) {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := resolveGuarantor(tx, &inv); err != nil {
+			return err
+		}
+		var totals utils.ChargeTotals
+		for i := range lines {
+			if err := priceInvoiceLine(tx, &lines[i], inv.Currency); err != nil {
+				return err
+			}
+			totals = totals.Add(lineTotals(lines[i]))
+		}
+		inv.Subtotal = totals.Subtotal
+		inv.DiscountTotal = totals.Discount
+		inv.TaxTotal = totals.Tax
+		inv.Amount = totals.Total
+		inv.AmountPaid = 0
+		inv.BalanceDue = totals.Total
+		inv.PaidAt = nil
+		inv.Status = "draft"
+		inv.Number = nil
+		inv.FiscalYear = 0
+		if err := tx.Create(&inv).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].InvoiceID = inv.ID
+		}
+		if len(lines) > 0 {
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+		if issue {
+			return issueInvoice(tx, &inv, time.Now())
+		}
+		return nil
+	})
+	if err != nil {
 		log.Println("Error creating invoice:", err)
-		return 0, err
 	}
-	return inv.ID, nil
+	return inv, err
 }
 
 // GetInvoiceByID retrieves a single invoice by ID
@@ -37,22 +173,158 @@ func GetAllInvoices() ([]models.Invoice, error) {
 	return invoices, nil
 }
 
-// UpdateInvoice updates an existing invoice
+// UpdateInvoice replaces the editable fields of a draft invoice: patient, appointment,
+// guarantor, location, due date and notes. Totals and status stay as derived from the lines
+// and the currency as chosen at creation; issued invoices cannot be changed.
 func UpdateInvoice(inv models.Invoice) error {
-	if err := config.GormDB.Save(&inv).Error; err != nil {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var existing models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&existing, inv.ID).Error; err != nil {
+			return err
+		}
+		if existing.Status != "draft" {
+			return ErrInvoiceIssued
+		}
+		if err := resolveGuarantor(tx, &inv); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Invoice{}).Where("id = ?", inv.ID).
+			Select("patient_id", "appointment_id", "guarantor_id", "location", "due_date", "notes").
+			Updates(&inv).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&inv, inv.ID).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
 		log.Println("Error updating invoice:", err)
-		return err
 	}
-	return nil
+	return err
+}
+
+// VoidInvoice voids an invoice that has no payments or credit notes left. The invoice and
+// its lines are kept for the record; a voided draft keeps a NULL number.
+func VoidInvoice(id int, reason string, userID int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var inv models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, id).Error; err != nil {
+			return err
+		}
+		if inv.Status == "void" {
+			return ErrAlreadyVoided
+		}
+		st, err := invoiceSettlement(tx, id)
+		if err != nil {
+			return err
+		}
+		if st.Paid > 0 || st.Credited > 0 {
+			return ErrInvoiceHasPayments
+		}
+		return tx.Model(&models.Invoice{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":      "void",
+			"voided_at":   time.Now(),
+			"void_reason": reason,
+			"voided_by":   userID,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error voiding invoice:", err)
+	}
+	return err
 }
 
-// DeleteInvoice deletes an invoice by ID
-func DeleteInvoice(id int) error {
-	if err := config.GormDB.Delete(&models.Invoice{}, id).Error; err != nil {
-		log.Println("Error deleting invoice:", err)
+// issueInvoice gives a locked draft invoice the next number of its location's series for the
+// current fiscal year and sets issued_at, until then the creation time; after that the invoice
+// can no longer be changed. The series row stays locked until tx commits, so concurrent issues
+// are numbered one after the other and a failed issue does not use up a number.
+func issueInvoice(tx *gorm.DB, inv *models.Invoice, now time.Time) error {
+	if inv.Status != "draft" {
+		return ErrInvoiceNotDraft
+	}
+	var lines int64
+	if err := tx.Model(&models.InvoiceLine{}).Where("invoice_id = ?", inv.ID).Count(&lines).Error; err != nil {
 		return err
 	}
-	return nil
+	if lines == 0 {
+		return ErrInvoiceEmpty
+	}
+	fiscalYear := utils.FiscalYear(now, utils.FiscalYearStartMonth())
+	n, err := nextSequenceValue(tx, utils.InvoiceNumberSeries(inv.Location, fiscalYear))
+	if err != nil {
+		return err
+	}
+	number := utils.FormatInvoiceNumber(utils.InvoiceNumberPrefix(), inv.Location, fiscalYear, n)
+	inv.Number = &number
+	inv.FiscalYear = fiscalYear
+	inv.IssuedAt = now
+	inv.Status = utils.DeriveInvoiceStatus("unpaid", inv.Amount, 0, 0, inv.DueDate, now)
+	return tx.Model(&models.Invoice{}).Where("id = ?", inv.ID).Updates(map[string]interface{}{
+		"number":      number,
+		"fiscal_year": fiscalYear,
+		"issued_at":   now,
+		"status":      inv.Status,
+	}).Error
+}
+
+// IssueInvoice numbers a draft invoice and makes it payable
+func IssueInvoice(id int) (models.Invoice, error) {
+	var inv models.Invoice
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, id).Error; err != nil {
+			return err
+		}
+		return issueInvoice(tx, &inv, time.Now())
+	})
+	if err != nil {
+		log.Println("Error issuing invoice:", err)
+	}
+	return inv, err
+}
+
+// MigrateInvoiceNumbers numbers the issued invoices that predate invoice numbering, oldest
+// first, so that every invoice a patient has received carries a number. It runs once; void
+// invoices are skipped because a voided draft was never issued and must not take a number.
+func MigrateInvoiceNumbers() error {
+	err := runOnce("invoice-numbers", func(tx *gorm.DB) error {
+		var batch []models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("number IS NULL AND status NOT IN ?", []string{"draft", "void"}).
+			Order("issued_at, id").
+			Find(&batch).Error; err != nil {
+			return err
+		}
+		start := utils.FiscalYearStartMonth()
+		for _, inv := range batch {
+			fiscalYear := utils.FiscalYear(inv.IssuedAt, start)
+			n, err := nextSequenceValue(tx, utils.InvoiceNumberSeries(inv.Location, fiscalYear))
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Invoice{}).Where("id = ?", inv.ID).Updates(map[string]interface{}{
+				"number":      utils.FormatInvoiceNumber(utils.InvoiceNumberPrefix(), inv.Location, fiscalYear, n),
+				"fiscal_year": fiscalYear,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error migrating invoice numbers:", err)
+	}
+	return err
+}
+
+// GetInvoiceByNumber retrieves an issued invoice by its legal number
+func GetInvoiceByNumber(number string) (models.Invoice, error) {
+	var inv models.Invoice
+	if err := config.GormDB.Where("number = ?", number).First(&inv).Error; err != nil {
+		log.Println("Error fetching invoice by number:", err)
+		return inv, err
+	}
+	return inv, nil
 }
 
 // GetInvoicesByPatientID retrieves all invoices for a given patient
@@ -75,18 +347,36 @@ func GetInvoicesByStatus(status string) ([]models.Invoice, error) {
 	return invoices, nil
 }
 
-// MarkInvoicePaid marks an invoice as paid and updates the PaidAt field
-func MarkInvoicePaid(id int, paidAt time.Time) error {
-	if err := config.GormDB.Model(&models.Invoice{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"status":  "paid",
-			"paid_at": paidAt,
-		}).Error; err != nil {
+// MarkInvoicePaid settles an invoice by recording a payment for its outstanding balance
+func MarkInvoicePaid(id int, paidAt time.Time, method string, receivedBy int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		inv, err := lockPayableInvoice(tx, id)
+		if err != nil {
+			return err
+		}
+		st, err := invoiceSettlement(tx, id)
+		if err != nil {
+			return err
+		}
+		if due := st.balance(inv.Amount); due > 0 {
+			if err := tx.Create(&models.Payment{
+				InvoiceId:     id,
+				Amount:        due,
+				Currency:      inv.Currency,
+				PaymentDate:   paidAt,
+				PaymentMethod: method,
+				Notes:         "Recorded when the invoice was marked paid",
+				ReceivedBy:    &receivedBy,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
 		log.Println("Error marking invoice as paid:", err)
-		return err
 	}
-	return nil
+	return err
 }
 
 // FilterInvoicesByDateRange returns invoices within a date range
@@ -98,3 +388,108 @@ func FilterInvoicesByDateRange(from, to string) ([]models.Invoice, error) {
 	}
 	return invoices, nil
 }
+
+// GetOpenInvoicesByPatientID retrieves a patient's invoices that are not settled yet
+func GetOpenInvoicesByPatientID(patientID int) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	if err := config.GormDB.Where("patient_id = ? AND status IN ?", patientID, []string{"unpaid", "partially_paid", "overdue", "collections"}).
+		Order("due_date ASC").
+		Find(&invoices).Error; err != nil {
+		log.Println("Error fetching open invoices by patient ID:", err)
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// GetInvoiceLines retrieves the line items of an invoice
+func GetInvoiceLines(invoiceID int) ([]models.InvoiceLine, error) {
+	var lines []models.InvoiceLine
+	if err := config.GormDB.Where("invoice_id = ?", invoiceID).Order("id").Find(&lines).Error; err != nil {
+		log.Println("Error fetching invoice lines:", err)
+		return nil, err
+	}
+	return lines, nil
+}
+
+// lockOpenInvoice locks a draft invoice, the only kind lines may be added to or removed from
+func lockOpenInvoice(tx *gorm.DB, invoiceID int) (models.Invoice, error) {
+	var inv models.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, invoiceID).Error; err != nil {
+		return inv, err
+	}
+	if !invoiceAcceptsCharges(inv.Status) {
+		return inv, ErrInvoiceNotOpen
+	}
+	return inv, nil
+}
+
+// invoiceAcceptsCharges reports whether lines may still be added to or removed from an invoice
+func invoiceAcceptsCharges(status string) bool {
+	return status == "draft"
+}
+
+// AddInvoiceLine prices a line, adds it to a draft invoice and updates the invoice totals
+func AddInvoiceLine(invoiceID int, line *models.InvoiceLine) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		inv, err := lockOpenInvoice(tx, invoiceID)
+		if err != nil {
+			return err
+		}
+		if err := priceInvoiceLine(tx, line, inv.Currency); err != nil {
+			return err
+		}
+		line.InvoiceID = invoiceID
+		if err := tx.Create(line).Error; err != nil {
+			return err
+		}
+		return recalculateInvoiceTotals(tx, invoiceID)
+	})
+	if err != nil {
+		log.Println("Error adding invoice line:", err)
+	}
+	return err
+}
+
+// DeleteInvoiceLine removes a manually added line from a draft invoice and updates the totals
+func DeleteInvoiceLine(invoiceID, lineID int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockOpenInvoice(tx, invoiceID); err != nil {
+			return err
+		}
+		var line models.InvoiceLine
+		if err := tx.Where("id = ? AND invoice_id = ?", lineID, invoiceID).First(&line).Error; err != nil {
+			return err
+		}
+		if line.SourceType != "" {
+			return ErrInvoiceLineHasSource
+		}
+		if err := tx.Delete(&line).Error; err != nil {
+			return err
+		}
+		return recalculateInvoiceTotals(tx, invoiceID)
+	})
+	if err != nil {
+		log.Println("Error deleting invoice line:", err)
+	}
+	return err
+}
+
+// MigrateSingleAmountInvoices gives every invoice created before line items existed one line
+// carrying its amount, so that totals can be derived from lines from now on. It runs once and
+// leaves drafts alone: a draft whose lines were all deleted is simply empty.
+func MigrateSingleAmountInvoices() error {
+	err := runOnce("single-amount-invoices", func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO invoice_lines (invoice_id, description, quantity, unit_price, discount, tax_rate, tax_amount, amount, created_at)
+			SELECT i.id, COALESCE(NULLIF(i.notes, ''), 'Services'), 1, i.amount, 0, 0, 0, i.amount, i.issued_at
+			FROM invoices i
+			WHERE i.status <> 'draft' AND NOT EXISTS (SELECT 1 FROM invoice_lines l WHERE l.invoice_id = i.id)`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE invoices SET subtotal = amount
+			WHERE subtotal = 0 AND discount_total = 0 AND tax_total = 0 AND amount <> 0`).Error
+	})
+	if err != nil {
+		log.Println("Error migrating single amount invoices:", err)
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// CreateLabResults inserts a batch of lab results in a single transaction
+func CreateLabResults(results []models.LabResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	if err := config.GormDB.Create(&results).Error; err != nil {
+		log.Println("Error creating lab results:", err)
+		return err
+	}
+	return nil
+}
+
+// GetLabResultsByPatientID retrieves lab results for a patient, newest first
+func GetLabResultsByPatientID(patientID int) ([]models.LabResult, error) {
+	var results []models.LabResult
+	if err := config.GormDB.Where("patient_id = ?", patientID).
+		Order("observed_at DESC").
+		Find(&results).Error; err != nil {
+		log.Println("Error fetching lab results by patient ID:", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetLabResultsByPatientBetween retrieves a patient's lab results observed in [from, to]
+func GetLabResultsByPatientBetween(patientID int, from, to time.Time) ([]models.LabResult, error) {
+	var results []models.LabResult
+	if err := config.GormDB.Where("patient_id = ? AND observed_at BETWEEN ? AND ?", patientID, from, to).
+		Order("observed_at").
+		Find(&results).Error; err != nil {
+		log.Println("Error fetching lab results for period:", err)
+		return nil, err
+	}
+	return results, nil
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/samichen99/HAP-hospital-management-system/config"
 	"github.com/samichen99/HAP-hospital-management-system/models"
 )
@@ -38,12 +40,49 @@ func GetMedicalRecordsByDoctorID(doctorID int) ([]models.MedicalRecord, error) {
 	return records, err
 }
 
-// UpdateMedicalRecord updates an existing medical record
+// UpdateMedicalRecord updates an existing medical record; the portal release flag is changed
+// only through ReleaseMedicalRecord
 func UpdateMedicalRecord(record models.MedicalRecord) error {
-	return config.GormDB.Save(&record).Error
+	return config.GormDB.Omit("released_to_patient", "released_at").Save(&record).Error
 }
 
 // DeleteMedicalRecord deletes a medical record by ID
 func DeleteMedicalRecord(id int) error {
 	return config.GormDB.Delete(&models.MedicalRecord{}, id).Error
 }
+
+// GetMedicalRecordsByPatientBetween retrieves a patient's medical records created in [from, to]
+func GetMedicalRecordsByPatientBetween(patientID int, from, to time.Time) ([]models.MedicalRecord, error) {
+	var records []models.MedicalRecord
+	err := config.GormDB.Where("patient_id = ? AND creation_date BETWEEN ? AND ?", patientID, from, to).
+		Order("creation_date").
+		Find(&records).Error
+	return records, err
+}
+
+// ReleaseMedicalRecord shows (or hides again) a record in the patient portal
+func ReleaseMedicalRecord(id int, released bool) (models.MedicalRecord, error) {
+	var record models.MedicalRecord
+	if err := config.GormDB.First(&record, id).Error; err != nil {
+		return record, err
+	}
+	record.ReleasedToPatient = released
+	record.ReleasedAt = nil
+	if released {
+		now := time.Now()
+		record.ReleasedAt = &now
+	}
+	err := config.GormDB.Model(&record).
+		Select("released_to_patient", "released_at").
+		Updates(&record).Error
+	return record, err
+}
+
+// GetReleasedMedicalRecordsByPatientID retrieves the records a patient may see in the portal
+func GetReleasedMedicalRecordsByPatientID(patientID int) ([]models.MedicalRecord, error) {
+	var records []models.MedicalRecord
+	err := config.GormDB.Where("patient_id = ? AND released_to_patient", patientID).
+		Order("creation_date DESC").
+		Find(&records).Error
+	return records, err
+}
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// runOnce runs a data migration in a transaction unless it has already been applied. The
+// marker is written first, so concurrently starting servers wait for each other and only
+// one of them runs fn.
+func runOnce(name string, fn func(tx *gorm.DB) error) error {
+	return config.GormDB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.MigrationMarker{Name: name, AppliedAt: time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return fn(tx)
+	})
+}
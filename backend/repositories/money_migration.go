@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+// moneyColumns lists the amount columns that used to be float and now hold minor units.
+// withCurrency tables also gained a currency column.
+var moneyColumns = []struct {
+	table        string
+	columns      []string
+	withCurrency bool
+}{
+	{"invoices", []string{"subtotal", "discount_total", "tax_total", "amount"}, true},
+	{"invoice_lines", []string{"unit_price", "discount", "tax_amount", "amount"}, false},
+	{"service_items", []string{"unit_price"}, true},
+	{"payments", []string{"amount"}, true},
+	{"drug_stock_items", []string{"unit_price"}, false},
+	{"purchase_order_lines", []string{"unit_cost"}, false},
+}
+
+// MigrateMoneyColumns converts float amount columns to bigint cents, rounding half away from
+// zero, and gives existing rows the default currency. It must run before AutoMigrate, which
+// would otherwise cast the floats without scaling them. Columns already converted are skipped.
+func MigrateMoneyColumns() error {
+	currency := utils.DefaultCurrency()
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		for _, t := range moneyColumns {
+			if !tx.Migrator().HasTable(t.table) {
+				continue
+			}
+			for _, column := range t.columns {
+				var dataType string
+				if err := tx.Raw(`SELECT data_type FROM information_schema.columns
+					WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, t.table, column).
+					Scan(&dataType).Error; err != nil {
+					return err
+				}
+				if dataType != "double precision" && dataType != "real" && dataType != "numeric" {
+					continue
+				}
+				if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s TYPE bigint USING round(%s::numeric * 100)::bigint`,
+					t.table, column, column)).Error; err != nil {
+					return err
+				}
+			}
+			if t.withCurrency && !tx.Migrator().HasColumn(t.table, "currency") {
+				// currency is a validated three letter code, safe to inline in DDL
+				if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN currency varchar(3) NOT NULL DEFAULT '%s'`, t.table, currency)).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN currency DROP DEFAULT`, t.table)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error migrating money columns:", err)
+	}
+	return err
+}
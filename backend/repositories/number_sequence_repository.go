@@ -0,0 +1,13 @@
+package repositories
+
+import "gorm.io/gorm"
+
+// nextSequenceValue takes the next number of a named series. The sequence row stays locked
+// until tx ends, so numbers are handed out in order and a rolled back transaction leaves no gap.
+func nextSequenceValue(tx *gorm.DB, name string) (int64, error) {
+	var value int64
+	err := tx.Raw(`INSERT INTO number_sequences (name, value) VALUES (?, 1)
+		ON CONFLICT (name) DO UPDATE SET value = number_sequences.value + 1
+		RETURNING value`, name).Scan(&value).Error
+	return value, err
+}
@@ -1,10 +1,13 @@
 package repositories
 
 import (
+	"errors"
 	"log"
 
 	"github.com/samichen99/HAP-hospital-management-system/config"
 	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CreatePatient repo :
@@ -29,7 +32,7 @@ func GetPatientByID(id int) (models.Patient, error) {
 
 // UpdatePatient repo :
 func UpdatePatient(patient *models.Patient) error {
-	if err := config.GormDB.Save(&patient).Error; err != nil {
+	if err := config.GormDB.Omit("merged_into_id").Save(&patient).Error; err != nil {
 		log.Println("Error updating patient:", err)
 		return err
 	}
@@ -68,3 +71,85 @@ func SearchPatientsByName(name string) ([]models.Patient, error) {
 	}
 	return patients, nil
 }
+
+// GetPatientByMRN retrieves the patient with the given medical record number. MRNs are
+// unique among patients that have one.
+func GetPatientByMRN(mrn string) (models.Patient, error) {
+	var patient models.Patient
+	if err := config.GormDB.Where("mrn = ?", mrn).First(&patient).Error; err != nil {
+		log.Println("Error retrieving patient by MRN:", err)
+		return patient, err
+	}
+	return patient, nil
+}
+
+var ErrPatientNotTemporary = errors.New("only temporary patients can be merged")
+
+// patientOwnedTables lists every table whose rows follow a patient when a temporary
+// identity is merged into the real record
+var patientOwnedTables = []string{
+	"appointments",
+	"medical_records",
+	"files",
+	"invoices",
+	"lab_results",
+	"immunizations",
+	"problems",
+	"admissions",
+	"vital_signs",
+	"discharge_summaries",
+	"dispensings",
+	"referrals",
+	"ed_encounters",
+	"consents",
+	"signed_documents",
+	"related_persons",
+	"patient_relationships",
+	"patient_credits",
+	"credit_notes",
+	"insurance_policies",
+	"claims",
+	"invoice_communications",
+	"payment_intents",
+}
+
+// MergePatient moves all clinical and billing rows of a temporary patient to the target
+// patient and marks the temporary record as merged. Both rows are locked for the duration.
+func MergePatient(sourceID, targetID int) (models.Patient, error) {
+	var target models.Patient
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var source models.Patient
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&source, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&target, targetID).Error; err != nil {
+			return err
+		}
+		if !source.IsTemporary || source.MergedIntoID != nil || target.MergedIntoID != nil || sourceID == targetID {
+			return ErrPatientNotTemporary
+		}
+
+		for _, table := range patientOwnedTables {
+			if err := tx.Table(table).Where("patient_id = ?", sourceID).Update("patient_id", targetID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.PatientRelationship{}).Where("related_patient_id = ?", sourceID).
+			Update("related_patient_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RelatedPerson{}).Where("linked_patient_id = ?", sourceID).
+			Update("linked_patient_id", targetID).Error; err != nil {
+			return err
+		}
+		// reminder markers are only used for de-duplication; the target keeps its own
+		if err := tx.Where("patient_id = ?", sourceID).Delete(&models.ImmunizationReminder{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Patient{}).Where("id = ?", sourceID).Update("merged_into_id", targetID).Error
+	})
+	if err != nil {
+		log.Println("Error merging patient:", err)
+	}
+	return target, err
+}
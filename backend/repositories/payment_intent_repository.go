@@ -0,0 +1,286 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrIntentStatus = errors.New("payment intent is not in a state that allows this")
+
+// CheckIntentAmount validates an online payment of an invoice before a checkout is opened.
+// Amount 0 pays the whole balance; it returns the invoice and the amount to charge.
+func CheckIntentAmount(invoiceID int, amount models.Money) (models.Invoice, models.Money, error) {
+	inv, err := GetInvoiceByID(invoiceID)
+	if err != nil {
+		return inv, 0, err
+	}
+	switch inv.Status {
+	case "draft", "void", "cancelled":
+		return inv, 0, ErrInvoiceNotPayable
+	}
+	if amount == 0 {
+		amount = inv.BalanceDue
+	}
+	if inv.BalanceDue <= 0 || amount > inv.BalanceDue {
+		return inv, 0, ErrOverpayment
+	}
+	return inv, amount, nil
+}
+
+// CreatePaymentIntent stores a checkout opened at the gateway
+func CreatePaymentIntent(intent *models.PaymentIntent) error {
+	if err := config.GormDB.Create(intent).Error; err != nil {
+		log.Println("Error creating payment intent:", err)
+		return err
+	}
+	return nil
+}
+
+// GetPaymentIntentByID retrieves a payment intent by ID
+func GetPaymentIntentByID(id int) (models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	if err := config.GormDB.First(&intent, id).Error; err != nil {
+		log.Println("Error fetching payment intent:", err)
+		return intent, err
+	}
+	return intent, nil
+}
+
+// GetPaymentIntentsByInvoiceID lists the online payment attempts of an invoice, newest first
+func GetPaymentIntentsByInvoiceID(invoiceID int) ([]models.PaymentIntent, error) {
+	var list []models.PaymentIntent
+	if err := config.GormDB.Where("invoice_id = ?", invoiceID).Order("created_at DESC, id DESC").Find(&list).Error; err != nil {
+		log.Println("Error fetching payment intents:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetGatewayEvents lists the webhook events received for a payment intent, oldest first
+func GetGatewayEvents(intentID int) ([]models.GatewayEvent, error) {
+	var list []models.GatewayEvent
+	if err := config.GormDB.Where("intent_id = ?", intentID).Order("created_at, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching gateway events:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// settleIntent records the payment of a succeeded intent. Money taken above the balance
+// becomes patient credit. When the invoice can no longer take payments (e.g. it was voided
+// meanwhile) no payment is recorded and the reason is kept on the intent for follow-up.
+func settleIntent(tx *gorm.DB, intent *models.PaymentIntent, amount models.Money, now time.Time) (string, error) {
+	if amount <= 0 {
+		amount = intent.Amount
+	}
+	intent.Status = "succeeded"
+	intent.FailureReason = ""
+	payment := models.Payment{
+		InvoiceId:     intent.InvoiceID,
+		Amount:        amount,
+		Currency:      intent.Currency,
+		PaymentDate:   now,
+		PaymentMethod: "card",
+		Notes:         "Online payment " + intent.ExternalID,
+		IntentID:      &intent.ID,
+	}
+	if err := tx.SavePoint("settle_intent").Error; err != nil {
+		return "", err
+	}
+	if _, err := applyPayment(tx, &payment, true); err != nil {
+		if !errors.Is(err, ErrInvoiceNotPayable) && !errors.Is(err, ErrOverpayment) && !errors.Is(err, ErrCurrencyMismatch) {
+			return "", err
+		}
+		if err := tx.RollbackTo("settle_intent").Error; err != nil {
+			return "", err
+		}
+		intent.PaymentID = nil
+		intent.FailureReason = "charge received but not applied: " + err.Error()
+		return intent.FailureReason, nil
+	}
+	intent.PaymentID = &payment.ID
+	return "payment recorded", nil
+}
+
+// BeginCapture moves an authorized intent of gateway to capturing before the gateway is asked
+// to capture it, so that concurrent captures of the same intent are serialised here. An intent
+// already capturing is returned as is: its capture was interrupted and may be retried.
+func BeginCapture(id int, gateway string) (models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&intent, id).Error; err != nil {
+			return err
+		}
+		if intent.Gateway != gateway {
+			return ErrIntentStatus
+		}
+		switch intent.Status {
+		case "capturing":
+			return nil
+		case "authorized":
+			intent.Status = "capturing"
+			return tx.Model(&models.PaymentIntent{}).Where("id = ?", id).Update("status", intent.Status).Error
+		default:
+			return ErrIntentStatus
+		}
+	})
+	if err != nil {
+		log.Println("Error starting capture of payment intent:", err)
+	}
+	return intent, err
+}
+
+// CaptureIntent records the payment of a capturing intent the gateway has just captured.
+// Calling it again for an intent whose payment is already recorded does nothing.
+func CaptureIntent(id int, amount models.Money) (models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&intent, id).Error; err != nil {
+			return err
+		}
+		if intent.PaymentID != nil {
+			return nil
+		}
+		if intent.Status != "capturing" {
+			return ErrIntentStatus
+		}
+		if _, err := settleIntent(tx, &intent, amount, time.Now()); err != nil {
+			return err
+		}
+		return tx.Save(&intent).Error
+	})
+	if err != nil {
+		log.Println("Error capturing payment intent:", err)
+	}
+	return intent, err
+}
+
+// ApplyGatewayEvent applies a verified webhook event once. Redelivered events are recognised
+// by their event ID and returned with duplicate set. Events for unknown intents are stored
+// and ignored so that the gateway stops retrying them.
+func ApplyGatewayEvent(gateway string, ev utils.WebhookEvent, payload []byte) (models.GatewayEvent, bool, error) {
+	record := models.GatewayEvent{
+		Gateway:    gateway,
+		EventID:    ev.ID,
+		Type:       ev.Type,
+		ExternalID: ev.IntentID,
+		Payload:    string(payload),
+	}
+	duplicate := false
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		// lock the intent first so that concurrent deliveries of its events run one at a time
+		var intent models.PaymentIntent
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("gateway = ? AND external_id = ?", gateway, ev.IntentID).
+			First(&intent).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		found := err == nil
+
+		var existing models.GatewayEvent
+		err = tx.Where("gateway = ? AND event_id = ?", gateway, ev.ID).First(&existing).Error
+		if err == nil {
+			record = existing
+			duplicate = true
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if !found {
+			record.Result = "ignored: unknown payment intent"
+			return tx.Create(&record).Error
+		}
+		record.IntentID = &intent.ID
+		now := time.Now()
+		switch ev.Type {
+		case utils.EventPaymentAuthorized:
+			if intent.Status != "pending" {
+				record.Result = "ignored: intent is " + intent.Status
+				break
+			}
+			intent.Status = "authorized"
+			record.Result = "authorized"
+		case utils.EventPaymentSucceeded:
+			if intent.PaymentID != nil || intent.Status == "disputed" || intent.Status == "dispute_lost" {
+				record.Result = "ignored: payment already recorded"
+				break
+			}
+			if record.Result, err = settleIntent(tx, &intent, ev.Amount, now); err != nil {
+				return err
+			}
+		case utils.EventPaymentFailed:
+			if intent.Status != "pending" && intent.Status != "authorized" && intent.Status != "capturing" {
+				record.Result = "ignored: intent is " + intent.Status
+				break
+			}
+			intent.Status = "failed"
+			intent.FailureReason = ev.Reason
+			record.Result = "failed"
+		case utils.EventDisputeCreated:
+			if intent.Status != "succeeded" {
+				record.Result = "ignored: intent is " + intent.Status
+				break
+			}
+			intent.Status = "disputed"
+			intent.FailureReason = ev.Reason
+			record.Result = "disputed"
+			if intent.PaymentID != nil {
+				// the gateway holds the money back, so the invoice is open again until the dispute is won
+				if err := tx.SavePoint("dispute").Error; err != nil {
+					return err
+				}
+				if err := voidPayment(tx, *intent.PaymentID, "Card payment disputed: "+ev.Reason, 0); err != nil {
+					if err := tx.RollbackTo("dispute").Error; err != nil {
+						return err
+					}
+					record.Result = "disputed; payment not voided: " + err.Error()
+				} else {
+					intent.PaymentID = nil
+					record.Result = "disputed; payment voided"
+				}
+			}
+		case utils.EventDisputeWon:
+			if intent.Status != "disputed" {
+				record.Result = "ignored: intent is " + intent.Status
+				break
+			}
+			if intent.PaymentID != nil {
+				intent.Status = "succeeded"
+				intent.FailureReason = ""
+				record.Result = "dispute won"
+				break
+			}
+			if record.Result, err = settleIntent(tx, &intent, ev.Amount, now); err != nil {
+				return err
+			}
+			record.Result = "dispute won; " + record.Result
+		case utils.EventDisputeLost:
+			if intent.Status != "disputed" {
+				record.Result = "ignored: intent is " + intent.Status
+				break
+			}
+			intent.Status = "dispute_lost"
+			record.Result = "dispute lost"
+		default:
+			record.Result = "ignored: unsupported event type"
+		}
+		if err := tx.Save(&intent).Error; err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		log.Println("Error applying gateway event:", err)
+	}
+	return record, duplicate, err
+}
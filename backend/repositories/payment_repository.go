@@ -2,20 +2,167 @@ package repositories
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/samichen99/HAP-hospital-management-system/config"
 	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// CreatePayment inserts a new payment record
-func CreatePayment(payment models.Payment) error {
-	result := config.GormDB.Create(&payment)
-	if result.Error != nil {
-		return result.Error
+var (
+	ErrOverpayment        = errors.New("payment exceeds the invoice balance")
+	ErrInvoiceNotPayable  = errors.New("invoice cannot receive payments")
+	ErrInsufficientCredit = errors.New("patient credit balance is too low")
+	ErrCreditAlreadyUsed  = errors.New("credit from this payment has already been used")
+	ErrCreditPayment      = errors.New("payments from patient credit can only be voided, not changed or refunded")
+	ErrPaymentVoided      = errors.New("payment has been voided")
+	ErrPaymentRefunded    = errors.New("payment has refunds; void them first")
+	ErrAlreadyVoided      = errors.New("record has already been voided")
+	ErrRemittancePayment  = errors.New("insurance remittances can only be voided, not changed or refunded")
+	ErrOnlinePayment      = errors.New("online payments can only be voided or refunded, not changed")
+)
+
+// checkPaymentCurrency makes sure a payment is in its invoice's currency, defaulting to it
+func checkPaymentCurrency(inv models.Invoice, payment *models.Payment) error {
+	if payment.Currency == "" {
+		payment.Currency = inv.Currency
 	}
+	if strings.ToUpper(payment.Currency) != inv.Currency {
+		return ErrCurrencyMismatch
+	}
+	payment.Currency = inv.Currency
 	return nil
 }
 
+// lockPayableInvoice locks an invoice that payments can be applied to
+func lockPayableInvoice(tx *gorm.DB, invoiceID int) (models.Invoice, error) {
+	var inv models.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, invoiceID).Error; err != nil {
+		return inv, err
+	}
+	switch inv.Status {
+	case "draft", "void", "cancelled":
+		return inv, ErrInvoiceNotPayable
+	}
+	return inv, nil
+}
+
+// settlement is what has been paid, refunded and credited on an invoice. Voided payments,
+// refunds and credit notes do not count, nor do pending refunds.
+type settlement struct {
+	Paid       models.Money
+	Refunded   models.Money
+	Credited   models.Money
+	LastPaidAt *time.Time
+}
+
+// netPaid is what the patient has paid and kept paid
+func (s settlement) netPaid() models.Money {
+	return s.Paid - s.Refunded
+}
+
+// balance is what is still owed on an invoice of the given amount
+func (s settlement) balance(amount models.Money) models.Money {
+	return amount - s.Credited - s.netPaid()
+}
+
+func invoiceSettlement(db *gorm.DB, invoiceID int) (settlement, error) {
+	var s settlement
+	err := db.Raw(`SELECT
+		(SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = @id AND voided_at IS NULL)::bigint AS paid,
+		(SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE invoice_id = @id AND voided_at IS NULL AND status = 'confirmed')::bigint AS refunded,
+		(SELECT COALESCE(SUM(amount), 0) FROM credit_notes WHERE invoice_id = @id AND voided_at IS NULL)::bigint AS credited,
+		(SELECT MAX(payment_date) FROM payments WHERE invoice_id = @id AND voided_at IS NULL) AS last_paid_at`,
+		map[string]interface{}{"id": invoiceID}).Scan(&s).Error
+	return s, err
+}
+
+// refreshInvoiceBalance derives amount_paid (payments less refunds), amount_credited (credit
+// notes), balance_due, status and paid_at of a (locked) invoice from its payments, refunds and
+// credit notes
+func refreshInvoiceBalance(tx *gorm.DB, inv *models.Invoice) error {
+	s, err := invoiceSettlement(tx, inv.ID)
+	if err != nil {
+		return err
+	}
+	inv.AmountPaid = s.netPaid()
+	inv.AmountCredited = s.Credited
+	inv.BalanceDue = s.balance(inv.Amount)
+	inv.Status = utils.DeriveInvoiceStatus(inv.Status, inv.Amount, s.Credited, s.netPaid(), inv.DueDate, time.Now())
+	inv.PaidAt = nil
+	if inv.Status == "paid" {
+		inv.PaidAt = s.LastPaidAt
+	}
+	return tx.Model(&models.Invoice{}).Where("id = ?", inv.ID).Updates(map[string]interface{}{
+		"amount_paid":     inv.AmountPaid,
+		"amount_credited": inv.AmountCredited,
+		"balance_due":     inv.BalanceDue,
+		"status":          inv.Status,
+		"paid_at":         inv.PaidAt,
+	}).Error
+}
+
+// applyPayment is ApplyPayment inside an existing transaction
+func applyPayment(tx *gorm.DB, payment *models.Payment, keepCredit bool) (*models.PatientCredit, error) {
+	inv, err := lockPayableInvoice(tx, payment.InvoiceId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPaymentCurrency(inv, payment); err != nil {
+		return nil, err
+	}
+	st, err := invoiceSettlement(tx, inv.ID)
+	if err != nil {
+		return nil, err
+	}
+	balance := st.balance(inv.Amount)
+	if balance <= 0 || (payment.Amount > balance && !keepCredit) {
+		return nil, ErrOverpayment
+	}
+	var credit *models.PatientCredit
+	if payment.Amount > balance {
+		credit = &models.PatientCredit{
+			PatientID: inv.PatientID,
+			Currency:  inv.Currency,
+			Amount:    payment.Amount - balance,
+			Notes:     fmt.Sprintf("Overpayment of invoice #%d", inv.ID),
+		}
+		payment.Amount = balance
+	}
+	if err := tx.Create(payment).Error; err != nil {
+		return nil, err
+	}
+	if credit != nil {
+		credit.PaymentID = &payment.ID
+		if err := tx.Create(credit).Error; err != nil {
+			return nil, err
+		}
+	}
+	return credit, refreshInvoiceBalance(tx, &inv)
+}
+
+// ApplyPayment records a payment against an invoice and updates the invoice balance and status.
+// A payment larger than the balance is rejected with ErrOverpayment unless keepCredit is set,
+// in which case the balance is settled and the rest is returned as patient credit.
+func ApplyPayment(payment *models.Payment, keepCredit bool) (*models.PatientCredit, error) {
+	var credit *models.PatientCredit
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		credit, err = applyPayment(tx, payment, keepCredit)
+		return err
+	})
+	if err != nil {
+		log.Println("Error applying payment:", err)
+		return nil, err
+	}
+	return credit, nil
+}
+
 // GetPaymentByID fetches a single payment by ID
 func GetPaymentByID(id int) (*models.Payment, error) {
 	var payment models.Payment
@@ -46,26 +193,283 @@ func GetPaymentsByInvoiceID(invoiceID int) ([]models.Payment, error) {
 	return payments, nil
 }
 
-// UpdatePayment updates an existing payment record
-func UpdatePayment(payment models.Payment) error {
-	result := config.GormDB.Model(&models.Payment{}).Where("id = ?", payment.ID).Updates(payment)
+// GetPaymentsByPatientID retrieves the payments made against a patient's invoices
+func GetPaymentsByPatientID(patientID int) ([]models.Payment, error) {
+	var payments []models.Payment
+	result := config.GormDB.
+		Joins("JOIN invoices ON invoices.id = payments.invoice_id").
+		Where("invoices.patient_id = ?", patientID).
+		Order("payments.payment_date DESC").
+		Find(&payments)
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
+	}
+	return payments, nil
+}
+
+// UpdatePayment changes the amount, date, method or notes of a payment and recomputes its
+// invoice. The invoice and currency of a payment cannot change. Like voidPayment it locks the
+// payment before its invoice.
+func UpdatePayment(payment models.Payment) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var existing models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&existing, payment.ID).Error; err != nil {
+			return err
+		}
+		if existing.VoidedAt != nil {
+			return ErrPaymentVoided
+		}
+		if existing.ClaimID != nil {
+			return ErrRemittancePayment
+		}
+		if existing.IntentID != nil && (payment.Amount != 0 && payment.Amount != existing.Amount ||
+			payment.PaymentMethod != "" && payment.PaymentMethod != existing.PaymentMethod) {
+			return ErrOnlinePayment
+		}
+		if existing.PaymentMethod == "credit" || payment.PaymentMethod == "credit" {
+			if payment.PaymentMethod != existing.PaymentMethod && payment.PaymentMethod != "" ||
+				payment.Amount != 0 && payment.Amount != existing.Amount {
+				return ErrCreditPayment
+			}
+		}
+		var inv models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, existing.InvoiceId).Error; err != nil {
+			return err
+		}
+		refunded, err := refundedAmount(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		if payment.Amount != 0 && payment.Amount < refunded {
+			return ErrRefundExceedsPayment
+		}
+		if err := tx.Model(&models.Payment{}).Where("id = ?", payment.ID).
+			Omit("invoice_id", "currency", "claim_id", "intent_id", "received_by", "voided_at", "void_reason", "voided_by").
+			Updates(payment).Error; err != nil {
+			return err
+		}
+		st, err := invoiceSettlement(tx, inv.ID)
+		if err != nil {
+			return err
+		}
+		if st.balance(inv.Amount) < 0 && payment.Amount > existing.Amount {
+			return ErrOverpayment
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error updating payment:", err)
+	}
+	return err
+}
+
+// creditBalance returns a patient's unused credit in a currency
+func creditBalance(db *gorm.DB, patientID int, currency string) (models.Money, error) {
+	var sums struct{ Balance models.Money }
+	err := db.Model(&models.PatientCredit{}).
+		Select("COALESCE(SUM(amount), 0)::bigint AS balance").
+		Where("patient_id = ? AND currency = ?", patientID, currency).
+		Scan(&sums).Error
+	return sums.Balance, err
+}
+
+// voidPayment is VoidPayment inside an existing transaction
+func voidPayment(tx *gorm.DB, id int, reason string, userID int) error {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error; err != nil {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("no payment found with the given ID")
+	if payment.VoidedAt != nil {
+		return ErrAlreadyVoided
 	}
-	return nil
+	var inv models.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, payment.InvoiceId).Error; err != nil {
+		return err
+	}
+	refunded, err := refundedAmount(tx, id)
+	if err != nil {
+		return err
+	}
+	if refunded > 0 {
+		return ErrPaymentRefunded
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Patient{}, inv.PatientID).Error; err != nil {
+		return err
+	}
+	var ledger struct{ Net models.Money }
+	if err := tx.Model(&models.PatientCredit{}).
+		Select("COALESCE(SUM(amount), 0)::bigint AS net").
+		Where("payment_id = ?", id).
+		Scan(&ledger).Error; err != nil {
+		return err
+	}
+	if ledger.Net != 0 {
+		if err := tx.Create(&models.PatientCredit{
+			PatientID: inv.PatientID,
+			Currency:  inv.Currency,
+			Amount:    -ledger.Net,
+			PaymentID: &id,
+			Notes:     fmt.Sprintf("Reversal for voided payment #%d", id),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	balance, err := creditBalance(tx, inv.PatientID, inv.Currency)
+	if err != nil {
+		return err
+	}
+	if balance < 0 {
+		return ErrCreditAlreadyUsed
+	}
+
+	if err := tx.Model(&models.Payment{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"voided_at":   time.Now(),
+		"void_reason": reason,
+		"voided_by":   userID,
+	}).Error; err != nil {
+		return err
+	}
+	if payment.ClaimID != nil {
+		if err := reverseRemittance(tx, *payment.ClaimID, payment.Amount); err != nil {
+			return err
+		}
+	}
+	return refreshInvoiceBalance(tx, &inv)
+}
+
+// VoidPayment voids a payment, reverses the credit it produced or used and recomputes its
+// invoice. Payments with refunds, or whose overpayment credit has since been spent, cannot
+// be voided.
+func VoidPayment(id int, reason string, userID int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		return voidPayment(tx, id, reason, userID)
+	})
+	if err != nil {
+		log.Println("Error voiding payment:", err)
+	}
+	return err
+}
+
+// ApplyCredit pays an invoice from the patient's credit. amount 0 uses as much credit as
+// the balance allows.
+func ApplyCredit(invoiceID int, amount models.Money) (models.Payment, error) {
+	var payment models.Payment
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		inv, err := lockPayableInvoice(tx, invoiceID)
+		if err != nil {
+			return err
+		}
+		// the patient row serializes concurrent use of the same credit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Patient{}, inv.PatientID).Error; err != nil {
+			return err
+		}
+		available, err := creditBalance(tx, inv.PatientID, inv.Currency)
+		if err != nil {
+			return err
+		}
+		st, err := invoiceSettlement(tx, inv.ID)
+		if err != nil {
+			return err
+		}
+		due := st.balance(inv.Amount)
+		if amount == 0 {
+			amount = min(available, due)
+		}
+		if amount <= 0 || amount > available {
+			return ErrInsufficientCredit
+		}
+		if amount > due {
+			return ErrOverpayment
+		}
+
+		payment = models.Payment{
+			InvoiceId:     inv.ID,
+			Amount:        amount,
+			Currency:      inv.Currency,
+			PaymentDate:   time.Now(),
+			PaymentMethod: "credit",
+			Notes:         "Paid from patient credit",
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PatientCredit{
+			PatientID: inv.PatientID,
+			Currency:  inv.Currency,
+			Amount:    -amount,
+			PaymentID: &payment.ID,
+			Notes:     fmt.Sprintf("Applied to invoice #%d", inv.ID),
+		}).Error; err != nil {
+			return err
+		}
+		return refreshInvoiceBalance(tx, &inv)
+	})
+	if err != nil {
+		log.Println("Error applying patient credit:", err)
+	}
+	return payment, err
 }
 
-// DeletePayment removes a payment record by ID
-func DeletePayment(id int) error {
-	result := config.GormDB.Delete(&models.Payment{}, id)
+// PatientCreditSummary is a patient's credit ledger with the unused balance per currency
+type PatientCreditSummary struct {
+	Balances map[string]models.Money `json:"balances"`
+	Entries  []models.PatientCredit  `json:"entries"`
+}
+
+// GetPatientCredits returns a patient's credit ledger, newest first
+func GetPatientCredits(patientID int) (PatientCreditSummary, error) {
+	summary := PatientCreditSummary{Balances: map[string]models.Money{}}
+	if err := config.GormDB.Where("patient_id = ?", patientID).
+		Order("created_at DESC, id DESC").
+		Find(&summary.Entries).Error; err != nil {
+		log.Println("Error fetching patient credits:", err)
+		return summary, err
+	}
+	for _, e := range summary.Entries {
+		summary.Balances[e.Currency] += e.Amount
+	}
+	return summary, nil
+}
+
+// MarkOverdueInvoices flags unsettled invoices whose due date has passed
+func MarkOverdueInvoices(now time.Time) (int64, error) {
+	result := config.GormDB.Model(&models.Invoice{}).
+		Where("status IN ? AND balance_due > 0 AND due_date < ?", []string{"unpaid", "partially_paid"}, now).
+		Update("status", "overdue")
 	if result.Error != nil {
-		return result.Error
+		log.Println("Error marking overdue invoices:", result.Error)
+		return 0, result.Error
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("no payment found with the given ID")
+	return result.RowsAffected, nil
+}
+
+// MigrateInvoiceBalances fills amount_paid and balance_due of invoices that predate them.
+// Invoices that were marked paid by hand get a settling payment for the unrecorded part so
+// that their balance agrees with their status. Invoices already migrated are skipped.
+func MigrateInvoiceBalances() error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO payments (invoice_id, amount, currency, payment_date, payment_method, notes)
+			SELECT i.id, i.amount - COALESCE((SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = i.id AND p.voided_at IS NULL), 0),
+				i.currency, COALESCE(i.paid_at, i.updated_at, NOW()), 'unrecorded', 'Recorded when the invoice was marked paid'
+			FROM invoices i
+			WHERE i.status = 'paid' AND i.amount_paid + i.amount_credited + i.balance_due <> i.amount
+				AND i.amount > COALESCE((SELECT SUM(p.amount) FROM payments p WHERE p.invoice_id = i.id AND p.voided_at IS NULL), 0)`).Error; err != nil {
+			return err
+		}
+		var batch []models.Invoice
+		return tx.Where("amount_paid + amount_credited + balance_due <> amount").
+			FindInBatches(&batch, 500, func(btx *gorm.DB, _ int) error {
+				for i := range batch {
+					if err := refreshInvoiceBalance(tx, &batch[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+	})
+	if err != nil {
+		log.Println("Error migrating invoice balances:", err)
 	}
-	return nil
+	return err
 }
@@ -0,0 +1,338 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrStockExpired      = errors.New("stock batch is expired")
+	ErrNoPrescription    = errors.New("medical record has no prescription")
+	ErrInvoiceNotOpen    = errors.New("invoice is not open for new charges")
+)
+
+// DispenseItem is one batch and quantity to dispense
+type DispenseItem struct {
+	StockItemID int `json:"stock_item_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// DispenseRequest dispenses one or more batches against a prescription.
+// When Bill is set the items are charged on InvoiceID, or on the patient's
+// draft invoice (a new one is created if there is none).
+type DispenseRequest struct {
+	MedicalRecordID int            `json:"medical_record_id"`
+	Items           []DispenseItem `json:"items"`
+	Notes           string         `json:"notes"`
+	Bill            bool           `json:"bill"`
+	InvoiceID       int            `json:"invoice_id"`
+}
+
+// DispenseResult is returned by DispenseDrugs
+type DispenseResult struct {
+	Dispensings []models.Dispensing `json:"dispensings"`
+	InvoiceID   *int                `json:"invoice_id,omitempty"`
+}
+
+func lockDrugStockItem(tx *gorm.DB, id int) (models.DrugStockItem, error) {
+	var item models.DrugStockItem
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, id).Error
+	return item, err
+}
+
+// ReceiveDrugStock adds a new batch and records the receipt movement
+func ReceiveDrugStock(item *models.DrugStockItem, performedBy int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(item).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PharmacyStockMovement{
+			StockItemID:  item.ID,
+			Type:         "receipt",
+			Quantity:     item.Quantity,
+			BalanceAfter: item.Quantity,
+			Reason:       "batch " + item.BatchNumber + " received",
+			PerformedBy:  performedBy,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error receiving drug stock:", err)
+	}
+	return err
+}
+
+// GetDrugStockItemByID retrieves one stock batch
+func GetDrugStockItemByID(id int) (models.DrugStockItem, error) {
+	var item models.DrugStockItem
+	if err := config.GormDB.First(&item, id).Error; err != nil {
+		log.Println("Error fetching drug stock item:", err)
+		return item, err
+	}
+	return item, nil
+}
+
+// GetDrugStock lists stock batches, optionally filtered by drug code and location.
+// Empty batches are hidden unless includeEmpty is set.
+func GetDrugStock(drugCode, location string, includeEmpty bool) ([]models.DrugStockItem, error) {
+	var items []models.DrugStockItem
+	q := config.GormDB.Order("drug_name, expiry_date")
+	if drugCode != "" {
+		q = q.Where("drug_code = ?", drugCode)
+	}
+	if location != "" {
+		q = q.Where("location = ?", location)
+	}
+	if !includeEmpty {
+		q = q.Where("quantity > 0")
+	}
+	if err := q.Find(&items).Error; err != nil {
+		log.Println("Error fetching drug stock:", err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// AdjustDrugStock applies a signed correction (count, breakage, expiry write-off) to a batch
+func AdjustDrugStock(id, delta int, movementType, reason string, performedBy int) (models.DrugStockItem, error) {
+	var item models.DrugStockItem
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if item, err = lockDrugStockItem(tx, id); err != nil {
+			return err
+		}
+		if item.Quantity+delta < 0 {
+			return ErrInsufficientStock
+		}
+		item.Quantity += delta
+		if err := tx.Model(&models.DrugStockItem{}).Where("id = ?", id).Update("quantity", item.Quantity).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PharmacyStockMovement{
+			StockItemID:  id,
+			Type:         movementType,
+			Quantity:     delta,
+			BalanceAfter: item.Quantity,
+			Reason:       reason,
+			PerformedBy:  performedBy,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error adjusting drug stock:", err)
+	}
+	return item, err
+}
+
+// GetStockMovements returns the audit trail of a batch, newest first
+func GetStockMovements(stockItemID int) ([]models.PharmacyStockMovement, error) {
+	var movements []models.PharmacyStockMovement
+	if err := config.GormDB.Where("stock_item_id = ?", stockItemID).
+		Order("created_at DESC, id DESC").
+		Find(&movements).Error; err != nil {
+		log.Println("Error fetching stock movements:", err)
+		return nil, err
+	}
+	return movements, nil
+}
+
+// openInvoiceForCharges locks the invoice that dispensed items are billed to
+func openInvoiceForCharges(tx *gorm.DB, patientID, invoiceID int) (models.Invoice, error) {
+	var inv models.Invoice
+	locked := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	if invoiceID != 0 {
+		if err := locked.First(&inv, invoiceID).Error; err != nil {
+			return inv, err
+		}
+		if inv.PatientID != patientID || !invoiceAcceptsCharges(inv.Status) {
+			return inv, ErrInvoiceNotOpen
+		}
+		if inv.Currency != utils.DefaultCurrency() {
+			return inv, ErrCurrencyMismatch
+		}
+		return inv, nil
+	}
+
+	err := locked.Where("patient_id = ? AND status = ? AND currency = ?", patientID, "draft", utils.DefaultCurrency()).
+		Order("issued_at DESC").
+		First(&inv).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return inv, err
+	}
+
+	now := time.Now()
+	inv = models.Invoice{
+		PatientID: patientID,
+		Currency:  utils.DefaultCurrency(),
+		Status:    "draft",
+		IssuedAt:  now,
+		DueDate:   now.AddDate(0, 0, 14),
+		Notes:     "Pharmacy charges",
+	}
+	if err := resolveGuarantor(tx, &inv); err != nil {
+		return inv, err
+	}
+	return inv, tx.Create(&inv).Error
+}
+
+// DispenseDrugs decrements stock, records the dispensings and their movements
+// and optionally bills them, all in one transaction
+func DispenseDrugs(req DispenseRequest, performedBy int) (DispenseResult, error) {
+	var result DispenseResult
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var record models.MedicalRecord
+		if err := tx.First(&record, req.MedicalRecordID).Error; err != nil {
+			return err
+		}
+		if record.Prescription == "" {
+			return ErrNoPrescription
+		}
+
+		var inv models.Invoice
+		if req.Bill {
+			var err error
+			if inv, err = openInvoiceForCharges(tx, record.PatientID, req.InvoiceID); err != nil {
+				return err
+			}
+			result.InvoiceID = &inv.ID
+		}
+
+		now := time.Now()
+		var billed models.Money
+		for _, it := range req.Items {
+			item, err := lockDrugStockItem(tx, it.StockItemID)
+			if err != nil {
+				return err
+			}
+			if !item.ExpiryDate.After(now) {
+				return fmt.Errorf("%w: %s batch %s", ErrStockExpired, item.DrugName, item.BatchNumber)
+			}
+			if item.Quantity < it.Quantity {
+				return fmt.Errorf("%w: %s batch %s has %d left", ErrInsufficientStock, item.DrugName, item.BatchNumber, item.Quantity)
+			}
+
+			item.Quantity -= it.Quantity
+			if err := tx.Model(&models.DrugStockItem{}).Where("id = ?", item.ID).Update("quantity", item.Quantity).Error; err != nil {
+				return err
+			}
+
+			d := models.Dispensing{
+				MedicalRecordID: record.ID,
+				PatientID:       record.PatientID,
+				StockItemID:     item.ID,
+				Quantity:        it.Quantity,
+				DispensedBy:     performedBy,
+				DispensedAt:     now,
+				Notes:           req.Notes,
+			}
+			if err := tx.Create(&d).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.PharmacyStockMovement{
+				StockItemID:  item.ID,
+				Type:         "dispense",
+				Quantity:     -it.Quantity,
+				BalanceAfter: item.Quantity,
+				DispensingID: &d.ID,
+				PerformedBy:  performedBy,
+			}).Error; err != nil {
+				return err
+			}
+
+			if req.Bill {
+				line := models.InvoiceLine{
+					InvoiceID:   inv.ID,
+					Description: fmt.Sprintf("%s %s (batch %s)", item.DrugName, item.Strength, item.BatchNumber),
+					Quantity:    it.Quantity,
+					UnitPrice:   item.UnitPrice,
+					SourceType:  "dispensing",
+					SourceID:    &d.ID,
+				}
+				if err := priceInvoiceLine(tx, &line, inv.Currency); err != nil {
+					return err
+				}
+				if err := tx.Create(&line).Error; err != nil {
+					return err
+				}
+				billed += line.Amount
+				d.InvoiceLineID = &line.ID
+				if err := tx.Model(&models.Dispensing{}).Where("id = ?", d.ID).Update("invoice_line_id", line.ID).Error; err != nil {
+					return err
+				}
+			}
+			result.Dispensings = append(result.Dispensings, d)
+		}
+
+		if req.Bill && billed > 0 {
+			return recalculateInvoiceTotals(tx, inv.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error dispensing drugs:", err)
+	}
+	return result, err
+}
+
+// GetDispensings lists dispensings, optionally filtered by patient and prescription
+func GetDispensings(patientID, medicalRecordID int) ([]models.Dispensing, error) {
+	var list []models.Dispensing
+	q := config.GormDB.Order("dispensed_at DESC")
+	if patientID != 0 {
+		q = q.Where("patient_id = ?", patientID)
+	}
+	if medicalRecordID != 0 {
+		q = q.Where("medical_record_id = ?", medicalRecordID)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching dispensings:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// LowStockAlert is a drug whose usable stock at a location is at or below its reorder level
+type LowStockAlert struct {
+	DrugCode     string `json:"drug_code"`
+	DrugName     string `json:"drug_name"`
+	Location     string `json:"location"`
+	Quantity     int    `json:"quantity"`
+	ReorderLevel int    `json:"reorder_level"`
+}
+
+// GetLowStockAlerts sums non-expired batches per drug and location
+func GetLowStockAlerts() ([]LowStockAlert, error) {
+	var alerts []LowStockAlert
+	if err := config.GormDB.Model(&models.DrugStockItem{}).
+		Select("drug_code, MAX(drug_name) AS drug_name, location, " +
+			"COALESCE(SUM(quantity) FILTER (WHERE expiry_date > NOW()), 0) AS quantity, MAX(reorder_level) AS reorder_level").
+		Group("drug_code, location").
+		Having("COALESCE(SUM(quantity) FILTER (WHERE expiry_date > NOW()), 0) <= MAX(reorder_level)").
+		Order("drug_code, location").
+		Scan(&alerts).Error; err != nil {
+		log.Println("Error fetching low stock alerts:", err)
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// GetNearExpiryStock lists batches with stock left that expire within the given number of days
+// (already expired batches are included so they get written off)
+func GetNearExpiryStock(days int) ([]models.DrugStockItem, error) {
+	var items []models.DrugStockItem
+	if err := config.GormDB.
+		Where("quantity > 0 AND expiry_date <= ?", time.Now().AddDate(0, 0, days)).
+		Order("expiry_date").
+		Find(&items).Error; err != nil {
+		log.Println("Error fetching near-expiry stock:", err)
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// CreateProblem inserts a new problem list entry
+func CreateProblem(problem *models.Problem) error {
+	if err := config.GormDB.Create(problem).Error; err != nil {
+		log.Println("Error creating problem:", err)
+		return err
+	}
+	return nil
+}
+
+// GetProblemByID retrieves a problem by ID
+func GetProblemByID(id int) (models.Problem, error) {
+	var problem models.Problem
+	if err := config.GormDB.First(&problem, id).Error; err != nil {
+		log.Println("Error fetching problem:", err)
+		return problem, err
+	}
+	return problem, nil
+}
+
+// GetProblemsByPatientID retrieves a patient's problem list, optionally filtered by status
+func GetProblemsByPatientID(patientID int, status string) ([]models.Problem, error) {
+	var problems []models.Problem
+	q := config.GormDB.Where("patient_id = ?", patientID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Order("onset_date DESC NULLS LAST, id DESC").Find(&problems).Error; err != nil {
+		log.Println("Error fetching problems by patient ID:", err)
+		return nil, err
+	}
+	return problems, nil
+}
+
+// UpdateProblem updates an existing problem
+func UpdateProblem(problem models.Problem) error {
+	result := config.GormDB.Model(&models.Problem{}).Where("id = ?", problem.ID).Updates(problem)
+	if result.Error != nil {
+		log.Println("Error updating problem:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("no problem found with the given ID")
+	}
+	return nil
+}
+
+// ResolveProblem marks a problem as resolved
+func ResolveProblem(id int, resolvedAt time.Time) error {
+	if err := config.GormDB.Model(&models.Problem{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":        "resolved",
+			"resolved_date": resolvedAt,
+		}).Error; err != nil {
+		log.Println("Error resolving problem:", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteProblem deletes a problem by ID
+func DeleteProblem(id int) error {
+	if err := config.GormDB.Delete(&models.Problem{}, id).Error; err != nil {
+		log.Println("Error deleting problem:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrReferralState      = errors.New("referral is not in a valid state for this action")
+	ErrAttachmentMismatch = errors.New("attached record or file does not belong to the referred patient")
+)
+
+// urgencyOrder sorts emergency referrals before urgent and routine ones
+const urgencyOrder = "CASE urgency WHEN 'emergency' THEN 0 WHEN 'urgent' THEN 1 ELSE 2 END"
+
+// CreateExternalProvider inserts an external provider
+func CreateExternalProvider(p *models.ExternalProvider) error {
+	if err := config.GormDB.Create(p).Error; err != nil {
+		log.Println("Error creating external provider:", err)
+		return err
+	}
+	return nil
+}
+
+// GetExternalProviders lists external providers
+func GetExternalProviders() ([]models.ExternalProvider, error) {
+	var providers []models.ExternalProvider
+	if err := config.GormDB.Order("name").Find(&providers).Error; err != nil {
+		log.Println("Error fetching external providers:", err)
+		return nil, err
+	}
+	return providers, nil
+}
+
+// CreateReferral stores a sent referral with its attachments after checking
+// that every attachment belongs to the referred patient
+func CreateReferral(ref *models.Referral) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		for _, a := range ref.Attachments {
+			var count int64
+			switch {
+			case a.MedicalRecordID != nil:
+				if err := tx.Model(&models.MedicalRecord{}).
+					Where("id = ? AND patient_id = ?", *a.MedicalRecordID, ref.PatientID).
+					Count(&count).Error; err != nil {
+					return err
+				}
+			case a.FileID != nil:
+				if err := tx.Model(&models.File{}).
+					Where("id = ? AND patient_id = ?", *a.FileID, ref.PatientID).
+					Count(&count).Error; err != nil {
+					return err
+				}
+			}
+			if count == 0 {
+				return ErrAttachmentMismatch
+			}
+		}
+		return tx.Create(ref).Error
+	})
+	if err != nil {
+		log.Println("Error creating referral:", err)
+	}
+	return err
+}
+
+// GetReferralByID retrieves a referral with its attachments
+func GetReferralByID(id int) (models.Referral, error) {
+	var ref models.Referral
+	if err := config.GormDB.Preload("Attachments").First(&ref, id).Error; err != nil {
+		log.Println("Error fetching referral:", err)
+		return ref, err
+	}
+	return ref, nil
+}
+
+// GetReferralInbox lists referrals addressed to a doctor, most urgent and oldest first
+func GetReferralInbox(doctorID int, status string) ([]models.Referral, error) {
+	var list []models.Referral
+	q := config.GormDB.Preload("Attachments").Where("to_doctor_id = ?", doctorID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	} else {
+		q = q.Where("status IN ?", []string{"sent", "accepted"})
+	}
+	if err := q.Order(urgencyOrder + ", sent_at").Find(&list).Error; err != nil {
+		log.Println("Error fetching referral inbox:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetReferralsSentBy lists the referrals written by a doctor, newest first
+func GetReferralsSentBy(doctorID int, status string) ([]models.Referral, error) {
+	var list []models.Referral
+	q := config.GormDB.Preload("Attachments").Where("from_doctor_id = ?", doctorID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Order("sent_at DESC").Find(&list).Error; err != nil {
+		log.Println("Error fetching sent referrals:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetReferralsByPatientID lists a patient's referrals, newest first
+func GetReferralsByPatientID(patientID int) ([]models.Referral, error) {
+	var list []models.Referral
+	if err := config.GormDB.Preload("Attachments").
+		Where("patient_id = ?", patientID).
+		Order("sent_at DESC").
+		Find(&list).Error; err != nil {
+		log.Println("Error fetching referrals by patient ID:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// TransitionReferral locks a referral, checks it is in one of the allowed states and
+// applies the change; apply may modify the referral and write related rows through tx
+func TransitionReferral(id int, from []string, apply func(tx *gorm.DB, ref *models.Referral) error) (models.Referral, error) {
+	var ref models.Referral
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ref, id).Error; err != nil {
+			return err
+		}
+		allowed := false
+		for _, s := range from {
+			allowed = allowed || ref.Status == s
+		}
+		if !allowed {
+			return ErrReferralState
+		}
+		if err := apply(tx, &ref); err != nil {
+			return err
+		}
+		return tx.Model(&models.Referral{}).Where("id = ?", id).
+			Select("status", "response_note", "appointment_id", "responded_at", "completed_at").
+			Updates(&ref).Error
+	})
+	if err != nil {
+		log.Println("Error updating referral:", err)
+	}
+	return ref, err
+}
+
+// BookReferralAppointment creates the appointment with the receiving doctor
+// and moves the referral to appointment_booked, inside the caller's transaction.
+// It returns ErrSlotUnavailable when the doctor is not free at that time.
+func BookReferralAppointment(tx *gorm.DB, ref *models.Referral, at time.Time, duration int) (models.Appointment, error) {
+	if err := reserveDoctorSlot(tx, uint(*ref.ToDoctorID), at, duration); err != nil {
+		return models.Appointment{}, err
+	}
+	appt := models.Appointment{
+		PatientID: uint(ref.PatientID),
+		DoctorID:  uint(*ref.ToDoctorID),
+		DateTime:  at,
+		Status:    "scheduled",
+		Reason:    "Referral: " + ref.Reason,
+		Duration:  duration,
+	}
+	if err := tx.Create(&appt).Error; err != nil {
+		return appt, err
+	}
+	ref.Status = "appointment_booked"
+	ref.AppointmentID = &appt.ID
+	return appt, nil
+}
@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"github.com/samichen99/HAP-hospital-management-system/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotGuarantor        = errors.New("guarantor must be a related person of the patient marked as guarantor")
+	ErrRelatedPersonInUse  = errors.New("related person is the guarantor of existing invoices")
+	ErrInvalidRelationship = errors.New("unsupported relationship or patient linked to itself")
+	ErrAlreadyLinked       = errors.New("patients are already linked with this relationship")
+)
+
+// CreateRelatedPerson inserts a related person
+func CreateRelatedPerson(p *models.RelatedPerson) error {
+	if err := config.GormDB.Create(p).Error; err != nil {
+		log.Println("Error creating related person:", err)
+		return err
+	}
+	return nil
+}
+
+// GetRelatedPersonByID retrieves a related person by ID
+func GetRelatedPersonByID(id int) (models.RelatedPerson, error) {
+	var p models.RelatedPerson
+	if err := config.GormDB.First(&p, id).Error; err != nil {
+		log.Println("Error fetching related person:", err)
+		return p, err
+	}
+	return p, nil
+}
+
+// GetRelatedPersonsByPatientID lists a patient's related persons by priority. role filters
+// on emergency_contact, guardian, guarantor or next_of_kin.
+func GetRelatedPersonsByPatientID(patientID int, role string) ([]models.RelatedPerson, error) {
+	var list []models.RelatedPerson
+	q := config.GormDB.Where("patient_id = ?", patientID)
+	switch role {
+	case "emergency_contact":
+		q = q.Where("is_emergency_contact")
+	case "guardian":
+		q = q.Where("is_guardian")
+	case "guarantor":
+		q = q.Where("is_guarantor")
+	case "next_of_kin":
+		q = q.Where("is_next_of_kin")
+	}
+	if err := q.Order("priority, id").Find(&list).Error; err != nil {
+		log.Println("Error fetching related persons:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateRelatedPerson saves a related person
+func UpdateRelatedPerson(p *models.RelatedPerson) error {
+	if err := config.GormDB.Save(p).Error; err != nil {
+		log.Println("Error updating related person:", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteRelatedPerson removes a related person unless invoices are addressed to them
+func DeleteRelatedPerson(id int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Invoice{}).Where("guarantor_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRelatedPersonInUse
+		}
+		return tx.Delete(&models.RelatedPerson{}, id).Error
+	})
+	if err != nil {
+		log.Println("Error deleting related person:", err)
+	}
+	return err
+}
+
+// LinkPatients records a family relationship between two patients together with its reciprocal
+func LinkPatients(patientID, relatedPatientID int, relationship string) (models.PatientRelationship, error) {
+	link := models.PatientRelationship{PatientID: patientID, RelatedPatientID: relatedPatientID, Relationship: relationship}
+	inverse, ok := utils.ReciprocalRelationship(relationship)
+	if !ok || patientID == relatedPatientID {
+		return link, ErrInvalidRelationship
+	}
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range []int{patientID, relatedPatientID} {
+			if err := tx.First(&models.Patient{}, id).Error; err != nil {
+				return err
+			}
+		}
+		var count int64
+		if err := tx.Model(&models.PatientRelationship{}).
+			Where("patient_id = ? AND related_patient_id = ? AND relationship = ?", patientID, relatedPatientID, relationship).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyLinked
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PatientRelationship{
+			PatientID:        relatedPatientID,
+			RelatedPatientID: patientID,
+			Relationship:     inverse,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error linking patients:", err)
+	}
+	return link, err
+}
+
+// UnlinkPatients removes a family relationship and its reciprocal
+func UnlinkPatients(id int) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var link models.PatientRelationship
+		if err := tx.First(&link, id).Error; err != nil {
+			return err
+		}
+		inverse, _ := utils.ReciprocalRelationship(link.Relationship)
+		if err := tx.Where("patient_id = ? AND related_patient_id = ? AND relationship = ?",
+			link.RelatedPatientID, link.PatientID, inverse).
+			Delete(&models.PatientRelationship{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&link).Error
+	})
+	if err != nil {
+		log.Println("Error unlinking patients:", err)
+	}
+	return err
+}
+
+// FamilyMember is a patient relationship with the related patient's details
+type FamilyMember struct {
+	ID               int    `json:"id"`
+	RelatedPatientID int    `json:"related_patient_id"`
+	Relationship     string `json:"relationship"`
+	FullName         string `json:"full_name"`
+	MRN              string `json:"mrn"`
+	DateOfBirth      string `json:"date_of_birth"`
+	Phone            string `json:"phone"`
+}
+
+// GetFamilyMembers lists the patients linked to a patient
+func GetFamilyMembers(patientID int) ([]FamilyMember, error) {
+	var list []FamilyMember
+	if err := config.GormDB.Table("patient_relationships r").
+		Select("r.id, r.related_patient_id, r.relationship, p.full_name, p.mrn, p.date_of_birth, p.phone").
+		Joins("JOIN patients p ON p.id = r.related_patient_id").
+		Where("r.patient_id = ?", patientID).
+		Order("r.relationship, p.full_name").
+		Scan(&list).Error; err != nil {
+		log.Println("Error fetching family members:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// resolveGuarantor checks an explicit guarantor and, for minors without one, addresses
+// the invoice to the patient's first guarantor
+func resolveGuarantor(db *gorm.DB, inv *models.Invoice) error {
+	if inv.GuarantorID != nil {
+		var count int64
+		if err := db.Model(&models.RelatedPerson{}).
+			Where("id = ? AND patient_id = ? AND is_guarantor", *inv.GuarantorID, inv.PatientID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotGuarantor
+		}
+		return nil
+	}
+
+	if inv.PatientID == 0 {
+		return nil
+	}
+	var patient models.Patient
+	if err := db.First(&patient, inv.PatientID).Error; err != nil {
+		return err
+	}
+	if !utils.IsMinor(patient.DateOfBirth, time.Now()) {
+		return nil
+	}
+	var guarantor models.RelatedPerson
+	err := db.Where("patient_id = ? AND is_guarantor", inv.PatientID).Order("priority, id").First(&guarantor).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	case err != nil:
+		return err
+	}
+	inv.GuarantorID = &guarantor.ID
+	return nil
+}
+
+// InvoiceBillTo is the party an invoice is addressed to
+type InvoiceBillTo struct {
+	Type    string `json:"type"` // patient or guarantor
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email,omitempty"`
+	// OnBehalfOf names the patient when the invoice goes to a guarantor
+	OnBehalfOf string `json:"on_behalf_of,omitempty"`
+}
+
+// GetInvoiceBillTo returns the guarantor an invoice is addressed to, or the patient
+func GetInvoiceBillTo(inv models.Invoice) (InvoiceBillTo, error) {
+	patient, err := GetPatientByID(inv.PatientID)
+	if err != nil {
+		return InvoiceBillTo{}, err
+	}
+	if inv.GuarantorID == nil {
+		return InvoiceBillTo{
+			Type:    "patient",
+			ID:      patient.ID,
+			Name:    patient.FullName,
+			Address: patient.Address,
+			Phone:   patient.Phone,
+		}, nil
+	}
+	g, err := GetRelatedPersonByID(*inv.GuarantorID)
+	if err != nil {
+		return InvoiceBillTo{}, err
+	}
+	return InvoiceBillTo{
+		Type:       "guarantor",
+		ID:         g.ID,
+		Name:       g.FullName,
+		Address:    g.Address,
+		Phone:      g.Phone,
+		Email:      g.Email,
+		OnBehalfOf: patient.FullName,
+	}, nil
+}
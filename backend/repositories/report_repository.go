@@ -0,0 +1,270 @@
+package repositories
+
+import (
+	"log"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// Financial reports are aggregated in SQL. Sums are cast back to bigint because Postgres
+// returns SUM(bigint) as numeric. Periods are half-open: from <= t < to.
+
+// ARAgingRow is the open balance of a currency (or of one patient) by days past due.
+// Current holds invoices that are not due yet.
+type ARAgingRow struct {
+	Currency    string       `json:"currency"`
+	PatientID   *int         `json:"patient_id,omitempty"`
+	PatientName string       `json:"patient_name,omitempty"`
+	Invoices    int          `json:"invoices"`
+	Current     models.Money `json:"current"`
+	Days0To30   models.Money `gorm:"column:days_0_30" json:"days_0_30"`
+	Days31To60  models.Money `gorm:"column:days_31_60" json:"days_31_60"`
+	Days61To90  models.Money `gorm:"column:days_61_90" json:"days_61_90"`
+	Over90      models.Money `gorm:"column:days_over_90" json:"days_over_90"`
+	Total       models.Money `json:"total"`
+}
+
+// GetARAging buckets the balance due of open invoices by how many days past their due date
+// they are on asOf, per currency or, with byPatient, per patient and currency
+func GetARAging(asOf time.Time, byPatient bool) ([]ARAgingRow, error) {
+	groupBy := "i.currency"
+	columns := "i.currency"
+	if byPatient {
+		groupBy = "i.currency, i.patient_id, p.full_name"
+		columns = "i.currency, i.patient_id, p.full_name AS patient_name"
+	}
+	var rows []ARAgingRow
+	err := config.GormDB.Raw(`SELECT `+columns+`,
+			COUNT(*) AS invoices,
+			COALESCE(SUM(i.balance_due) FILTER (WHERE d.days < 0), 0)::bigint AS current,
+			COALESCE(SUM(i.balance_due) FILTER (WHERE d.days BETWEEN 0 AND 30), 0)::bigint AS days_0_30,
+			COALESCE(SUM(i.balance_due) FILTER (WHERE d.days BETWEEN 31 AND 60), 0)::bigint AS days_31_60,
+			COALESCE(SUM(i.balance_due) FILTER (WHERE d.days BETWEEN 61 AND 90), 0)::bigint AS days_61_90,
+			COALESCE(SUM(i.balance_due) FILTER (WHERE d.days > 90), 0)::bigint AS days_over_90,
+			COALESCE(SUM(i.balance_due), 0)::bigint AS total
+		FROM invoices i
+		JOIN patients p ON p.id = i.patient_id
+		CROSS JOIN LATERAL (SELECT CAST(@as_of AS date) - CAST(i.due_date AS date) AS days) d
+		WHERE i.status IN ('unpaid', 'partially_paid', 'overdue', 'collections') AND i.balance_due > 0 AND i.issued_at <= @as_of
+		GROUP BY `+groupBy+`
+		ORDER BY `+groupBy,
+		map[string]interface{}{"as_of": asOf}).Scan(&rows).Error
+	if err != nil {
+		log.Println("Error building AR aging report:", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+// RevenuePeriodRow is what was invoiced, credited, collected and refunded in one period
+type RevenuePeriodRow struct {
+	Period       time.Time    `json:"period"`
+	Currency     string       `json:"currency"`
+	Invoiced     models.Money `json:"invoiced"`
+	Credited     models.Money `json:"credited"`
+	NetRevenue   models.Money `json:"net_revenue"`
+	Collected    models.Money `json:"collected"`
+	Refunded     models.Money `json:"refunded"`
+	NetCollected models.Money `json:"net_collected"`
+}
+
+// GetRevenueByPeriod totals issued invoices less credit notes, and payments less refunds, by
+// day, week, month, quarter or year. Voided records and drafts do not count.
+func GetRevenueByPeriod(from, to time.Time, interval string) ([]RevenuePeriodRow, error) {
+	var rows []RevenuePeriodRow
+	err := config.GormDB.Raw(`SELECT t.period, t.currency,
+			SUM(t.invoiced)::bigint AS invoiced,
+			SUM(t.credited)::bigint AS credited,
+			SUM(t.invoiced - t.credited)::bigint AS net_revenue,
+			SUM(t.collected)::bigint AS collected,
+			SUM(t.refunded)::bigint AS refunded,
+			SUM(t.collected - t.refunded)::bigint AS net_collected
+		FROM (
+			SELECT date_trunc(@interval, issued_at) AS period, currency, amount AS invoiced, 0 AS credited, 0 AS collected, 0 AS refunded
+			FROM invoices WHERE status NOT IN ('draft', 'void') AND issued_at >= @from AND issued_at < @to
+			UNION ALL
+			SELECT date_trunc(@interval, issued_at), currency, 0, amount, 0, 0
+			FROM credit_notes WHERE voided_at IS NULL AND issued_at >= @from AND issued_at < @to
+			UNION ALL
+			SELECT date_trunc(@interval, payment_date), currency, 0, 0, amount, 0
+			FROM payments WHERE voided_at IS NULL AND payment_date >= @from AND payment_date < @to
+			UNION ALL
+			SELECT date_trunc(@interval, refunded_at), currency, 0, 0, 0, amount
+			FROM refunds WHERE voided_at IS NULL AND status = 'confirmed' AND refunded_at >= @from AND refunded_at < @to
+		) t
+		GROUP BY t.period, t.currency
+		ORDER BY t.period, t.currency`,
+		map[string]interface{}{"from": from, "to": to, "interval": interval}).Scan(&rows).Error
+	if err != nil {
+		log.Println("Error building revenue by period report:", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+// RevenueByDoctorRow is the revenue of the invoices raised for a doctor's appointments.
+// Invoices without an appointment are reported without a doctor.
+type RevenueByDoctorRow struct {
+	DoctorID   *int         `json:"doctor_id,omitempty"`
+	DoctorName string       `json:"doctor_name"`
+	Currency   string       `json:"currency"`
+	Invoices   int          `json:"invoices"`
+	Invoiced   models.Money `json:"invoiced"`
+	Credited   models.Money `json:"credited"`
+	NetRevenue models.Money `json:"net_revenue"`
+	Collected  models.Money `json:"collected"`
+}
+
+// GetRevenueByDoctor totals the invoices issued in a period per doctor, with what has been
+// credited and collected on them so far
+func GetRevenueByDoctor(from, to time.Time) ([]RevenueByDoctorRow, error) {
+	var rows []RevenueByDoctorRow
+	err := config.GormDB.Raw(`SELECT d.id AS doctor_id, COALESCE(d.full_name, 'No doctor') AS doctor_name, i.currency,
+			COUNT(*) AS invoices,
+			SUM(i.amount)::bigint AS invoiced,
+			SUM(i.amount_credited)::bigint AS credited,
+			SUM(i.amount - i.amount_credited)::bigint AS net_revenue,
+			SUM(i.amount_paid)::bigint AS collected
+		FROM invoices i
+		LEFT JOIN appointments a ON a.id = i.appointment_id
+		LEFT JOIN doctors d ON d.id = a.doctor_id
+		WHERE i.status NOT IN ('draft', 'void') AND i.issued_at >= @from AND i.issued_at < @to
+		GROUP BY d.id, d.full_name, i.currency
+		ORDER BY net_revenue DESC, doctor_name`,
+		map[string]interface{}{"from": from, "to": to}).Scan(&rows).Error
+	if err != nil {
+		log.Println("Error building revenue by doctor report:", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+// RevenueByServiceRow is what was billed for one catalogue service (or one free-text charge)
+type RevenueByServiceRow struct {
+	ServiceItemID *int         `json:"service_item_id,omitempty"`
+	Service       string       `json:"service"`
+	Name          string       `json:"name"`
+	Category      string       `json:"category"`
+	Currency      string       `json:"currency"`
+	Quantity      int          `json:"quantity"`
+	Gross         models.Money `json:"gross"`
+	Discount      models.Money `json:"discount"`
+	Tax           models.Money `json:"tax"`
+	Amount        models.Money `json:"amount"`
+}
+
+// GetRevenueByService totals the invoice lines of invoices issued in a period per service.
+// Credit notes apply to whole invoices and are not spread over services.
+func GetRevenueByService(from, to time.Time) ([]RevenueByServiceRow, error) {
+	var rows []RevenueByServiceRow
+	err := config.GormDB.Raw(`SELECT l.service_item_id,
+			COALESCE(s.code, NULLIF(l.code, ''), l.description) AS service,
+			MAX(COALESCE(s.name, l.description)) AS name,
+			COALESCE(s.category, NULLIF(l.source_type, ''), '') AS category,
+			i.currency,
+			SUM(l.quantity) AS quantity,
+			SUM(l.quantity * l.unit_price)::bigint AS gross,
+			SUM(l.discount)::bigint AS discount,
+			SUM(l.tax_amount)::bigint AS tax,
+			SUM(l.amount)::bigint AS amount
+		FROM invoice_lines l
+		JOIN invoices i ON i.id = l.invoice_id
+		LEFT JOIN service_items s ON s.id = l.service_item_id
+		WHERE i.status NOT IN ('draft', 'void') AND i.issued_at >= @from AND i.issued_at < @to
+		GROUP BY l.service_item_id, COALESCE(s.code, NULLIF(l.code, ''), l.description), COALESCE(s.category, NULLIF(l.source_type, ''), ''), i.currency
+		ORDER BY amount DESC, service`,
+		map[string]interface{}{"from": from, "to": to}).Scan(&rows).Error
+	if err != nil {
+		log.Println("Error building revenue by service report:", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+// RevenueByMethodRow is what was collected and refunded with one payment method
+type RevenueByMethodRow struct {
+	Method       string       `json:"method"`
+	Currency     string       `json:"currency"`
+	Payments     int          `json:"payments"`
+	Collected    models.Money `json:"collected"`
+	Refunds      int          `json:"refunds"`
+	Refunded     models.Money `json:"refunded"`
+	NetCollected models.Money `json:"net_collected"`
+}
+
+// GetRevenueByPaymentMethod totals payments and refunds made in a period per method
+func GetRevenueByPaymentMethod(from, to time.Time) ([]RevenueByMethodRow, error) {
+	var rows []RevenueByMethodRow
+	err := config.GormDB.Raw(`SELECT t.method, t.currency,
+			SUM(t.payments) AS payments,
+			SUM(t.collected)::bigint AS collected,
+			SUM(t.refunds) AS refunds,
+			SUM(t.refunded)::bigint AS refunded,
+			SUM(t.collected - t.refunded)::bigint AS net_collected
+		FROM (
+			SELECT payment_method AS method, currency, 1 AS payments, amount AS collected, 0 AS refunds, 0 AS refunded
+			FROM payments WHERE voided_at IS NULL AND payment_date >= @from AND payment_date < @to
+			UNION ALL
+			SELECT method, currency, 0, 0, 1, amount
+			FROM refunds WHERE voided_at IS NULL AND status = 'confirmed' AND refunded_at >= @from AND refunded_at < @to
+		) t
+		GROUP BY t.method, t.currency
+		ORDER BY t.method, t.currency`,
+		map[string]interface{}{"from": from, "to": to}).Scan(&rows).Error
+	if err != nil {
+		log.Println("Error building revenue by payment method report:", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+// CashUpRow is the money one cashier took and paid out with one method
+type CashUpRow struct {
+	CashierID *int         `json:"cashier_id,omitempty"`
+	Cashier   string       `json:"cashier"`
+	Method    string       `json:"method"`
+	Currency  string       `json:"currency"`
+	Payments  int          `json:"payments"`
+	Received  models.Money `json:"received"`
+	Refunds   int          `json:"refunds"`
+	Refunded  models.Money `json:"refunded"`
+	Net       models.Money `json:"net"`
+}
+
+// GetCashUp totals what each cashier received and refunded in a period (normally one day),
+// optionally for one cashier. Overpayments kept as credit were received too and count in
+// full; payments from patient credit, insurance remittances and online payments do not pass the till.
+func GetCashUp(from, to time.Time, cashierID int) ([]CashUpRow, error) {
+	var rows []CashUpRow
+	err := config.GormDB.Raw(`SELECT t.cashier_id, COALESCE(u.username, 'Unknown') AS cashier, t.method, t.currency,
+			SUM(t.payments) AS payments,
+			SUM(t.received)::bigint AS received,
+			SUM(t.refunds) AS refunds,
+			SUM(t.refunded)::bigint AS refunded,
+			SUM(t.received - t.refunded)::bigint AS net
+		FROM (
+			SELECT p.received_by AS cashier_id, p.payment_method AS method, p.currency, 1 AS payments,
+				p.amount + COALESCE((SELECT SUM(c.amount) FROM patient_credits c WHERE c.payment_id = p.id AND c.amount > 0), 0) AS received,
+				0 AS refunds, 0 AS refunded
+			FROM payments p
+			WHERE p.voided_at IS NULL AND p.payment_method <> 'credit' AND p.claim_id IS NULL AND p.intent_id IS NULL
+				AND p.payment_date >= @from AND p.payment_date < @to
+			UNION ALL
+			SELECT NULLIF(r.recorded_by, 0), r.method, r.currency, 0, 0, 1, r.amount
+			FROM refunds r
+			WHERE r.voided_at IS NULL AND r.status = 'confirmed' AND r.refunded_at >= @from AND r.refunded_at < @to
+				AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.id = r.payment_id AND p.intent_id IS NOT NULL)
+		) t
+		LEFT JOIN users u ON u.id = t.cashier_id
+		WHERE @cashier = 0 OR t.cashier_id = @cashier
+		GROUP BY t.cashier_id, u.username, t.method, t.currency
+		ORDER BY cashier, t.method, t.currency`,
+		map[string]interface{}{"from": from, "to": to, "cashier": cashierID}).Scan(&rows).Error
+	if err != nil {
+		log.Println("Error building cash-up report:", err)
+		return nil, err
+	}
+	return rows, nil
+}
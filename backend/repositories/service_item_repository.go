@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+var (
+	ErrServiceCodeExists   = errors.New("a service with this code already exists")
+	ErrUnknownServiceItem  = errors.New("service item not found in the price catalogue")
+	ErrServiceItemInactive = errors.New("service item is no longer offered")
+)
+
+// CreateServiceItem adds a service to the price catalogue
+func CreateServiceItem(item *models.ServiceItem) error {
+	var count int64
+	if err := config.GormDB.Model(&models.ServiceItem{}).Where("code = ?", item.Code).Count(&count).Error; err != nil {
+		log.Println("Error checking service code:", err)
+		return err
+	}
+	if count > 0 {
+		return ErrServiceCodeExists
+	}
+	if err := config.GormDB.Create(item).Error; err != nil {
+		log.Println("Error creating service item:", err)
+		return err
+	}
+	return nil
+}
+
+// GetServiceItems lists the catalogue by code, optionally only active items of a category
+func GetServiceItems(activeOnly bool, category string) ([]models.ServiceItem, error) {
+	var list []models.ServiceItem
+	q := config.GormDB.Order("code")
+	if activeOnly {
+		q = q.Where("active = ?", true)
+	}
+	if category != "" {
+		q = q.Where("category = ?", category)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching service items:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetServiceItemByID retrieves a catalogue item by ID
+func GetServiceItemByID(id int) (models.ServiceItem, error) {
+	var item models.ServiceItem
+	if err := config.GormDB.First(&item, id).Error; err != nil {
+		log.Println("Error fetching service item:", err)
+		return item, err
+	}
+	return item, nil
+}
+
+// UpdateServiceItem saves a catalogue item. Invoice lines keep the price they were billed at.
+func UpdateServiceItem(item *models.ServiceItem) error {
+	var count int64
+	if err := config.GormDB.Model(&models.ServiceItem{}).Where("code = ? AND id <> ?", item.Code, item.ID).Count(&count).Error; err != nil {
+		log.Println("Error checking service code:", err)
+		return err
+	}
+	if count > 0 {
+		return ErrServiceCodeExists
+	}
+	if err := config.GormDB.Save(item).Error; err != nil {
+		log.Println("Error updating service item:", err)
+		return err
+	}
+	return nil
+}
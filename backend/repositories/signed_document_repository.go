@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDocumentSigned = errors.New("document has already been signed")
+
+// CreateFormTemplate inserts a form template
+func CreateFormTemplate(t *models.FormTemplate) error {
+	if err := config.GormDB.Create(t).Error; err != nil {
+		log.Println("Error creating form template:", err)
+		return err
+	}
+	return nil
+}
+
+// GetFormTemplates lists form templates, optionally only the active ones
+func GetFormTemplates(activeOnly bool) ([]models.FormTemplate, error) {
+	var list []models.FormTemplate
+	q := config.GormDB.Order("name")
+	if activeOnly {
+		q = q.Where("active = ?", true)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		log.Println("Error fetching form templates:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetFormTemplateByID retrieves a form template by ID
+func GetFormTemplateByID(id int) (models.FormTemplate, error) {
+	var t models.FormTemplate
+	if err := config.GormDB.First(&t, id).Error; err != nil {
+		log.Println("Error fetching form template:", err)
+		return t, err
+	}
+	return t, nil
+}
+
+// UpdateFormTemplate saves a form template. Documents already generated keep their own copy of the text.
+func UpdateFormTemplate(t *models.FormTemplate) error {
+	if err := config.GormDB.Save(t).Error; err != nil {
+		log.Println("Error updating form template:", err)
+		return err
+	}
+	return nil
+}
+
+// CreateSignedDocument stores a generated, not yet signed document
+func CreateSignedDocument(doc *models.SignedDocument) error {
+	if err := config.GormDB.Create(doc).Error; err != nil {
+		log.Println("Error creating document:", err)
+		return err
+	}
+	return nil
+}
+
+// GetSignedDocumentByID retrieves a document by ID
+func GetSignedDocumentByID(id int) (models.SignedDocument, error) {
+	var doc models.SignedDocument
+	if err := config.GormDB.First(&doc, id).Error; err != nil {
+		log.Println("Error fetching document:", err)
+		return doc, err
+	}
+	return doc, nil
+}
+
+// GetSignedDocumentsByPatientID lists a patient's documents, newest first
+func GetSignedDocumentsByPatientID(patientID int) ([]models.SignedDocument, error) {
+	var list []models.SignedDocument
+	if err := config.GormDB.Where("patient_id = ?", patientID).
+		Order("created_at DESC").
+		Find(&list).Error; err != nil {
+		log.Println("Error fetching documents by patient ID:", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+// SignDocument stores the signature of a pending document together with the rendered PDF
+// and, when the template grants one, the consent record. doc carries the signature fields.
+func SignDocument(doc *models.SignedDocument, file *models.File, consent *models.Consent) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var existing models.SignedDocument
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&existing, doc.ID).Error; err != nil {
+			return err
+		}
+		if existing.Status != "pending" {
+			return ErrDocumentSigned
+		}
+
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		doc.FileID = &file.ID
+		if consent != nil {
+			consent.FileID = &file.ID
+			if err := tx.Create(consent).Error; err != nil {
+				return err
+			}
+			doc.ConsentID = &consent.ID
+		}
+
+		doc.Status = "signed"
+		return tx.Model(&models.SignedDocument{}).Where("id = ?", doc.ID).
+			Select("status", "signer_name", "signer_relationship", "witness_name", "signature_type",
+				"typed_signature", "signature_image", "signed_at", "signed_by_user_id", "signer_ip",
+				"content_hash", "file_hash", "file_id", "consent_id").
+			Updates(doc).Error
+	})
+	if err != nil {
+		log.Println("Error signing document:", err)
+	}
+	return err
+}
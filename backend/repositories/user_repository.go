@@ -1,10 +1,19 @@
 package repositories
 
 import (
+	"errors"
 	"log"
 
 	"github.com/samichen99/HAP-hospital-management-system/config"
 	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrIdentityNotVerified      = errors.New("identity could not be verified")
+	ErrPatientAlreadyRegistered = errors.New("patient already has a portal account")
+	ErrUserExists               = errors.New("username or email is already in use")
 )
 
 // CreateUser
@@ -66,3 +75,46 @@ func GetUserByEmail(email string) (models.User, error) {
 	}
 	return user, nil
 }
+
+// RegisterPatientUser creates a portal account for the patient identified by MRN and date of
+// birth. Temporary and merged records cannot be registered. Username and email are checked
+// first, so that ErrUserExists says nothing about the identity.
+func RegisterPatientUser(user *models.User, mrn, dateOfBirth string) error {
+	err := config.GormDB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).
+			Where("email = ? OR username = ?", user.Email, user.Username).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+
+		var patient models.Patient
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("mrn = ? AND date_of_birth = ? AND NOT is_temporary AND merged_into_id IS NULL", mrn, dateOfBirth).
+			First(&patient).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrIdentityNotVerified
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("patient_id = ?", patient.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPatientAlreadyRegistered
+		}
+
+		user.Role = "patient"
+		user.PatientID = &patient.ID
+		return tx.Create(user).Error
+	})
+	if err != nil {
+		log.Println("Error registering patient user:", err)
+	}
+	return err
+}
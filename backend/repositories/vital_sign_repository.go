@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"log"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// CreateVitalSign inserts a new set of vital signs
+func CreateVitalSign(v *models.VitalSign) error {
+	if err := config.GormDB.Create(v).Error; err != nil {
+		log.Println("Error creating vital sign:", err)
+		return err
+	}
+	return nil
+}
+
+// GetVitalSignsByPatientID retrieves a patient's vital signs, optionally limited to one admission
+func GetVitalSignsByPatientID(patientID, admissionID int) ([]models.VitalSign, error) {
+	var vitals []models.VitalSign
+	q := config.GormDB.Where("patient_id = ?", patientID)
+	if admissionID != 0 {
+		q = q.Where("admission_id = ?", admissionID)
+	}
+	if err := q.Order("recorded_at").Find(&vitals).Error; err != nil {
+		log.Println("Error fetching vital signs:", err)
+		return nil, err
+	}
+	return vitals, nil
+}
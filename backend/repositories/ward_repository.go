@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"errors"
+	"log"
+
+	"github.com/samichen99/HAP-hospital-management-system/config"
+	"github.com/samichen99/HAP-hospital-management-system/models"
+	"gorm.io/gorm"
+)
+
+// CreateWard inserts a new ward
+func CreateWard(ward *models.Ward) error {
+	if err := config.GormDB.Create(ward).Error; err != nil {
+		log.Println("Error creating ward:", err)
+		return err
+	}
+	return nil
+}
+
+// GetAllWards retrieves wards with their rooms and beds
+func GetAllWards() ([]models.Ward, error) {
+	var wards []models.Ward
+	if err := config.GormDB.
+		Preload("Rooms", func(db *gorm.DB) *gorm.DB { return db.Order("number") }).
+		Preload("Rooms.Beds", func(db *gorm.DB) *gorm.DB { return db.Order("label") }).
+		Order("name").
+		Find(&wards).Error; err != nil {
+		log.Println("Error fetching wards:", err)
+		return nil, err
+	}
+	return wards, nil
+}
+
+// CreateRoom inserts a new room in a ward
+func CreateRoom(room *models.Room) error {
+	if err := config.GormDB.Create(room).Error; err != nil {
+		log.Println("Error creating room:", err)
+		return err
+	}
+	return nil
+}
+
+// CreateBed inserts a new bed; the ward is taken from the room
+func CreateBed(bed *models.Bed) error {
+	var room models.Room
+	if err := config.GormDB.First(&room, bed.RoomID).Error; err != nil {
+		log.Println("Error fetching room for bed:", err)
+		return err
+	}
+	bed.WardID = room.WardID
+	if bed.Status == "" {
+		bed.Status = "free"
+	}
+	if err := config.GormDB.Create(bed).Error; err != nil {
+		log.Println("Error creating bed:", err)
+		return err
+	}
+	return nil
+}
+
+// GetBeds lists beds, optionally filtered by ward and status
+func GetBeds(wardID int, status string) ([]models.Bed, error) {
+	var beds []models.Bed
+	q := config.GormDB.Order("ward_id, room_id, label")
+	if wardID != 0 {
+		q = q.Where("ward_id = ?", wardID)
+	}
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Find(&beds).Error; err != nil {
+		log.Println("Error fetching beds:", err)
+		return nil, err
+	}
+	return beds, nil
+}
+
+// SetBedStatus changes housekeeping status (free, cleaning, blocked) of a bed that is not occupied
+func SetBedStatus(id int, status string) error {
+	result := config.GormDB.Model(&models.Bed{}).
+		Where("id = ? AND status <> ?", id, "occupied").
+		Update("status", status)
+	if result.Error != nil {
+		log.Println("Error updating bed status:", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBedNotAvailable
+	}
+	return nil
+}
+
+// ErrBedNotAvailable is returned when a bed is occupied, blocked or being cleaned
+var ErrBedNotAvailable = errors.New("bed is not available")
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// TrustedProxies parses TRUSTED_PROXIES, a comma-separated list of IP addresses or CIDR ranges
+// of the reverse proxies in front of the server. Invalid entries are ignored.
+func TrustedProxies() []*net.IPNet {
+	return ParseIPNets(os.Getenv("TRUSTED_PROXIES"))
+}
+
+// ParseIPNets parses a comma-separated list of IP addresses or CIDR ranges. Single addresses
+// become /32 or /128 ranges; invalid entries are ignored.
+func ParseIPNets(list string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, n, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+// RemoteAddrAllowed reports whether the host of a "host:port" address lies in one of nets
+func RemoteAddrAllowed(remoteAddr string, nets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return isTrustedProxy(net.ParseIP(host), nets)
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the caller of a request received from remoteAddr.
+// X-Forwarded-For is only honoured when the request came through a trusted proxy; it is then
+// read from the right, skipping the trusted proxies, so a client cannot spoof its address by
+// sending the header itself.
+func ClientIP(remoteAddr, forwardedFor string, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if forwardedFor == "" || !isTrustedProxy(net.ParseIP(host), trusted) {
+		return host
+	}
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			break
+		}
+		host = hop
+		if !isTrustedProxy(ip, trusted) {
+			break
+		}
+	}
+	return host
+}
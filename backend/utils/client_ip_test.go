@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+	cases := []struct {
+		name, remote, forwarded, want string
+	}{
+		{"direct", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"spoofed header from an untrusted caller", "203.0.113.5:4000", "198.51.100.1", "203.0.113.5"},
+		{"through the proxy", "10.0.0.2:4000", "198.51.100.1", "198.51.100.1"},
+		{"client-supplied hop before the proxy's", "10.0.0.2:4000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chained proxies", "10.0.0.2:4000", "198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"garbage hop", "10.0.0.2:4000", "not-an-ip", "10.0.0.2"},
+	}
+	for _, c := range cases {
+		if got := ClientIP(c.remote, c.forwarded, trusted); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRemoteAddrAllowed(t *testing.T) {
+	allowed := ParseIPNets("192.0.2.10, 10.1.0.0/16, not-an-ip")
+	if len(allowed) != 2 {
+		t.Fatalf("expected 2 valid entries, got %d", len(allowed))
+	}
+	cases := map[string]bool{
+		"192.0.2.10:5000":  true,
+		"10.1.200.3:5000":  true,
+		"192.0.2.11:5000":  false,
+		"203.0.113.5:5000": false,
+		"garbage":          false,
+	}
+	for addr, want := range cases {
+		if got := RemoteAddrAllowed(addr, allowed); got != want {
+			t.Errorf("%s: got %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+var ErrInvalidCoverage = errors.New("co_pay must not be negative and co_insurance must be between 0 and 100")
+
+// ValidateCoverageRule checks the cost sharing of a coverage rule
+func ValidateCoverageRule(rule models.CoverageRule) error {
+	if rule.CoPay < 0 || rule.CoInsurance < 0 || rule.CoInsurance > 100 {
+		return ErrInvalidCoverage
+	}
+	return nil
+}
+
+// SplitCharge divides the total of a single-line claim between payer and patient: the patient
+// pays the co-pay (at most the whole charge) and the co-insurance share of what is left, the
+// payer the rest. Without a rule, or when the rule excludes the service, the patient pays everything.
+func SplitCharge(charged models.Money, rule *models.CoverageRule) (payer, patient models.Money) {
+	payer, patient, _ = splitCharge(charged, rule, coPayOf(rule))
+	return payer, patient
+}
+
+// SplitCharges splits every line of a claim like SplitCharge, but charges each rule's co-pay
+// once per claim: it is taken from the first lines the rule covers until it is paid.
+func SplitCharges(charged []models.Money, rules []*models.CoverageRule) (payer, patient []models.Money) {
+	payer = make([]models.Money, len(charged))
+	patient = make([]models.Money, len(charged))
+	due := make(map[*models.CoverageRule]models.Money)
+	for i, c := range charged {
+		rule := rules[i]
+		if _, seen := due[rule]; !seen {
+			due[rule] = coPayOf(rule)
+		}
+		var coPay models.Money
+		payer[i], patient[i], coPay = splitCharge(c, rule, due[rule])
+		due[rule] -= coPay
+	}
+	return payer, patient
+}
+
+func coPayOf(rule *models.CoverageRule) models.Money {
+	if rule == nil {
+		return 0
+	}
+	return rule.CoPay
+}
+
+// splitCharge splits one line with coPayDue still owed under the rule; it also returns the
+// part of the co-pay the line took
+func splitCharge(charged models.Money, rule *models.CoverageRule, coPayDue models.Money) (payer, patient, coPay models.Money) {
+	if rule == nil || !rule.Covered || charged <= 0 {
+		return 0, charged, 0
+	}
+	coPay = coPayDue
+	if coPay > charged {
+		coPay = charged
+	}
+	if coPay < 0 {
+		coPay = 0
+	}
+	patient = coPay + (charged - coPay).Percent(rule.CoInsurance)
+	return charged - patient, patient, coPay
+}
+
+// MatchCoverageRule picks the rule for a service category: an exact category match wins over
+// the payer's catch-all rule (empty category). It returns nil when neither exists.
+func MatchCoverageRule(rules []models.CoverageRule, category string) *models.CoverageRule {
+	var fallback *models.CoverageRule
+	for i := range rules {
+		switch rules[i].ServiceCategory {
+		case category:
+			if category != "" {
+				return &rules[i]
+			}
+			fallback = &rules[i]
+		case "":
+			fallback = &rules[i]
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+func TestSplitCharge(t *testing.T) {
+	cases := []struct {
+		name           string
+		charged        models.Money
+		rule           *models.CoverageRule
+		payer, patient models.Money
+	}{
+		{"no rule", 10000, nil, 0, 10000},
+		{"not covered", 10000, &models.CoverageRule{Covered: false}, 0, 10000},
+		{"full cover", 10000, &models.CoverageRule{Covered: true}, 10000, 0},
+		{"co-pay and co-insurance", 10000, &models.CoverageRule{Covered: true, CoPay: 2000, CoInsurance: 20}, 6400, 3600},
+		{"co-pay above charge", 1500, &models.CoverageRule{Covered: true, CoPay: 2000, CoInsurance: 20}, 0, 1500},
+		{"rounding", 999, &models.CoverageRule{Covered: true, CoInsurance: 15}, 849, 150},
+	}
+	for _, c := range cases {
+		payer, patient := SplitCharge(c.charged, c.rule)
+		if payer != c.payer || patient != c.patient {
+			t.Errorf("%s: got payer %d patient %d, want %d and %d", c.name, payer, patient, c.payer, c.patient)
+		}
+		if payer+patient != c.charged {
+			t.Errorf("%s: split does not add up to the charge", c.name)
+		}
+	}
+}
+
+func TestSplitChargesCoPayOncePerClaim(t *testing.T) {
+	lab := &models.CoverageRule{Covered: true, CoPay: 2000, CoInsurance: 20}
+	imaging := &models.CoverageRule{Covered: true, CoPay: 5000}
+	charged := []models.Money{1500, 10000, 10000, 8000}
+	rules := []*models.CoverageRule{lab, lab, lab, imaging}
+
+	payer, patient := SplitCharges(charged, rules)
+	// the lab co-pay is 15.00 from the first line and 5.00 from the second, none from the third
+	wantPatient := []models.Money{1500, 500 + 1900, 2000, 5000}
+	for i := range charged {
+		if patient[i] != wantPatient[i] || payer[i]+patient[i] != charged[i] {
+			t.Errorf("line %d: got payer %d patient %d, want patient %d", i, payer[i], patient[i], wantPatient[i])
+		}
+	}
+}
+
+func TestMatchCoverageRule(t *testing.T) {
+	rules := []models.CoverageRule{
+		{ID: 1, ServiceCategory: ""},
+		{ID: 2, ServiceCategory: "laboratory"},
+	}
+	if r := MatchCoverageRule(rules, "laboratory"); r == nil || r.ID != 2 {
+		t.Errorf("expected the laboratory rule, got %+v", r)
+	}
+	if r := MatchCoverageRule(rules, "imaging"); r == nil || r.ID != 1 {
+		t.Errorf("expected the catch-all rule, got %+v", r)
+	}
+	if r := MatchCoverageRule(rules[1:], "imaging"); r != nil {
+		t.Errorf("expected no rule, got %+v", r)
+	}
+}
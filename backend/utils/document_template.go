@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// DocumentTemplate holds the configurable wording and layout of a generated financial document
+type DocumentTemplate struct {
+	Title      string `json:"title"`
+	Intro      string `json:"intro"`
+	Footer     string `json:"footer"`
+	ShowTax    bool   `json:"show_tax"`
+	DateFormat string `json:"date_format"`
+}
+
+var defaultDocumentTemplates = map[string]DocumentTemplate{
+	"invoice": {Title: "Invoice", Footer: "Please quote the invoice number with your payment.", ShowTax: true, DateFormat: "2006-01-02"},
+	"receipt": {Title: "Payment receipt", Footer: "Thank you for your payment.", DateFormat: "2006-01-02 15:04"},
+}
+
+// LoadDocumentTemplate returns the template of a document kind ("invoice", "receipt").
+// DOCUMENT_TEMPLATES_FILE may point at a JSON object keyed by kind; fields it leaves
+// empty keep their defaults.
+func LoadDocumentTemplate(kind string) (DocumentTemplate, error) {
+	tmpl := defaultDocumentTemplates[kind]
+	path := os.Getenv("DOCUMENT_TEMPLATES_FILE")
+	if path == "" {
+		return tmpl, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tmpl, err
+	}
+	var custom map[string]json.RawMessage
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return tmpl, err
+	}
+	if raw, ok := custom[kind]; ok {
+		// decoding over the defaults only replaces the fields that are present
+		if err := json.Unmarshal(raw, &tmpl); err != nil {
+			return tmpl, err
+		}
+	}
+	return tmpl, nil
+}
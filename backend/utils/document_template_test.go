@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDocumentTemplateDefaults(t *testing.T) {
+	t.Setenv("DOCUMENT_TEMPLATES_FILE", "")
+	tmpl, err := LoadDocumentTemplate("invoice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Title != "Invoice" || !tmpl.ShowTax {
+		t.Errorf("unexpected default template %+v", tmpl)
+	}
+}
+
+func TestLoadDocumentTemplateOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	if err := os.WriteFile(path, []byte(`{"invoice": {"title": "Tax invoice", "show_tax": false}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCUMENT_TEMPLATES_FILE", path)
+
+	tmpl, err := LoadDocumentTemplate("invoice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Title != "Tax invoice" || tmpl.ShowTax || tmpl.DateFormat != "2006-01-02" {
+		t.Errorf("overrides not merged with defaults: %+v", tmpl)
+	}
+	receipt, err := LoadDocumentTemplate("receipt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Title != "Payment receipt" {
+		t.Errorf("receipt should keep its defaults, got %+v", receipt)
+	}
+}
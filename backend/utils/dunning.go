@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// DunningPolicy decides when overdue invoices are reminded, charged a late fee and handed to collections
+type DunningPolicy struct {
+	ReminderDays         []int        `json:"reminder_days"` // days past due, ascending
+	LateFee              models.Money `json:"late_fee"`
+	LateFeePercent       float64      `json:"late_fee_percent"` // of the balance due
+	LateFeeAfterDays     int          `json:"late_fee_after_days"`
+	CollectionsAfterDays int          `json:"collections_after_days"` // 0 never escalates
+}
+
+// LoadDunningPolicy reads the dunning policy from DUNNING_* environment variables.
+// DUNNING_REMINDER_DAYS is a comma separated list such as "7,30,60".
+func LoadDunningPolicy() DunningPolicy {
+	p := DunningPolicy{
+		ReminderDays:         ParseReminderDays(os.Getenv("DUNNING_REMINDER_DAYS")),
+		LateFeeAfterDays:     envInt("DUNNING_LATE_FEE_AFTER_DAYS", 30),
+		CollectionsAfterDays: envInt("DUNNING_COLLECTIONS_AFTER_DAYS", 90),
+	}
+	if fee, err := models.ParseMoney(os.Getenv("DUNNING_LATE_FEE")); err == nil && fee > 0 {
+		p.LateFee = fee
+	}
+	if pct, err := strconv.ParseFloat(os.Getenv("DUNNING_LATE_FEE_PERCENT"), 64); err == nil && pct > 0 && pct <= 100 {
+		p.LateFeePercent = pct
+	}
+	return p
+}
+
+// ParseReminderDays parses a reminder schedule, falling back to 7, 30 and 60 days when it is
+// empty or invalid
+func ParseReminderDays(s string) []int {
+	var days []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := strconv.Atoi(part)
+		if err != nil || d < 0 {
+			return []int{7, 30, 60}
+		}
+		if !seen[d] {
+			seen[d] = true
+			days = append(days, d)
+		}
+	}
+	if len(days) == 0 {
+		return []int{7, 30, 60}
+	}
+	sort.Ints(days)
+	return days
+}
+
+// DaysOverdue counts the calendar days from the due date to now; negative when not due yet
+func DaysOverdue(due, now time.Time) int {
+	d := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+	n := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(n.Sub(d).Hours() / 24)
+}
+
+// ReminderStage is the reminder (1-based) an invoice should get now, or 0 when the reminder
+// for the current stage was already sent. When several stages were passed at once only the
+// latest is sent.
+func (p DunningPolicy) ReminderStage(daysOverdue, sentStage int) int {
+	stage := 0
+	for _, d := range p.ReminderDays {
+		if daysOverdue >= d {
+			stage++
+		}
+	}
+	if stage > sentStage {
+		return stage
+	}
+	return 0
+}
+
+// LateFeeFor is the one-off late fee of an invoice with the given balance, or 0 when no fee
+// is configured, it was already charged or the invoice is not late enough
+func (p DunningPolicy) LateFeeFor(balance models.Money, daysOverdue int, charged bool) models.Money {
+	if charged || balance <= 0 || daysOverdue < p.LateFeeAfterDays {
+		return 0
+	}
+	return p.LateFee + balance.Percent(p.LateFeePercent)
+}
+
+// Escalates reports whether an invoice this far overdue goes to collections
+func (p DunningPolicy) Escalates(daysOverdue int) bool {
+	return p.CollectionsAfterDays > 0 && daysOverdue >= p.CollectionsAfterDays
+}
+
+// DunningNotice holds the wording of a reminder or collections notice
+type DunningNotice struct {
+	Subject string
+	Body    string
+}
+
+// ComposeDunningNotice writes the notice for reminder stage (or, with stage 0, the hand-over
+// to collections) of an invoice
+func ComposeDunningNotice(stage int, recipient, reference string, balance, lateFee string, due time.Time) DunningNotice {
+	greeting := "Dear patient,"
+	if recipient != "" {
+		greeting = "Dear " + recipient + ","
+	}
+	var n DunningNotice
+	var b strings.Builder
+	b.WriteString(greeting + "\n\n")
+	switch {
+	case stage == 0:
+		n.Subject = "Final notice: invoice " + reference + " passed to collections"
+		fmt.Fprintf(&b, "Invoice %s, due on %s, remains unpaid despite our reminders. The outstanding balance of %s has been passed to our collections department.\n",
+			reference, due.Format("2006-01-02"), balance)
+	case stage == 1:
+		n.Subject = "Payment reminder: invoice " + reference
+		fmt.Fprintf(&b, "Our records show that invoice %s, due on %s, has an outstanding balance of %s. If you have already paid, please disregard this reminder.\n",
+			reference, due.Format("2006-01-02"), balance)
+	default:
+		n.Subject = fmt.Sprintf("Reminder %d: invoice %s is overdue", stage, reference)
+		fmt.Fprintf(&b, "Invoice %s was due on %s and still has an outstanding balance of %s. Please pay it as soon as possible to avoid further action.\n",
+			reference, due.Format("2006-01-02"), balance)
+	}
+	if lateFee != "" {
+		fmt.Fprintf(&b, "A late payment fee of %s has been charged on a separate invoice.\n", lateFee)
+	}
+	b.WriteString("\nPlease quote the invoice number with your payment.\n")
+	n.Body = b.String()
+	return n
+}
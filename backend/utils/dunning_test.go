@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReminderDays(t *testing.T) {
+	cases := map[string][]int{
+		"":          {7, 30, 60},
+		"14, 45":    {14, 45},
+		"60,7,30,7": {7, 30, 60},
+		"7,soon":    {7, 30, 60},
+		"-1":        {7, 30, 60},
+	}
+	for in, want := range cases {
+		if got := ParseReminderDays(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseReminderDays(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestDaysOverdue(t *testing.T) {
+	due := time.Date(2025, 3, 1, 23, 0, 0, 0, time.UTC)
+	if got := DaysOverdue(due, time.Date(2025, 3, 8, 1, 0, 0, 0, time.UTC)); got != 7 {
+		t.Errorf("expected 7 days overdue, got %d", got)
+	}
+	if got := DaysOverdue(due, time.Date(2025, 2, 27, 12, 0, 0, 0, time.UTC)); got != -2 {
+		t.Errorf("expected -2 days overdue, got %d", got)
+	}
+}
+
+func TestReminderStage(t *testing.T) {
+	p := DunningPolicy{ReminderDays: []int{7, 30, 60}}
+	cases := []struct{ days, sent, want int }{
+		{3, 0, 0},
+		{7, 0, 1},
+		{10, 1, 0},
+		{30, 1, 2},
+		{75, 0, 3}, // job missed the earlier stages
+		{90, 3, 0},
+	}
+	for _, c := range cases {
+		if got := p.ReminderStage(c.days, c.sent); got != c.want {
+			t.Errorf("ReminderStage(%d, %d) = %d, want %d", c.days, c.sent, got, c.want)
+		}
+	}
+}
+
+func TestLateFeeFor(t *testing.T) {
+	p := DunningPolicy{LateFee: 1500, LateFeePercent: 2, LateFeeAfterDays: 30}
+	if got := p.LateFeeFor(10000, 30, false); got != 1700 {
+		t.Errorf("expected 17.00, got %s", got)
+	}
+	if got := p.LateFeeFor(10000, 29, false); got != 0 {
+		t.Errorf("expected no fee before the threshold, got %s", got)
+	}
+	if got := p.LateFeeFor(10000, 45, true); got != 0 {
+		t.Errorf("expected the fee to be charged once, got %s", got)
+	}
+	if got := (DunningPolicy{}).LateFeeFor(10000, 45, false); got != 0 {
+		t.Errorf("expected no fee without a configured fee, got %s", got)
+	}
+}
+
+func TestEscalates(t *testing.T) {
+	p := DunningPolicy{CollectionsAfterDays: 90}
+	if p.Escalates(89) || !p.Escalates(90) {
+		t.Error("expected escalation from day 90")
+	}
+	if (DunningPolicy{}).Escalates(365) {
+		t.Error("expected no escalation when disabled")
+	}
+}
+
+func TestComposeDunningNotice(t *testing.T) {
+	due := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	n := ComposeDunningNotice(2, "Jane Doe", "INV-2025-000007", "USD 120.00", "USD 15.00", due)
+	if !strings.Contains(n.Subject, "Reminder 2") || !strings.Contains(n.Body, "Dear Jane Doe,") ||
+		!strings.Contains(n.Body, "USD 120.00") || !strings.Contains(n.Body, "late payment fee of USD 15.00") {
+		t.Errorf("unexpected notice: %+v", n)
+	}
+	if n := ComposeDunningNotice(0, "", "INV-2025-000007", "USD 120.00", "", due); !strings.Contains(n.Subject, "collections") {
+		t.Errorf("unexpected collections notice: %+v", n)
+	}
+}
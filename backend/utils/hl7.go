@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MLLP framing bytes used by HL7 v2 over TCP
+const (
+	MLLPStartBlock byte = 0x0b
+	MLLPEndBlock   byte = 0x1c
+	MLLPCarriage   byte = 0x0d
+)
+
+// HL7Segment is a single parsed segment (MSH, PID, OBX ...)
+type HL7Segment struct {
+	Name   string
+	Fields []string
+}
+
+// HL7Message holds the segments of a parsed HL7 v2 message
+type HL7Message struct {
+	Segments          []HL7Segment
+	FieldSeparator    string
+	ComponentSep      string
+	RepetitionSep     string
+	MessageType       string
+	TriggerEvent      string
+	MessageControlID  string
+	SendingApp        string
+	SendingFacility   string
+	ReceivingApp      string
+	ReceivingFacility string
+	ProcessingID      string
+	Version           string
+}
+
+// ParseHL7 parses a pipe-delimited HL7 v2 message. Segments may be separated by CR, LF or CRLF.
+func ParseHL7(raw string) (*HL7Message, error) {
+	raw = strings.Trim(raw, "\x0b\x1c\r\n ")
+	if !strings.HasPrefix(raw, "MSH") || len(raw) < 8 {
+		return nil, errors.New("message must start with an MSH segment")
+	}
+
+	msg := &HL7Message{
+		FieldSeparator: string(raw[3]),
+		ComponentSep:   string(raw[4]),
+		RepetitionSep:  string(raw[5]),
+	}
+
+	lines := strings.FieldsFunc(raw, func(r rune) bool { return r == '\r' || r == '\n' })
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, msg.FieldSeparator)
+		seg := HL7Segment{Name: fields[0]}
+		if seg.Name == "MSH" {
+			// MSH-1 is the field separator itself, so shift the fields to keep HL7 numbering
+			seg.Fields = append([]string{fields[0], msg.FieldSeparator}, fields[1:]...)
+		} else {
+			seg.Fields = fields
+		}
+		msg.Segments = append(msg.Segments, seg)
+	}
+
+	msh := msg.Segment("MSH")
+	msg.SendingApp = msh.Field(3)
+	msg.SendingFacility = msh.Field(4)
+	msg.ReceivingApp = msh.Field(5)
+	msg.ReceivingFacility = msh.Field(6)
+	msg.MessageType = msg.Component(msh.Field(9), 1)
+	msg.TriggerEvent = msg.Component(msh.Field(9), 2)
+	msg.MessageControlID = msh.Field(10)
+	msg.ProcessingID = msh.Field(11)
+	msg.Version = msh.Field(12)
+
+	if msg.MessageType == "" {
+		return nil, errors.New("MSH-9 message type is missing")
+	}
+	return msg, nil
+}
+
+// Segment returns the first segment with the given name, or an empty segment
+func (m *HL7Message) Segment(name string) HL7Segment {
+	for _, s := range m.Segments {
+		if s.Name == name {
+			return s
+		}
+	}
+	return HL7Segment{Name: name}
+}
+
+// SegmentsNamed returns every segment with the given name in message order
+func (m *HL7Message) SegmentsNamed(name string) []HL7Segment {
+	var out []HL7Segment
+	for _, s := range m.Segments {
+		if s.Name == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Component returns the 1-based component of a field, e.g. family name from PID-5
+func (m *HL7Message) Component(field string, idx int) string {
+	if field == "" || idx < 1 {
+		return ""
+	}
+	// only the first repetition is considered
+	field = strings.SplitN(field, m.RepetitionSep, 2)[0]
+	parts := strings.Split(field, m.ComponentSep)
+	if idx > len(parts) {
+		return ""
+	}
+	return parts[idx-1]
+}
+
+// Field returns the 1-based field of a segment (HL7 numbering), or "" when absent
+func (s HL7Segment) Field(idx int) string {
+	if idx < 1 || idx >= len(s.Fields) {
+		return ""
+	}
+	return s.Fields[idx]
+}
+
+// ParseHL7Time parses HL7 DTM values (YYYY[MM[DD[HH[MM[SS]]]]] with optional offset)
+func ParseHL7Time(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if i := strings.IndexAny(v, "+-"); i > 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "."); i > 0 {
+		v = v[:i]
+	}
+	layouts := map[int]string{
+		4:  "2006",
+		6:  "200601",
+		8:  "20060102",
+		10: "2006010215",
+		12: "200601021504",
+		14: "20060102150405",
+	}
+	layout, ok := layouts[len(v)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid HL7 timestamp %q", v)
+	}
+	return time.Parse(layout, v)
+}
+
+// BuildHL7Ack builds an ACK for the given message. code is AA, AE or AR.
+func BuildHL7Ack(msg *HL7Message, code, text string) string {
+	fs, cs := "|", "^"
+	controlID, trigger, version := "", "", "2.5"
+	sendingApp, sendingFacility, receivingApp, receivingFacility := "", "", "", ""
+	processingID := "P"
+	if msg != nil {
+		fs, cs = msg.FieldSeparator, msg.ComponentSep
+		controlID, trigger = msg.MessageControlID, msg.TriggerEvent
+		sendingApp, sendingFacility = msg.SendingApp, msg.SendingFacility
+		receivingApp, receivingFacility = msg.ReceivingApp, msg.ReceivingFacility
+		if msg.Version != "" {
+			version = msg.Version
+		}
+		if msg.ProcessingID != "" {
+			processingID = msg.ProcessingID
+		}
+	}
+
+	now := time.Now().Format("20060102150405")
+	msh := strings.Join([]string{
+		"MSH", cs + "~\\&", receivingApp, receivingFacility, sendingApp, sendingFacility,
+		now, "", "ACK" + cs + trigger + cs + "ACK", "ACK" + now, processingID, version,
+	}, fs)
+	msa := strings.Join([]string{"MSA", code, controlID, sanitizeHL7Text(text, fs)}, fs)
+	return msh + "\r" + msa + "\r"
+}
+
+// WrapMLLP frames a payload for MLLP transport
+func WrapMLLP(payload string) []byte {
+	out := make([]byte, 0, len(payload)+3)
+	out = append(out, MLLPStartBlock)
+	out = append(out, payload...)
+	return append(out, MLLPEndBlock, MLLPCarriage)
+}
+
+func sanitizeHL7Text(text, fs string) string {
+	text = strings.ReplaceAll(text, fs, " ")
+	text = strings.ReplaceAll(text, "\r", " ")
+	return strings.ReplaceAll(text, "\n", " ")
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleADT = "MSH|^~\\&|REGADT|MCM|HMS|HOSP|20260105120000||ADT^A04^ADT_A01|MSG00001|P|2.5\r" +
+	"EVN|A04|20260105120000\r" +
+	"PID|1||MRN12345^^^MCM^MR||Doe^John^Q||19800214|M|||12 Main St^^Springfield^IL^62701||555-0100\r"
+
+func TestParseHL7(t *testing.T) {
+	msg, err := ParseHL7(sampleADT)
+	if err != nil {
+		t.Fatalf("expected message to parse: %v", err)
+	}
+
+	if msg.MessageType != "ADT" || msg.TriggerEvent != "A04" {
+		t.Fatalf("unexpected type %s^%s", msg.MessageType, msg.TriggerEvent)
+	}
+	if msg.MessageControlID != "MSG00001" {
+		t.Fatalf("unexpected control id %q", msg.MessageControlID)
+	}
+
+	pid := msg.Segment("PID")
+	if got := msg.Component(pid.Field(3), 1); got != "MRN12345" {
+		t.Fatalf("expected PID-3.1 MRN12345, got %q", got)
+	}
+	if got := msg.Component(pid.Field(5), 2); got != "John" {
+		t.Fatalf("expected given name John, got %q", got)
+	}
+	if got := pid.Field(7); got != "19800214" {
+		t.Fatalf("expected PID-7 19800214, got %q", got)
+	}
+}
+
+func TestParseHL7RejectsMissingMSH(t *testing.T) {
+	if _, err := ParseHL7("PID|1||123"); err == nil {
+		t.Fatal("expected an error for a message without MSH")
+	}
+}
+
+func TestBuildHL7Ack(t *testing.T) {
+	msg, err := ParseHL7(sampleADT)
+	if err != nil {
+		t.Fatalf("expected message to parse: %v", err)
+	}
+
+	ack := BuildHL7Ack(msg, "AE", "no patient|matches")
+	if !strings.Contains(ack, "\rMSA|AE|MSG00001|no patient matches\r") {
+		t.Fatalf("unexpected ACK: %q", ack)
+	}
+	if !strings.HasPrefix(ack, "MSH|^~\\&|HMS|HOSP|REGADT|MCM|") {
+		t.Fatalf("expected sender and receiver to be swapped: %q", ack)
+	}
+}
+
+func TestParseHL7Time(t *testing.T) {
+	ts, err := ParseHL7Time("202601051230+0100")
+	if err != nil {
+		t.Fatalf("expected timestamp to parse: %v", err)
+	}
+	if ts.Format("2006-01-02 15:04") != "2026-01-05 12:30" {
+		t.Fatalf("unexpected time %v", ts)
+	}
+}
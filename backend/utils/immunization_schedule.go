@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// DefaultVaccinationSchedule seeds the schedule table on first start; it can be edited afterwards
+var DefaultVaccinationSchedule = []models.VaccinationScheduleEntry{
+	{VaccineCode: "HEPB", VaccineName: "Hepatitis B", DoseNumber: 1, AgeDays: 0, GraceDays: 7},
+	{VaccineCode: "HEPB", VaccineName: "Hepatitis B", DoseNumber: 2, AgeDays: 60, GraceDays: 30},
+	{VaccineCode: "HEPB", VaccineName: "Hepatitis B", DoseNumber: 3, AgeDays: 180, GraceDays: 60},
+	{VaccineCode: "DTAP", VaccineName: "Diphtheria, tetanus, pertussis", DoseNumber: 1, AgeDays: 60, GraceDays: 30},
+	{VaccineCode: "DTAP", VaccineName: "Diphtheria, tetanus, pertussis", DoseNumber: 2, AgeDays: 120, GraceDays: 30},
+	{VaccineCode: "DTAP", VaccineName: "Diphtheria, tetanus, pertussis", DoseNumber: 3, AgeDays: 180, GraceDays: 30},
+	{VaccineCode: "IPV", VaccineName: "Polio", DoseNumber: 1, AgeDays: 60, GraceDays: 30},
+	{VaccineCode: "IPV", VaccineName: "Polio", DoseNumber: 2, AgeDays: 120, GraceDays: 30},
+	{VaccineCode: "MMR", VaccineName: "Measles, mumps, rubella", DoseNumber: 1, AgeDays: 365, GraceDays: 90},
+	{VaccineCode: "MMR", VaccineName: "Measles, mumps, rubella", DoseNumber: 2, AgeDays: 1461, GraceDays: 365},
+}
+
+// DueImmunization is a scheduled dose that has not been given yet
+type DueImmunization struct {
+	VaccineCode string    `json:"vaccine_code"`
+	VaccineName string    `json:"vaccine_name"`
+	DoseNumber  int       `json:"dose_number"`
+	DueDate     time.Time `json:"due_date"`
+	Status      string    `json:"status"` // overdue, due or upcoming
+	DaysOverdue int       `json:"days_overdue,omitempty"`
+}
+
+// ParseDateOfBirth parses the DateOfBirth string stored on patients
+func ParseDateOfBirth(dob string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, dob); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date of birth: " + dob)
+}
+
+// ComputeDueImmunizations compares the schedule with the doses already given and returns
+// overdue and due doses plus upcoming doses within horizonDays of now.
+// A dose counts as given when the patient has at least that many doses of the vaccine.
+func ComputeDueImmunizations(dob time.Time, schedule []models.VaccinationScheduleEntry, given []models.Immunization, now time.Time, horizonDays int) []DueImmunization {
+	dosesGiven := map[string]int{}
+	for _, g := range given {
+		dosesGiven[g.VaccineCode]++
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := today.AddDate(0, 0, horizonDays)
+
+	var due []DueImmunization
+	for _, entry := range schedule {
+		if !entry.Active || entry.DoseNumber <= dosesGiven[entry.VaccineCode] {
+			continue
+		}
+
+		dueDate := dob.AddDate(0, 0, entry.AgeDays)
+		item := DueImmunization{
+			VaccineCode: entry.VaccineCode,
+			VaccineName: entry.VaccineName,
+			DoseNumber:  entry.DoseNumber,
+			DueDate:     dueDate,
+		}
+
+		switch {
+		case today.After(dueDate.AddDate(0, 0, entry.GraceDays)):
+			item.Status = "overdue"
+			item.DaysOverdue = int(today.Sub(dueDate).Hours() / 24)
+		case !today.Before(dueDate):
+			item.Status = "due"
+		case !dueDate.After(horizon):
+			item.Status = "upcoming"
+		default:
+			continue
+		}
+		due = append(due, item)
+	}
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].DueDate.Before(due[j].DueDate) })
+	return due
+}
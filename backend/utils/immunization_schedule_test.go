@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+func TestComputeDueImmunizations(t *testing.T) {
+	dob := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 5, 15, 10, 0, 0, 0, time.UTC) // 134 days old
+
+	schedule := []models.VaccinationScheduleEntry{
+		{VaccineCode: "HEPB", DoseNumber: 1, AgeDays: 0, GraceDays: 7, Active: true},
+		{VaccineCode: "HEPB", DoseNumber: 2, AgeDays: 60, GraceDays: 30, Active: true},
+		{VaccineCode: "DTAP", DoseNumber: 2, AgeDays: 120, GraceDays: 30, Active: true},
+		{VaccineCode: "DTAP", DoseNumber: 3, AgeDays: 180, GraceDays: 30, Active: true},
+		{VaccineCode: "MMR", DoseNumber: 1, AgeDays: 365, GraceDays: 90, Active: true},
+		{VaccineCode: "OLD", DoseNumber: 1, AgeDays: 10, GraceDays: 0, Active: false},
+	}
+	given := []models.Immunization{{VaccineCode: "HEPB", DoseNumber: 1}}
+
+	due := ComputeDueImmunizations(dob, schedule, given, now, 60)
+
+	want := map[string]string{"HEPB-2": "overdue", "DTAP-2": "due", "DTAP-3": "upcoming"}
+	if len(due) != len(want) {
+		t.Fatalf("expected %d doses, got %+v", len(want), due)
+	}
+	for _, d := range due {
+		key := d.VaccineCode + "-" + string(rune('0'+d.DoseNumber))
+		if want[key] != d.Status {
+			t.Fatalf("dose %s: expected %q, got %q", key, want[key], d.Status)
+		}
+	}
+	if due[0].VaccineCode != "HEPB" || due[0].DaysOverdue != 74 {
+		t.Fatalf("expected overdue HEPB dose first with 74 days overdue, got %+v", due[0])
+	}
+}
+
+func TestParseDateOfBirth(t *testing.T) {
+	if _, err := ParseDateOfBirth("not-a-date"); err == nil {
+		t.Fatal("expected invalid date to fail")
+	}
+	got, err := ParseDateOfBirth("2020-02-29")
+	if err != nil || got.Day() != 29 {
+		t.Fatalf("unexpected result %v %v", got, err)
+	}
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLocationCode = errors.New("location must be 1-10 letters, digits or dashes")
+
+var (
+	locationCode = regexp.MustCompile(`^[A-Z0-9-]{1,10}$`)
+	numberPrefix = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+)
+
+// InvoiceNumberPrefix is the prefix of legal invoice numbers (INVOICE_NUMBER_PREFIX, default INV)
+func InvoiceNumberPrefix() string {
+	if p := strings.ToUpper(strings.TrimSpace(os.Getenv("INVOICE_NUMBER_PREFIX"))); numberPrefix.MatchString(p) {
+		return p
+	}
+	return "INV"
+}
+
+// FiscalYearStartMonth is the first month of the fiscal year (FISCAL_YEAR_START_MONTH, default January)
+func FiscalYearStartMonth() time.Month {
+	if m := envInt("FISCAL_YEAR_START_MONTH", 1); m >= 1 && m <= 12 {
+		return time.Month(m)
+	}
+	return time.January
+}
+
+// FiscalYear is the calendar year in which the fiscal year containing t started,
+// e.g. 2025 for 2026-02-10 when fiscal years start in April
+func FiscalYear(t time.Time, start time.Month) int {
+	if t.Month() < start {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// NormalizeLocationCode upper-cases the billing location of an invoice; empty means the main site
+func NormalizeLocationCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || locationCode.MatchString(code) {
+		return code, nil
+	}
+	return "", ErrInvalidLocationCode
+}
+
+// InvoiceNumberSeries names the gapless sequence an invoice number is taken from
+func InvoiceNumberSeries(location string, fiscalYear int) string {
+	return fmt.Sprintf("invoice:%s:%d", location, fiscalYear)
+}
+
+// FormatInvoiceNumber formats a legal invoice number, e.g. INV-2025-000042 or INV-EAST-2025-000042
+func FormatInvoiceNumber(prefix, location string, fiscalYear int, n int64) string {
+	if location != "" {
+		prefix += "-" + location
+	}
+	return FormatDocumentNumber(prefix, fiscalYear, n)
+}
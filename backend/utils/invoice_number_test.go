@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFiscalYear(t *testing.T) {
+	cases := []struct {
+		date  time.Time
+		start time.Month
+		want  int
+	}{
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.January, 2025},
+		{time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), time.January, 2025},
+		{time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC), time.April, 2025},
+		{time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.April, 2025},
+		{time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), time.April, 2024},
+	}
+	for _, c := range cases {
+		if got := FiscalYear(c.date, c.start); got != c.want {
+			t.Errorf("FiscalYear(%s, %s) = %d, want %d", c.date.Format("2006-01-02"), c.start, got, c.want)
+		}
+	}
+}
+
+func TestFormatInvoiceNumber(t *testing.T) {
+	if got := FormatInvoiceNumber("INV", "", 2025, 7); got != "INV-2025-000007" {
+		t.Errorf("unexpected number %q", got)
+	}
+	if got := FormatInvoiceNumber("INV", "EAST", 2025, 7); got != "INV-EAST-2025-000007" {
+		t.Errorf("unexpected number %q", got)
+	}
+}
+
+func TestNormalizeLocationCode(t *testing.T) {
+	if got, err := NormalizeLocationCode(" east "); err != nil || got != "EAST" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if got, err := NormalizeLocationCode(""); err != nil || got != "" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := NormalizeLocationCode("north wing"); err != ErrInvalidLocationCode {
+		t.Errorf("expected ErrInvalidLocationCode, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+var (
+	ErrInvalidChargeLine = errors.New("quantity must be > 0 and unit price, discount and tax rate must not be negative; discount cannot exceed the line amount")
+	ErrInvalidCurrency   = errors.New("currency must be a three letter ISO 4217 code")
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// DefaultCurrency is the currency of new invoices, drug and purchase prices (CURRENCY, default USD)
+func DefaultCurrency() string {
+	if c := strings.ToUpper(strings.TrimSpace(os.Getenv("CURRENCY"))); currencyCode.MatchString(c) {
+		return c
+	}
+	return "USD"
+}
+
+// NormalizeCurrency upper-cases a currency code, falling back to the default when empty
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency(), nil
+	}
+	if !currencyCode.MatchString(code) {
+		return "", ErrInvalidCurrency
+	}
+	return code, nil
+}
+
+// ChargeTotals is the breakdown of a charge line or a whole invoice.
+// Total = Subtotal - Discount + Tax.
+type ChargeTotals struct {
+	Subtotal models.Money `json:"subtotal"`
+	Discount models.Money `json:"discount"`
+	Tax      models.Money `json:"tax"`
+	Total    models.Money `json:"total"`
+}
+
+// ComputeLine prices quantity units at unitPrice, takes off an absolute discount and adds
+// taxRate percent tax on what is left, rounded to whole cents.
+func ComputeLine(quantity int, unitPrice, discount models.Money, taxRate float64) (ChargeTotals, error) {
+	if quantity <= 0 || unitPrice < 0 || discount < 0 || taxRate < 0 {
+		return ChargeTotals{}, ErrInvalidChargeLine
+	}
+	subtotal := unitPrice.Times(quantity)
+	if discount > subtotal {
+		return ChargeTotals{}, ErrInvalidChargeLine
+	}
+	tax := (subtotal - discount).Percent(taxRate)
+	return ChargeTotals{
+		Subtotal: subtotal,
+		Discount: discount,
+		Tax:      tax,
+		Total:    subtotal - discount + tax,
+	}, nil
+}
+
+// Add sums two breakdowns, e.g. to total the lines of an invoice
+func (t ChargeTotals) Add(o ChargeTotals) ChargeTotals {
+	return ChargeTotals{
+		Subtotal: t.Subtotal + o.Subtotal,
+		Discount: t.Discount + o.Discount,
+		Tax:      t.Tax + o.Tax,
+		Total:    t.Total + o.Total,
+	}
+}
+
+// DeriveInvoiceStatus works out an invoice's payment status from what has been credited and
+// paid (net of refunds). Drafts and void or cancelled invoices keep their status. An invoice
+// settled by credit notes alone is credited. An unsettled invoice past its due date is
+// overdue, even when partially paid, unless it has been handed to collections.
+func DeriveInvoiceStatus(current string, amount, credited, paid models.Money, due, now time.Time) string {
+	switch current {
+	case "draft", "void", "cancelled":
+		return current
+	}
+	owed := amount - credited
+	switch {
+	case amount > 0 && paid >= owed && paid <= 0:
+		return "credited"
+	case amount > 0 && paid >= owed:
+		return "paid"
+	case current == "collections":
+		return current
+	case !due.IsZero() && now.After(due) && paid < owed:
+		return "overdue"
+	case paid > 0:
+		return "partially_paid"
+	default:
+		return "unpaid"
+	}
+}
+
+// FormatDocumentNumber formats a number of a yearly document series, e.g. CN-2025-000042
+func FormatDocumentNumber(prefix string, year int, n int64) string {
+	return fmt.Sprintf("%s-%d-%06d", prefix, year, n)
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+func TestComputeLine(t *testing.T) {
+	got, err := ComputeLine(3, 1999, 500, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := ChargeTotals{Subtotal: 5997, Discount: 500, Tax: 550, Total: 6047}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	for _, c := range []struct {
+		qty             int
+		price, discount int64
+		tax             float64
+	}{
+		{0, 1000, 0, 0},
+		{1, -100, 0, 0},
+		{1, 1000, 1100, 0},
+		{1, 1000, 0, -5},
+	} {
+		if _, err := ComputeLine(c.qty, models.Money(c.price), models.Money(c.discount), c.tax); err != ErrInvalidChargeLine {
+			t.Errorf("ComputeLine(%+v): expected ErrInvalidChargeLine, got %v", c, err)
+		}
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	t.Setenv("CURRENCY", "eur")
+	for in, want := range map[string]string{"": "EUR", "usd": "USD", " GBP ": "GBP"} {
+		if got, err := NormalizeCurrency(in); err != nil || got != want {
+			t.Errorf("NormalizeCurrency(%q): expected %q, got %q (%v)", in, want, got, err)
+		}
+	}
+	if _, err := NormalizeCurrency("EURO"); err != ErrInvalidCurrency {
+		t.Errorf("expected ErrInvalidCurrency, got %v", err)
+	}
+}
+
+func TestDeriveInvoiceStatus(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	future, past := now.AddDate(0, 0, 7), now.AddDate(0, 0, -1)
+	cases := []struct {
+		current                string
+		amount, credited, paid models.Money
+		due                    time.Time
+		want                   string
+	}{
+		{"unpaid", 10000, 0, 0, future, "unpaid"},
+		{"unpaid", 10000, 0, 2500, future, "partially_paid"},
+		{"partially_paid", 10000, 0, 10000, past, "paid"},
+		{"partially_paid", 10000, 0, 2500, past, "overdue"},
+		{"paid", 10000, 0, 0, future, "unpaid"},
+		{"unpaid", 10000, 2500, 7500, past, "paid"},
+		{"unpaid", 10000, 10000, 0, past, "credited"},
+		{"paid", 10000, 0, 5000, future, "partially_paid"}, // half refunded
+		{"void", 10000, 0, 0, past, "void"},
+		{"collections", 10000, 0, 2500, past, "collections"},
+		{"collections", 10000, 0, 10000, past, "paid"},
+		{"unpaid", 0, 0, 0, past, "unpaid"},
+	}
+	for _, c := range cases {
+		if got := DeriveInvoiceStatus(c.current, c.amount, c.credited, c.paid, c.due, now); got != c.want {
+			t.Errorf("DeriveInvoiceStatus(%+v): expected %q, got %q", c, c.want, got)
+		}
+	}
+}
+
+func TestFormatDocumentNumber(t *testing.T) {
+	if got := FormatDocumentNumber("CN", 2025, 42); got != "CN-2025-000042" {
+		t.Errorf("unexpected number %q", got)
+	}
+}
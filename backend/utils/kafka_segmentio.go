@@ -81,13 +81,17 @@ func PublishToTopic(ctx context.Context, topic, key string, value interface{}) e
 
 // PublishAppointmentEvent helper
 func PublishAppointmentEvent(ctx context.Context, eventTopic string, data interface{}) error {
-	
+	return PublishEvent(ctx, eventTopic, eventTopic, data)
+}
+
+// PublishEvent wraps data in the standard event envelope and publishes it
+func PublishEvent(ctx context.Context, eventTopic, key string, data interface{}) error {
 	payload := map[string]interface{}{
 		"event":     eventTopic,
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 		"data":      data,
 	}
-	return PublishToTopic(ctx, eventTopic, eventTopic, payload)
+	return PublishToTopic(ctx, eventTopic, key, payload)
 }
 
 // StartAppointmentConsumers starts a simple consumer 
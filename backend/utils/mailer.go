@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNoRecipient = errors.New("no email address to send to")
+
+// Mailer sends plain text emails
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends through the SMTP server given by SMTP_HOST, SMTP_PORT (default 587),
+// SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// LogMailer only logs emails; it is used when no SMTP server is configured
+type LogMailer struct{}
+
+// NewMailer returns an SMTP mailer when SMTP_HOST is set and a LogMailer otherwise
+func NewMailer() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return LogMailer{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	m := SMTPMailer{Addr: host + ":" + port, From: os.Getenv("SMTP_FROM")}
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		m.Auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	if m.From == "" {
+		m.From = os.Getenv("HOSPITAL_EMAIL")
+	}
+	return m
+}
+
+func (m SMTPMailer) Send(to, subject, body string) error {
+	if to == "" {
+		return ErrNoRecipient
+	}
+	// header values must not smuggle extra headers in
+	clean := strings.NewReplacer("\r", "", "\n", "")
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		clean.Replace(m.From), clean.Replace(to), clean.Replace(subject), time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, []byte(msg))
+}
+
+func (LogMailer) Send(to, subject, body string) error {
+	if to == "" {
+		return ErrNoRecipient
+	}
+	log.Printf("[mail] to=%s subject=%q (SMTP_HOST not set, not sent)", to, subject)
+	return nil
+}
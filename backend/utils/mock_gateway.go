@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+// MockGateway is an in-memory payment gateway for development and tests. Its checkouts are
+// completed with SimulateEvent, which produces the signed webhook a real gateway would send.
+type MockGateway struct {
+	secret   string
+	mu       sync.Mutex
+	intents  map[string]*GatewayIntent
+	refunded map[string]models.Money
+}
+
+// NewMockGateway returns a mock gateway signing its webhooks with secret
+func NewMockGateway(secret string) *MockGateway {
+	return &MockGateway{secret: secret, intents: map[string]*GatewayIntent{}, refunded: map[string]models.Money{}}
+}
+
+func mockID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+func (g *MockGateway) Name() string {
+	return "mock"
+}
+
+func (g *MockGateway) CreateIntent(ctx context.Context, req GatewayIntentRequest) (GatewayIntent, error) {
+	id := mockID("mock_pi_")
+	intent := GatewayIntent{
+		ExternalID:  id,
+		Status:      "pending",
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		CheckoutURL: "https://mock-gateway.invalid/checkout/" + id,
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.intents[id] = &intent
+	return intent, nil
+}
+
+func (g *MockGateway) Capture(ctx context.Context, externalID string, amount models.Money) (GatewayIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	intent, ok := g.intents[externalID]
+	if !ok {
+		return GatewayIntent{}, ErrUnknownIntent
+	}
+	if intent.Status == "succeeded" && amount == intent.Amount {
+		return *intent, nil
+	}
+	if intent.Status != "authorized" || amount <= 0 || amount > intent.Amount {
+		return *intent, ErrIntentNotCapturable
+	}
+	intent.Amount = amount
+	intent.Status = "succeeded"
+	return *intent, nil
+}
+
+func (g *MockGateway) Refund(ctx context.Context, externalID string, amount models.Money) (GatewayRefund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	intent, ok := g.intents[externalID]
+	if !ok {
+		return GatewayRefund{}, ErrUnknownIntent
+	}
+	if intent.Status != "succeeded" || amount <= 0 || g.refunded[externalID]+amount > intent.Amount {
+		return GatewayRefund{}, ErrRefundNotAllowed
+	}
+	g.refunded[externalID] += amount
+	return GatewayRefund{ExternalID: mockID("mock_re_"), Amount: amount}, nil
+}
+
+func (g *MockGateway) ParseWebhook(payload []byte, header http.Header, now time.Time) (WebhookEvent, error) {
+	var ev WebhookEvent
+	if err := VerifyWebhookSignature(g.secret, header.Get(WebhookSignatureHeader), payload, now); err != nil {
+		return ev, err
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil || ev.ID == "" || ev.Type == "" {
+		return ev, ErrInvalidSignature
+	}
+	return ev, nil
+}
+
+// SimulateEvent plays the customer or card network: it moves a mock intent to the state the
+// event type implies and returns the signed webhook body and headers for it
+func (g *MockGateway) SimulateEvent(eventType, externalID, reason string) ([]byte, http.Header, error) {
+	g.mu.Lock()
+	intent, ok := g.intents[externalID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, nil, ErrUnknownIntent
+	}
+	switch eventType {
+	case EventPaymentAuthorized:
+		intent.Status = "authorized"
+	case EventPaymentSucceeded, EventDisputeWon:
+		intent.Status = "succeeded"
+	case EventPaymentFailed:
+		intent.Status = "failed"
+	case EventDisputeCreated, EventDisputeLost:
+		intent.Status = "disputed"
+	}
+	ev := WebhookEvent{
+		ID:       mockID("mock_evt_"),
+		Type:     eventType,
+		IntentID: externalID,
+		Amount:   intent.Amount,
+		Currency: intent.Currency,
+		Reason:   reason,
+	}
+	g.mu.Unlock()
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(WebhookSignatureHeader, SignWebhook(g.secret, time.Now(), payload))
+	return payload, header, nil
+}
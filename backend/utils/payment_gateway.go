@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samichen99/HAP-hospital-management-system/models"
+)
+
+var (
+	ErrUnknownGateway       = errors.New("unknown payment gateway")
+	ErrWebhookSecretMissing = errors.New("PAYMENT_WEBHOOK_SECRET is not set")
+	ErrInvalidSignature     = errors.New("invalid webhook signature")
+	ErrStaleWebhook         = errors.New("webhook timestamp is too old")
+	ErrUnknownIntent        = errors.New("payment intent not found at the gateway")
+	ErrIntentNotCapturable  = errors.New("payment intent cannot be captured")
+	ErrRefundNotAllowed     = errors.New("payment intent cannot be refunded by this amount")
+)
+
+// Webhook event types understood by the webhook endpoint
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentSucceeded  = "payment.succeeded"
+	EventPaymentFailed     = "payment.failed"
+	EventDisputeCreated    = "dispute.created"
+	EventDisputeWon        = "dispute.won"
+	EventDisputeLost       = "dispute.lost"
+)
+
+// WebhookSignatureHeader carries "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">"
+const WebhookSignatureHeader = "X-Gateway-Signature"
+
+// webhookTolerance is how old a signed webhook may be before it is rejected as a replay
+const webhookTolerance = 5 * time.Minute
+
+// GatewayIntentRequest asks a gateway for a checkout of an invoice balance
+type GatewayIntentRequest struct {
+	Reference       string
+	Description     string
+	Amount          models.Money
+	Currency        string
+	CaptureManually bool
+}
+
+// GatewayIntent is a payment intent as the gateway sees it
+type GatewayIntent struct {
+	ExternalID  string       `json:"id"`
+	Status      string       `json:"status"` // pending, authorized, succeeded, failed
+	Amount      models.Money `json:"amount"`
+	Currency    string       `json:"currency"`
+	CheckoutURL string       `json:"checkout_url"`
+}
+
+// GatewayRefund is a refund made at the gateway
+type GatewayRefund struct {
+	ExternalID string       `json:"id"`
+	Amount     models.Money `json:"amount"`
+}
+
+// WebhookEvent is a verified webhook notification from a gateway
+type WebhookEvent struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	IntentID string       `json:"intent_id"`
+	Amount   models.Money `json:"amount"`
+	Currency string       `json:"currency"`
+	Reason   string       `json:"reason,omitempty"` // failure or dispute reason
+}
+
+// PaymentGateway takes card payments online. Implementations must be safe for concurrent use.
+type PaymentGateway interface {
+	Name() string
+	CreateIntent(ctx context.Context, req GatewayIntentRequest) (GatewayIntent, error)
+	// Capture must succeed again for an intent already captured with the same amount, so that
+	// a capture that was not recorded can be retried
+	Capture(ctx context.Context, externalID string, amount models.Money) (GatewayIntent, error)
+	Refund(ctx context.Context, externalID string, amount models.Money) (GatewayRefund, error)
+	// ParseWebhook verifies the signature of a webhook request and decodes its event
+	ParseWebhook(payload []byte, header http.Header, now time.Time) (WebhookEvent, error)
+}
+
+// NewPaymentGateway returns the gateway named by PAYMENT_GATEWAY (default "mock", the only
+// one built in). Webhooks are signed with PAYMENT_WEBHOOK_SECRET, which is required.
+func NewPaymentGateway() (PaymentGateway, error) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, ErrWebhookSecretMissing
+	}
+	switch strings.ToLower(os.Getenv("PAYMENT_GATEWAY")) {
+	case "", "mock":
+		return NewMockGateway(secret), nil
+	default:
+		return nil, ErrUnknownGateway
+	}
+}
+
+// SignWebhook computes the signature header value of a webhook body sent at t
+func SignWebhook(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(payload)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a signature header made by SignWebhook and rejects
+// webhooks signed more than five minutes away from now
+func VerifyWebhookSignature(secret, header string, payload []byte, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	signedAt := time.Unix(unix, 0)
+	expected := SignWebhook(secret, signedAt, payload)
+	if !hmac.Equal([]byte(expected), []byte("t="+ts+",v1="+sig)) {
+		return ErrInvalidSignature
+	}
+	if now.Sub(signedAt) > webhookTolerance || signedAt.Sub(now) > webhookTolerance {
+		return ErrStaleWebhook
+	}
+	return nil
+}
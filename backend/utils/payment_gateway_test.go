@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1740000000, 0)
+	body := []byte(`{"id":"evt_1","type":"payment.succeeded"}`)
+	header := SignWebhook("secret", now, body)
+
+	if err := VerifyWebhookSignature("secret", header, body, now.Add(time.Minute)); err != nil {
+		t.Fatalf("expected a valid signature, got %v", err)
+	}
+	if err := VerifyWebhookSignature("other", header, body, now); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature for the wrong secret, got %v", err)
+	}
+	if err := VerifyWebhookSignature("secret", header, []byte(`{"id":"evt_2"}`), now); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature for a changed body, got %v", err)
+	}
+	if err := VerifyWebhookSignature("secret", header, body, now.Add(10*time.Minute)); err != ErrStaleWebhook {
+		t.Errorf("expected ErrStaleWebhook for a replayed webhook, got %v", err)
+	}
+	if err := VerifyWebhookSignature("secret", "v1=abc", body, now); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature without a timestamp, got %v", err)
+	}
+}
+
+func TestMockGatewayLifecycle(t *testing.T) {
+	ctx := context.Background()
+	g := NewMockGateway("secret")
+	intent, err := g.CreateIntent(ctx, GatewayIntentRequest{Amount: 5000, Currency: "USD"})
+	if err != nil || intent.Status != "pending" || intent.CheckoutURL == "" {
+		t.Fatalf("unexpected intent %+v, %v", intent, err)
+	}
+	if _, err := g.Capture(ctx, intent.ExternalID, 5000); err != ErrIntentNotCapturable {
+		t.Errorf("expected pending intents not to be capturable, got %v", err)
+	}
+
+	payload, header, err := g.SimulateEvent(EventPaymentAuthorized, intent.ExternalID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := g.ParseWebhook(payload, header, time.Now())
+	if err != nil || ev.Type != EventPaymentAuthorized || ev.IntentID != intent.ExternalID || ev.Amount != 5000 {
+		t.Fatalf("unexpected event %+v, %v", ev, err)
+	}
+	if _, err := g.ParseWebhook(payload, http.Header{}, time.Now()); err != ErrInvalidSignature {
+		t.Errorf("expected unsigned webhooks to be rejected, got %v", err)
+	}
+
+	if captured, err := g.Capture(ctx, intent.ExternalID, 4000); err != nil || captured.Status != "succeeded" || captured.Amount != 4000 {
+		t.Fatalf("unexpected capture %+v, %v", captured, err)
+	}
+	if retried, err := g.Capture(ctx, intent.ExternalID, 4000); err != nil || retried.Amount != 4000 {
+		t.Errorf("expected a retried capture to succeed again, got %+v, %v", retried, err)
+	}
+	if _, err := g.Capture(ctx, intent.ExternalID, 5000); err != ErrIntentNotCapturable {
+		t.Errorf("expected a second capture of another amount to fail, got %v", err)
+	}
+	if _, err := g.Refund(ctx, intent.ExternalID, 3000); err != nil {
+		t.Errorf("expected a partial refund, got %v", err)
+	}
+	if _, err := g.Refund(ctx, intent.ExternalID, 1500); err != ErrRefundNotAllowed {
+		t.Errorf("expected refunds above the captured amount to fail, got %v", err)
+	}
+	if _, err := g.Refund(ctx, "mock_pi_unknown", 100); err != ErrUnknownIntent {
+		t.Errorf("expected ErrUnknownIntent, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Letterhead is printed at the top of every generated document
+type Letterhead struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+}
+
+// DefaultLetterhead reads the hospital letterhead from the environment
+func DefaultLetterhead() Letterhead {
+	l := Letterhead{
+		Name:    os.Getenv("HOSPITAL_NAME"),
+		Address: os.Getenv("HOSPITAL_ADDRESS"),
+		Phone:   os.Getenv("HOSPITAL_PHONE"),
+		Email:   os.Getenv("HOSPITAL_EMAIL"),
+	}
+	if l.Name == "" {
+		l.Name = "Hospital Management System"
+	}
+	return l
+}
+
+// PDFDocument is a small wrapper around fpdf with the layout helpers our documents need
+type PDFDocument struct {
+	pdf *fpdf.Fpdf
+	tr  func(string) string
+}
+
+// NewPDFDocument starts an A4 document with the letterhead and a title
+func NewPDFDocument(letterhead Letterhead, title string) *PDFDocument {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.SetCreationDate(time.Now())
+	pdf.SetTitle(title, true)
+
+	doc := &PDFDocument{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, doc.tr(letterhead.Name), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 10, "Page "+strconv.Itoa(pdf.PageNo())+" / {nb}", "", 0, "R", false, 0, "")
+	})
+	pdf.AliasNbPages("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, doc.tr(letterhead.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range []string{letterhead.Address, letterhead.Phone, letterhead.Email} {
+		if line != "" {
+			pdf.CellFormat(0, 4.5, doc.tr(line), "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.Ln(2)
+	x, y := pdf.GetXY()
+	pdf.Line(x, y, 195, y)
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, doc.tr(title), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+	return doc
+}
+
+// Heading writes a section heading
+func (d *PDFDocument) Heading(text string) {
+	d.pdf.Ln(3)
+	d.pdf.SetFont("Helvetica", "B", 11)
+	d.pdf.CellFormat(0, 7, d.tr(text), "B", 1, "L", false, 0, "")
+	d.pdf.Ln(1)
+}
+
+// Paragraph writes wrapped body text
+func (d *PDFDocument) Paragraph(text string) {
+	if text == "" {
+		text = "-"
+	}
+	d.pdf.SetFont("Helvetica", "", 10)
+	d.pdf.MultiCell(0, 5, d.tr(text), "", "L", false)
+}
+
+// KeyValue writes a label and a value on one line
+func (d *PDFDocument) KeyValue(label, value string) {
+	d.pdf.SetFont("Helvetica", "B", 10)
+	d.pdf.CellFormat(45, 5.5, d.tr(label), "", 0, "L", false, 0, "")
+	d.pdf.SetFont("Helvetica", "", 10)
+	d.pdf.MultiCell(0, 5.5, d.tr(value), "", "L", false)
+}
+
+// Table writes a simple bordered table. align holds one fpdf alignment per column ("L", "R", "C").
+func (d *PDFDocument) Table(headers []string, widths []float64, align []string, rows [][]string) {
+	d.pdf.SetFont("Helvetica", "B", 9)
+	d.pdf.SetFillColor(235, 235, 235)
+	for i, h := range headers {
+		d.pdf.CellFormat(widths[i], 6, d.tr(h), "1", 0, "C", true, 0, "")
+	}
+	d.pdf.Ln(-1)
+
+	d.pdf.SetFont("Helvetica", "", 9)
+	for _, row := range rows {
+		for i, cell := range row {
+			a := "L"
+			if i < len(align) {
+				a = align[i]
+			}
+			d.pdf.CellFormat(widths[i], 6, d.tr(cell), "1", 0, a, false, 0, "")
+		}
+		d.pdf.Ln(-1)
+	}
+}
+
+// TotalLine writes a right aligned label/amount pair, used under invoice tables
+func (d *PDFDocument) TotalLine(label, value string, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	d.pdf.SetFont("Helvetica", style, 10)
+	d.pdf.CellFormat(140, 6, d.tr(label), "", 0, "R", false, 0, "")
+	d.pdf.CellFormat(40, 6, d.tr(value), "", 1, "R", false, 0, "")
+}
+
+// Image embeds a PNG or JPEG image, e.g. a drawn signature
+func (d *PDFDocument) Image(name string, data []byte, imageType string, width float64) {
+	opts := fpdf.ImageOptions{ImageType: imageType, ReadDpi: true}
+	d.pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(data))
+	d.pdf.ImageOptions(name, d.pdf.GetX(), d.pdf.GetY(), width, 0, true, opts, 0, "")
+}
+
+// Space adds vertical whitespace
+func (d *PDFDocument) Space(mm float64) {
+	d.pdf.Ln(mm)
+}
+
+// Bytes renders the document
+func (d *PDFDocument) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
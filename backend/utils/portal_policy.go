@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Errors returned by the self-service booking policy
+var (
+	ErrBookingTooSoon        = errors.New("appointments must be booked further in advance")
+	ErrBookingTooFar         = errors.New("appointments cannot be booked that far ahead")
+	ErrTooManyAppointments   = errors.New("too many upcoming appointments")
+	ErrCancellationTooLate   = errors.New("appointment can no longer be cancelled online, please call the hospital")
+	ErrAppointmentNotBooking = errors.New("only scheduled appointments can be cancelled")
+)
+
+// BookingPolicy limits what patients can book and cancel through the portal
+type BookingPolicy struct {
+	MinNoticeHours      int `json:"min_notice_hours"`
+	MaxDaysAhead        int `json:"max_days_ahead"`
+	CancelNoticeHours   int `json:"cancel_notice_hours"`
+	MaxOpenAppointments int `json:"max_open_appointments"`
+	DurationMinutes     int `json:"duration_minutes"`
+}
+
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+// LoadBookingPolicy reads the portal policy from PORTAL_* environment variables
+func LoadBookingPolicy() BookingPolicy {
+	return BookingPolicy{
+		MinNoticeHours:      envInt("PORTAL_MIN_BOOKING_NOTICE_HOURS", 2),
+		MaxDaysAhead:        envInt("PORTAL_MAX_BOOKING_DAYS", 90),
+		CancelNoticeHours:   envInt("PORTAL_CANCEL_NOTICE_HOURS", 24),
+		MaxOpenAppointments: envInt("PORTAL_MAX_OPEN_APPOINTMENTS", 3),
+		DurationMinutes:     envInt("PORTAL_APPOINTMENT_MINUTES", 30),
+	}
+}
+
+// CheckBooking validates a requested appointment time given the patient's upcoming appointments
+func (p BookingPolicy) CheckBooking(at, now time.Time, openAppointments int) error {
+	if at.Before(now.Add(time.Duration(p.MinNoticeHours) * time.Hour)) {
+		return ErrBookingTooSoon
+	}
+	if at.After(now.AddDate(0, 0, p.MaxDaysAhead)) {
+		return ErrBookingTooFar
+	}
+	if openAppointments >= p.MaxOpenAppointments {
+		return ErrTooManyAppointments
+	}
+	return nil
+}
+
+// CheckCancellation validates that a scheduled appointment may still be cancelled online
+func (p BookingPolicy) CheckCancellation(status string, at, now time.Time) error {
+	if status != "scheduled" {
+		return ErrAppointmentNotBooking
+	}
+	if at.Before(now.Add(time.Duration(p.CancelNoticeHours) * time.Hour)) {
+		return ErrCancellationTooLate
+	}
+	return nil
+}
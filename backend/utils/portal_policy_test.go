@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBookingPolicy(t *testing.T) {
+	p := BookingPolicy{MinNoticeHours: 2, MaxDaysAhead: 30, CancelNoticeHours: 24, MaxOpenAppointments: 2}
+	now := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	bookings := []struct {
+		name string
+		at   time.Time
+		open int
+		want error
+	}{
+		{"within notice period", now.Add(time.Hour), 0, ErrBookingTooSoon},
+		{"too far ahead", now.AddDate(0, 0, 31), 0, ErrBookingTooFar},
+		{"limit reached", now.AddDate(0, 0, 3), 2, ErrTooManyAppointments},
+		{"allowed", now.AddDate(0, 0, 3), 1, nil},
+	}
+	for _, c := range bookings {
+		if got := p.CheckBooking(c.at, now, c.open); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+
+	if err := p.CheckCancellation("scheduled", now.Add(48*time.Hour), now); err != nil {
+		t.Errorf("expected cancellation two days ahead to be allowed, got %v", err)
+	}
+	if err := p.CheckCancellation("scheduled", now.Add(12*time.Hour), now); err != ErrCancellationTooLate {
+		t.Errorf("expected ErrCancellationTooLate, got %v", err)
+	}
+	if err := p.CheckCancellation("completed", now.Add(48*time.Hour), now); err != ErrAppointmentNotBooking {
+		t.Errorf("expected ErrAppointmentNotBooking, got %v", err)
+	}
+}
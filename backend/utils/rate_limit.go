@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter allows at most Max attempts per key within a sliding Window. It is kept in
+// memory, so limits apply per server instance. It is safe for concurrent use.
+type AttemptLimiter struct {
+	Max    int
+	Window time.Duration
+
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+}
+
+// NewAttemptLimiter returns a limiter allowing max attempts per key within window
+func NewAttemptLimiter(max int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{Max: max, Window: window, attempts: map[string][]time.Time{}}
+}
+
+// recent drops the attempts of key that fell out of the window
+func (l *AttemptLimiter) recent(key string, now time.Time) []time.Time {
+	kept := l.attempts[key][:0]
+	for _, t := range l.attempts[key] {
+		if now.Sub(t) < l.Window {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.attempts, key)
+		return nil
+	}
+	l.attempts[key] = kept
+	return kept
+}
+
+// Allow records an attempt for every key and reports whether all of them were still within
+// the limit. Nothing is recorded when one of the keys is already exhausted.
+func (l *AttemptLimiter) Allow(now time.Time, keys ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.attempts) > 10000 {
+		for key := range l.attempts {
+			l.recent(key, now)
+		}
+	}
+	for _, key := range keys {
+		if len(l.recent(key, now)) >= l.Max {
+			return false
+		}
+	}
+	for _, key := range keys {
+		l.attempts[key] = append(l.attempts[key], now)
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	l := NewAttemptLimiter(2, time.Minute)
+	now := time.Unix(1740000000, 0)
+	if !l.Allow(now, "ip:1", "mrn:A") || !l.Allow(now, "ip:1", "mrn:B") {
+		t.Fatal("expected the first two attempts from an address to pass")
+	}
+	if l.Allow(now, "ip:1", "mrn:C") {
+		t.Error("expected the third attempt from the same address to be refused")
+	}
+	if !l.Allow(now, "ip:2", "mrn:A") {
+		t.Error("expected a second attempt for the MRN from another address to pass")
+	}
+	if l.Allow(now, "ip:3", "mrn:A") {
+		t.Error("expected a third attempt for the same MRN to be refused")
+	}
+	if !l.Allow(now.Add(time.Minute), "ip:1", "mrn:A") {
+		t.Error("expected attempts to be allowed again once the window has passed")
+	}
+}
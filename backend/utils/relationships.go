@@ -0,0 +1,44 @@
+package utils
+
+import "time"
+
+// AgeOfMajority is the age from which patients are billed and consent for themselves
+const AgeOfMajority = 18
+
+// AgeInYears returns the completed years between dob and now
+func AgeInYears(dob, now time.Time) int {
+	years := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		years--
+	}
+	return years
+}
+
+// IsMinor reports whether a patient with the stored date of birth is under the age of
+// majority. Unparseable dates are treated as adults.
+func IsMinor(dob string, now time.Time) bool {
+	t, err := ParseDateOfBirth(dob)
+	if err != nil {
+		return false
+	}
+	return AgeInYears(t, now) < AgeOfMajority
+}
+
+// reciprocalRelationships maps a family link to the link seen from the other patient
+var reciprocalRelationships = map[string]string{
+	"parent":      "child",
+	"child":       "parent",
+	"spouse":      "spouse",
+	"partner":     "partner",
+	"sibling":     "sibling",
+	"grandparent": "grandchild",
+	"grandchild":  "grandparent",
+	"guardian":    "ward",
+	"ward":        "guardian",
+}
+
+// ReciprocalRelationship returns the inverse of a family relationship and whether it is supported
+func ReciprocalRelationship(relationship string) (string, bool) {
+	r, ok := reciprocalRelationships[relationship]
+	return r, ok
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsMinor(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		dob  string
+		want bool
+	}{
+		{"2007-06-16", true},  // turns 18 tomorrow
+		{"2007-06-15", false}, // 18th birthday today
+		{"2015-01-01", true},
+		{"1980-01-01", false},
+		{"unknown", false},
+	}
+	for _, c := range cases {
+		if got := IsMinor(c.dob, now); got != c.want {
+			t.Errorf("IsMinor(%q): expected %v, got %v", c.dob, c.want, got)
+		}
+	}
+}
+
+func TestReciprocalRelationship(t *testing.T) {
+	for rel, want := range map[string]string{"parent": "child", "child": "parent", "spouse": "spouse", "guardian": "ward"} {
+		if got, ok := ReciprocalRelationship(rel); !ok || got != want {
+			t.Errorf("ReciprocalRelationship(%q): expected %q, got %q", rel, want, got)
+		}
+	}
+	if _, ok := ReciprocalRelationship("neighbour"); ok {
+		t.Error("expected unsupported relationship to be rejected")
+	}
+}
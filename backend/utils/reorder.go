@@ -0,0 +1,33 @@
+package utils
+
+import "math"
+
+// SuggestReorderQuantity returns how many units of an item to order for a location, or 0.
+//
+// The reorder point is the larger of the configured reorder level and the expected usage
+// during the supplier lead time. Once stock on hand plus stock on order drops to the reorder
+// point, enough is suggested to get back above it and cover another coverDays of usage,
+// rounded up to a multiple of reorderQty (the pack/order size) when one is set.
+func SuggestReorderQuantity(onHand, onOrder, reorderLevel, reorderQty int, dailyUsage float64, leadTimeDays, coverDays int) int {
+	if reorderLevel <= 0 && dailyUsage <= 0 {
+		return 0
+	}
+	available := onHand + onOrder
+	reorderPoint := reorderLevel
+	if leadUsage := int(math.Ceil(dailyUsage * float64(leadTimeDays))); leadUsage > reorderPoint {
+		reorderPoint = leadUsage
+	}
+	if available > reorderPoint {
+		return 0
+	}
+
+	target := reorderPoint + int(math.Ceil(dailyUsage*float64(coverDays)))
+	need := target - available
+	if need < 1 {
+		need = 1
+	}
+	if reorderQty > 0 {
+		need = (need + reorderQty - 1) / reorderQty * reorderQty
+	}
+	return need
+}
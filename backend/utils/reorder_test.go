@@ -0,0 +1,26 @@
+package utils
+
+import "testing"
+
+func TestSuggestReorderQuantity(t *testing.T) {
+	cases := []struct {
+		name                             string
+		onHand, onOrder, level, packSize int
+		dailyUsage                       float64
+		leadTime, cover                  int
+		want                             int
+	}{
+		{"above reorder point", 200, 0, 50, 0, 5, 7, 14, 0},
+		{"on order covers it", 20, 100, 50, 0, 5, 7, 14, 0},
+		{"below level without usage", 10, 0, 50, 0, 0, 7, 14, 40},
+		{"lead time usage drives reorder point", 60, 0, 50, 0, 10, 7, 14, 150},
+		{"rounded to pack size", 10, 0, 50, 100, 0, 7, 14, 100},
+		{"nothing configured and no usage", 0, 0, 0, 0, 0, 7, 14, 0},
+	}
+	for _, c := range cases {
+		got := SuggestReorderQuantity(c.onHand, c.onOrder, c.level, c.packSize, c.dailyUsage, c.leadTime, c.cover)
+		if got != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, got)
+		}
+	}
+}
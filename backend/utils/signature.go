@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignatureImage is returned for signature images that are not PNG or JPEG
+var ErrInvalidSignatureImage = errors.New("signature image must be a base64 encoded PNG or JPEG")
+
+// SignaturePayload is everything that is covered by a document's content hash.
+// Changing any field after signing makes verification fail.
+type SignaturePayload struct {
+	DocumentID         int       `json:"document_id"`
+	PatientID          int       `json:"patient_id"`
+	Title              string    `json:"title"`
+	Content            string    `json:"content"`
+	SignerName         string    `json:"signer_name"`
+	SignerRelationship string    `json:"signer_relationship"`
+	WitnessName        string    `json:"witness_name,omitempty"` // omitted when empty so older hashes still verify
+	SignatureType      string    `json:"signature_type"`
+	TypedSignature     string    `json:"typed_signature"`
+	SignatureImage     []byte    `json:"-"`
+	SignedAt           time.Time `json:"-"`
+	SignedByUserID     int       `json:"signed_by_user_id"`
+	SignerIP           string    `json:"signer_ip"`
+}
+
+// Hash returns the hex SHA-256 of the payload. The image is folded in by its own digest and
+// the timestamp in UTC with microsecond precision, which is what Postgres stores.
+func (p SignaturePayload) Hash() string {
+	imageSum := sha256.Sum256(p.SignatureImage)
+	canonical, _ := json.Marshal(struct {
+		SignaturePayload
+		SignatureImage string `json:"signature_image_sha256"`
+		SignedAt       string `json:"signed_at"`
+	}{
+		SignaturePayload: p,
+		SignatureImage:   hex.EncodeToString(imageSum[:]),
+		SignedAt:         p.SignedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashBytes returns the hex SHA-256 of data, used for the stored PDF
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DecodeSignatureImage decodes a base64 image, optionally given as a data URL
+// (data:image/png;base64,...), and returns the bytes with the fpdf image type
+func DecodeSignatureImage(s string) ([]byte, string, error) {
+	if i := strings.Index(s, ","); strings.HasPrefix(s, "data:") && i >= 0 {
+		s = s[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, "", ErrInvalidSignatureImage
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return data, "PNG", nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return data, "JPG", nil
+	}
+	return nil, "", ErrInvalidSignatureImage
+}
+
+// SignatureImageType detects the fpdf image type of stored signature bytes
+func SignatureImageType(data []byte) string {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}) {
+		return "JPG"
+	}
+	return "PNG"
+}
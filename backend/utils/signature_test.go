@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestSignaturePayloadHash(t *testing.T) {
+	signedAt := time.Date(2025, 3, 1, 10, 30, 0, 123456789, time.UTC)
+	p := SignaturePayload{
+		DocumentID:     7,
+		PatientID:      3,
+		Title:          "Consent to treatment",
+		Content:        "I agree to the proposed treatment.",
+		SignerName:     "Jane Doe",
+		SignatureType:  "drawn",
+		SignatureImage: []byte("image"),
+		SignedAt:       signedAt,
+		SignedByUserID: 12,
+		SignerIP:       "10.0.0.5",
+	}
+	want := p.Hash()
+
+	// the timestamp as read back from Postgres (microseconds, local zone) hashes the same
+	reloaded := p
+	reloaded.SignedAt = signedAt.Truncate(time.Microsecond).In(time.FixedZone("CET", 3600))
+	if got := reloaded.Hash(); got != want {
+		t.Errorf("expected reloaded payload to hash to %s, got %s", want, got)
+	}
+
+	tampered := p
+	tampered.Content += " Not."
+	if tampered.Hash() == want {
+		t.Error("expected content change to change the hash")
+	}
+	tampered = p
+	tampered.SignatureImage = []byte("other image")
+	if tampered.Hash() == want {
+		t.Error("expected signature image change to change the hash")
+	}
+	tampered = p
+	tampered.WitnessName = "John Roe"
+	if tampered.Hash() == want {
+		t.Error("expected witness change to change the hash")
+	}
+}
+
+func TestDecodeSignatureImage(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nrest")
+	enc := base64.StdEncoding.EncodeToString(png)
+
+	for _, in := range []string{enc, "data:image/png;base64," + enc} {
+		data, typ, err := DecodeSignatureImage(in)
+		if err != nil || typ != "PNG" || string(data) != string(png) {
+			t.Errorf("DecodeSignatureImage(%.30q) = %q, %v", in, typ, err)
+		}
+	}
+	if _, _, err := DecodeSignatureImage(base64.StdEncoding.EncodeToString([]byte("GIF89a"))); err != ErrInvalidSignatureImage {
+		t.Errorf("expected ErrInvalidSignatureImage for a GIF, got %v", err)
+	}
+	if _, _, err := DecodeSignatureImage("not base64!"); err != ErrInvalidSignatureImage {
+		t.Errorf("expected ErrInvalidSignatureImage for invalid base64, got %v", err)
+	}
+}